@@ -0,0 +1,102 @@
+package intriniorealtime
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// quoddAPIKeyHeader carries the API key of QUODD token requests, which
+// Intrinio's API takes in a header rather than the query.
+const quoddAPIKeyHeader = "X-Authorization-Public-Key"
+
+// NewWithAPIKey is New for an account that authenticates with an API key
+// instead of a username and password, see WithAPIKey.
+func NewWithAPIKey(key string, provider provider, opts ...Option) *Client {
+	return New("", "", provider, append(opts, WithAPIKey(key))...)
+}
+
+// WithAPIKey makes the client authenticate with an Intrinio API key the way
+// its provider expects: in the api_key query parameter of the token
+// request, or for QUODD in a header. It can't be combined with a username
+// or password; Connect then returns ErrConflictingAuth.
+func WithAPIKey(key string) Option {
+	return func(cli *Client) error {
+		if key == "" {
+			return fmt.Errorf("API key must not be empty")
+		}
+		cli.apiKey = key
+		return nil
+	}
+}
+
+// validateAuth checks that the client has one way to authenticate. It runs
+// after all options have been applied.
+func (cli *Client) validateAuth() error {
+	if cli.apiKey != "" && (cli.username != "" || cli.password != "") {
+		return ErrConflictingAuth
+	}
+	return nil
+}
+
+// tokenRequest returns the request for the next token: the one of a
+// Provider of the user's own, or else the one to the auth endpoint with the
+// client's credentials.
+func (cli *Client) tokenRequest() (*http.Request, error) {
+	if cli.feed != nil {
+		return cli.feed.AuthRequest()
+	}
+	url, err := cli.authEndpoint()
+	if err != nil {
+		return nil, err
+	}
+	req, err := cli.makeTokenRequest(url)
+	if err != nil {
+		return nil, redactAuthError(err)
+	}
+	return req, nil
+}
+
+// makeTokenRequest returns the token request to url with the client's
+// credentials.
+func (cli *Client) makeTokenRequest(url string) (*http.Request, error) {
+	if cli.apiKey != "" {
+		return makeAPIKeyRequest(cli.provider, url, cli.apiKey)
+	}
+	return makeAuthRequest(cli.provider, url, cli.username, cli.password)
+}
+
+// makeAPIKeyRequest returns the token request to url that authenticates
+// with key.
+func makeAPIKeyRequest(provider provider, url, key string) (*http.Request, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	if provider == QUODD {
+		req.Header.Set(quoddAPIKeyHeader, key)
+		return req, nil
+	}
+	q := req.URL.Query()
+	q.Set("api_key", key)
+	req.URL.RawQuery = q.Encode()
+	return req, nil
+}
+
+// redactAuthError drops the query, which may hold the API key, from the URL
+// of a failed token request's error, so it can be logged and returned.
+func redactAuthError(err error) error {
+	ue, ok := err.(*url.Error)
+	if !ok {
+		return err
+	}
+	redacted := *ue
+	if u, perr := url.Parse(ue.URL); perr == nil {
+		u.RawQuery = ""
+		redacted.URL = u.String()
+	} else {
+		redacted.URL = ""
+	}
+	return &redacted
+}
@@ -0,0 +1,87 @@
+package intriniorealtime
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestClientAPIKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		provider   provider
+		wantQuery  string
+		wantHeader string
+	}{
+		{name: "IEXはAPIキーをクエリで渡すこと", provider: IEX, wantQuery: "api_key=my+key"},
+		{name: "QUODDはAPIキーをヘッダで渡すこと", provider: QUODD, wantHeader: "my key"},
+		{name: "REALTIMEはAPIキーをクエリで渡すこと", provider: REALTIME, wantQuery: "api_key=my+key"},
+		{name: "OPRAはAPIキーをクエリで渡すこと", provider: OPRA, wantQuery: "api_key=my+key"},
+		{name: "FXCMはAPIキーをクエリで渡すこと", provider: FXCM, wantQuery: "api_key=my+key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+			sut := NewWithAPIKey("my key", tt.provider, WithReconnectPolicy(fastReconnect), WithAuthURL(server.authURL()), WithWebsocketURL(server.soketURL()))
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("Connect() error = %v", err)
+			}
+			defer sut.Disconnect()
+
+			server.mu.Lock()
+			reqs := server.authReqs
+			server.mu.Unlock()
+			if len(reqs) != 1 {
+				t.Fatalf("auth requests = %d, want 1", len(reqs))
+			}
+			req := reqs[0]
+			if req.Method != "GET" || req.URL.Path != "/auth" {
+				t.Errorf("auth request = %s %s, want GET /auth", req.Method, req.URL.Path)
+			}
+			if req.URL.RawQuery != tt.wantQuery {
+				t.Errorf("query = %q, want %q", req.URL.RawQuery, tt.wantQuery)
+			}
+			if got := req.Header.Get(quoddAPIKeyHeader); got != tt.wantHeader {
+				t.Errorf("%s = %q, want %q", quoddAPIKeyHeader, got, tt.wantHeader)
+			}
+			if _, _, ok := req.BasicAuth(); ok {
+				t.Error("basic auth sent, want the API key only")
+			}
+		})
+	}
+}
+
+func TestAPIKeyConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		password string
+	}{
+		{name: "ユーザ名と併用できないこと", username: "user"},
+		{name: "パスワードと併用できないこと", password: "pass"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := New(tt.username, tt.password, IEX, WithAPIKey("my key"))
+			if err := sut.Connect(); !errors.Is(err, ErrConflictingAuth) {
+				sut.Disconnect()
+				t.Errorf("Connect() error = %v, want ErrConflictingAuth", err)
+			}
+		})
+	}
+}
+
+func TestAuthErrorHidesAPIKey(t *testing.T) {
+	for _, sut := range []*Client{
+		NewWithAPIKey("secret-key", IEX),
+		New("secret-key", "", REALTIME),
+	} {
+		sut.authURL = "http://127.0.0.1:1/auth"
+		_, err := sut.requestToken(context.Background())
+		if err == nil || strings.Contains(err.Error(), "secret-key") {
+			t.Errorf("requestToken(%s) error = %v, want one without the key", sut.provider, err)
+		}
+	}
+}
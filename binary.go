@@ -0,0 +1,215 @@
+package intriniorealtime
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The event types of Intrinio's binary equities protocol.
+const (
+	binaryTrade byte = 0
+	binaryAsk   byte = 1
+	binaryBid   byte = 2
+)
+
+// binaryEvent is one event of a binary frame: a trade, or one side of the
+// top of book.
+type binaryEvent struct {
+	trade *Trade
+	side  *QuoteSide
+	bid   bool
+
+	// raw is the event's own bytes within the frame.
+	raw []byte
+}
+
+// symbol returns the symbol the event is about.
+func (e binaryEvent) symbol() string {
+	if e.trade != nil {
+		return e.trade.Symbol
+	}
+	return e.side.Symbol
+}
+
+// binaryParser returns the parser for the binary frames of provider, or nil
+// when it only speaks JSON. IEX and REALTIME are served with Intrinio's
+// binary equities protocol on the newer endpoints; QUODD and DELAYED_SIP have
+// no binary feed.
+func binaryParser(provider provider) func(frame []byte) ([]binaryEvent, error) {
+	if provider == IEX || provider == REALTIME {
+		return parseEquitiesFrame
+	}
+	return nil
+}
+
+// parseEquitiesFrame splits a frame of the binary equities protocol into its
+// events. The first byte is the number of events; each event starts with its
+// type and its length in bytes, the length counting those two bytes too.
+// The events before a malformed one are returned along with the error.
+func parseEquitiesFrame(frame []byte) ([]binaryEvent, error) {
+	if len(frame) == 0 {
+		return nil, errors.New("empty binary frame")
+	}
+	count := int(frame[0])
+	events := make([]binaryEvent, 0, count)
+	offset := 1
+	for i := 0; i < count; i++ {
+		if len(frame) < offset+2 {
+			return events, fmt.Errorf("binary frame ends at event %d of %d", i+1, count)
+		}
+		n := int(frame[offset+1])
+		if n < 3 || len(frame) < offset+n {
+			return events, fmt.Errorf("event %d of %d has length %d, %d bytes left", i+1, count, n, len(frame)-offset)
+		}
+		e, err := parseEquitiesEvent(frame[offset : offset+n])
+		if err != nil {
+			return events, fmt.Errorf("event %d of %d: %v", i+1, count, err)
+		}
+		events = append(events, e)
+		offset += n
+	}
+	if offset != len(frame) {
+		return events, fmt.Errorf("%d bytes after the last of %d events", len(frame)-offset, count)
+	}
+	return events, nil
+}
+
+// parseEquitiesEvent parses one event. After the type, the length and the
+// length of the symbol come the symbol, the source, the market center as a
+// UTF-16 code unit, the price as a float32, the size as a uint32 and the
+// time in nanoseconds since the epoch as a uint64, all little endian. A
+// trade then carries the day's volume as a uint32. Both end with the length
+// of the conditions and the conditions.
+func parseEquitiesEvent(b []byte) (binaryEvent, error) {
+	typ := b[0]
+	if typ != binaryTrade && typ != binaryAsk && typ != binaryBid {
+		return binaryEvent{}, fmt.Errorf("unknown event type %d", typ)
+	}
+	fixed := 20
+	if typ == binaryTrade {
+		fixed = 24
+	}
+	s := int(b[2])
+	if s == 0 || len(b) < 3+s+fixed {
+		return binaryEvent{}, fmt.Errorf("symbol of %d bytes doesn't fit an event of %d", s, len(b))
+	}
+	symbol := string(b[3 : 3+s])
+	if !isASCII(symbol) {
+		return binaryEvent{}, fmt.Errorf("symbol %q is not ASCII", symbol)
+	}
+	p := b[3+s:]
+	var venue Exchange
+	if c := binary.LittleEndian.Uint16(p[1:3]); c != 0 {
+		venue = LookupExchange(string(rune(c)))
+	}
+	price, fixedPrice, err := binaryPrice(math.Float32frombits(binary.LittleEndian.Uint32(p[3:7])))
+	if err != nil {
+		return binaryEvent{}, err
+	}
+	size := int64(binary.LittleEndian.Uint32(p[7:11]))
+	at := binary.LittleEndian.Uint64(p[11:19])
+	if math.MaxInt64 < at {
+		return binaryEvent{}, fmt.Errorf("timestamp %d out of range", at)
+	}
+	raw := RawTime{Value: int64(at), Unit: Nanoseconds}
+	timestamp := raw.Time()
+	conditions := p[19:]
+	if typ == binaryTrade {
+		conditions = p[23:]
+	}
+	if len(conditions)-1 < int(conditions[0]) {
+		return binaryEvent{}, fmt.Errorf("conditions of %d bytes don't fit the event", conditions[0])
+	}
+	e := binaryEvent{raw: b}
+	if typ == binaryTrade {
+		e.trade = &Trade{Symbol: symbol, Price: price, FixedPrice: fixedPrice, Size: size, Timestamp: timestamp, RawTimestamp: raw, Venue: venue,
+			Darkpool: sipDarkpool(venue.Code), Extended: extendedHours(string(conditions[1 : 1+conditions[0]]))}
+		return e, nil
+	}
+	e.side = &QuoteSide{Symbol: symbol, Price: price, FixedPrice: fixedPrice, Size: size, Timestamp: timestamp, RawTimestamp: raw, Venue: venue, Exchange: venue.Code}
+	e.bid = typ == binaryBid
+	return e, nil
+}
+
+// binaryPrice returns the shortest decimal that is f, so 187.37 stays 187.37
+// rather than the float32's 187.3699951171875, and that price rounded to a
+// Price.
+func binaryPrice(f float32) (float64, Price, error) {
+	if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) || f < 0 {
+		return 0, 0, fmt.Errorf("invalid price %v", f)
+	}
+	price, err := strconv.ParseFloat(strconv.FormatFloat(float64(f), 'f', -1, 32), 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	if float64(math.MaxInt64)/priceScale <= price {
+		return 0, 0, fmt.Errorf("price %v out of range", f)
+	}
+	return price, Price(math.Round(price * priceScale)), nil
+}
+
+// extendedHours reports the sale conditions of the consolidated tapes that
+// mark a trade outside regular market hours: T, form T, and U, extended
+// hours sold out of sequence.
+func extendedHours(conditions string) bool {
+	return strings.ContainsAny(conditions, "TU")
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || 0x7e < s[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// binaryFrame passes the events of a binary frame on to the handlers, each
+// as a Message of its own, so they are dispatched by their symbol like any
+// other message. A frame that can't be parsed is reported through OnError
+// as a *MalformedMessageError after the events before the broken one were
+// passed on. Providers without a binary protocol leave binary frames to
+// OnRawMessage.
+func (cli *Client) binaryFrame(frame []byte, receivedAt time.Time) {
+	parse := binaryParser(cli.provider)
+	if parse == nil {
+		return
+	}
+	events, err := parse(frame)
+	for i := range events {
+		e := &events[i]
+		msg := Message{Provider: cli.provider, Type: MessageQuote, Channel: e.symbol(), ReceivedAt: receivedAt, Raw: e.raw, binary: e}
+		if e.trade != nil {
+			msg.Type = MessageTrade
+		}
+		if !cli.selected(msg.Type) {
+			continue
+		}
+		cli.onMessage(msg)
+		cli.dispatch(msg)
+	}
+	if err != nil {
+		cli.onError(&MalformedMessageError{Data: frame, Err: err})
+	}
+}
+
+// handleBinary runs the trade and top-of-book handlers for a binary event.
+// OnQuote and the provider's typed handlers only get JSON messages.
+func (cli *Client) handleBinary(e *binaryEvent) {
+	cli.handlerMu.RLock()
+	onTrade, onBid, onAsk := cli.tradeHandler, cli.bidHandler, cli.askHandler
+	cli.handlerMu.RUnlock()
+	switch {
+	case e.trade != nil && onTrade != nil && cli.darkpoolFilter.passes(*e.trade):
+		cli.runHandler("OnTrade", func() { onTrade(*e.trade) })
+	case e.side != nil && e.bid && onBid != nil:
+		cli.runHandler("OnBid", func() { onBid(*e.side) })
+	case e.side != nil && !e.bid && onAsk != nil:
+		cli.runHandler("OnAsk", func() { onAsk(*e.side) })
+	}
+}
@@ -0,0 +1,220 @@
+package intriniorealtime
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func loadBinary(t testing.TB, name string) []byte {
+	t.Helper()
+	b, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestParseEquitiesFrame(t *testing.T) {
+	at := time.Unix(0, 1760621400123456789).UTC()
+	raw := func(d time.Duration) RawTime { return RawTime{Value: at.Add(d).UnixNano(), Unit: Nanoseconds} }
+	tests := []struct {
+		name       string
+		frame      string
+		wantTrades []Trade
+		wantBids   []QuoteSide
+		wantAsks   []QuoteSide
+	}{
+		{
+			name:  "複数のイベントを分けること",
+			frame: "equities_frame.bin",
+			wantTrades: []Trade{
+				{Symbol: "AAPL", Price: 187.37, FixedPrice: 1873700, Size: 100, Timestamp: at, RawTimestamp: raw(0), Venue: LookupExchange("Q")},
+			},
+			wantBids: []QuoteSide{
+				{Symbol: "AAPL", Price: 187.36, FixedPrice: 1873600, Size: 300, Timestamp: at.Add(time.Microsecond), RawTimestamp: raw(time.Microsecond), Venue: LookupExchange("P"), Exchange: "P"},
+			},
+			wantAsks: []QuoteSide{
+				{Symbol: "MSFT", Price: 415.5, FixedPrice: 4155000, Size: 200, Timestamp: at.Add(2 * time.Microsecond), RawTimestamp: raw(2 * time.Microsecond), Venue: LookupExchange("v"), Exchange: "v"},
+			},
+		},
+		{
+			name:  "時間外の約定を見分けること",
+			frame: "equities_trade_extended.bin",
+			wantTrades: []Trade{
+				{Symbol: "BRK.B", Price: 457.1234, FixedPrice: 4571234, Size: 5, Timestamp: at.Add(3 * time.Microsecond), RawTimestamp: raw(3 * time.Microsecond), Venue: LookupExchange("d"), Darkpool: true, Extended: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame := loadBinary(t, tt.frame)
+			events, err := parseEquitiesFrame(frame)
+			if err != nil {
+				t.Fatalf("parseEquitiesFrame() error = %v", err)
+			}
+			var trades []Trade
+			var bids, asks []QuoteSide
+			n := 1
+			for _, e := range events {
+				n += len(e.raw)
+				switch {
+				case e.trade != nil:
+					trades = append(trades, *e.trade)
+				case e.bid:
+					bids = append(bids, *e.side)
+				default:
+					asks = append(asks, *e.side)
+				}
+			}
+			if n != len(frame) {
+				t.Errorf("events cover %d bytes, want %d", n, len(frame))
+			}
+			if !reflect.DeepEqual(trades, tt.wantTrades) {
+				t.Errorf("trades = %+v, want %+v", trades, tt.wantTrades)
+			}
+			if !reflect.DeepEqual(bids, tt.wantBids) {
+				t.Errorf("bids = %+v, want %+v", bids, tt.wantBids)
+			}
+			if !reflect.DeepEqual(asks, tt.wantAsks) {
+				t.Errorf("asks = %+v, want %+v", asks, tt.wantAsks)
+			}
+		})
+	}
+}
+
+func TestParseEquitiesFrameMalformed(t *testing.T) {
+	valid := loadBinary(t, "equities_frame.bin")
+	with := func(i int, b ...byte) []byte {
+		frame := append([]byte(nil), valid...)
+		copy(frame[i:], b)
+		return frame
+	}
+	tests := []struct {
+		name       string
+		frame      []byte
+		wantEvents int
+	}{
+		{name: "空のフレームはエラーにすること", frame: nil},
+		{name: "途中で切れたフレームは前のイベントだけ返すこと", frame: valid[:len(valid)-5], wantEvents: 2},
+		{name: "イベントの数が多すぎればエラーにすること", frame: with(0, 4), wantEvents: 3},
+		{name: "余ったバイトはエラーにすること", frame: append(append([]byte(nil), valid...), 0), wantEvents: 3},
+		{name: "知らない種類はエラーにすること", frame: with(1, 9)},
+		{name: "短すぎる長さはエラーにすること", frame: with(2, 2)},
+		{name: "銘柄の長さがはみ出せばエラーにすること", frame: with(3, 0xf0)},
+		{name: "空の銘柄はエラーにすること", frame: with(3, 0)},
+		{name: "NaNの価格はエラーにすること", frame: with(11, 0x00, 0x00, 0xc0, 0x7f)},
+		{name: "負の価格はエラーにすること", frame: with(14, 0xc3)},
+		{name: "条件の長さがはみ出せばエラーにすること", frame: with(31, 9)},
+		{name: "2つめのイベントが壊れていれば1つめだけ返すこと", frame: with(33, 9), wantEvents: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := parseEquitiesFrame(tt.frame)
+			if err == nil {
+				t.Fatalf("parseEquitiesFrame() error = nil, want an error")
+			}
+			if len(events) != tt.wantEvents {
+				t.Errorf("parseEquitiesFrame() events = %d, want %d", len(events), tt.wantEvents)
+			}
+		})
+	}
+}
+
+func FuzzParseEquitiesFrame(f *testing.F) {
+	f.Add(loadBinary(f, "equities_frame.bin"))
+	f.Add(loadBinary(f, "equities_trade_extended.bin"))
+	f.Add([]byte{})
+	f.Add([]byte{1, 0, 3})
+	f.Fuzz(func(t *testing.T, frame []byte) {
+		events, err := parseEquitiesFrame(frame)
+		n := 1
+		for _, e := range events {
+			if (e.trade == nil) == (e.side == nil) {
+				t.Fatalf("event %+v is neither a trade nor a side", e)
+			}
+			if e.symbol() == "" {
+				t.Fatalf("event %+v has no symbol", e)
+			}
+			n += len(e.raw)
+		}
+		if err == nil && n != len(frame) {
+			t.Fatalf("events cover %d bytes of %d", n, len(frame))
+		}
+		if 0 < len(events) && len(frame) < n {
+			t.Fatalf("events cover %d bytes of %d", n, len(frame))
+		}
+	})
+}
+
+func TestClientBinaryFrames(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	trades := make(chan Trade, 10)
+	bids := make(chan QuoteSide, 10)
+	asks := make(chan QuoteSide, 10)
+	errs := make(chan error, 10)
+	types := make(chan MessageType, 10)
+	sut := server.newClient(IEX)
+	sut.OnTrade(func(trade Trade) { trades <- trade })
+	sut.OnBid(func(bid QuoteSide) { bids <- bid })
+	sut.OnAsk(func(ask QuoteSide) { asks <- ask })
+	sut.OnError(func(err error) { errs <- err })
+	sut.OnMessage(func(msg Message) {
+		if msg.Payload == nil {
+			types <- msg.Type
+		}
+	})
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	frame := loadBinary(t, "equities_frame.bin")
+	server.broadcastRaw(websocket.BinaryMessage, frame[:len(frame)-5])
+	server.broadcastRaw(websocket.BinaryMessage, frame)
+
+	var malformed *MalformedMessageError
+	if err := receive(t, "OnError()", errs); !errors.As(err, &malformed) {
+		t.Errorf("OnError() error = %v, want a *MalformedMessageError", err)
+	}
+	for i := 0; i < 2; i++ {
+		if trade := receive(t, "OnTrade()", trades); trade.Symbol != "AAPL" || trade.Price != 187.37 {
+			t.Errorf("OnTrade() = %+v, want AAPL at 187.37", trade)
+		}
+		if bid := receive(t, "OnBid()", bids); bid.Symbol != "AAPL" || bid.Price != 187.36 {
+			t.Errorf("OnBid() = %+v, want AAPL at 187.36", bid)
+		}
+	}
+	if ask := receive(t, "OnAsk()", asks); ask.Symbol != "MSFT" || ask.Size != 200 {
+		t.Errorf("OnAsk() = %+v, want 200 MSFT", ask)
+	}
+	var got []MessageType
+	for i := 0; i < 5; i++ {
+		got = append(got, receive(t, "OnMessage()", types))
+	}
+	if want := []MessageType{MessageTrade, MessageQuote, MessageTrade, MessageQuote, MessageQuote}; !reflect.DeepEqual(got, want) {
+		t.Errorf("OnMessage() types = %v, want %v", got, want)
+	}
+	if !sut.Connected() {
+		t.Error("binary frame dropped the connection")
+	}
+}
+
+// receive waits for a value from c, which handler sends.
+func receive[T any](t *testing.T, handler string, c chan T) T {
+	t.Helper()
+	select {
+	case v := <-c:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s was not called", handler)
+		panic("unreachable")
+	}
+}
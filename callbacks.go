@@ -0,0 +1,111 @@
+package intriniorealtime
+
+import "sync"
+
+// callbackQueue runs queued callbacks one after another on a single
+// goroutine, which is started when there is work and exits when the queue is
+// empty. It is what keeps user handlers from running concurrently.
+type callbackQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	entries []callback
+	running bool
+}
+
+// callback is a queued handler call. quote is the received message for
+// OnQuote calls, which the overflow policy may drop.
+type callback struct {
+	f     func()
+	quote *Message
+}
+
+func newCallbackQueue() *callbackQueue {
+	q := &callbackQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push queues f. It never blocks: a handler that causes another callback
+// must not wait on its own goroutine.
+func (q *callbackQueue) push(f func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.add(callback{f: f})
+}
+
+// pushQuote queues the OnQuote call f for msg from the read loop. When limit
+// callbacks or more are waiting the policy decides: OverflowBlock waits for
+// room, so the read loop slows down to the pace of the handlers the way it
+// did when it called them itself; the other policies return the message
+// they dropped instead.
+func (q *callbackQueue) pushQuote(msg *Message, f func(), limit int, policy OverflowPolicy) (dropped *Message) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for limit <= len(q.entries) {
+		if policy == OverflowDropNewest {
+			return msg
+		}
+		if policy == OverflowDropOldest {
+			if i := q.oldestQuote(); 0 <= i {
+				dropped = q.entries[i].quote
+				q.entries = append(q.entries[:i], q.entries[i+1:]...)
+				break
+			}
+		}
+		q.cond.Wait()
+	}
+	q.add(callback{f: f, quote: msg})
+	return dropped
+}
+
+// oldestQuote returns the index of the first queued OnQuote call, or -1.
+func (q *callbackQueue) oldestQuote() int {
+	for i, e := range q.entries {
+		if e.quote != nil {
+			return i
+		}
+	}
+	return -1
+}
+
+func (q *callbackQueue) add(e callback) {
+	q.entries = append(q.entries, e)
+	if !q.running {
+		q.running = true
+		go q.run()
+	}
+}
+
+func (q *callbackQueue) run() {
+	for {
+		q.mu.Lock()
+		if len(q.entries) == 0 {
+			q.running = false
+			q.mu.Unlock()
+			return
+		}
+		e := q.entries[0]
+		q.entries[0] = callback{}
+		q.entries = q.entries[1:]
+		q.cond.Broadcast()
+		q.mu.Unlock()
+		e.f()
+	}
+}
+
+// len returns the number of callbacks waiting to run.
+func (q *callbackQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.entries)
+}
+
+// callHandler runs a user handler on the callback goroutine, or right away
+// with WithConcurrentCallbacks.
+func (cli *Client) callHandler(name string, f func()) {
+	if cli.concurrentCallbacks {
+		cli.runHandler(name, f)
+		return
+	}
+	cli.callbacks.push(func() { cli.runHandler(name, f) })
+}
@@ -0,0 +1,71 @@
+package intriniorealtime
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestClientCallbacksNotConcurrent(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	var inside, overlaps, calls int32
+	enter := func() {
+		if 1 < atomic.AddInt32(&inside, 1) {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(100 * time.Microsecond)
+		atomic.AddInt32(&inside, -1)
+	}
+	reconnected := make(chan struct{}, 10)
+	sut := server.newClient(QUODD)
+	sut.OnQuote(func(map[string]interface{}) { enter() })
+	sut.OnError(func(error) { enter() })
+	sut.OnReconnect(func(error) {
+		enter()
+		reconnected <- struct{}{}
+	})
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				server.broadcast(map[string]interface{}{"event": "quote", "data": map[string]interface{}{"ticker": "AAPL"}})
+				time.Sleep(100 * time.Microsecond)
+			}
+		}
+	}()
+	for i := 0; i < 3; i++ {
+		server.kick(websocket.CloseGoingAway, "restart")
+		select {
+		case <-reconnected:
+		case <-time.After(5 * time.Second):
+			t.Fatal("client did not reconnect")
+		}
+		sut.SetIdle(i%2 == 0)
+	}
+	close(stop)
+	wg.Wait()
+
+	if atomic.LoadInt32(&calls) == 0 {
+		t.Fatal("no handler was called")
+	}
+	if got := atomic.LoadInt32(&overlaps); got != 0 {
+		t.Errorf("handlers overlapped %d times", got)
+	}
+}
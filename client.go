@@ -1,10 +1,18 @@
 package intriniorealtime
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"runtime/debug"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -16,6 +24,24 @@ const (
 
 	cIEXRealtimeTokenURL = "https://realtime.intrinio.com/auth"
 	cIEXWebsocketURL     = "wss://realtime.intrinio.com/socket/websocket"
+
+	cRealtimeTokenURL     = "https://realtime-mx.intrinio.com/auth"
+	cRealtimeWebsocketURL = "wss://realtime-mx.intrinio.com/socket/websocket"
+
+	cDelayedSIPTokenURL     = "https://realtime-delayed-sip.intrinio.com/auth"
+	cDelayedSIPWebsocketURL = "wss://realtime-delayed-sip.intrinio.com/socket/websocket"
+
+	cNasdaqBasicTokenURL     = "https://realtime-nasdaq-basic.intrinio.com/auth"
+	cNasdaqBasicWebsocketURL = "wss://realtime-nasdaq-basic.intrinio.com/socket/websocket"
+
+	cOPRATokenURL     = "https://realtime-options.intrinio.com/auth"
+	cOPRAWebsocketURL = "wss://realtime-options.intrinio.com/socket/websocket"
+
+	cCryptoquoteTokenURL     = "https://crypto.intrinio.com/auth"
+	cCryptoquoteWebsocketURL = "wss://crypto.intrinio.com/socket/websocket"
+
+	cFXCMTokenURL     = "https://fxcm.intrinio.com/auth"
+	cFXCMWebsocketURL = "wss://fxcm.intrinio.com/socket/websocket"
 )
 
 type provider string
@@ -25,318 +51,1445 @@ const (
 	IEX provider = "iex"
 	// QUODD provider
 	QUODD provider = "quodd"
+	// REALTIME provider, Intrinio's multi-exchange feed
+	REALTIME provider = "realtime"
+	// DELAYED_SIP provider, the consolidated feed delayed by 15 minutes
+	DELAYED_SIP provider = "delayed_sip"
+	// NASDAQ_BASIC provider, Nasdaq's last sales and best bid and offer
+	NASDAQ_BASIC provider = "nasdaq_basic"
+	// OPRA provider, the options of every US exchange
+	OPRA provider = "opra"
+	// CRYPTOQUOTE provider, crypto pairs from the exchanges that trade them
+	CRYPTOQUOTE provider = "cryptoquote"
+	// FXCM provider, the prices of currency pairs
+	FXCM provider = "fxcm"
+	// MANUAL provider, a server of the caller's that speaks the messages of
+	// one of the others, see WithManualEndpoint
+	MANUAL provider = "manual"
+	// SIMULATED provider, made-up IEX quotes for the joined symbols, for
+	// development without credentials or outside market hours
+	SIMULATED provider = "simulated"
 )
 
 const (
-	writeWait     = 10 * time.Second
-	readWait      = 30 * time.Second
-	heartbeatWait = 3 * time.Second
+	writeWait       = 10 * time.Second
+	readWait        = 30 * time.Second
+	heartbeatWait   = 3 * time.Second
+	staleWait       = 60 * time.Second
+	authWait        = 30 * time.Second
+	authRequestWait = 10 * time.Second // of a single token request, see WithHTTPClient
+	closeWait       = time.Second
+	sendQueueLen    = 256
 )
 
 // Client Overview
 type Client struct {
+	// droppedMessages is first so it is 64-bit aligned for atomic access.
+	droppedMessages uint64
+
 	DebugMode bool
 
+	logger Logger // of WithLogger
+
 	username string
 	password string
+	apiKey   string
 	provider provider
+	feed     Provider    // a Provider of the user's own, see New
+	replay   io.Reader   // the recorded frames of NewReplay
+	sim      *simulation // the generator of SIMULATED
+
+	transport *transport // WithConn's, its conn guarded by mu
+
+	authURL    string
+	manual     *manualEndpoint
+	soketURLs  []string  // tried in order, starting at endpoint
+	endpoint   int       // index into soketURLs of the last endpoint that worked
+	endpointAt time.Time // when endpoint was last moved off the primary
 
 	token          string
-	ws             *websocket.Conn
-	channels       map[string]bool
-	joinedChannels map[string]bool
+	tokenAt        time.Time
+	tokenTTL       time.Duration
+	tokenMargin    time.Duration
+	tokenRefresher chan struct{} // the done of the running refreshTokenBeforeExpiry
+	staticToken    bool
+	authRetry      ReconnectPolicy
+	rateLimitRetry int
+	authTimeout    time.Duration
+	httpClient     *http.Client // for token requests, see WithHTTPClient
+	dialer         *websocket.Dialer
+	proxy          func(*http.Request) (*url.URL, error) // see WithProxy
+	proxied        bool
+	tlsConfig      *tls.Config     // a clone of WithTLSConfig's
+	headers        http.Header     // of WithHeader, on token requests and handshakes
+	authTransport  *http.Transport // of WithProxy and WithTLSConfig, unless WithHTTPClient was given
+	ws             *websocket.Conn // guarded by mu, see conn and releaseConn
+	channels       map[string]int  // what the user asked for, with join counts; guarded by mu
+	refCounted     bool
+	joinedChannels map[string]bool // what was sent on the live connection
+	subscribed     bool            // joinedChannels is in sync with the live connection
+	refreshMu      sync.Mutex      // held by refreshChannels from diff to enqueue
 
-	quoteHander  func(quote map[string]interface{})
-	errorHandler func(err error)
+	// Joins on the live connection, guarded by mu. IEX answers each one;
+	// pendingJoins maps the topic to the channel until it does.
+	pendingJoins map[string]string
+	confirmed    map[string]bool
 
+	// The phoenix wire format, and the refs of the live connection:
+	// phoenixRef is the last one handed out, atomically, pendingReplies the
+	// messages whose reply is awaited by their ref and joinRefs the ref of
+	// the latest join of every topic, both guarded by mu.
+	phoenixVsn     string
+	phoenixRef     uint64
+	pendingReplies map[string]pendingReply
+	joinRefs       map[string]string
+
+	// handlerMu guards every handler field, so handlers can be registered or
+	// replaced while the client is running.
+	handlerMu           sync.RWMutex
+	quoteHander         func(quote map[string]interface{})
+	errorHandler        func(err error)
+	tokenRefreshHandler func()
+	overflowHandler     func(dropped int, channel string)
+	sendStallHandler    func(d time.Duration, queued int)
+	depthHandler        func(depth Depth)
+	statusHandler       func(status SecurityStatusEvent)
+	iexQuoteHandler     func(quote IEXQuote)
+	quoddQuoteHandler   func(quote QuoddQuoteData)
+	quoddTradeHandler   func(trade QuoddTradeData)
+	tradeHandler        func(trade Trade)
+	rawHandler          func(messageType int, data []byte)
+	joinErrorHandler    func(channel string, reason error)
+	bidHandler          func(bid QuoteSide)
+	askHandler          func(ask QuoteSide)
+	normalizedHandler   func(quote NormalizedQuote)
+	optionTradeHandler  func(trade OptionTrade)
+	optionQuoteHandler  func(quote OptionQuote)
+	cryptoTradeHandler  func(trade CryptoTrade)
+	cryptoBookHandler   func(update CryptoBookUpdate)
+	fxQuoteHandler      func(quote FxQuote)
+	typedHandlers       []typedHandler
+	infoHandler         func(info InfoMessage)
+	gapHandler          func(channel string, from, to time.Time)
+	messageHandler      func(msg Message)
+
+	// book feeds OnBid and OnAsk.
+	book topOfBook
+
+	// gaps holds the last timestamp of every symbol with WithGapTolerance.
+	gaps         gapTracker
+	gapTolerance time.Duration
+
+	reconnectHandler       func(cause error)
+	reconnectFailedHandler func(err error)
+	disconnectHandler      func(cause error)
+	reconnectPolicy        ReconnectPolicy
+	noReconnect            bool
+	authRejects            int
+	backoff                int
+	connectedAt            time.Time
+
+	staleTimeout          time.Duration
+	readDeadline          time.Duration
+	idleReadDeadline      time.Duration
+	idleHeartbeatInterval time.Duration
+	idle                  int32
+	idleHandler           func(idle bool)
+	now                   func() time.Time
+	writeDeadline         time.Duration
+	pingInterval          time.Duration
+	heartbeatInterval     time.Duration
+	maxMissedHeartbeats   int32
+	newTicker             func(d time.Duration) (<-chan time.Time, func())
+	after                 func(d time.Duration) <-chan time.Time
+	missedHeartbeats      int32
+	quoddBeats            [quoddBeatsKept]int64 // see heartbeatMessage, guarded by mu
+	quoddBeat             int
+	lastMessageAt         int64
+	optionErr             error
+	history               *stateHistory
+	dispatchWorkers       int
+	noRecover             bool
+	concurrentCallbacks   bool
+	useNumber             bool
+	decoder               Decoder
+	strict                bool
+	controlToQuote        bool
+	noSymbolValidation    bool
+	dataTypes             DataTypes
+	quoddExchange         string              // see WithQuoddExchange
+	quoddExchanges        map[string][]string // by symbol
+	sendQueueSize         int
+	sendQueueTimeout      time.Duration
+	sendStallThreshold    time.Duration
+	inboundQueueLen       int
+	overflowPolicy        OverflowPolicy
+	darkpoolFilter        DarkpoolFilter
+	callbacks             *callbackQueue
+
+	mu            sync.Mutex
 	breakHartbeat chan struct{}
+	hartbeated    chan struct{}
+	receiverDone  chan struct{}
 	breakSender   chan struct{}
 	sended        chan struct{}
-	q             chan map[string]interface{}
+	q             chan outgoing
+	control       chan outgoing // heartbeats, written before q
+	pings         chan string
+	pendingPings  map[string]chan struct{}
+	pingSeq       int64
 	closing       bool
+	stopped       bool
+	stopReason    error
+	dispatcher    *dispatcher
+	enqueuing     *sync.WaitGroup
+	reconnecting  bool
+	done          chan struct{}
+
+	// What the overflow policy dropped since OnOverflow was last called.
+	overflowPending    int
+	overflowChannel    string
+	overflowTimer      bool
+	overflowReportedAt time.Time
+
+	sendStallReportedAt time.Time // when OnSendStall was last called
 }
 
 // New Overview
-func New(username, password string, provider provider) *Client {
-	return &Client{
-		username:       username,
-		password:       password,
-		provider:       provider,
-		DebugMode:      false,
-		channels:       make(map[string]bool),
-		joinedChannels: make(map[string]bool),
+//
+// provider is one of the provider constants, or a Provider of your own.
+func New(username, password string, p Provider, opts ...Option) *Client {
+	provider, feed := resolveProvider(p)
+	cli := &Client{
+		username:              username,
+		password:              password,
+		provider:              provider,
+		feed:                  feed,
+		DebugMode:             false,
+		channels:              make(map[string]int),
+		joinedChannels:        make(map[string]bool),
+		pendingJoins:          make(map[string]string),
+		confirmed:             make(map[string]bool),
+		phoenixVsn:            PhoenixV1,
+		pendingReplies:        make(map[string]pendingReply),
+		joinRefs:              make(map[string]string),
+		staleTimeout:          staleWait,
+		readDeadline:          readWait,
+		idleReadDeadline:      idleReadWait,
+		idleHeartbeatInterval: idleHeartbeatWait,
+		now:                   time.Now,
+		writeDeadline:         writeWait,
+		heartbeatInterval:     heartbeatWait,
+		maxMissedHeartbeats:   3,
+		reconnectPolicy:       DefaultReconnectPolicy,
+		tokenTTL:              defaultTokenTTL(provider),
+		tokenMargin:           tokenRefreshMargin,
+		authRetry:             defaultAuthRetry,
+		rateLimitRetry:        3,
+		authTimeout:           authWait,
+		newTicker:             newTicker,
+		after:                 time.After,
+		done:                  make(chan struct{}),
+		history:               newStateHistory(stateHistorySize),
+		callbacks:             newCallbackQueue(),
+		sendQueueSize:         sendQueueLen,
+		sendStallThreshold:    defaultSendStallThreshold,
+		inboundQueueLen:       dispatchQueueSize,
 	}
+	for _, opt := range opts {
+		if err := opt(cli); err != nil && cli.optionErr == nil {
+			cli.optionErr = err
+		}
+	}
+	if err := cli.validateTimings(); err != nil && cli.optionErr == nil {
+		cli.optionErr = err
+	}
+	if err := cli.resolveManual(); err != nil && cli.optionErr == nil {
+		cli.optionErr = err
+	}
+	if err := cli.validateAuth(); err != nil && cli.optionErr == nil {
+		cli.optionErr = err
+	}
+	if err := cli.resolveFeed(); err != nil && cli.optionErr == nil {
+		cli.optionErr = err
+	}
+	cli.resolveTransport()
+	cli.resolveSimulation()
+	return cli
+}
+
+// NewWithOptions is New, but returns the error of an invalid option instead
+// of leaving it to Connect.
+func NewWithOptions(username, password string, p Provider, opts ...Option) (*Client, error) {
+	cli := New(username, password, p, opts...)
+	if cli.optionErr != nil {
+		return nil, cli.optionErr
+	}
+	return cli, nil
 }
 
 // Connect Overview
 func (cli *Client) Connect() error {
-	cli.debug("%s\n", "Websocket connecting...")
+	return cli.ConnectContext(context.Background())
+}
+
+// ConnectContext is like Connect, but gives up fetching the token and dialing
+// once ctx is done, including while waiting out an auth rate limit.
+func (cli *Client) ConnectContext(ctx context.Context) error {
+	if cli.optionErr != nil {
+		return cli.optionErr
+	}
+	cli.info("connecting")
+	cli.mu.Lock()
+	if cli.stopped {
+		// A previous Disconnect may still be waiting for dispatch workers.
+		done := cli.done
+		cli.mu.Unlock()
+		<-done
+		cli.mu.Lock()
+	}
+	cli.stopped = false
+	select {
+	case <-cli.done:
+		// Reused after Disconnect; handlers and channels carry over.
+		cli.done = make(chan struct{})
+	default:
+	}
+	cli.backoff = 0
+	cli.mu.Unlock()
 	cli.channelInitialize()
-	if err := cli.refreshToken(); err != nil {
+	if cli.replay != nil {
+		cli.startReplay()
+		return nil
+	}
+	if cli.sim != nil {
+		cli.startSimulation()
+		return nil
+	}
+	if err := cli.dial(ctx); err != nil {
 		return err
 	}
-	return cli.refreshWebsocket()
+	cli.startDispatcher()
+	cli.resubscribe()
+	cli.startTokenRefresher()
+	return nil
 }
 
 // Disconnect Overview
 func (cli *Client) Disconnect() error {
-	if cli.Connected() == false {
+	return cli.disconnect(nil)
+}
+
+// DisconnectWithTimeout closes the connection like Disconnect, but gives up
+// waiting for the sender after d. When the time runs out the websocket is
+// closed forcibly and ErrDisconnectTimeout is returned.
+func (cli *Client) DisconnectWithTimeout(d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	return cli.disconnect(timer.C)
+}
+
+// OnDisconnect registers a callback invoked once the client has
+// disconnected: cause is nil after Disconnect, the error the client gave up
+// on otherwise, and io.EOF at the end of a replay.
+func (cli *Client) OnDisconnect(f func(cause error)) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
+	cli.disconnectHandler = f
+}
+
+func (cli *Client) onDisconnect(cause error) {
+	cli.handlerMu.RLock()
+	f := cli.disconnectHandler
+	cli.handlerMu.RUnlock()
+	if f != nil {
+		cli.callHandler("OnDisconnect", func() { f(cause) })
+	}
+}
+
+// Done returns a channel that is closed once the client has fully shut down,
+// either by Disconnect or because the connection was lost. A later Connect
+// starts over with a new channel.
+func (cli *Client) Done() <-chan struct{} {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	return cli.done
+}
+
+// Wait blocks until the client has fully shut down.
+func (cli *Client) Wait() {
+	<-cli.Done()
+}
+
+// stop disconnects because of reason rather than at the caller's request.
+func (cli *Client) stop(reason error) {
+	cli.mu.Lock()
+	cli.stopReason = reason
+	cli.mu.Unlock()
+	cli.Disconnect()
+}
+
+func (cli *Client) disconnect(timeout <-chan time.Time) error {
+	cli.mu.Lock()
+	stopped, reason := cli.stopped, cli.stopReason
+	cli.stopped = true
+	cli.stopReason = nil
+	cli.mu.Unlock()
+	err := cli.closeConnection(timeout)
+	if finished := cli.stopDispatcher(); finished != nil {
+		// Not waited for here, so a handler may call Disconnect itself.
+		go func() {
+			<-finished
+			cli.onDone()
+		}()
+	} else {
+		cli.onDone()
+	}
+	if !stopped {
+		cli.record(TransitionDisconnected, 0, reason)
+		cli.onDisconnect(reason)
+	}
+	return err
+}
+
+func (cli *Client) closeConnection(timeout <-chan time.Time) error {
+	cli.mu.Lock()
+	if cli.breakSender == nil || cli.ws == nil {
+		cli.mu.Unlock()
 		return nil
 	}
+	ws, sended, hartbeated := cli.ws, cli.sended, cli.hartbeated
 	if cli.closing {
-		return nil
+		// Another caller is already tearing the connection down; wait for it.
+		cli.mu.Unlock()
+		select {
+		case <-sended:
+			return nil
+		case <-timeout:
+			return ErrDisconnectTimeout
+		}
 	}
 
+	// Shut down in a fixed order: the heartbeat first, because it feeds the
+	// send queue, then the sender, which flushes the queue on its way out.
 	cli.onClosing()
+	breakSender := cli.breakSender
 	close(cli.breakHartbeat)
-	close(cli.breakSender)
-	<-cli.sended
+	cli.mu.Unlock()
+
+	var err error
+	select {
+	case <-hartbeated:
+		close(breakSender)
+		select {
+		case <-sended:
+		case <-timeout:
+			err = ErrDisconnectTimeout
+		}
+	case <-timeout:
+		close(breakSender)
+		err = ErrDisconnectTimeout
+	}
+	if err != nil {
+		ws.Close()
+	}
+	cli.releaseConn(ws)
+	cli.mu.Lock()
 	cli.onClosed()
-	return nil
+	cli.mu.Unlock()
+	return err
 }
 
 // Join Overview
+//
+// Channels joined before Connect or while reconnecting are remembered and
+// subscribed as soon as the connection is established. Join, Leave and
+// LeaveAll may be called from any goroutine. Channels are trimmed and their
+// tickers upper-cased, so "aapl" and "AAPL " are the same channel as "AAPL".
 func (cli *Client) Join(channels ...string) {
+	cli.mu.Lock()
 	for _, channel := range channels {
-		c := strings.TrimSpace(channel)
-		if _, ok := cli.channels[c]; !ok {
-			cli.channels[c] = true
+		c := normalizeChannel(channel)
+		if cli.refCounted || cli.channels[c] == 0 {
+			cli.channels[c]++
 		}
 	}
+	cli.mu.Unlock()
 	cli.refreshChannels()
 }
 
 // Leave Overview
+//
+// With WithRefCountedSubscriptions a channel is only left once Leave has
+// been called for it as often as Join.
 func (cli *Client) Leave(channels ...string) {
+	cli.mu.Lock()
 	for _, channel := range channels {
-		delete(cli.channels, strings.TrimSpace(channel))
+		c := normalizeChannel(channel)
+		if cli.refCounted && 1 < cli.channels[c] {
+			cli.channels[c]--
+			continue
+		}
+		delete(cli.channels, c)
 	}
+	cli.mu.Unlock()
 	cli.refreshChannels()
 }
 
 // LeaveAll Overview
+//
+// It leaves every channel regardless of how often it was joined.
 func (cli *Client) LeaveAll() {
-	cli.channels = make(map[string]bool)
+	cli.mu.Lock()
+	cli.channels = make(map[string]int)
+	cli.mu.Unlock()
 	cli.refreshChannels()
 }
 
+// Subscriptions returns the joined channels, sorted. While connected the
+// server has been sent a join for each of them, or will be shortly.
+func (cli *Client) Subscriptions() []string {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	channels := make([]string, 0, len(cli.channels))
+	for k := range cli.channels {
+		channels = append(channels, k)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// SubscriptionRefs returns how often channel has been joined and not left
+// yet. Without WithRefCountedSubscriptions it is one for every joined
+// channel.
+func (cli *Client) SubscriptionRefs(channel string) int {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	return cli.channels[normalizeChannel(channel)]
+}
+
 // Connected Overview
 func (cli *Client) Connected() bool {
-	return cli.ws != nil
+	return cli.conn() != nil
+}
+
+// conn returns the live websocket, or nil when there is none. Goroutines that
+// serve a connection get it as an argument and never read cli.ws themselves.
+func (cli *Client) conn() *websocket.Conn {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	return cli.ws
+}
+
+// releaseConn forgets ws unless it has already been replaced by a newer
+// connection.
+func (cli *Client) releaseConn(ws *websocket.Conn) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	if cli.ws == ws {
+		cli.ws = nil
+	}
 }
 
 func (cli *Client) channelInitialize() {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
 	cli.breakHartbeat = make(chan struct{}, 1)
+	cli.hartbeated = make(chan struct{})
+	cli.receiverDone = make(chan struct{})
 	cli.breakSender = make(chan struct{}, 1)
 	cli.sended = make(chan struct{}, 1)
-	cli.q = make(chan map[string]interface{})
+	cli.q = make(chan outgoing, cli.sendQueueSize)
+	cli.enqueuing = &sync.WaitGroup{}
+	cli.control = make(chan outgoing, 1)
+	cli.pings = make(chan string)
+	cli.pendingPings = make(map[string]chan struct{})
 	cli.closing = false
+	cli.subscribed = false
+}
+
+// refreshToken fetches a new token. Network errors and 5xx responses are
+// retried according to authRetry for at most authTimeout, and 429 responses
+// up to rateLimitRetry times after the wait the server asked for. Other 4xx
+// responses are returned right away.
+func (cli *Client) refreshToken(ctx context.Context) error {
+	deadline := time.Now().Add(cli.authTimeout)
+	limited := 0
+	for attempt := 1; ; attempt++ {
+		status, err := cli.requestToken(ctx)
+		if err == nil {
+			return nil
+		}
+		if rl, ok := err.(*RateLimitedError); ok {
+			limited++
+			rl.Attempts = attempt
+			if cli.rateLimitRetry < limited {
+				return rl
+			}
+			wait := rl.RetryAfter
+			if wait == 0 {
+				wait = cli.authRetry.delay(limited - 1)
+			}
+			cli.warn("auth rate limited", "attempt", attempt, "retry_in", wait)
+			if err := cli.sleep(ctx, wait); err != nil {
+				return err
+			}
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if status != 0 && status < 500 || cli.authRetry.MaxAttempts <= attempt {
+			return &AuthError{StatusCode: status, Attempts: attempt, Err: err}
+		}
+		delay := cli.authRetry.delay(attempt - 1)
+		if deadline.Before(time.Now().Add(delay)) {
+			return &AuthError{StatusCode: status, Attempts: attempt, Err: err}
+		}
+		cli.warn("auth failed", "attempt", attempt, "status", status, "retry_in", delay, "err", err)
+		if err := cli.sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
 }
 
-func (cli *Client) refreshToken() error {
-	req, err := http.NewRequest("GET", makeAuthURL(cli.provider), nil)
+// sleep waits for d unless ctx is done or the client is disconnected first.
+func (cli *Client) sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-cli.Done():
+		return ErrClientClosed
+	}
+}
+
+// requestToken makes a single call to the auth endpoint. The status is zero
+// when no response was received.
+func (cli *Client) requestToken(ctx context.Context) (int, error) {
+	req, err := cli.tokenRequest()
 	if err != nil {
-		return err
+		return 0, err
 	}
-	req.Header.Add("Content-Type", "application/json")
-	req.SetBasicAuth(cli.username, cli.password)
-	client := &http.Client{Timeout: time.Duration(10) * time.Second}
-	resp, err := client.Do(req)
+	if req == nil {
+		// A Provider of the user's own that needs no token.
+		cli.mu.Lock()
+		cli.token = ""
+		cli.tokenAt = cli.now()
+		cli.mu.Unlock()
+		return 0, nil
+	}
+	cli.setHeaders(req)
+	client := cli.authClient()
+	resp, err := client.Do(req.WithContext(ctx))
 	if err != nil {
-		return err
+		return 0, authProxyError(client, req, redactAuthError(err))
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return resp.StatusCode, &RateLimitedError{RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
+	}
 	if resp.StatusCode != 200 {
-		return fmt.Errorf("%s", "Auth failed.")
+		return resp.StatusCode, fmt.Errorf("%s", "Auth failed.")
 	}
 	b, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return 0, err
 	}
+	cli.mu.Lock()
 	cli.token = string(b)
-	return nil
+	cli.tokenAt = cli.now()
+	cli.mu.Unlock()
+	cli.record(TransitionTokenRefreshed, 0, nil)
+	return resp.StatusCode, nil
 }
 
-func (cli *Client) refreshWebsocket() error {
-	if cli.Connected() {
-		cli.Disconnect()
+func (cli *Client) refreshWebsocket(ctx context.Context) error {
+	if cli.conn() != nil {
+		cli.closeConnection(nil)
 	}
 
-	c, _, err := websocket.DefaultDialer.Dial(makeSoketURL(cli.provider, cli.token), nil)
+	var c *websocket.Conn
+	var err error
+	if cli.transport != nil {
+		c, err = cli.transportConn(ctx)
+	} else {
+		c, err = cli.dialEndpoints(ctx)
+	}
 	if err != nil {
 		return err
 	}
+	cli.mu.Lock()
+	if cli.stopped {
+		cli.mu.Unlock()
+		c.Close()
+		return ErrClientClosed
+	}
 	cli.ws = c
-	cli.onConnected()
+	// Refs count from one on every connection.
+	cli.joinRefs = make(map[string]string)
+	cli.pendingReplies = make(map[string]pendingReply)
+	atomic.StoreUint64(&cli.phoenixRef, 0)
+	cli.mu.Unlock()
+	cli.onConnected(c)
 	return nil
 }
 
+// resubscribe sends a join for every channel on a fresh connection, including
+// the ones that were joined before Connect or while reconnecting.
+func (cli *Client) resubscribe() {
+	cli.mu.Lock()
+	cli.joinedChannels = make(map[string]bool)
+	cli.pendingJoins = make(map[string]string)
+	cli.confirmed = make(map[string]bool)
+	cli.subscribed = true
+	cli.mu.Unlock()
+	cli.refreshChannels()
+}
+
+// refreshChannels sends the joins and leaves needed to bring the live
+// connection in line with channels. Without a connection that has been
+// resubscribed it does nothing; the next resubscribe catches up.
+//
+// Only one refresh runs at a time, from the diff until its messages are
+// queued, so two concurrent calls can't queue their frames interleaved in an
+// order that contradicts joinedChannels.
 func (cli *Client) refreshChannels() {
-	if cli.Connected() == false {
+	var errs []error
+	defer func() {
+		// Reported once the refresh is over, as a handler may call Join.
+		for _, err := range errs {
+			cli.onError(err)
+		}
+	}()
+	cli.refreshMu.Lock()
+	defer cli.refreshMu.Unlock()
+	cli.mu.Lock()
+	if cli.ws == nil || cli.closing || !cli.subscribed {
+		cli.mu.Unlock()
 		return
 	}
+	q, breakSender, enqueuing := cli.q, cli.breakSender, cli.enqueuing
+	var changes []channelChange
 	for k := range cli.channels {
 		if _, ok := cli.joinedChannels[k]; !ok {
-			cli.q <- makeJoinMessage(cli.provider, k)
+			changes = append(changes, channelChange{channel: k, join: true, msg: cli.joinMessage(k)})
+			cli.awaitJoin(k)
 		}
 	}
 	for k := range cli.joinedChannels {
 		if _, ok := cli.channels[k]; !ok {
-			cli.q <- makeLeaveMessage(cli.provider, k)
+			changes = append(changes, channelChange{channel: k, msg: cli.leaveMessage(k)})
+			cli.forgetJoin(k)
 		}
 	}
 	cli.joinedChannels = make(map[string]bool)
 	for k := range cli.channels {
 		cli.joinedChannels[k] = true
 	}
+	cli.pruneGaps()
+	// The sender waits for us before its final flush, so nothing we manage
+	// to queue is lost.
+	enqueuing.Add(1)
+	cli.mu.Unlock()
+	defer enqueuing.Done()
+	for _, c := range changes {
+		if err := cli.enqueue(q, breakSender, c.msg); err != nil {
+			m, _ := c.msg.(map[string]interface{})
+			errs = append(errs, &DroppedMessageError{Message: m, Err: err})
+			if err == ErrSendQueueFull {
+				cli.unsent(q, c)
+			}
+		}
+	}
+}
+
+// channelChange is a join or leave refreshChannels wants to send.
+type channelChange struct {
+	channel string
+	join    bool
+	msg     interface{}
+}
+
+// outgoing is a message in the send queue and, while OnSendStall is
+// registered, when it was queued. msg is a map for the built-in providers
+// and whatever a Provider of the user's own returned for it. It is never
+// changed, as the user may share it between calls.
+type outgoing struct {
+	msg      interface{}
+	queuedAt time.Time
+}
+
+// enqueue hands msg to the sender. When the queue is full it waits as
+// configured with WithSendQueueTimeout and returns ErrSendQueueFull if no
+// room was made. It returns ErrClientClosed when the connection is closing.
+func (cli *Client) enqueue(q chan outgoing, breakSender chan struct{}, msg interface{}) error {
+	o := outgoing{msg: msg}
+	if cli.timingSends() {
+		o.queuedAt = time.Now()
+	}
+	select {
+	case q <- o:
+		return nil
+	default:
+	}
+	if cli.sendQueueTimeout < 0 {
+		return ErrSendQueueFull
+	}
+	var timeout <-chan time.Time
+	if 0 < cli.sendQueueTimeout {
+		timer := time.NewTimer(cli.sendQueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case q <- o:
+		return nil
+	case <-breakSender:
+		return ErrClientClosed
+	case <-timeout:
+		return ErrSendQueueFull
+	}
+}
+
+// unsent undoes the bookkeeping for a change that never made it into q, so
+// the next Join or Leave tries it again.
+func (cli *Client) unsent(q chan outgoing, c channelChange) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	if cli.q != q {
+		return
+	}
+	if c.join {
+		delete(cli.joinedChannels, c.channel)
+		cli.forgetJoin(c.channel)
+	} else {
+		cli.joinedChannels[c.channel] = true
+	}
+}
+
+func (cli *Client) startReceiver(ws *websocket.Conn) {
+	cli.mu.Lock()
+	receiverDone := cli.receiverDone
+	cli.mu.Unlock()
+	err := cli.receive(ws)
+	close(receiverDone)
+	owned := cli.ownsConnection(ws)
+	if ce, ok := err.(*websocket.CloseError); ok {
+		err = &CloseError{Code: ce.Code, Text: ce.Text, ByServer: owned}
+		if !owned {
+			// Our own close frame echoed back.
+			cli.onError(err)
+		}
+	}
+	if owned {
+		cli.connectionLost(ws, err)
+	}
 }
 
-func (cli *Client) startReceiver() {
+// connectionLost is where every unexpected end of ws ends up. The cause is
+// always reported, then the connection is replaced unless reconnecting was
+// turned off with WithoutReconnect.
+func (cli *Client) connectionLost(ws *websocket.Conn, cause error) {
+	cli.warn("connection lost", "err", cause)
+	cli.record(TransitionConnectionLost, 0, cause)
+	cli.onError(cause)
+	if !cli.noReconnect {
+		cli.reconnect(ws, cause)
+	} else if cli.ownsConnection(ws) {
+		cli.stop(cause)
+	}
+}
+
+func (cli *Client) receive(ws *websocket.Conn) error {
+	ws.SetPongHandler(func(appData string) error {
+		cli.touch()
+		cli.onPong(appData)
+		return ws.SetReadDeadline(time.Now().Add(cli.currentReadDeadline()))
+	})
+	for {
+		ws.SetReadDeadline(time.Now().Add(cli.currentReadDeadline()))
+		messageType, data, err := ws.ReadMessage()
+		if err != nil {
+			return err
+		}
+		receivedAt := cli.now()
+		cli.touch()
+		if err := cli.handleFrame(messageType, data, receivedAt); err != nil {
+			return err
+		}
+	}
+}
+
+// handleFrame decodes a frame that was received at receivedAt and passes it
+// on to the handlers, wherever it came from. An error means the connection
+// can't go on: the frame couldn't be decoded, or the token was rejected.
+func (cli *Client) handleFrame(messageType int, data []byte, receivedAt time.Time) error {
+	if messageType == websocket.TextMessage && cli.feed != nil {
+		return cli.handleFeedFrame(data, receivedAt)
+	}
+	// data is never touched again once OnRawMessage has it.
+	var ret, exact map[string]interface{}
+	var err error
+	if messageType == websocket.TextMessage {
+		ret, exact, err = cli.decodeReceived(data)
+	}
+	cli.onRawMessage(messageType, data)
+	if err == nil && cli.strict && messageType == websocket.TextMessage {
+		err = checkSchema(cli.provider, exact)
+	}
+	if err != nil && cli.strict {
+		cli.onError(&MalformedMessageError{Data: data, Err: err})
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if messageType == websocket.BinaryMessage {
+		cli.binaryFrame(data, receivedAt)
+		return nil
+	}
+	if messageType != websocket.TextMessage {
+		return nil
+	}
+	if cli.tokenRejected(ret) {
+		return ErrTokenRejected
+	}
+	if cli.replay != nil && !cli.replayJoined(ret) {
+		return nil
+	}
+	msg := cli.newMessage(data, ret, exact, receivedAt)
+	if !cli.selected(msg.Type) {
+		return nil
+	}
+	if msg.Type == MessageHeartbeatAck {
+		atomic.StoreInt32(&cli.missedHeartbeats, 0)
+	}
+	cli.onMessage(msg)
+	if info, ok := parseInfo(cli.provider, ret); ok {
+		cli.infoMessage(info)
+	}
+	cli.answerHeartbeat(ret)
+	if cli.joinReply(ret) {
+		return nil
+	}
+	if depth, ok := parseDepth(cli.provider, exact); ok {
+		cli.onDepth(depth)
+		return nil
+	}
+	if status, ok := parseSecurityStatus(cli.provider, exact); ok {
+		cli.onSecurityStatus(status)
+		return nil
+	}
+	if msg.Type.control() && !cli.controlToQuote || offLastPriceLobby(cli.provider, ret) {
+		return nil
+	}
+	cli.checkGap(ret)
+	cli.dispatch(msg)
+	return nil
+}
+
+// ownsConnection reports whether ws is still the live connection and nobody
+// has started closing or replacing it.
+func (cli *Client) ownsConnection(ws *websocket.Conn) bool {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	return cli.ws == ws && !cli.closing && !cli.stopped && !cli.reconnecting
+}
+
+func (cli *Client) startSender(ws *websocket.Conn) {
+	cli.mu.Lock()
+	q, control, pings, breakSender, sended, hartbeated, receiverDone, enqueuing := cli.q, cli.control, cli.pings, cli.breakSender, cli.sended, cli.hartbeated, cli.receiverDone, cli.enqueuing
+	cli.mu.Unlock()
 	defer func() {
-		cli.Disconnect()
+		cli.debug("sender closed")
+		// q is never closed: anything still sending on it watches breakSender
+		// and reports the message as dropped. Whatever did make it into the
+		// buffer is written by the flush, which waits for those senders.
+		<-hartbeated
+		enqueuing.Wait()
+		cli.flush(ws, control)
+		cli.flush(ws, q)
+		cli.leaveAll(ws)
+		cli.closeHandshake(ws, receiverDone)
+		ws.Close()
+		cli.releaseConn(ws)
+		close(sended)
 	}()
+	var broken bool
+	var ping <-chan time.Time
+	if interval := cli.pingPeriod(); 0 < interval {
+		pingTicker := time.NewTicker(interval)
+		defer pingTicker.Stop()
+		ping = pingTicker.C
+	}
 	for {
-		cli.ws.SetReadDeadline(time.Now().Add(readWait))
-		var ret map[string]interface{}
-		if err := cli.ws.ReadJSON(&ret); err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+		// Heartbeats jump the queue, so a long list of joins can't hold
+		// them up until the server gives up on us.
+		select {
+		case o := <-control:
+			broken = cli.send(ws, o, broken)
+			continue
+		default:
+		}
+		select {
+		case o := <-control:
+			broken = cli.send(ws, o, broken)
+		case o := <-q:
+			broken = cli.send(ws, o, broken)
+		case <-ping:
+			if err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(cli.writeDeadline)); err != nil {
+				cli.onError(err)
+			}
+		case id := <-pings:
+			if err := ws.WriteControl(websocket.PingMessage, []byte(id), time.Now().Add(cli.writeDeadline)); err != nil {
 				cli.onError(err)
 			}
+		case <-breakSender:
 			return
 		}
-		cli.onQuote(ret)
 	}
 }
 
-func (cli *Client) startSender() {
-	defer func() {
-		cli.debug("close sender")
-		for 0 < len(cli.q) {
-			cli.debug("Quit sender! queue count = %d\n", len(cli.q))
-			time.Sleep(100 * time.Millisecond)
+// leaveAll unsubscribes every joined channel when the client is being
+// disconnected, so the server frees them right away instead of waiting for
+// its idle timeout. Channels that could not be left are reported as a
+// LeaveError.
+func (cli *Client) leaveAll(ws *websocket.Conn) {
+	cli.mu.Lock()
+	if !cli.stopped {
+		cli.mu.Unlock()
+		return
+	}
+	channels := make([]string, 0, len(cli.joinedChannels))
+	for k := range cli.joinedChannels {
+		channels = append(channels, k)
+	}
+	cli.joinedChannels = make(map[string]bool)
+	cli.pendingJoins = make(map[string]string)
+	cli.confirmed = make(map[string]bool)
+	cli.mu.Unlock()
+	cli.gaps.prune(func(string) bool { return false })
+
+	var failed []string
+	var lastErr error
+	for _, channel := range channels {
+		ws.SetWriteDeadline(time.Now().Add(cli.writeDeadline))
+		if err := ws.WriteJSON(cli.phoenixFrame(cli.leaveMessage(channel))); err != nil {
+			failed = append(failed, channel)
+			lastErr = err
 		}
-		close(cli.q)
-		cli.ws.Close()
-		cli.ws = nil
-		close(cli.sended)
-	}()
+	}
+	if 0 < len(failed) {
+		sort.Strings(failed)
+		cli.onError(&LeaveError{Channels: failed, Err: lastErr})
+	}
+}
+
+// closeHandshake sends a normal closure and gives the server a moment to
+// echo it, which the receiver sees as the end of the stream. The socket is
+// closed by the caller either way.
+func (cli *Client) closeHandshake(ws *websocket.Conn, receiverDone chan struct{}) {
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	if err := ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(closeWait)); err != nil {
+		cli.debug("close frame not sent", "err", err)
+		return
+	}
+	timer := time.NewTimer(closeWait)
+	defer timer.Stop()
+	select {
+	case <-receiverDone:
+	case <-timer.C:
+	}
+}
+
+// flush writes the messages that are still being handed to q when the sender
+// is stopped, so a Leave right before Disconnect still reaches the server.
+func (cli *Client) flush(ws *websocket.Conn, q chan outgoing) {
 	for {
 		select {
-		case data := <-cli.q:
-			cli.debug("send data = %v\n", data)
-			cli.ws.SetWriteDeadline(time.Now().Add(writeWait))
-			if err := cli.ws.WriteJSON(data); err != nil {
-				cli.onError(err)
+		case o := <-q:
+			if err := cli.write(ws, o); err != nil {
+				cli.onError(newWriteError(o.msg, err))
 			}
-		case <-cli.breakSender:
+		default:
 			return
 		}
 	}
 }
 
-func (cli *Client) heartbeat() {
-	hearbeatTime := time.NewTicker(heartbeatWait)
-	defer hearbeatTime.Stop()
+// send writes o unless an earlier write already broke ws, and reports
+// whether ws is broken afterwards. The websocket keeps failing once a write
+// has failed, even on a timeout, so retrying on it is pointless: the
+// connection is given up instead and the reconnect replays every
+// subscription, including the one that was lost here.
+func (cli *Client) send(ws *websocket.Conn, o outgoing, broken bool) bool {
+	if broken {
+		return true
+	}
+	if err := cli.write(ws, o); err != nil {
+		// reconnect waits for the sender to exit, so it can't run here.
+		go cli.connectionLost(ws, newWriteError(o.msg, err))
+		return true
+	}
+	return false
+}
+
+func (cli *Client) write(ws *websocket.Conn, o outgoing) error {
+	m, _ := o.msg.(map[string]interface{})
+	cli.debug("send", "event", m["event"], "topic", m["topic"])
+	if !cli.timingSends() {
+		ws.SetWriteDeadline(time.Now().Add(cli.writeDeadline))
+		return ws.WriteJSON(cli.phoenixFrame(o.msg))
+	}
+	start := time.Now()
+	ws.SetWriteDeadline(start.Add(cli.writeDeadline))
+	err := ws.WriteJSON(cli.phoenixFrame(o.msg))
+	cli.sendTook(start, time.Since(start), o.queuedAt)
+	return err
+}
+
+// pingPeriod returns how often websocket ping frames are sent. Unless set
+// explicitly it is derived from the read deadline so that a pong always
+// arrives before the deadline expires.
+func (cli *Client) pingPeriod() time.Duration {
+	if cli.pingInterval != 0 {
+		return cli.pingInterval
+	}
+	return cli.readDeadline * 8 / 10
+}
+
+func (cli *Client) heartbeat(ws *websocket.Conn) {
+	cli.mu.Lock()
+	control, breakHartbeat, hartbeated := cli.control, cli.breakHartbeat, cli.hartbeated
+	cli.mu.Unlock()
+	defer close(hartbeated)
+	atomic.StoreInt32(&cli.missedHeartbeats, 0)
+	if cli.heartbeatInterval <= 0 {
+		return
+	}
+	hearbeatTime, stop := cli.newTicker(cli.heartbeatInterval)
+	defer stop()
+	var sentAt time.Time
 	for {
 		select {
-		case <-hearbeatTime.C:
-			cli.q <- makeHeartbeatMessage(cli.provider)
-		case <-cli.breakHartbeat:
+		case <-hearbeatTime:
+			if cli.Idle() && cli.now().Sub(sentAt) < cli.idleHeartbeatInterval {
+				continue
+			}
+			sentAt = cli.now()
+			missed := atomic.AddInt32(&cli.missedHeartbeats, 1) - 1
+			if 0 < cli.maxMissedHeartbeats && cli.maxMissedHeartbeats <= missed {
+				// reconnect waits for this goroutine to exit, so it can't run here.
+				go cli.connectionLost(ws, &HeartbeatTimeoutError{Missed: int(missed)})
+				return
+			}
+			select {
+			case control <- outgoing{msg: cli.heartbeatMessage()}:
+			case <-breakHartbeat:
+				return
+			}
+		case <-breakHartbeat:
 			return
 		}
 	}
 }
 
-func (cli *Client) debug(format string, a ...interface{}) {
-	if cli.DebugMode == false {
-		return
-	}
-	fmt.Printf(format, a...)
+func newTicker(d time.Duration) (<-chan time.Time, func()) {
+	t := time.NewTicker(d)
+	return t.C, t.Stop
 }
 
-func (cli *Client) onConnected() {
-	cli.debug("%s\n", "Websocket connected")
-	go cli.startReceiver()
-	go cli.startSender()
-	go cli.heartbeat()
+func (cli *Client) onConnected(ws *websocket.Conn) {
+	cli.info("connected")
+	cli.record(TransitionConnected, 0, nil)
+	cli.touch()
+	cli.mu.Lock()
+	cli.connectedAt = time.Now()
+	cli.mu.Unlock()
+	go cli.startReceiver(ws)
+	go cli.startSender(ws)
+	go cli.heartbeat(ws)
+	go cli.watchdog(ws)
 }
 
 func (cli *Client) onClosing() {
 	cli.closing = true
-	cli.debug("%s\n", "Websocket closing")
+	cli.debug("closing")
 }
 func (cli *Client) onCloseFailed() {
 	cli.closing = false
-	cli.debug("%s\n", "Websocket failed close")
+	cli.warn("close failed")
 }
 
 func (cli *Client) onClosed() {
 	cli.closing = false
-	cli.debug("%s\n", "Websocket closed")
+	cli.info("closed")
+}
+
+func (cli *Client) onDone() {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	select {
+	case <-cli.done:
+	default:
+		close(cli.done)
+	}
 }
 
 // OnQuote Overview
+//
+// Like every On* method it may be called at any time, also after Connect;
+// the next message goes to the new handler.
 func (cli *Client) OnQuote(f func(map[string]interface{})) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
 	cli.quoteHander = f
 }
 
-func (cli *Client) onQuote(a map[string]interface{}) {
-	cli.debug("%v\n", a)
-	if cli.quoteHander != nil {
-		cli.quoteHander(a)
+func (cli *Client) onQuote(msg Message) {
+	if cli.concurrentCallbacks {
+		cli.handleQuote(msg)
+		return
+	}
+	dropped := cli.callbacks.pushQuote(&msg, func() { cli.handleQuote(msg) }, cli.inboundQueueLen, cli.overflowPolicy)
+	if dropped != nil {
+		cli.overflowed(*dropped)
+	}
+}
+
+// handleQuote runs OnQuote and the typed quote handlers on the calling
+// goroutine.
+func (cli *Client) handleQuote(msg Message) {
+	if msg.binary != nil {
+		cli.handleBinary(msg.binary)
+		return
+	}
+	// OnQuote gets the payload, the typed handlers the one with exact sizes.
+	a, exact := msg.Payload, msg.exact
+	cli.debug("message", "type", msg.Type, "channel", msg.Channel)
+	cli.handlerMu.RLock()
+	f, iex, quoddQuote, quoddTrade, onTrade := cli.quoteHander, cli.iexQuoteHandler, cli.quoddQuoteHandler, cli.quoddTradeHandler, cli.tradeHandler
+	onBid, onAsk, normalized, typed := cli.bidHandler, cli.askHandler, cli.normalizedHandler, cli.typedHandlers
+	optionTrade, optionQuote := cli.optionTradeHandler, cli.optionQuoteHandler
+	cryptoTrade, cryptoBook, fxQuote := cli.cryptoTradeHandler, cli.cryptoBookHandler, cli.fxQuoteHandler
+	cli.handlerMu.RUnlock()
+	if f != nil {
+		cli.runHandler("OnQuote", func() { f(a) })
+	}
+	if iex != nil {
+		if quote, ok := parseIEXQuote(cli.provider, exact); ok {
+			cli.runHandler("OnIEXQuote", func() { iex(quote) })
+		}
+	}
+	if quoddQuote != nil {
+		if quote, ok := parseQuoddQuote(cli.provider, exact); ok {
+			cli.runHandler("OnQuoddQuote", func() { quoddQuote(quote) })
+		}
+	}
+	if quoddTrade != nil {
+		if trade, ok := parseQuoddTrade(cli.provider, exact); ok {
+			cli.runHandler("OnQuoddTrade", func() { quoddTrade(trade) })
+		}
+	}
+	if onTrade != nil {
+		if trade, ok := parseTrade(cli.provider, exact); ok && cli.darkpoolFilter.passes(trade) {
+			cli.runHandler("OnTrade", func() { onTrade(trade) })
+		}
+	}
+	if onBid != nil || onAsk != nil {
+		bid, ask := cli.book.sides(cli.provider, exact)
+		if bid != nil && onBid != nil {
+			cli.runHandler("OnBid", func() { onBid(*bid) })
+		}
+		if ask != nil && onAsk != nil {
+			cli.runHandler("OnAsk", func() { onAsk(*ask) })
+		}
+	}
+	if optionTrade != nil {
+		if trade, ok := parseOptionTrade(cli.provider, exact); ok {
+			cli.runHandler("OnOptionTrade", func() { optionTrade(trade) })
+		}
+	}
+	if optionQuote != nil {
+		if quote, ok := parseOptionQuote(cli.provider, exact); ok {
+			cli.runHandler("OnOptionQuote", func() { optionQuote(quote) })
+		}
+	}
+	if cryptoTrade != nil {
+		if trade, ok := parseCryptoTrade(cli.provider, exact); ok {
+			cli.runHandler("OnCryptoTrade", func() { cryptoTrade(trade) })
+		}
+	}
+	if cryptoBook != nil {
+		if update, ok := parseCryptoBookUpdate(cli.provider, exact); ok {
+			cli.runHandler("OnCryptoBookUpdate", func() { cryptoBook(update) })
+		}
+	}
+	if fxQuote != nil {
+		if quote, ok := parseFxQuote(cli.provider, exact); ok {
+			cli.runHandler("OnFxQuote", func() { fxQuote(quote) })
+		}
+	}
+	if normalized != nil {
+		for _, quote := range normalize(cli.provider, exact) {
+			quote := quote
+			cli.runHandler("OnNormalizedQuote", func() { normalized(quote) })
+		}
+	}
+	if len(typed) != 0 {
+		cli.handleTyped(typed, exact)
 	}
 }
 
 // OnError Overview
 func (cli *Client) OnError(f func(err error)) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
 	cli.errorHandler = f
 }
 
 func (cli *Client) onError(err error) {
-	cli.debug("IntrinioRealtime | Websocket error: %v\n", err)
-	if cli.errorHandler != nil {
-		cli.errorHandler(err)
+	cli.warn("error", "err", err)
+	cli.handlerMu.RLock()
+	f := cli.errorHandler
+	cli.handlerMu.RUnlock()
+	if f != nil {
+		cli.callHandler("OnError", func() { f(err) })
+	}
+}
+
+// runHandler runs a user handler, turning a panic into a HandlerPanicError
+// reported through OnError unless WithoutPanicRecovery was given. A panic in
+// OnError itself is only logged, it has nowhere else to go.
+func (cli *Client) runHandler(name string, f func()) {
+	if !cli.noRecover {
+		defer func() {
+			if v := recover(); v != nil {
+				err := &HandlerPanicError{Handler: name, Value: v, Stack: debug.Stack()}
+				if name == "OnError" {
+					cli.logError("handler panicked", "handler", name, "err", err, "stack", string(err.Stack))
+					return
+				}
+				cli.onError(err)
+			}
+		}()
 	}
+	f()
 }
 
+// makeAuthURL returns the auth URL of provider, "" for MANUAL, whose URL is
+// the client's own, see authEndpoint.
 func makeAuthURL(provider provider) string {
 	switch provider {
 	case IEX:
 		return cIEXRealtimeTokenURL
 	case QUODD:
 		return cQUODDRealtimeTokenURL
+	case REALTIME:
+		return cRealtimeTokenURL
+	case DELAYED_SIP:
+		return cDelayedSIPTokenURL
+	case NASDAQ_BASIC:
+		return cNasdaqBasicTokenURL
+	case OPRA:
+		return cOPRATokenURL
+	case CRYPTOQUOTE:
+		return cCryptoquoteTokenURL
+	case FXCM:
+		return cFXCMTokenURL
 	default:
-		panic("A value that does not exist was specified.")
+		return ""
+	}
+}
+
+// apiKeyAuth reports the providers that take an API key rather than a
+// username and password.
+func apiKeyAuth(provider provider) bool {
+	switch provider {
+	case REALTIME, DELAYED_SIP, NASDAQ_BASIC, OPRA, CRYPTOQUOTE, FXCM:
+		return true
+	}
+	return false
+}
+
+// makeAuthRequest returns the token request to url. IEX and QUODD take the
+// username and password as basic auth; the providers of apiKeyAuth take the
+// API key, passed as the username, in the query.
+func makeAuthRequest(provider provider, url, username, password string) (*http.Request, error) {
+	if apiKeyAuth(provider) {
+		return makeAPIKeyRequest(provider, url, username)
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
 	}
+	req.Header.Add("Content-Type", "application/json")
+	req.SetBasicAuth(username, password)
+	return req, nil
 }
 
-func makeSoketURL(provider provider, token string) string {
+// makeSoketBaseURLs returns the websocket base URLs of provider, nil for
+// MANUAL, see soketEndpoints.
+func makeSoketBaseURLs(provider provider) []string {
 	switch provider {
 	case IEX:
-		return fmt.Sprintf("%s?vsn=1.0.0&token=%s", cIEXWebsocketURL, token)
+		return []string{cIEXWebsocketURL}
+	case QUODD:
+		return []string{cQUODDWebsocketURL}
+	case REALTIME:
+		return []string{cRealtimeWebsocketURL}
+	case DELAYED_SIP:
+		return []string{cDelayedSIPWebsocketURL}
+	case NASDAQ_BASIC:
+		return []string{cNasdaqBasicWebsocketURL}
+	case OPRA:
+		return []string{cOPRAWebsocketURL}
+	case CRYPTOQUOTE:
+		return []string{cCryptoquoteWebsocketURL}
+	case FXCM:
+		return []string{cFXCMWebsocketURL}
+	default:
+		return nil
+	}
+}
+
+// makeSoketURL returns the URL that dials base with token. vsn is the
+// phoenix wire format, see WithPhoenixVersion.
+func makeSoketURL(provider provider, base, token, vsn string) string {
+	switch provider {
+	case IEX, REALTIME, DELAYED_SIP, NASDAQ_BASIC, OPRA, CRYPTOQUOTE, FXCM:
+		return fmt.Sprintf("%s?vsn=%s&token=%s", base, vsn, token)
 	case QUODD:
-		return fmt.Sprintf("%s/%s", cQUODDWebsocketURL, token)
+		return fmt.Sprintf("%s/%s", base, token)
 	default:
 		panic("A value that does not exist was specified.")
 	}
 }
 
+// makeJoinMessage returns the message that joins channel. NASDAQ_BASIC
+// joins say whether they want the trades only, for trades-only channels, or
+// the quote updates as well.
 func makeJoinMessage(provider provider, channel string) map[string]interface{} {
-	if provider == IEX {
+	if phoenix(provider) {
+		payload := map[string]interface{}{}
+		if provider == NASDAQ_BASIC {
+			payload["trades_only"] = tradesOnly(channel)
+		}
 		return map[string]interface{}{
-			"topic":   parseTopic(channel),
+			"topic":   parseTopic(provider, channel),
 			"event":   "phx_join",
-			"payload": map[string]interface{}{},
+			"payload": payload,
 			"ref":     nil,
 		}
 	} else if provider == QUODD {
 		return map[string]interface{}{
 			"event": "subscribe",
-			"data": map[string]string{
-				"ticker": channel,
-				"action": "subscribe",
-			},
+			"data":  makeQUODDData(channel, "subscribe"),
 		}
 	} else {
 		panic("A value that does not exist was specified.")
@@ -344,9 +1497,9 @@ func makeJoinMessage(provider provider, channel string) map[string]interface{} {
 }
 
 func makeLeaveMessage(provider provider, channel string) map[string]interface{} {
-	if provider == IEX {
+	if phoenix(provider) {
 		return map[string]interface{}{
-			"topic":   parseTopic(channel),
+			"topic":   parseTopic(provider, channel),
 			"event":   "phx_leave",
 			"payload": map[string]interface{}{},
 			"ref":     nil,
@@ -354,10 +1507,7 @@ func makeLeaveMessage(provider provider, channel string) map[string]interface{}
 	} else if provider == QUODD {
 		return map[string]interface{}{
 			"event": "unsubscribe",
-			"data": map[string]string{
-				"ticker": channel,
-				"action": "unsubscribe",
-			},
+			"data":  makeQUODDData(channel, "unsubscribe"),
 		}
 	} else {
 		panic("A value that does not exist was specified.")
@@ -365,7 +1515,9 @@ func makeLeaveMessage(provider provider, channel string) map[string]interface{}
 }
 
 func makeHeartbeatMessage(provider provider) map[string]interface{} {
-	if provider == IEX {
+	if provider == CRYPTOQUOTE {
+		return makeCryptoHeartbeat()
+	} else if phoenix(provider) {
 		return map[string]interface{}{
 			"topic":   "phoenix",
 			"event":   "heartbeat",
@@ -385,11 +1537,43 @@ func makeHeartbeatMessage(provider provider) map[string]interface{} {
 	}
 }
 
-func parseTopic(channel string) string {
+// parseTopic returns the phoenix topic of channel. A trades-only channel is
+// the topic of its ticker, and OPRA, CRYPTOQUOTE and FXCM have topics of
+// their own.
+func parseTopic(provider provider, channel string) string {
+	switch provider {
+	case OPRA:
+		return optionsPrefix + channel
+	case FXCM:
+		return fxPrefix + channel
+	case CRYPTOQUOTE:
+		return cryptoTopic(channel)
+	}
+	channel = strings.TrimPrefix(channel, tradesPrefix)
 	if channel == "$lobby" {
 		return "iex:lobby"
 	} else if channel == "$lobby_last_price" {
 		return "iex:lobby:last_price"
 	}
-	return "iex:securities:" + channel
+	return iexSecuritiesPrefix + channel
+}
+
+// topicChannel returns the channel of an IEX topic, the reverse of
+// parseTopic, or "" for the topics that aren't channels.
+func topicChannel(topic string) string {
+	switch {
+	case topic == "iex:lobby":
+		return "$lobby"
+	case topic == "iex:lobby:last_price":
+		return "$lobby_last_price"
+	case strings.HasPrefix(topic, iexSecuritiesPrefix):
+		return topic[len(iexSecuritiesPrefix):]
+	case strings.HasPrefix(topic, optionsPrefix):
+		return topic[len(optionsPrefix):]
+	case strings.HasPrefix(topic, "crypto:"):
+		return cryptoTopicChannel(topic)
+	case strings.HasPrefix(topic, fxPrefix):
+		return topic[len(fxPrefix):]
+	}
+	return ""
 }
@@ -1,9 +1,18 @@
 package intriniorealtime
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/gorilla/websocket"
 )
 
 const (
@@ -200,12 +209,8 @@ func TestClientLeave(t *testing.T) {
 			sut.DebugMode = true
 			sut.Connect()
 			defer sut.Disconnect()
-			sut.OnQuote(func(data map[string]interface{}) {
-				event := data["event"].(string)
-				if event == "info" {
-					message := data["data"].(map[string]interface{})["message"].(string)
-					readedData = event == "info" && strings.Contains(message, "unsubscribed")
-				}
+			sut.OnInfo(func(info InfoMessage) {
+				readedData = info.Kind == InfoUnsubscribed
 			})
 			sut.Join("AAPL.NB", "MSFT.NB", "GE.NB")
 			time.Sleep(5 * time.Second)
@@ -218,3 +223,1260 @@ func TestClientLeave(t *testing.T) {
 		})
 	}
 }
+
+func TestClientDisconnect(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		prepare func() *Client
+	}{
+		{
+			name: "Connectする前にDisconnectしてもパニックやハングが発生しないこと",
+			prepare: func() *Client {
+				return New(yourIntrinioAPIUserName, yourIntrinioAPIPassword, IEX)
+			},
+		},
+		{
+			name: "Connectに失敗した後にDisconnectしてもパニックやハングが発生しないこと",
+			prepare: func() *Client {
+				sut := server.newClient(IEX)
+				sut.authURL = server.srv.URL + "/unknown"
+				if err := sut.Connect(); err == nil {
+					t.Fatal("connect() error = nil, wantErr true")
+				}
+				return sut
+			},
+		},
+		{
+			name: "接続中にDisconnectすると切断されること",
+			prepare: func() *Client {
+				sut := server.newClient(QUODD)
+				if err := sut.Connect(); err != nil {
+					t.Fatalf("connect() error = %v", err)
+				}
+				return sut
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := tt.prepare()
+			done := make(chan error, 1)
+			go func() {
+				done <- sut.Disconnect()
+			}()
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Errorf("Disconnect() error = %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Disconnect() did not return")
+			}
+			if sut.Connected() {
+				t.Error("Connected() = true after Disconnect()")
+			}
+		})
+	}
+}
+
+func TestClientDisconnectConcurrently(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		goroutines int
+	}{
+		{name: "Disconnectを2回呼び出してもパニックが発生しないこと", goroutines: 2},
+		{name: "複数のgoroutineから同時にDisconnectを呼び出してもパニックが発生しないこと", goroutines: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := server.newClient(IEX)
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			var wg sync.WaitGroup
+			for i := 0; i < tt.goroutines; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := sut.Disconnect(); err != nil {
+						t.Errorf("Disconnect() error = %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+			if sut.Connected() {
+				t.Error("Connected() = true after Disconnect()")
+			}
+		})
+	}
+}
+
+func TestClientDisconnectWithTimeout(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		stall   bool
+		wantErr error
+	}{
+		{name: "送信が詰まっていなければ時間内に切断できること", stall: false, wantErr: nil},
+		{name: "送信が詰まっているときはタイムアウトして強制的に切断されること", stall: true, wantErr: ErrDisconnectTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.setStall(tt.stall)
+			sut := server.newClient(QUODD)
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			if tt.stall {
+				// A frame far larger than the socket buffers wedges the sender.
+				sut.mu.Lock()
+				q := sut.q
+				sut.mu.Unlock()
+				q <- outgoing{msg: map[string]interface{}{"data": strings.Repeat("x", 64<<20)}}
+				time.Sleep(200 * time.Millisecond)
+			}
+			start := time.Now()
+			if err := sut.DisconnectWithTimeout(500 * time.Millisecond); err != tt.wantErr {
+				t.Errorf("DisconnectWithTimeout() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); 2*time.Second < elapsed {
+				t.Errorf("DisconnectWithTimeout() took %v", elapsed)
+			}
+			if sut.Connected() {
+				t.Error("Connected() = true after DisconnectWithTimeout()")
+			}
+
+			server.setStall(false)
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("reconnect error = %v", err)
+			}
+			if err := sut.DisconnectWithTimeout(time.Second); err != nil {
+				t.Errorf("DisconnectWithTimeout() after reconnect error = %v", err)
+			}
+		})
+	}
+}
+
+func TestClientDone(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		connect bool
+		opts    []Option
+		close   func(sut *Client)
+	}{
+		{
+			name:    "ConnectせずにDisconnectするとDoneが閉じられること",
+			connect: false,
+			close:   func(sut *Client) { sut.Disconnect() },
+		},
+		{
+			name:    "Disconnectを呼び出すとDoneが閉じられること",
+			connect: true,
+			close:   func(sut *Client) { sut.Disconnect() },
+		},
+		{
+			name:    "再接続しない設定でサーバーから切断されるとDoneが閉じられること",
+			connect: true,
+			opts:    []Option{WithoutReconnect()},
+			close: func(sut *Client) {
+				for _, conn := range server.connections() {
+					conn.Close()
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := server.newClient(IEX, tt.opts...)
+			select {
+			case <-sut.Done():
+				t.Fatal("Done() is closed before shutdown")
+			default:
+			}
+			if tt.connect {
+				if err := sut.Connect(); err != nil {
+					t.Fatalf("connect() error = %v", err)
+				}
+			}
+			tt.close(sut)
+			select {
+			case <-sut.Done():
+			case <-time.After(5 * time.Second):
+				t.Fatal("Done() was not closed")
+			}
+			sut.Disconnect()
+			sut.Wait()
+		})
+	}
+}
+
+func TestClientPingPong(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	tests := []struct {
+		name          string
+		pingInterval  time.Duration
+		wantConnected bool
+	}{
+		{name: "pingにpongが返ってくればデータがなくても接続が維持されること", pingInterval: 0, wantConnected: true},
+		{name: "pingを無効にするとデータがないときに読み込み期限で切断されること", pingInterval: -1, wantConnected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := server.newClient(QUODD, WithPingInterval(tt.pingInterval), WithStaleTimeout(0), WithoutReconnect())
+			sut.readDeadline = 300 * time.Millisecond
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			defer sut.Disconnect()
+			time.Sleep(1500 * time.Millisecond)
+			if got := sut.Connected(); got != tt.wantConnected {
+				t.Errorf("Connected() = %v, want %v", got, tt.wantConnected)
+			}
+		})
+	}
+}
+
+func TestClientHeartbeatAck(t *testing.T) {
+	tests := []struct {
+		name          string
+		provider      provider
+		ack           bool
+		wantReconnect bool
+	}{
+		{name: "IEXがheartbeatに応答していれば再接続しないこと", provider: IEX, ack: true, wantReconnect: false},
+		{name: "QUODDがheartbeatに応答していれば再接続しないこと", provider: QUODD, ack: true, wantReconnect: false},
+		{name: "IEXがheartbeatに応答しなくなったら再接続すること", provider: IEX, ack: false, wantReconnect: true},
+		{name: "QUODDがheartbeatに応答しなくなったら再接続すること", provider: QUODD, ack: false, wantReconnect: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+			if tt.ack {
+				server.setReply(ackHeartbeats)
+			}
+
+			var heartbeatErr atomic.Value
+			reconnected := make(chan struct{}, 1)
+			sut := server.newClient(tt.provider, WithMaxMissedHeartbeats(2), WithStaleTimeout(0))
+			sut.heartbeatInterval = 50 * time.Millisecond
+			sut.OnError(func(err error) {
+				if e, ok := err.(*HeartbeatTimeoutError); ok {
+					heartbeatErr.Store(e)
+				}
+			})
+			sut.OnReconnect(func(error) {
+				select {
+				case reconnected <- struct{}{}:
+				default:
+				}
+			})
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			defer sut.Disconnect()
+
+			gotReconnect := false
+			select {
+			case <-reconnected:
+				gotReconnect = true
+			case <-time.After(time.Second):
+			}
+			if gotReconnect != tt.wantReconnect {
+				t.Errorf("reconnected = %v, want %v", gotReconnect, tt.wantReconnect)
+			}
+			if gotErr := heartbeatErr.Load() != nil; gotErr != tt.wantReconnect {
+				t.Errorf("HeartbeatTimeoutError reported = %v, want %v", gotErr, tt.wantReconnect)
+			}
+		})
+	}
+}
+
+func TestClientConnectedRace(t *testing.T) {
+	tests := []struct {
+		name  string
+		close func(server *fakeServer, sut *Client)
+	}{
+		{
+			name:  "Disconnect中にConnectedを呼び出してもデータ競合が発生しないこと",
+			close: func(server *fakeServer, sut *Client) { sut.Disconnect() },
+		},
+		{
+			name:  "サーバーからの切断中にConnectedを呼び出してもデータ競合が発生しないこと",
+			close: func(server *fakeServer, sut *Client) { server.kick(websocket.CloseGoingAway, "bye") },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+
+			sut := server.newClient(QUODD)
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			stop := make(chan struct{})
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						select {
+						case <-stop:
+							return
+						default:
+							sut.Connected()
+						}
+					}
+				}()
+			}
+			tt.close(server, sut)
+			if !waitUntil(5*time.Second, func() bool { return !sut.Connected() }) {
+				t.Error("Connected() = true after the connection was closed")
+			}
+			close(stop)
+			wg.Wait()
+			sut.Disconnect()
+		})
+	}
+}
+
+func TestClientJoinBeforeConnect(t *testing.T) {
+	tests := []struct {
+		name  string
+		join  []string
+		leave []string
+		want  []string
+	}{
+		{
+			name: "Connectする前にJoinしたチャンネルが接続後に購読されること",
+			join: []string{"AAPL.NB"},
+			want: []string{"AAPL.NB"},
+		},
+		{
+			name:  "Connectする前にLeaveしたチャンネルは購読されないこと",
+			join:  []string{"AAPL.NB", "MSFT.NB"},
+			leave: []string{"MSFT.NB"},
+			want:  []string{"AAPL.NB"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+			server.setReply(quoteOnSubscribe)
+
+			quotes := make(chan map[string]interface{}, 10)
+			sut := server.newClient(QUODD)
+			sut.OnQuote(func(data map[string]interface{}) {
+				quotes <- data
+			})
+			sut.Join(tt.join...)
+			sut.Leave(tt.leave...)
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			defer sut.Disconnect()
+
+			select {
+			case <-quotes:
+			case <-time.After(5 * time.Second):
+				t.Fatal("no quote was received")
+			}
+			if got := server.subscribedTickers(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("subscribed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientShutdownOrdering(t *testing.T) {
+	tests := []struct {
+		name      string
+		stall     bool
+		maxMissed int32
+		close     func(sut *Client) error
+		wantErr   error
+	}{
+		{
+			name:  "ハートビートの送信中にDisconnectしてもパニックしないこと",
+			close: func(sut *Client) error { return sut.Disconnect() },
+		},
+		{
+			name:  "ハートビートの送信中にDisconnectWithTimeoutしてもパニックしないこと",
+			close: func(sut *Client) error { return sut.DisconnectWithTimeout(time.Second) },
+		},
+		{
+			name:      "ハートビートの応答切れによる再接続とDisconnectが競合してもパニックしないこと",
+			maxMissed: 1,
+			close:     func(sut *Client) error { return sut.Disconnect() },
+		},
+		{
+			name:    "送信が詰まってハートビートが待たされていてもタイムアウトで切断できること",
+			stall:   true,
+			close:   func(sut *Client) error { return sut.DisconnectWithTimeout(200 * time.Millisecond) },
+			wantErr: ErrDisconnectTimeout,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+			server.setStall(tt.stall)
+			before := runtime.NumGoroutine()
+
+			for i := 0; i < 5; i++ {
+				sut := server.newClient(QUODD)
+				sut.heartbeatInterval = time.Millisecond
+				sut.maxMissedHeartbeats = tt.maxMissed
+				if err := sut.Connect(); err != nil {
+					t.Fatalf("connect() error = %v", err)
+				}
+				if tt.stall {
+					sut.mu.Lock()
+					q := sut.q
+					sut.mu.Unlock()
+					q <- outgoing{msg: map[string]interface{}{"data": strings.Repeat("x", 8<<20)}}
+				}
+				time.Sleep(20 * time.Millisecond)
+				if err := tt.close(sut); err != tt.wantErr {
+					t.Errorf("close error = %v, wantErr %v", err, tt.wantErr)
+				}
+				select {
+				case <-sut.Done():
+				case <-time.After(5 * time.Second):
+					t.Fatal("Done() was not closed")
+				}
+			}
+			server.Close()
+			if !waitUntil(5*time.Second, func() bool { return runtime.NumGoroutine() <= before }) {
+				t.Errorf("goroutines = %d, want at most %d", runtime.NumGoroutine(), before)
+			}
+		})
+	}
+}
+
+func TestClientLeaveBeforeDisconnect(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider provider
+		event    string
+		leave    func(sut *Client)
+	}{
+		{name: "IEXでJoin、Leave、Disconnectするとphx_leaveがサーバーに届くこと", provider: IEX, event: "phx_leave", leave: func(sut *Client) { sut.Leave("AAPL") }},
+		{name: "QUODDでJoin、Leave、Disconnectするとunsubscribeがサーバーに届くこと", provider: QUODD, event: "unsubscribe", leave: func(sut *Client) { sut.Leave("AAPL.NB") }},
+		{name: "LeaveAllの直後にDisconnectしてもunsubscribeがサーバーに届くこと", provider: QUODD, event: "unsubscribe", leave: func(sut *Client) { sut.LeaveAll() }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+
+			sut := server.newClient(tt.provider)
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			if tt.provider == IEX {
+				sut.Join("AAPL")
+			} else {
+				sut.Join("AAPL.NB")
+			}
+			tt.leave(sut)
+			if err := sut.Disconnect(); err != nil {
+				t.Fatalf("Disconnect() error = %v", err)
+			}
+			if !waitUntil(time.Second, func() bool { return len(server.messagesWithEvent(tt.event)) == 1 }) {
+				t.Errorf("%s was not received by the server", tt.event)
+			}
+		})
+	}
+}
+
+func TestWithHeartbeatInterval(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "指定しなければ3秒になること", want: heartbeatWait},
+		{name: "間隔を指定できること", opts: []Option{WithHeartbeatInterval(20 * time.Second)}, want: 20 * time.Second},
+		{name: "WithoutHeartbeatで無効にできること", opts: []Option{WithoutHeartbeat()}, want: 0},
+		{name: "0以下の間隔はエラーになること", opts: []Option{WithHeartbeatInterval(0)}, wantErr: true},
+		{name: "読み込みのデッドライン以上の間隔はエラーになること", opts: []Option{WithHeartbeatInterval(readWait)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := New(yourIntrinioAPIUserName, yourIntrinioAPIPassword, IEX, tt.opts...)
+			if (sut.optionErr != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", sut.optionErr, tt.wantErr)
+			}
+			if !tt.wantErr && sut.heartbeatInterval != tt.want {
+				t.Errorf("heartbeatInterval = %v, want %v", sut.heartbeatInterval, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientHeartbeatInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		want     time.Duration
+		wantSent int
+	}{
+		{name: "指定した間隔でハートビートが送られること", opts: []Option{WithHeartbeatInterval(15 * time.Second)}, want: 15 * time.Second, wantSent: 3},
+		{name: "WithoutHeartbeatのときはハートビートが送られないこと", opts: []Option{WithoutHeartbeat()}, wantSent: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+
+			intervals := make(chan time.Duration, 1)
+			ticks := make(chan time.Time)
+			sut := server.newClient(QUODD, append(tt.opts, WithMaxMissedHeartbeats(0), WithStaleTimeout(0))...)
+			sut.newTicker = func(d time.Duration) (<-chan time.Time, func()) {
+				intervals <- d
+				return ticks, func() {}
+			}
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			defer sut.Disconnect()
+
+			if tt.wantSent == 0 {
+				select {
+				case d := <-intervals:
+					t.Fatalf("heartbeat ticker started with %v", d)
+				case <-time.After(100 * time.Millisecond):
+				}
+				if got := len(server.messagesWithEvent("heartbeat")); got != 0 {
+					t.Errorf("heartbeats = %d, want 0", got)
+				}
+				return
+			}
+			select {
+			case d := <-intervals:
+				if d != tt.want {
+					t.Errorf("ticker interval = %v, want %v", d, tt.want)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("heartbeat ticker was not started")
+			}
+			for i := 0; i < tt.wantSent; i++ {
+				ticks <- time.Now()
+			}
+			if !waitUntil(time.Second, func() bool { return len(server.messagesWithEvent("heartbeat")) == tt.wantSent }) {
+				t.Errorf("heartbeats = %d, want %d", len(server.messagesWithEvent("heartbeat")), tt.wantSent)
+			}
+		})
+	}
+}
+
+func TestWithDeadlines(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []Option
+		wantRead  time.Duration
+		wantWrite time.Duration
+		wantErr   bool
+	}{
+		{name: "指定しなければ既定値になること", wantRead: readWait, wantWrite: writeWait},
+		{name: "デッドラインを指定できること", opts: []Option{WithReadDeadline(time.Minute), WithWriteDeadline(3 * time.Second)}, wantRead: time.Minute, wantWrite: 3 * time.Second},
+		{name: "0以下の読み込みデッドラインはエラーになること", opts: []Option{WithReadDeadline(0)}, wantErr: true},
+		{name: "0以下の書き込みデッドラインはエラーになること", opts: []Option{WithWriteDeadline(-time.Second)}, wantErr: true},
+		{name: "ハートビートの間隔より短い読み込みデッドラインはエラーになること", opts: []Option{WithReadDeadline(2 * time.Second)}, wantErr: true},
+		{name: "読み込みデッドラインより長い書き込みデッドラインはエラーになること", opts: []Option{WithWriteDeadline(time.Minute)}, wantErr: true},
+		{name: "オプションの順番に関係なく検証されること", opts: []Option{WithHeartbeatInterval(time.Minute), WithReadDeadline(2 * time.Minute)}, wantRead: 2 * time.Minute, wantWrite: writeWait},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := New(yourIntrinioAPIUserName, yourIntrinioAPIPassword, QUODD, tt.opts...)
+			if (sut.optionErr != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", sut.optionErr, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if sut.readDeadline != tt.wantRead || sut.writeDeadline != tt.wantWrite {
+				t.Errorf("deadlines = %v/%v, want %v/%v", sut.readDeadline, sut.writeDeadline, tt.wantRead, tt.wantWrite)
+			}
+		})
+	}
+}
+
+func TestClientReadDeadline(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+	server.setStall(true)
+
+	sut := server.newClient(QUODD, WithReadDeadline(300*time.Millisecond), WithWriteDeadline(100*time.Millisecond), WithoutHeartbeat(), WithoutReconnect())
+	start := time.Now()
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	select {
+	case <-sut.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the read deadline did not close the connection")
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("closed after %v, before the read deadline", elapsed)
+	}
+}
+
+func TestClientCloseHandshake(t *testing.T) {
+	tests := []struct {
+		name       string
+		disconnect func(sut *Client) error
+	}{
+		{name: "Disconnectするとサーバーに正常終了のクローズフレームが届くこと", disconnect: func(sut *Client) error { return sut.Disconnect() }},
+		{name: "DisconnectWithTimeoutでも正常終了のクローズフレームが届くこと", disconnect: func(sut *Client) error { return sut.DisconnectWithTimeout(5 * time.Second) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+
+			sut := server.newClient(IEX)
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			start := time.Now()
+			if err := tt.disconnect(sut); err != nil {
+				t.Fatalf("disconnect error = %v", err)
+			}
+			if elapsed := time.Since(start); closeWait <= elapsed {
+				t.Errorf("disconnect took %v, the close frame was not echoed", elapsed)
+			}
+			want := []int{websocket.CloseNormalClosure}
+			if !waitUntil(time.Second, func() bool { return reflect.DeepEqual(server.closeCodes(), want) }) {
+				t.Errorf("close codes = %v, want %v", server.closeCodes(), want)
+			}
+		})
+	}
+}
+
+func TestClientReuse(t *testing.T) {
+	tests := []struct {
+		name      string
+		joinAfter []string
+		want      []string
+	}{
+		{
+			name: "Disconnectした後に再びConnectするとJoinしていたチャンネルが再購読されること",
+			want: []string{"AAPL.NB", "AAPL.NB"},
+		},
+		{
+			name:      "切断中にJoinしたチャンネルも次のConnectで購読されること",
+			joinAfter: []string{"MSFT.NB"},
+			want:      []string{"AAPL.NB", "AAPL.NB", "MSFT.NB"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+			server.setReply(quoteOnSubscribe)
+
+			quotes := make(chan map[string]interface{}, 10)
+			sut := server.newClient(QUODD)
+			sut.OnQuote(func(data map[string]interface{}) {
+				quotes <- data
+			})
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			sut.Join("AAPL.NB")
+			<-quotes
+			if err := sut.Disconnect(); err != nil {
+				t.Fatalf("Disconnect() error = %v", err)
+			}
+			sut.Wait()
+
+			sut.Join(tt.joinAfter...)
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("second connect() error = %v", err)
+			}
+			defer sut.Disconnect()
+			select {
+			case <-sut.Done():
+				t.Fatal("Done() is closed after the second Connect")
+			default:
+			}
+			for range tt.want[1:] {
+				select {
+				case <-quotes:
+				case <-time.After(5 * time.Second):
+					t.Fatal("quotes did not resume")
+				}
+			}
+			got := server.subscribedTickers()
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("subscribed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientLeaveOnDisconnect(t *testing.T) {
+	t.Run("Disconnectすると購読中のチャンネルがすべてunsubscribeされること", func(t *testing.T) {
+		server := newFakeServer()
+		defer server.Close()
+
+		sut := server.newClient(QUODD)
+		if err := sut.Connect(); err != nil {
+			t.Fatalf("connect() error = %v", err)
+		}
+		sut.Join("AAPL.NB", "MSFT.NB")
+		if err := sut.Disconnect(); err != nil {
+			t.Fatalf("Disconnect() error = %v", err)
+		}
+		var got []string
+		waitUntil(time.Second, func() bool {
+			got = nil
+			for _, msg := range server.messagesWithEvent("unsubscribe") {
+				data, _ := msg["data"].(map[string]interface{})
+				ticker, _ := data["ticker"].(string)
+				got = append(got, ticker)
+			}
+			return len(got) == 2
+		})
+		sort.Strings(got)
+		if want := []string{"AAPL.NB", "MSFT.NB"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("unsubscribed = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("unsubscribeを送れなかったチャンネルがLeaveErrorで通知されること", func(t *testing.T) {
+		server := newFakeServer()
+		defer server.Close()
+		server.setStall(true)
+
+		leaveErr := make(chan *LeaveError, 1)
+		sut := server.newClient(QUODD, WithoutHeartbeat())
+		sut.OnError(func(err error) {
+			if le, ok := err.(*LeaveError); ok {
+				leaveErr <- le
+			}
+		})
+		if err := sut.Connect(); err != nil {
+			t.Fatalf("connect() error = %v", err)
+		}
+		sut.Join("AAPL.NB")
+		sut.mu.Lock()
+		q := sut.q
+		sut.mu.Unlock()
+		q <- outgoing{msg: map[string]interface{}{"data": strings.Repeat("x", 8<<20)}}
+		sut.DisconnectWithTimeout(200 * time.Millisecond)
+
+		select {
+		case le := <-leaveErr:
+			if want := []string{"AAPL.NB"}; !reflect.DeepEqual(le.Channels, want) {
+				t.Errorf("LeaveError.Channels = %v, want %v", le.Channels, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("LeaveError was not reported")
+		}
+	})
+}
+
+func TestClientJoinConcurrently(t *testing.T) {
+	tests := []struct {
+		name    string
+		connect bool
+	}{
+		{name: "接続前に複数のゴルーチンからJoinとLeaveを呼び出しても安全なこと", connect: false},
+		{name: "接続中に複数のゴルーチンからJoinとLeaveを呼び出しても安全なこと", connect: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+
+			sut := server.newClient(QUODD)
+			if tt.connect {
+				if err := sut.Connect(); err != nil {
+					t.Fatalf("connect() error = %v", err)
+				}
+			}
+			defer sut.Disconnect()
+
+			var wg sync.WaitGroup
+			var want []string
+			for i := 0; i < 50; i++ {
+				ticker := fmt.Sprintf("T%02d", i)
+				keep := i%2 == 0
+				if keep {
+					want = append(want, ticker)
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 20; j++ {
+						sut.Join(ticker, "AAPL")
+						sut.Leave(ticker)
+					}
+					if keep {
+						sut.Join(ticker)
+					}
+				}()
+			}
+			wg.Wait()
+
+			sut.mu.Lock()
+			var got []string
+			for k := range sut.channels {
+				if k != "AAPL" {
+					got = append(got, k)
+				}
+			}
+			sut.mu.Unlock()
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("channels = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestClientSwapHandlers(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	sut := server.newClient(QUODD)
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				server.broadcast(map[string]interface{}{
+					"event": "quote",
+					"data":  map[string]interface{}{"ticker": "AAPL", "bid_price_4d": 1594800},
+				})
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+	defer func() {
+		close(stop)
+		wg.Wait()
+	}()
+
+	t.Run("メッセージ受信中にハンドラーを差し替えてもデータ競合が発生しないこと", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			sut.OnQuote(func(map[string]interface{}) {})
+			sut.OnError(func(error) {})
+			sut.OnReconnect(func(error) {})
+		}
+	})
+	t.Run("差し替えたハンドラーがすぐに呼び出されること", func(t *testing.T) {
+		var got int32
+		sut.OnQuote(func(map[string]interface{}) { atomic.AddInt32(&got, 1) })
+		if !waitUntil(5*time.Second, func() bool { return 0 < atomic.LoadInt32(&got) }) {
+			t.Error("new OnQuote() handler was not called")
+		}
+	})
+}
+
+func TestClientHandlerPanic(t *testing.T) {
+	tests := []struct {
+		name       string
+		errorPanic bool
+	}{
+		{name: "OnQuoteがパニックしてもOnErrorに通知して受信を続けること"},
+		{name: "OnErrorがパニックしても受信を続けること", errorPanic: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+
+			errs := make(chan error, 10)
+			var quotes int32
+			sut := server.newClient(QUODD)
+			sut.OnQuote(func(map[string]interface{}) {
+				if atomic.AddInt32(&quotes, 1) == 1 {
+					var m map[string]int
+					m["boom"] = 1
+				}
+			})
+			sut.OnError(func(err error) {
+				errs <- err
+				if tt.errorPanic {
+					panic("error handler")
+				}
+			})
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			defer sut.Disconnect()
+
+			quote := map[string]interface{}{"event": "quote", "data": map[string]interface{}{"ticker": "AAPL"}}
+			server.broadcast(quote)
+			select {
+			case err := <-errs:
+				if pe, ok := err.(*HandlerPanicError); !ok || pe.Handler != "OnQuote" || len(pe.Stack) == 0 {
+					t.Errorf("OnError() err = %#v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("OnError() was not called")
+			}
+			server.broadcast(quote)
+			if !waitUntil(5*time.Second, func() bool { return atomic.LoadInt32(&quotes) == 2 }) {
+				t.Error("client stopped receiving after a handler panicked")
+			}
+		})
+	}
+}
+
+func TestClientEnqueue(t *testing.T) {
+	msg := map[string]interface{}{"event": "subscribe"}
+	tests := []struct {
+		name    string
+		full    bool
+		closed  bool
+		timeout time.Duration
+		want    error
+	}{
+		{name: "空きがあればキューに入ること", want: nil},
+		{name: "待たない設定ならすぐにErrSendQueueFullを返すこと", full: true, timeout: -1, want: ErrSendQueueFull},
+		{name: "待ち時間を過ぎたらErrSendQueueFullを返すこと", full: true, timeout: 10 * time.Millisecond, want: ErrSendQueueFull},
+		{name: "待っている間に切断されたらErrClientClosedを返すこと", full: true, closed: true, want: ErrClientClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := New(yourIntrinioAPIUserName, yourIntrinioAPIPassword, QUODD, WithSendQueueTimeout(tt.timeout))
+			q := make(chan outgoing, 1)
+			if tt.full {
+				q <- outgoing{msg: msg}
+			}
+			breakSender := make(chan struct{})
+			if tt.closed {
+				close(breakSender)
+			}
+			if got := sut.enqueue(q, breakSender, msg); got != tt.want {
+				t.Errorf("enqueue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientSendQueueFull(t *testing.T) {
+	errs := make(chan error, 10)
+	sut := New(yourIntrinioAPIUserName, yourIntrinioAPIPassword, QUODD, WithSendQueueSize(1), WithSendQueueTimeout(-1))
+	sut.OnError(func(err error) { errs <- err })
+	// A connection without a sender, so the queue only drains when we say so.
+	sut.channelInitialize()
+	sut.ws = &websocket.Conn{}
+	sut.subscribed = true
+
+	sut.Join("AAPL", "MSFT")
+	select {
+	case err := <-errs:
+		if de, ok := err.(*DroppedMessageError); !ok || de.Err != ErrSendQueueFull {
+			t.Errorf("OnError() err = %#v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnError() was not called")
+	}
+	first := (<-sut.q).msg.(map[string]interface{})
+	sut.Join()
+	select {
+	case second := <-sut.q:
+		got := []string{first["data"].(map[string]string)["ticker"], second.msg.(map[string]interface{})["data"].(map[string]string)["ticker"]}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, []string{"AAPL", "MSFT"}) {
+			t.Errorf("subscribed = %v, want [AAPL MSFT]", got)
+		}
+	default:
+		t.Error("dropped join was not retried")
+	}
+}
+
+// BenchmarkClientJoin measures how long joining 500 tickers holds up the
+// caller with different send queue sizes.
+func BenchmarkClientJoin(b *testing.B) {
+	tickers := make([]string, 500)
+	for i := range tickers {
+		tickers[i] = fmt.Sprintf("T%03d", i)
+	}
+	for _, size := range []int{0, 256, 1024} {
+		b.Run(fmt.Sprintf("queue=%d", size), func(b *testing.B) {
+			server := newFakeServer()
+			defer server.Close()
+			sut := server.newClient(QUODD, WithSendQueueSize(size))
+			if err := sut.Connect(); err != nil {
+				b.Fatalf("connect() error = %v", err)
+			}
+			defer sut.Disconnect()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				sut.Join(tickers...)
+				b.StopTimer()
+				sut.LeaveAll()
+				waitUntil(time.Second, func() bool { return len(sut.q) == 0 })
+				b.StartTimer()
+			}
+		})
+	}
+}
+
+func TestClientRefCountedSubscriptions(t *testing.T) {
+	tests := []struct {
+		name       string
+		refCounted bool
+		do         func(sut *Client)
+		want       int
+	}{
+		{name: "参照カウントなしでは一度のLeaveで抜けること", do: func(sut *Client) { sut.Join("AAPL", "AAPL"); sut.Leave("AAPL") }, want: 0},
+		{name: "参照カウントなしでは何度Joinしても1であること", do: func(sut *Client) { sut.Join("AAPL", "AAPL") }, want: 1},
+		{name: "参照カウントありではJoinの回数だけ数えること", refCounted: true, do: func(sut *Client) { sut.Join("AAPL", " AAPL") }, want: 2},
+		{name: "参照カウントありではLeaveで1つ減ること", refCounted: true, do: func(sut *Client) { sut.Join("AAPL", "AAPL"); sut.Leave("AAPL") }, want: 1},
+		{name: "参照カウントありでもLeaveAllで全て抜けること", refCounted: true, do: func(sut *Client) { sut.Join("AAPL", "AAPL"); sut.LeaveAll() }, want: 0},
+		{name: "参照カウントありでJoinしていないチャンネルをLeaveしても0のままであること", refCounted: true, do: func(sut *Client) { sut.Leave("AAPL") }, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.refCounted {
+				opts = append(opts, WithRefCountedSubscriptions())
+			}
+			sut := New(yourIntrinioAPIUserName, yourIntrinioAPIPassword, QUODD, opts...)
+			tt.do(sut)
+			if got := sut.SubscriptionRefs("AAPL"); got != tt.want {
+				t.Errorf("SubscriptionRefs() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientRefCountedSubscriptionsConcurrently(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	sut := server.newClient(QUODD, WithRefCountedSubscriptions())
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	// One subscriber keeps AAPL while the others come and go.
+	sut.Join("AAPL")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				sut.Join("AAPL", "MSFT")
+				sut.Leave("AAPL", "MSFT")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := sut.SubscriptionRefs("AAPL"); got != 1 {
+		t.Errorf("SubscriptionRefs(AAPL) = %d, want 1", got)
+	}
+	if got := sut.SubscriptionRefs("MSFT"); got != 0 {
+		t.Errorf("SubscriptionRefs(MSFT) = %d, want 0", got)
+	}
+	time.Sleep(100 * time.Millisecond)
+	for _, msg := range server.messagesWithEvent("unsubscribe") {
+		if data, _ := msg["data"].(map[string]interface{}); data["ticker"] == "AAPL" {
+			t.Fatalf("AAPL was left while a subscriber still held it: %v", msg)
+		}
+	}
+}
+
+func TestClientHeartbeatPriority(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial(server.soketURL(), nil)
+	if err != nil {
+		t.Fatalf("dial error = %v", err)
+	}
+	sut := New(yourIntrinioAPIUserName, yourIntrinioAPIPassword, QUODD, WithSendQueueSize(1000), WithPingInterval(-1))
+	sut.channelInitialize()
+	// A backlog of joins is already waiting when the heartbeat comes in.
+	for i := 0; i < 1000; i++ {
+		sut.q <- outgoing{msg: makeJoinMessage(QUODD, fmt.Sprintf("T%03d", i))}
+	}
+	sut.control <- outgoing{msg: makeHeartbeatMessage(QUODD)}
+	close(sut.hartbeated)
+	close(sut.receiverDone)
+	go sut.startSender(ws)
+
+	if !waitUntil(5*time.Second, func() bool { return 0 < len(server.messages()) }) {
+		t.Fatal("server received nothing")
+	}
+	t.Run("ジョインが溜まっていてもハートビートが先に送られること", func(t *testing.T) {
+		if got := server.messages()[0]["event"]; got != "heartbeat" {
+			t.Errorf("first message event = %v, want heartbeat", got)
+		}
+	})
+	t.Run("溜まっていたジョインも全て送られること", func(t *testing.T) {
+		if !waitUntil(5*time.Second, func() bool { return len(server.messagesWithEvent("subscribe")) == 1000 }) {
+			t.Errorf("subscribes = %d, want 1000", len(server.messagesWithEvent("subscribe")))
+		}
+	})
+	close(sut.breakSender)
+	<-sut.sended
+}
+
+func TestClientSubscriptionsMatchServer(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	// Without a buffer every refresh waits for the sender, which leaves
+	// plenty of room for concurrent refreshes to overtake each other.
+	sut := server.newClient(QUODD, WithSendQueueSize(0))
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	// Replay what the server saw, in order.
+	serverSide := func() []string {
+		set := map[string]bool{}
+		for _, msg := range server.messages() {
+			data, _ := msg["data"].(map[string]interface{})
+			ticker, _ := data["ticker"].(string)
+			switch msg["event"] {
+			case "subscribe":
+				set[ticker] = true
+			case "unsubscribe":
+				delete(set, ticker)
+			}
+		}
+		ret := []string{}
+		for k := range set {
+			ret = append(ret, k)
+		}
+		sort.Strings(ret)
+		return ret
+	}
+	var symbols []string
+	for i := 0; i < 40; i++ {
+		symbols = append(symbols, fmt.Sprintf("T%02d", i))
+	}
+	for round := 0; round < 50; round++ {
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				<-start
+				if (round+i)%2 == 0 {
+					sut.Join(symbols...)
+				} else {
+					sut.Leave(symbols[i*10:]...)
+				}
+			}(i)
+		}
+		close(start)
+		wg.Wait()
+		want := append([]string{}, sut.Subscriptions()...)
+		if !waitUntil(time.Second, func() bool { return reflect.DeepEqual(serverSide(), want) }) {
+			t.Fatalf("round %d: server subscriptions = %v, Subscriptions() = %v", round, serverSide(), want)
+		}
+	}
+}
+
+func TestClientWriteFailure(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	errs := make(chan error, 10)
+	reconnected := make(chan error, 1)
+	server.setStall(true)
+	sut := server.newClient(QUODD, WithWriteDeadline(100*time.Millisecond))
+	sut.OnError(func(err error) { errs <- err })
+	sut.OnReconnect(func(cause error) { reconnected <- cause })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+	// Only the first connection stalls.
+	server.setStall(false)
+
+	// A frame far larger than the socket buffers makes the write time out,
+	// and the join queued behind it can't be written on that connection.
+	sut.mu.Lock()
+	q := sut.q
+	sut.mu.Unlock()
+	q <- outgoing{msg: map[string]interface{}{"event": "bulk", "data": strings.Repeat("x", 64<<20)}}
+	sut.Join("AAPL")
+
+	select {
+	case err := <-errs:
+		if we, ok := err.(*WriteError); !ok || we.Event != "bulk" {
+			t.Errorf("OnError() err = %#v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnError() was not called")
+	}
+	select {
+	case <-reconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("client did not reconnect after the write failed")
+	}
+	if !waitUntil(5*time.Second, func() bool { return 0 < len(server.subscribedTickers()) }) {
+		t.Fatal("join never reached the server")
+	}
+	if got := server.subscribedTickers(); !reflect.DeepEqual(got, []string{"AAPL"}) {
+		t.Errorf("subscribed = %v, want [AAPL]", got)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  map[string]interface{}
+		want string
+	}{
+		{name: "QUODDのJoinはティッカーを示すこと", msg: makeJoinMessage(QUODD, "AAPL"), want: "write subscribe for AAPL failed: boom"},
+		{name: "板情報のJoinは板情報のチャンネルを示すこと", msg: makeJoinMessage(QUODD, DepthChannel("AAPL")), want: "write subscribe for $depth:AAPL failed: boom"},
+		{name: "IEXのLeaveはトピックを示すこと", msg: makeLeaveMessage(IEX, "GE"), want: "write phx_leave for iex:securities:GE failed: boom"},
+		{name: "チャンネルのないメッセージはイベントだけ示すこと", msg: makeHeartbeatMessage(QUODD), want: "write heartbeat failed: boom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newWriteError(tt.msg, errors.New("boom")).Error(); got != tt.want {
+				t.Errorf("Error() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
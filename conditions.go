@@ -0,0 +1,86 @@
+package intriniorealtime
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TradeConditions are the sale condition flags IEX sends with a trade, in
+// the bit layout of IEX's own feed. Bits without a name here are kept as
+// they came, so conditions added later can still be logged.
+type TradeConditions uint32
+
+// The sale conditions IEX defines.
+const (
+	ConditionSinglePriceCross   TradeConditions = 0x08
+	ConditionTradeThroughExempt TradeConditions = 0x10
+	ConditionOddLot             TradeConditions = 0x20
+	ConditionExtendedHours      TradeConditions = 0x40
+	ConditionIntermarketSweep   TradeConditions = 0x80
+)
+
+var conditionNames = []struct {
+	flag TradeConditions
+	name string
+}{
+	{ConditionIntermarketSweep, "intermarket sweep"},
+	{ConditionExtendedHours, "extended hours"},
+	{ConditionOddLot, "odd lot"},
+	{ConditionTradeThroughExempt, "trade through exempt"},
+	{ConditionSinglePriceCross, "single-price cross"},
+}
+
+// knownConditions has every named bit set.
+const knownConditions = ConditionSinglePriceCross | ConditionTradeThroughExempt | ConditionOddLot | ConditionExtendedHours | ConditionIntermarketSweep
+
+// Has reports whether all flags are set.
+func (c TradeConditions) Has(flags TradeConditions) bool {
+	return c&flags == flags
+}
+
+// IsOddLot reports a trade of fewer than a round lot.
+func (c TradeConditions) IsOddLot() bool { return c.Has(ConditionOddLot) }
+
+// IsExtendedHours reports a trade outside regular market hours.
+func (c TradeConditions) IsExtendedHours() bool { return c.Has(ConditionExtendedHours) }
+
+// IsIntermarketSweep reports an intermarket sweep order.
+func (c TradeConditions) IsIntermarketSweep() bool { return c.Has(ConditionIntermarketSweep) }
+
+// IsTradeThroughExempt reports a trade exempt from the trade-through rule.
+func (c TradeConditions) IsTradeThroughExempt() bool { return c.Has(ConditionTradeThroughExempt) }
+
+// IsSinglePriceCross reports an opening, closing or halt cross.
+func (c TradeConditions) IsSinglePriceCross() bool { return c.Has(ConditionSinglePriceCross) }
+
+// Unknown returns the bits that have no name in this package.
+func (c TradeConditions) Unknown() TradeConditions {
+	return c &^ knownConditions
+}
+
+// String lists the conditions by name, followed by any unknown bits in hex.
+func (c TradeConditions) String() string {
+	var names []string
+	for _, n := range conditionNames {
+		if c.Has(n.flag) {
+			names = append(names, n.name)
+		}
+	}
+	if u := c.Unknown(); u != 0 {
+		names = append(names, fmt.Sprintf("%#x", uint32(u)))
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
+
+// parseConditions reads the "conditions" field of an IEX payload, the flags
+// as a number.
+func parseConditions(v interface{}) TradeConditions {
+	n, ok := integer(v)
+	if !ok || n < 0 {
+		return 0
+	}
+	return TradeConditions(n)
+}
@@ -0,0 +1,59 @@
+package intriniorealtime
+
+import "testing"
+
+func TestParseConditions(t *testing.T) {
+	tests := []struct {
+		name        string
+		raw         interface{}
+		want        TradeConditions
+		wantOddLot  bool
+		wantExtHrs  bool
+		wantUnknown TradeConditions
+		wantString  string
+	}{
+		{name: "条件がなければ0にすること", raw: nil, wantString: "none"},
+		{name: "端株を読むこと", raw: float64(0x20), want: ConditionOddLot, wantOddLot: true, wantString: "odd lot"},
+		{name: "時間外を読むこと", raw: float64(0x40), want: ConditionExtendedHours, wantExtHrs: true, wantString: "extended hours"},
+		{
+			name:       "複数の条件を読むこと",
+			raw:        float64(0x80 | 0x40 | 0x20),
+			want:       ConditionIntermarketSweep | ConditionExtendedHours | ConditionOddLot,
+			wantOddLot: true,
+			wantExtHrs: true,
+			wantString: "intermarket sweep, extended hours, odd lot",
+		},
+		{name: "クロスと除外を読むこと", raw: float64(0x18), want: ConditionTradeThroughExempt | ConditionSinglePriceCross, wantString: "trade through exempt, single-price cross"},
+		{
+			name:        "知らないビットも残すこと",
+			raw:         float64(0x201),
+			want:        0x201,
+			wantUnknown: 0x201,
+			wantString:  "0x201",
+		},
+		{
+			name:        "知っているビットと知らないビットを分けること",
+			raw:         float64(0x24),
+			want:        ConditionOddLot | 0x04,
+			wantOddLot:  true,
+			wantUnknown: 0x04,
+			wantString:  "odd lot, 0x4",
+		},
+		{name: "負の値は0にすること", raw: float64(-1), wantString: "none"},
+		{name: "数値でなければ0にすること", raw: "I", wantString: "none"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseConditions(tt.raw)
+			if got != tt.want {
+				t.Errorf("parseConditions(%v) = %#x, want %#x", tt.raw, uint32(got), uint32(tt.want))
+			}
+			if got.IsOddLot() != tt.wantOddLot || got.IsExtendedHours() != tt.wantExtHrs || got.Unknown() != tt.wantUnknown {
+				t.Errorf("IsOddLot() = %v, IsExtendedHours() = %v, Unknown() = %#x", got.IsOddLot(), got.IsExtendedHours(), uint32(got.Unknown()))
+			}
+			if got.String() != tt.wantString {
+				t.Errorf("String() = %q, want %q", got, tt.wantString)
+			}
+		})
+	}
+}
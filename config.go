@@ -0,0 +1,214 @@
+package intriniorealtime
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// providers are the built-in providers in the order ParseProvider lists
+// them.
+var providers = []provider{IEX, QUODD, REALTIME, DELAYED_SIP, NASDAQ_BASIC, OPRA, CRYPTOQUOTE, FXCM, MANUAL, SIMULATED}
+
+// ParseProvider returns the provider named s, such as "iex" or
+// "NASDAQ_BASIC", in any case and with dashes for underscores.
+func ParseProvider(s string) (provider, error) {
+	name := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(s), "-", "_"))
+	names := make([]string, len(providers))
+	for i, p := range providers {
+		if string(p) == name {
+			return p, nil
+		}
+		names[i] = strings.ToUpper(string(p))
+	}
+	if name == "" {
+		return "", fmt.Errorf("no provider given, want one of %s", strings.Join(names, ", "))
+	}
+	return "", fmt.Errorf("unknown provider %q, want one of %s", s, strings.Join(names, ", "))
+}
+
+// Config is what NewFromConfig makes a client with. Zero fields keep the
+// defaults of the options they stand for.
+type Config struct {
+	// Username and Password, or APIKey, are the credentials. OPRA,
+	// CRYPTOQUOTE and FXCM also take the API key alone as the Username.
+	Username string
+	Password string
+	APIKey   string
+
+	// Provider names a provider the way ParseProvider reads it.
+	Provider string
+
+	Debug bool // see WithDebug
+
+	AuthURL      string // see WithAuthURL
+	WebsocketURL string // see WithWebsocketURL
+
+	ReadDeadline      time.Duration // see WithReadDeadline
+	WriteDeadline     time.Duration // see WithWriteDeadline
+	HeartbeatInterval time.Duration // see WithHeartbeatInterval
+	StaleTimeout      time.Duration // see WithStaleTimeout
+	SendQueueSize     int           // see WithSendQueueSize
+}
+
+// NewFromConfig makes a client with the credentials, provider and options
+// of c, followed by opts. A field that is wrong is a *ConfigError naming
+// it, as is the MANUAL provider, whose endpoint only WithManualEndpoint
+// gives; fields that don't fit together, such as a heartbeat interval beyond
+// the read deadline, are the error NewWithOptions returns for them.
+func NewFromConfig(c Config, opts ...Option) (*Client, error) {
+	p, err := ParseProvider(c.Provider)
+	if err != nil {
+		return nil, &ConfigError{Field: "Provider", Err: err}
+	}
+	if p == MANUAL {
+		return nil, &ConfigError{Field: "Provider", Err: fmt.Errorf("%s needs WithManualEndpoint, use NewWithOptions", p)}
+	}
+	switch {
+	case c.APIKey != "" && (c.Username != "" || c.Password != ""):
+		return nil, &ConfigError{Field: "APIKey", Err: ErrConflictingAuth}
+	case c.APIKey == "" && c.Username == "" && p != SIMULATED:
+		return nil, &ConfigError{Field: "Username", Err: fmt.Errorf("a username or an API key is required for %s", p)}
+	case c.Username != "" && c.Password == "" && passwordAuth(p):
+		return nil, &ConfigError{Field: "Password", Err: fmt.Errorf("a password is required with the username for %s", p)}
+	}
+	var config []Option
+	add := func(field string, opt Option) {
+		config = append(config, func(cli *Client) error {
+			if err := opt(cli); err != nil {
+				return &ConfigError{Field: field, Err: err}
+			}
+			return nil
+		})
+	}
+	if c.APIKey != "" {
+		add("APIKey", WithAPIKey(c.APIKey))
+	}
+	if c.Debug {
+		add("Debug", WithDebug())
+	}
+	if c.AuthURL != "" {
+		add("AuthURL", WithAuthURL(c.AuthURL))
+	}
+	if c.WebsocketURL != "" {
+		add("WebsocketURL", WithWebsocketURL(c.WebsocketURL))
+	}
+	if c.ReadDeadline != 0 {
+		add("ReadDeadline", WithReadDeadline(c.ReadDeadline))
+	}
+	if c.WriteDeadline != 0 {
+		add("WriteDeadline", WithWriteDeadline(c.WriteDeadline))
+	}
+	if c.HeartbeatInterval != 0 {
+		add("HeartbeatInterval", WithHeartbeatInterval(c.HeartbeatInterval))
+	}
+	if c.StaleTimeout != 0 {
+		add("StaleTimeout", WithStaleTimeout(c.StaleTimeout))
+	}
+	if c.SendQueueSize != 0 {
+		add("SendQueueSize", WithSendQueueSize(c.SendQueueSize))
+	}
+	return NewWithOptions(c.Username, c.Password, p, append(config, opts...)...)
+}
+
+// passwordAuth reports the providers whose Username needs a Password.
+func passwordAuth(p provider) bool {
+	switch p {
+	case IEX, QUODD, REALTIME, DELAYED_SIP, NASDAQ_BASIC:
+		return true
+	}
+	return false
+}
+
+// envVars are the environment variables NewFromEnv reads, by the Config
+// field they set.
+var envVars = []struct{ name, field string }{
+	{"INTRINIO_USERNAME", "Username"},
+	{"INTRINIO_PASSWORD", "Password"},
+	{"INTRINIO_API_KEY", "APIKey"},
+	{"INTRINIO_PROVIDER", "Provider"},
+	{"INTRINIO_DEBUG", "Debug"},
+	{"INTRINIO_AUTH_URL", "AuthURL"},
+	{"INTRINIO_WEBSOCKET_URL", "WebsocketURL"},
+	{"INTRINIO_READ_DEADLINE", "ReadDeadline"},
+	{"INTRINIO_WRITE_DEADLINE", "WriteDeadline"},
+	{"INTRINIO_HEARTBEAT_INTERVAL", "HeartbeatInterval"},
+	{"INTRINIO_STALE_TIMEOUT", "StaleTimeout"},
+	{"INTRINIO_SEND_QUEUE_SIZE", "SendQueueSize"},
+}
+
+// NewFromEnv is NewFromConfig with the Config the environment describes:
+// INTRINIO_USERNAME, INTRINIO_PASSWORD or INTRINIO_API_KEY, and
+// INTRINIO_PROVIDER, as well as INTRINIO_DEBUG, a bool such as "true",
+// INTRINIO_AUTH_URL, INTRINIO_WEBSOCKET_URL, the durations such as "45s" of
+// INTRINIO_READ_DEADLINE, INTRINIO_WRITE_DEADLINE,
+// INTRINIO_HEARTBEAT_INTERVAL and INTRINIO_STALE_TIMEOUT, and
+// INTRINIO_SEND_QUEUE_SIZE. A variable that is wrong is a *ConfigError
+// naming it.
+func NewFromEnv(opts ...Option) (*Client, error) {
+	return newFromEnv(os.Getenv, opts...)
+}
+
+func newFromEnv(getenv func(string) string, opts ...Option) (*Client, error) {
+	c, err := configFromEnv(getenv)
+	if err != nil {
+		return nil, err
+	}
+	cli, err := NewFromConfig(c, opts...)
+	if ce, ok := err.(*ConfigError); ok {
+		for _, v := range envVars {
+			if v.field == ce.Field {
+				return nil, &ConfigError{Field: v.name, Err: ce.Err}
+			}
+		}
+	}
+	return cli, err
+}
+
+func configFromEnv(getenv func(string) string) (Config, error) {
+	c := Config{
+		Username:     getenv("INTRINIO_USERNAME"),
+		Password:     getenv("INTRINIO_PASSWORD"),
+		APIKey:       getenv("INTRINIO_API_KEY"),
+		Provider:     getenv("INTRINIO_PROVIDER"),
+		AuthURL:      getenv("INTRINIO_AUTH_URL"),
+		WebsocketURL: getenv("INTRINIO_WEBSOCKET_URL"),
+	}
+	if s := getenv("INTRINIO_DEBUG"); s != "" {
+		debug, err := strconv.ParseBool(s)
+		if err != nil {
+			return Config{}, &ConfigError{Field: "INTRINIO_DEBUG", Err: fmt.Errorf("%q is not a bool", s)}
+		}
+		c.Debug = debug
+	}
+	durations := []struct {
+		name string
+		d    *time.Duration
+	}{
+		{"INTRINIO_READ_DEADLINE", &c.ReadDeadline},
+		{"INTRINIO_WRITE_DEADLINE", &c.WriteDeadline},
+		{"INTRINIO_HEARTBEAT_INTERVAL", &c.HeartbeatInterval},
+		{"INTRINIO_STALE_TIMEOUT", &c.StaleTimeout},
+	}
+	for _, v := range durations {
+		s := getenv(v.name)
+		if s == "" {
+			continue
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return Config{}, &ConfigError{Field: v.name, Err: fmt.Errorf("%q is not a duration", s)}
+		}
+		*v.d = d
+	}
+	if s := getenv("INTRINIO_SEND_QUEUE_SIZE"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return Config{}, &ConfigError{Field: "INTRINIO_SEND_QUEUE_SIZE", Err: fmt.Errorf("%q is not a number", s)}
+		}
+		c.SendQueueSize = n
+	}
+	return c, nil
+}
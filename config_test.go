@@ -0,0 +1,162 @@
+package intriniorealtime
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseProvider(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    provider
+		wantErr bool
+	}{
+		{name: "小文字の名前を読めること", s: "iex", want: IEX},
+		{name: "大文字の名前を読めること", s: "NASDAQ_BASIC", want: NASDAQ_BASIC},
+		{name: "ハイフンと空白を許すこと", s: " Delayed-Sip ", want: DELAYED_SIP},
+		{name: "知らない名前はエラーになること", s: "nyse", wantErr: true},
+		{name: "空の名前はエラーになること", s: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseProvider(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseProvider() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "IEX, QUODD, REALTIME") {
+				t.Errorf("ParseProvider() error = %v, want the valid names", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseProvider() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFromConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		wantField string
+	}{
+		{name: "ユーザー名とパスワードで作れること", config: Config{Username: "user", Password: "pass", Provider: "iex"}},
+		{name: "APIキーで作れること", config: Config{APIKey: "key", Provider: "REALTIME", Debug: true, SendQueueSize: 8}},
+		{name: "SIMULATEDは資格情報なしで作れること", config: Config{Provider: "simulated"}},
+		{name: "知らないプロバイダを示すこと", config: Config{Username: "user", Provider: "nyse"}, wantField: "Provider"},
+		{name: "エンドポイントのないMANUALを示すこと", config: Config{Username: "user", Provider: "manual"}, wantField: "Provider"},
+		{name: "資格情報がなければ示すこと", config: Config{Provider: "iex"}, wantField: "Username"},
+		{name: "REALTIMEのパスワードがなければ示すこと", config: Config{Username: "user", Provider: "realtime"}, wantField: "Password"},
+		{name: "DELAYED_SIPのパスワードがなければ示すこと", config: Config{Username: "user", Provider: "delayed_sip"}, wantField: "Password"},
+		{name: "NASDAQ_BASICのパスワードがなければ示すこと", config: Config{Username: "user", Provider: "nasdaq_basic"}, wantField: "Password"},
+		{name: "QUODDのパスワードがなければ示すこと", config: Config{Username: "user", Provider: "quodd"}, wantField: "Password"},
+		{name: "IEXのパスワードがなければ示すこと", config: Config{Username: "user", Provider: "iex"}, wantField: "Password"},
+		{name: "OPRAはAPIキーをユーザー名で作れること", config: Config{Username: "key", Provider: "opra"}},
+		{name: "APIキーとユーザー名の両方を示すこと", config: Config{Username: "user", APIKey: "key", Provider: "iex"}, wantField: "APIKey"},
+		{name: "不正なURLを示すこと", config: Config{APIKey: "key", Provider: "iex", WebsocketURL: "http://example.com"}, wantField: "WebsocketURL"},
+		{name: "負のキューの大きさを示すこと", config: Config{APIKey: "key", Provider: "iex", SendQueueSize: -1}, wantField: "SendQueueSize"},
+		{name: "負のデッドラインを示すこと", config: Config{APIKey: "key", Provider: "iex", ReadDeadline: -time.Second}, wantField: "ReadDeadline"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut, err := NewFromConfig(tt.config)
+			var ce *ConfigError
+			if tt.wantField == "" {
+				if err != nil || sut == nil {
+					t.Fatalf("NewFromConfig() = %v, %v, want a client", sut, err)
+				}
+				if sut.DebugMode != tt.config.Debug {
+					t.Errorf("DebugMode = %v, want %v", sut.DebugMode, tt.config.Debug)
+				}
+				return
+			}
+			if !errors.As(err, &ce) || ce.Field != tt.wantField {
+				t.Errorf("NewFromConfig() error = %v, want a *ConfigError for %s", err, tt.wantField)
+			}
+			if sut != nil {
+				t.Errorf("NewFromConfig() = %v with an error", sut)
+			}
+		})
+	}
+
+	sut, err := NewFromConfig(Config{APIKey: "key", Provider: "opra", HeartbeatInterval: 5 * time.Second, SendQueueSize: 8}, WithDataTypes(TradesOnly))
+	if err != nil {
+		t.Fatalf("NewFromConfig() error = %v", err)
+	}
+	if sut.provider != OPRA || sut.apiKey != "key" || sut.heartbeatInterval != 5*time.Second || sut.sendQueueSize != 8 || sut.dataTypes != TradesOnly {
+		t.Errorf("NewFromConfig() = %+v, want the config and options applied", sut)
+	}
+}
+
+func TestNewFromEnv(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		wantField string
+	}{
+		{
+			name: "環境変数から作れること",
+			env:  map[string]string{"INTRINIO_USERNAME": "user", "INTRINIO_PASSWORD": "pass", "INTRINIO_PROVIDER": "QUODD", "INTRINIO_DEBUG": "true", "INTRINIO_READ_DEADLINE": "45s"},
+		},
+		{
+			name:      "プロバイダがなければ環境変数を示すこと",
+			env:       map[string]string{"INTRINIO_API_KEY": "key"},
+			wantField: "INTRINIO_PROVIDER",
+		},
+		{
+			name:      "MANUALは環境変数を示すこと",
+			env:       map[string]string{"INTRINIO_API_KEY": "key", "INTRINIO_PROVIDER": "MANUAL"},
+			wantField: "INTRINIO_PROVIDER",
+		},
+		{
+			name:      "パスワードがなければ環境変数を示すこと",
+			env:       map[string]string{"INTRINIO_USERNAME": "user", "INTRINIO_PROVIDER": "NASDAQ_BASIC"},
+			wantField: "INTRINIO_PASSWORD",
+		},
+		{
+			name:      "真偽値でなければ環境変数を示すこと",
+			env:       map[string]string{"INTRINIO_API_KEY": "key", "INTRINIO_PROVIDER": "iex", "INTRINIO_DEBUG": "yes please"},
+			wantField: "INTRINIO_DEBUG",
+		},
+		{
+			name:      "期間でなければ環境変数を示すこと",
+			env:       map[string]string{"INTRINIO_API_KEY": "key", "INTRINIO_PROVIDER": "iex", "INTRINIO_STALE_TIMEOUT": "60"},
+			wantField: "INTRINIO_STALE_TIMEOUT",
+		},
+		{
+			name:      "数でなければ環境変数を示すこと",
+			env:       map[string]string{"INTRINIO_API_KEY": "key", "INTRINIO_PROVIDER": "iex", "INTRINIO_SEND_QUEUE_SIZE": "many"},
+			wantField: "INTRINIO_SEND_QUEUE_SIZE",
+		},
+		{
+			name:      "オプションの誤りも環境変数で示すこと",
+			env:       map[string]string{"INTRINIO_API_KEY": "key", "INTRINIO_PROVIDER": "iex", "INTRINIO_AUTH_URL": "ftp://example.com"},
+			wantField: "INTRINIO_AUTH_URL",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut, err := newFromEnv(func(name string) string { return tt.env[name] })
+			var ce *ConfigError
+			if tt.wantField == "" {
+				if err != nil || sut == nil {
+					t.Fatalf("newFromEnv() = %v, %v, want a client", sut, err)
+				}
+				if sut.provider != QUODD || !sut.DebugMode || sut.readDeadline != 45*time.Second {
+					t.Errorf("newFromEnv() = %+v, want the environment applied", sut)
+				}
+				return
+			}
+			if !errors.As(err, &ce) || ce.Field != tt.wantField {
+				t.Errorf("newFromEnv() error = %v, want a *ConfigError for %s", err, tt.wantField)
+			}
+		})
+	}
+
+	t.Setenv("INTRINIO_PROVIDER", "simulated")
+	if _, err := NewFromEnv(); err != nil {
+		t.Errorf("NewFromEnv() error = %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package intriniorealtime
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The topics of CRYPTOQUOTE channels: the consolidated book of a pair, and
+// a pair on one exchange.
+const (
+	cryptoPairPrefix   = "crypto:pair:"
+	cryptoMarketPrefix = "crypto:market:"
+)
+
+// cryptoExchangeSep separates the pair of a CRYPTOQUOTE channel from the
+// exchange it is scoped to, as in "BTC-USD@COINBASE".
+const cryptoExchangeSep = "@"
+
+// CryptoChannel returns the CRYPTOQUOTE channel of pair, a base and a quote
+// currency such as "BTC-USD", on exchange, or the consolidated book of all
+// exchanges when exchange is empty. It can be passed to Join and Leave like
+// any other channel.
+func CryptoChannel(pair, exchange string) string {
+	if strings.TrimSpace(exchange) == "" {
+		return normalizeChannel(pair)
+	}
+	return normalizeChannel(strings.TrimSpace(pair) + cryptoExchangeSep + strings.TrimSpace(exchange))
+}
+
+// splitCryptoChannel returns the pair and the exchange of a CRYPTOQUOTE
+// channel, an empty exchange for the consolidated book.
+func splitCryptoChannel(channel string) (pair, exchange string) {
+	if i := strings.Index(channel, cryptoExchangeSep); 0 <= i {
+		return channel[:i], channel[i+len(cryptoExchangeSep):]
+	}
+	return channel, ""
+}
+
+// cryptoTopic returns the topic of a CRYPTOQUOTE channel.
+func cryptoTopic(channel string) string {
+	pair, exchange := splitCryptoChannel(channel)
+	if exchange == "" {
+		return cryptoPairPrefix + pair
+	}
+	return cryptoMarketPrefix + exchange + ":" + pair
+}
+
+// cryptoTopicChannel returns the channel of a CRYPTOQUOTE topic, the reverse
+// of cryptoTopic, or "" for the topics that aren't channels.
+func cryptoTopicChannel(topic string) string {
+	switch {
+	case strings.HasPrefix(topic, cryptoPairPrefix):
+		return topic[len(cryptoPairPrefix):]
+	case strings.HasPrefix(topic, cryptoMarketPrefix):
+		rest := topic[len(cryptoMarketPrefix):]
+		if i := strings.Index(rest, ":"); 0 < i {
+			return rest[i+1:] + cryptoExchangeSep + rest[:i]
+		}
+	}
+	return ""
+}
+
+// validateCryptoChannel checks a normalized CRYPTOQUOTE channel: a pair of
+// two currency codes of two to ten letters and digits, optionally scoped to
+// an exchange of letters, digits and underscores.
+func validateCryptoChannel(channel string) error {
+	invalid := func(reason string) error {
+		return &SymbolError{Symbol: channel, Reason: reason}
+	}
+	pair, exchange := splitCryptoChannel(channel)
+	currencies := strings.Split(pair, "-")
+	if len(currencies) != 2 || !cryptoCurrency(currencies[0]) || !cryptoCurrency(currencies[1]) {
+		return invalid("not a pair of currencies such as BTC-USD")
+	}
+	if strings.Contains(channel, cryptoExchangeSep) && !cryptoExchange(exchange) {
+		return invalid("not an exchange")
+	}
+	return nil
+}
+
+func cryptoCurrency(s string) bool {
+	if len(s) < 2 || 10 < len(s) {
+		return false
+	}
+	for _, r := range s {
+		if !('A' <= r && r <= 'Z' || '0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func cryptoExchange(s string) bool {
+	if s == "" || 20 < len(s) {
+		return false
+	}
+	for _, r := range s {
+		if !('A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// makeCryptoHeartbeat returns a CRYPTOQUOTE heartbeat. Unlike the other
+// phoenix servers Cryptoquote only answers heartbeats that carry a ref, and
+// closes connections whose heartbeats went unanswered for a while; the ref
+// is the time it was sent, which keeps it unique.
+func makeCryptoHeartbeat() map[string]interface{} {
+	return map[string]interface{}{
+		"topic":   "phoenix",
+		"event":   "heartbeat",
+		"payload": map[string]interface{}{},
+		"ref":     strconv.FormatInt(time.Now().UnixNano(), 10),
+	}
+}
+
+// CryptoTrade is a trade of a crypto pair on an exchange.
+type CryptoTrade struct {
+	Pair         string // e.g. "BTC-USD"
+	Exchange     string // as the feed names it, e.g. "coinbase"
+	Price        float64
+	Size         float64 // in the base currency
+	TakerSide    string  // "buy" or "sell", empty when the exchange doesn't say
+	Timestamp    time.Time
+	RawTimestamp RawTime
+
+	// FixedPrice is Price, exactly, with WithFixedPointPrices, when it has
+	// no more than four decimals.
+	FixedPrice Price
+
+	// Raw is the payload as received; don't modify it.
+	Raw map[string]interface{}
+}
+
+// CryptoBookUpdate is a change of a price level of a crypto pair's order
+// book, on an exchange or the consolidated book.
+type CryptoBookUpdate struct {
+	Pair         string
+	Exchange     string // empty for the consolidated book
+	Side         string // "bid" or "ask"
+	Price        float64
+	Size         float64 // the new size of the level, 0 when it was removed
+	Timestamp    time.Time
+	RawTimestamp RawTime
+
+	// FixedPrice is Price, exactly, with WithFixedPointPrices, when it has
+	// no more than four decimals.
+	FixedPrice Price
+
+	// Raw is the payload as received; don't modify it.
+	Raw map[string]interface{}
+}
+
+// OnCryptoTrade registers a handler for CRYPTOQUOTE trades. They go to
+// OnQuote as well, but not to OnTrade.
+func (cli *Client) OnCryptoTrade(f func(trade CryptoTrade)) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
+	cli.cryptoTradeHandler = f
+}
+
+// OnCryptoBookUpdate registers a handler for CRYPTOQUOTE order book updates.
+// They go to OnQuote as well, but not to OnBid and OnAsk.
+func (cli *Client) OnCryptoBookUpdate(f func(update CryptoBookUpdate)) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
+	cli.cryptoBookHandler = f
+}
+
+// cryptoPayload returns the payload of msg if it is a CRYPTOQUOTE message
+// with one of the given events.
+//
+// CRYPTOQUOTE sends trades as trade events whose payload has the pair, the
+// exchange, the price, the size in the base currency, the taker's side, buy
+// or sell, and the timestamp in milliseconds since the epoch. Order book
+// changes are book_update events with the pair, the exchange, which is
+// absent on the consolidated book, the side, bid or ask, the price, the new
+// size of the level and the timestamp.
+func cryptoPayload(provider provider, msg map[string]interface{}, events ...string) (map[string]interface{}, bool) {
+	if provider != CRYPTOQUOTE {
+		return nil, false
+	}
+	for _, event := range events {
+		if msg["event"] == event {
+			payload, ok := msg["payload"].(map[string]interface{})
+			return payload, ok
+		}
+	}
+	return nil, false
+}
+
+// parseCryptoTrade returns the trade carried by msg, if it is one.
+func parseCryptoTrade(provider provider, msg map[string]interface{}) (CryptoTrade, bool) {
+	payload, ok := cryptoPayload(provider, msg, "trade")
+	if !ok {
+		return CryptoTrade{}, false
+	}
+	trade := CryptoTrade{Raw: payload}
+	trade.Pair, _ = payload["pair"].(string)
+	trade.Exchange, _ = payload["exchange"].(string)
+	trade.Price, _ = number(payload["price"])
+	trade.FixedPrice, _ = fixedPrice(payload["price"])
+	trade.Size, _ = number(payload["size"])
+	trade.TakerSide, _ = payload["side"].(string)
+	at := payload["timestamp"]
+	trade.Timestamp, trade.RawTimestamp = unixTime(at, timeUnit(provider, "timestamp", at))
+	return trade, true
+}
+
+// parseCryptoBookUpdate returns the order book update carried by msg, if it
+// is one.
+func parseCryptoBookUpdate(provider provider, msg map[string]interface{}) (CryptoBookUpdate, bool) {
+	payload, ok := cryptoPayload(provider, msg, "book_update")
+	if !ok {
+		return CryptoBookUpdate{}, false
+	}
+	update := CryptoBookUpdate{Raw: payload}
+	update.Pair, _ = payload["pair"].(string)
+	update.Exchange, _ = payload["exchange"].(string)
+	update.Side, _ = payload["side"].(string)
+	update.Price, _ = number(payload["price"])
+	update.FixedPrice, _ = fixedPrice(payload["price"])
+	update.Size, _ = number(payload["size"])
+	at := payload["timestamp"]
+	update.Timestamp, update.RawTimestamp = unixTime(at, timeUnit(provider, "timestamp", at))
+	return update, true
+}
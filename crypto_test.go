@@ -0,0 +1,164 @@
+package intriniorealtime
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// cryptoDialect answers like the Cryptoquote server: every join gets a
+// phx_reply and then a trade, and only heartbeats with a ref are answered.
+func cryptoDialect(trade []byte) func(s *fakeServer, conn *websocket.Conn, msg map[string]interface{}) {
+	return func(s *fakeServer, conn *websocket.Conn, msg map[string]interface{}) {
+		switch msg["event"] {
+		case "heartbeat":
+			if msg["ref"] == nil {
+				return
+			}
+			s.send(conn, map[string]interface{}{
+				"topic":   "phoenix",
+				"event":   "phx_reply",
+				"payload": map[string]interface{}{"status": "ok", "response": map[string]interface{}{}},
+				"ref":     msg["ref"],
+			})
+		case "phx_join":
+			s.send(conn, map[string]interface{}{
+				"topic":   msg["topic"],
+				"event":   "phx_reply",
+				"payload": map[string]interface{}{"status": "ok", "response": map[string]interface{}{}},
+				"ref":     nil,
+			})
+			s.mu.Lock()
+			conn.WriteMessage(websocket.TextMessage, trade)
+			s.mu.Unlock()
+		}
+	}
+}
+
+func TestCryptoChannel(t *testing.T) {
+	tests := []struct {
+		name      string
+		pair      string
+		exchange  string
+		want      string
+		wantTopic string
+	}{
+		{name: "取引所がなければ統合板にすること", pair: " btc-usd ", want: "BTC-USD", wantTopic: "crypto:pair:BTC-USD"},
+		{name: "取引所を付けること", pair: "btc-usd", exchange: " coinbase ", want: "BTC-USD@COINBASE", wantTopic: "crypto:market:COINBASE:BTC-USD"},
+		{name: "空白だけの取引所は統合板にすること", pair: "ETH-USD", exchange: " ", want: "ETH-USD", wantTopic: "crypto:pair:ETH-USD"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CryptoChannel(tt.pair, tt.exchange)
+			if got != tt.want {
+				t.Fatalf("CryptoChannel() = %q, want %q", got, tt.want)
+			}
+			topic := parseTopic(CRYPTOQUOTE, got)
+			if topic != tt.wantTopic {
+				t.Errorf("parseTopic() = %q, want %q", topic, tt.wantTopic)
+			}
+			if channel := topicChannel(topic); channel != got {
+				t.Errorf("topicChannel(%q) = %q, want %q", topic, channel, got)
+			}
+		})
+	}
+}
+
+func TestParseCryptoMessages(t *testing.T) {
+	cli := New(yourIntrinioAPIUserName, "", CRYPTOQUOTE)
+
+	msg := decodeFixture(t, cli, "crypto_trade.json")
+	trade, ok := parseCryptoTrade(CRYPTOQUOTE, msg)
+	wantTrade := CryptoTrade{Pair: "BTC-USD", Exchange: "coinbase", Price: 67012.5, Size: 0.0153, TakerSide: "buy",
+		Timestamp: time.Unix(1760621400, 123000000).UTC(), RawTimestamp: RawTime{Value: 1760621400123, Unit: Milliseconds}}
+	trade.Raw = nil
+	if !ok || !reflect.DeepEqual(trade, wantTrade) {
+		t.Errorf("parseCryptoTrade() = %+v, %v, want %+v", trade, ok, wantTrade)
+	}
+	if got := Classify(CRYPTOQUOTE, msg); got != MessageTrade {
+		t.Errorf("Classify() = %v, want %v", got, MessageTrade)
+	}
+	if _, ok := parseCryptoBookUpdate(CRYPTOQUOTE, msg); ok {
+		t.Error("parseCryptoBookUpdate() of a trade = true, want false")
+	}
+
+	msg = decodeFixture(t, cli, "crypto_book_update.json")
+	update, ok := parseCryptoBookUpdate(CRYPTOQUOTE, msg)
+	wantUpdate := CryptoBookUpdate{Pair: "ETH-USD", Side: "ask", Price: 2612.37, Size: 4.25,
+		Timestamp: time.Unix(1760621400, 456000000).UTC(), RawTimestamp: RawTime{Value: 1760621400456, Unit: Milliseconds}}
+	update.Raw = nil
+	if !ok || !reflect.DeepEqual(update, wantUpdate) {
+		t.Errorf("parseCryptoBookUpdate() = %+v, %v, want %+v", update, ok, wantUpdate)
+	}
+	if got := Classify(CRYPTOQUOTE, msg); got != MessageQuote {
+		t.Errorf("Classify() = %v, want %v", got, MessageQuote)
+	}
+	if symbol, ok := SymbolFromMessage(CRYPTOQUOTE, msg); symbol != "ETH-USD" || !ok {
+		t.Errorf("SymbolFromMessage() = %q, %v, want ETH-USD", symbol, ok)
+	}
+	if err := checkSchema(CRYPTOQUOTE, msg); err != nil {
+		t.Errorf("checkSchema() = %v, want nil", err)
+	}
+}
+
+func TestMakeCryptoHeartbeat(t *testing.T) {
+	heartbeat := makeHeartbeatMessage(CRYPTOQUOTE)
+	if heartbeat["topic"] != "phoenix" || heartbeat["event"] != "heartbeat" {
+		t.Errorf("makeHeartbeatMessage() = %v, want a phoenix heartbeat", heartbeat)
+	}
+	if ref, _ := heartbeat["ref"].(string); ref == "" {
+		t.Errorf("makeHeartbeatMessage() ref = %v, want one", heartbeat["ref"])
+	}
+	if heartbeat := makeHeartbeatMessage(IEX); heartbeat["ref"] != nil {
+		t.Errorf("makeHeartbeatMessage(IEX) ref = %v, want nil", heartbeat["ref"])
+	}
+}
+
+func TestClientCryptoquote(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+	server.setReply(cryptoDialect(loadBinary(t, "crypto_trade.json")))
+
+	trades := make(chan CryptoTrade, 10)
+	updates := make(chan CryptoBookUpdate, 10)
+	reconnects := make(chan error, 10)
+	sut := server.newClient(CRYPTOQUOTE, WithHeartbeatInterval(20*time.Millisecond), WithMaxMissedHeartbeats(2))
+	sut.OnCryptoTrade(func(trade CryptoTrade) { trades <- trade })
+	sut.OnCryptoBookUpdate(func(update CryptoBookUpdate) { updates <- update })
+	sut.OnReconnect(func(cause error) { reconnects <- cause })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+	channel := CryptoChannel("btc-usd", "coinbase")
+	if err := sut.JoinChecked(channel); err != nil {
+		t.Fatalf("JoinChecked(%q) error = %v", channel, err)
+	}
+
+	if trade := receive(t, "OnCryptoTrade()", trades); trade.Pair != "BTC-USD" || trade.Size != 0.0153 || trade.Exchange != "coinbase" {
+		t.Errorf("OnCryptoTrade() = %+v, want 0.0153 BTC-USD on coinbase", trade)
+	}
+	if !waitUntil(time.Second, func() bool { return sut.Confirmed(channel) }) {
+		t.Errorf("Confirmed(%s) = false, want the join answered", channel)
+	}
+	if joins := server.messagesWithEvent("phx_join"); len(joins) != 1 || joins[0]["topic"] != "crypto:market:COINBASE:BTC-USD" {
+		t.Errorf("joins = %v, want one of crypto:market:COINBASE:BTC-USD", joins)
+	}
+
+	server.broadcastRaw(websocket.TextMessage, loadBinary(t, "crypto_book_update.json"))
+	if update := receive(t, "OnCryptoBookUpdate()", updates); update.Pair != "ETH-USD" || update.Side != "ask" || update.Exchange != "" {
+		t.Errorf("OnCryptoBookUpdate() = %+v, want the consolidated ETH-USD ask", update)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	select {
+	case cause := <-reconnects:
+		t.Errorf("reconnected after %v, want the heartbeats answered", cause)
+	default:
+	}
+	if heartbeats := server.messagesWithEvent("heartbeat"); len(heartbeats) < 3 {
+		t.Errorf("heartbeats = %d, want them sent every 20ms", len(heartbeats))
+	}
+}
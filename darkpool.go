@@ -0,0 +1,54 @@
+package intriniorealtime
+
+import "fmt"
+
+// DarkpoolFilter decides which trades go to OnTrade by where they were
+// printed, see Trade.Darkpool.
+type DarkpoolFilter int
+
+const (
+	// DarkpoolInclude passes on every trade.
+	DarkpoolInclude DarkpoolFilter = iota
+	// DarkpoolExclude passes on the trades printed on an exchange only.
+	DarkpoolExclude
+	// DarkpoolOnly passes on the dark pool and other off-exchange trades
+	// only.
+	DarkpoolOnly
+)
+
+// WithDarkpoolFilter sets which trades OnTrade gets. The default is
+// DarkpoolInclude. OnQuote and the provider's typed handlers still get every
+// trade.
+func WithDarkpoolFilter(f DarkpoolFilter) Option {
+	return func(cli *Client) error {
+		if f < DarkpoolInclude || DarkpoolOnly < f {
+			return fmt.Errorf("unknown dark pool filter: %d", f)
+		}
+		cli.darkpoolFilter = f
+		return nil
+	}
+}
+
+// passes reports whether f lets trade through to OnTrade.
+func (f DarkpoolFilter) passes(trade Trade) bool {
+	switch f {
+	case DarkpoolExclude:
+		return !trade.Darkpool
+	case DarkpoolOnly:
+		return trade.Darkpool
+	}
+	return true
+}
+
+// sipDarkpool reports the market centers of the SIP-backed feeds, the binary
+// equities protocol, REALTIME and DELAYED_SIP, that mark an off-exchange
+// trade: D, the FINRA facilities every dark pool reports to, E, a print
+// generated by the SIP rather than an exchange, and none at all, which the
+// tapes send for trades no exchange reported.
+func sipDarkpool(marketCenter string) bool {
+	switch marketCenter {
+	case "", " ", "D", "d", "E", "e":
+		return true
+	}
+	return false
+}
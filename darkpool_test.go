@@ -0,0 +1,129 @@
+package intriniorealtime
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSIPDarkpool(t *testing.T) {
+	tests := []struct {
+		name         string
+		marketCenter string
+		want         bool
+	}{
+		{name: "FINRAの施設はダークプールにすること", marketCenter: "D", want: true},
+		{name: "小文字のFINRAの施設もダークプールにすること", marketCenter: "d", want: true},
+		{name: "SIPが出した約定はダークプールにすること", marketCenter: "E", want: true},
+		{name: "市場のない約定はダークプールにすること", marketCenter: "", want: true},
+		{name: "空白の市場はダークプールにすること", marketCenter: " ", want: true},
+		{name: "Nasdaqはダークプールにしないこと", marketCenter: "Q"},
+		{name: "NYSE Arcaはダークプールにしないこと", marketCenter: "P"},
+		{name: "IEXはダークプールにしないこと", marketCenter: "V"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sipDarkpool(tt.marketCenter); got != tt.want {
+				t.Errorf("sipDarkpool(%q) = %v, want %v", tt.marketCenter, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDarkpoolFilterPasses(t *testing.T) {
+	lit, dark := Trade{Symbol: "AAPL"}, Trade{Symbol: "AAPL", Darkpool: true}
+	tests := []struct {
+		name     string
+		filter   DarkpoolFilter
+		wantLit  bool
+		wantDark bool
+	}{
+		{name: "指定しなければどちらも通すこと", filter: DarkpoolInclude, wantLit: true, wantDark: true},
+		{name: "除くときは取引所の約定だけ通すこと", filter: DarkpoolExclude, wantLit: true},
+		{name: "ダークプールだけのときはダークプールだけ通すこと", filter: DarkpoolOnly, wantDark: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.passes(lit); got != tt.wantLit {
+				t.Errorf("passes(lit) = %v, want %v", got, tt.wantLit)
+			}
+			if got := tt.filter.passes(dark); got != tt.wantDark {
+				t.Errorf("passes(dark) = %v, want %v", got, tt.wantDark)
+			}
+		})
+	}
+}
+
+func TestWithDarkpoolFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		want    DarkpoolFilter
+		wantErr bool
+	}{
+		{name: "指定しなければすべて通すこと", want: DarkpoolInclude},
+		{name: "ダークプールを除けること", opts: []Option{WithDarkpoolFilter(DarkpoolExclude)}, want: DarkpoolExclude},
+		{name: "ダークプールだけにできること", opts: []Option{WithDarkpoolFilter(DarkpoolOnly)}, want: DarkpoolOnly},
+		{name: "知らないフィルタはエラーになること", opts: []Option{WithDarkpoolFilter(DarkpoolFilter(9))}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := New(yourIntrinioAPIUserName, yourIntrinioAPIPassword, QUODD, tt.opts...)
+			if (sut.optionErr != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", sut.optionErr, tt.wantErr)
+			}
+			if !tt.wantErr && sut.darkpoolFilter != tt.want {
+				t.Errorf("darkpoolFilter = %v, want %v", sut.darkpoolFilter, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientDarkpoolFilter(t *testing.T) {
+	trade := func(exchange string, volume float64) map[string]interface{} {
+		return map[string]interface{}{"event": "trade", "data": map[string]interface{}{
+			"ticker": "AAPL.NB", "last_price_4d": float64(1594850), "trade_volume": volume, "trade_exchange": exchange,
+		}}
+	}
+	tests := []struct {
+		name   string
+		filter DarkpoolFilter
+		want   []int64
+	}{
+		{name: "指定しなければすべて受け取ること", filter: DarkpoolInclude, want: []int64{100, 2500, 300}},
+		{name: "ダークプールを除けること", filter: DarkpoolExclude, want: []int64{100, 300}},
+		{name: "ダークプールだけ受け取れること", filter: DarkpoolOnly, want: []int64{2500}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+
+			trades := make(chan Trade, 10)
+			quotes := make(chan map[string]interface{}, 10)
+			sut := server.newClient(QUODD, WithDarkpoolFilter(tt.filter))
+			sut.OnTrade(func(trade Trade) { trades <- trade })
+			sut.OnQuote(func(quote map[string]interface{}) { quotes <- quote })
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			defer sut.Disconnect()
+
+			server.broadcast(trade("t", 100))
+			server.broadcast(trade("d", 2500))
+			server.broadcast(trade("q", 300))
+			for i := 0; i < 3; i++ {
+				receive(t, "OnQuote()", quotes)
+			}
+			for _, want := range tt.want {
+				if got := receive(t, "OnTrade()", trades); got.Size != want || got.Darkpool != (got.Venue.Code == "d") {
+					t.Errorf("OnTrade() = %+v, want a trade of %d", got, want)
+				}
+			}
+			select {
+			case got := <-trades:
+				t.Errorf("OnTrade() got %+v, which the filter should have held back", got)
+			case <-time.After(50 * time.Millisecond):
+			}
+		})
+	}
+}
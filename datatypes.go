@@ -0,0 +1,56 @@
+package intriniorealtime
+
+import "fmt"
+
+// DataTypes selects the market data a client receives, see WithDataTypes.
+type DataTypes int
+
+const (
+	// AllData is trades and quotes, the default.
+	AllData DataTypes = iota
+	// TradesOnly is trades, without quote updates.
+	TradesOnly
+	// QuotesOnly is quote updates, without trades.
+	QuotesOnly
+)
+
+func (d DataTypes) String() string {
+	switch d {
+	case AllData:
+		return "all data"
+	case TradesOnly:
+		return "trades only"
+	case QuotesOnly:
+		return "quotes only"
+	}
+	return fmt.Sprintf("DataTypes(%d)", int(d))
+}
+
+// WithDataTypes makes the client receive the trades or the quotes only.
+// Where the provider filters on the server, NASDAQ_BASIC for TradesOnly,
+// every join asks for the selection, also on reconnect; for the others the
+// messages that aren't selected are dropped as they arrive. Either way they
+// never reach a handler but OnRawMessage, which sees every frame as it was
+// received.
+func WithDataTypes(d DataTypes) Option {
+	return func(cli *Client) error {
+		switch d {
+		case AllData, TradesOnly, QuotesOnly:
+			cli.dataTypes = d
+			return nil
+		}
+		return fmt.Errorf("unknown data types %d", int(d))
+	}
+}
+
+// selected reports whether a message of type t is passed on to the
+// handlers.
+func (cli *Client) selected(t MessageType) bool {
+	switch cli.dataTypes {
+	case TradesOnly:
+		return t != MessageQuote
+	case QuotesOnly:
+		return t != MessageTrade
+	}
+	return true
+}
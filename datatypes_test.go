@@ -0,0 +1,163 @@
+package intriniorealtime
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestDataTypesJoinMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		provider  provider
+		dataTypes DataTypes
+		want      map[string]interface{}
+	}{
+		{
+			name:      "NASDAQ_BASICでは約定だけをサーバーに頼むこと",
+			provider:  NASDAQ_BASIC,
+			dataTypes: TradesOnly,
+			want:      map[string]interface{}{"topic": "iex:securities:AAPL", "event": "phx_join", "payload": map[string]interface{}{"trades_only": true}, "ref": nil},
+		},
+		{
+			name:      "NASDAQ_BASICの気配だけはクライアントで絞ること",
+			provider:  NASDAQ_BASIC,
+			dataTypes: QuotesOnly,
+			want:      map[string]interface{}{"topic": "iex:securities:AAPL", "event": "phx_join", "payload": map[string]interface{}{"trades_only": false}, "ref": nil},
+		},
+		{
+			name:      "IEXの参加は変えないこと",
+			provider:  IEX,
+			dataTypes: TradesOnly,
+			want:      map[string]interface{}{"topic": "iex:securities:AAPL", "event": "phx_join", "payload": map[string]interface{}{}, "ref": nil},
+		},
+		{
+			name:      "REALTIMEの参加は変えないこと",
+			provider:  REALTIME,
+			dataTypes: QuotesOnly,
+			want:      map[string]interface{}{"topic": "iex:securities:AAPL", "event": "phx_join", "payload": map[string]interface{}{}, "ref": nil},
+		},
+		{
+			name:      "QUODDの参加は変えないこと",
+			provider:  QUODD,
+			dataTypes: TradesOnly,
+			want:      makeJoinMessage(QUODD, "AAPL.NB"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := New("", "", tt.provider, WithDataTypes(tt.dataTypes))
+			channel := "AAPL"
+			if tt.provider == QUODD {
+				channel = "AAPL.NB"
+			}
+			if got := sut.joinMessage(channel); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("joinMessage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDataTypesFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		provider  provider
+		dataTypes DataTypes
+		frames    []string
+		want      []MessageType
+	}{
+		{
+			name:      "約定だけなら気配を渡さないこと",
+			provider:  REALTIME,
+			dataTypes: TradesOnly,
+			frames:    []string{"realtime_quote.json", "realtime_trade.json"},
+			want:      []MessageType{MessageTrade},
+		},
+		{
+			name:      "気配だけなら約定を渡さないこと",
+			provider:  NASDAQ_BASIC,
+			dataTypes: QuotesOnly,
+			frames:    []string{"nasdaq_basic_trade.json", "nasdaq_basic_quote.json"},
+			want:      []MessageType{MessageQuote},
+		},
+		{
+			name:      "QUODDの約定だけでも絞ること",
+			provider:  QUODD,
+			dataTypes: TradesOnly,
+			frames:    []string{"quodd_quote.json", "quodd_trade.json"},
+			want:      []MessageType{MessageTrade},
+		},
+		{
+			name:      "既定ではすべて渡すこと",
+			provider:  REALTIME,
+			dataTypes: AllData,
+			frames:    []string{"realtime_quote.json", "realtime_trade.json"},
+			want:      []MessageType{MessageQuote, MessageTrade},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := New("", "", tt.provider, WithDataTypes(tt.dataTypes), WithConcurrentCallbacks())
+			var messages []MessageType
+			var quotes, trades, raw int
+			sut.OnMessage(func(msg Message) { messages = append(messages, msg.Type) })
+			sut.OnQuote(func(map[string]interface{}) { quotes++ })
+			sut.OnTrade(func(Trade) { trades++ })
+			sut.OnRawMessage(func(int, []byte) { raw++ })
+			for _, frame := range tt.frames {
+				if err := sut.handleFrame(websocket.TextMessage, loadBinary(t, frame), time.Now()); err != nil {
+					t.Fatalf("handleFrame(%s) error = %v", frame, err)
+				}
+			}
+			if !reflect.DeepEqual(messages, tt.want) {
+				t.Errorf("OnMessage() types = %v, want %v", messages, tt.want)
+			}
+			if quotes != len(tt.want) {
+				t.Errorf("OnQuote() called %d times, want %d", quotes, len(tt.want))
+			}
+			wantTrades := 0
+			for _, typ := range tt.want {
+				if typ == MessageTrade {
+					wantTrades++
+				}
+			}
+			if trades != wantTrades {
+				t.Errorf("OnTrade() called %d times, want %d", trades, wantTrades)
+			}
+			if raw != len(tt.frames) {
+				t.Errorf("OnRawMessage() called %d times, want every frame", raw)
+			}
+		})
+	}
+
+	if err := New("", "", IEX, WithDataTypes(DataTypes(7))).Connect(); err == nil {
+		t.Error("Connect() with unknown data types error = nil")
+	}
+}
+
+func TestDataTypesAfterReconnect(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+	server.setReply(realtimeDialect(loadBinary(t, "nasdaq_basic_trade.json")))
+
+	sut := server.newClient(NASDAQ_BASIC, WithDataTypes(TradesOnly))
+	sut.Join("AAPL")
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+	if !waitUntil(5*time.Second, func() bool { return len(server.messagesWithEvent("phx_join")) == 1 }) {
+		t.Fatal("AAPL was not joined")
+	}
+	server.kick(websocket.CloseGoingAway, "restart")
+	if !waitUntil(5*time.Second, func() bool { return len(server.messagesWithEvent("phx_join")) == 2 }) {
+		t.Fatal("AAPL was not joined again")
+	}
+	for _, join := range server.messagesWithEvent("phx_join") {
+		if !reflect.DeepEqual(join["payload"], map[string]interface{}{"trades_only": true}) {
+			t.Errorf("join = %v, want trades only", join)
+		}
+	}
+}
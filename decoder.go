@@ -0,0 +1,187 @@
+package intriniorealtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Decoder decodes the JSON of a received frame into v, the way
+// json.Unmarshal does. A generated decoder can be wrapped in a type of its
+// own that falls back to encoding/json for the types it doesn't know.
+type Decoder interface {
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// DecoderFunc adapts a function such as json.Unmarshal to a Decoder.
+type DecoderFunc func(data []byte, v interface{}) error
+
+// Unmarshal calls f(data, v).
+func (f DecoderFunc) Unmarshal(data []byte, v interface{}) error {
+	return f(data, v)
+}
+
+// decode decodes a received frame with the Decoder set with WithDecoder, or
+// with encoding/json, keeping numbers as json.Number with
+// WithFixedPointPrices.
+func (cli *Client) decode(data []byte, v interface{}) error {
+	if cli.decoder != nil {
+		return cli.decoder.Unmarshal(data, v)
+	}
+	if !cli.useNumber {
+		return json.Unmarshal(data, v)
+	}
+	return decodeNumbers(data, v)
+}
+
+// decodeFrame decodes a received text frame once into the two maps the
+// client works with. msg is what OnQuote gets, with numbers as float64, or
+// as json.Number with WithFixedPointPrices. exact is the same but keeps
+// sizes, volumes and timestamps as json.Number in any case, so the typed
+// models get share counts above 2^53 and timestamps to the nanosecond
+// without going through float64; the two share every part without such a
+// field. A Decoder of your own decodes into a single map used for both.
+func (cli *Client) decodeFrame(data []byte) (msg, exact map[string]interface{}, err error) {
+	if cli.phoenixVsn == PhoenixV2 && phoenix(cli.provider) {
+		if data, err = phoenixEnvelope(data); err != nil {
+			return nil, nil, err
+		}
+	}
+	if cli.decoder != nil || cli.useNumber {
+		err = cli.decode(data, &msg)
+		return msg, msg, err
+	}
+	if err := decodeNumbers(data, &exact); err != nil || exact == nil {
+		return nil, nil, err
+	}
+	view, _, err := splitNumbers(exact, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	msg, _ = view.(map[string]interface{})
+	return msg, exact, nil
+}
+
+// decodeReceived is decodeFrame for the read loop. While nothing reads the
+// exact map, see wantsExact, the frame is decoded once with float64 numbers
+// into a single map used for both.
+func (cli *Client) decodeReceived(data []byte) (msg, exact map[string]interface{}, err error) {
+	if cli.decoder != nil || cli.useNumber || cli.wantsExact() {
+		return cli.decodeFrame(data)
+	}
+	if cli.phoenixVsn == PhoenixV2 && phoenix(cli.provider) {
+		if data, err = phoenixEnvelope(data); err != nil {
+			return nil, nil, err
+		}
+	}
+	err = json.Unmarshal(data, &msg)
+	return msg, msg, err
+}
+
+// wantsExact reports whether anything reads the exact map of decodeFrame:
+// strict decoding, OnMessage, which may ask a Message for its models, or a
+// handler of typed models. With OnQuote and OnRawMessage alone it is never
+// built. Frames decoded before such a handler is registered have float64
+// sizes and timestamps in their models.
+func (cli *Client) wantsExact() bool {
+	if cli.strict {
+		return true
+	}
+	cli.handlerMu.RLock()
+	defer cli.handlerMu.RUnlock()
+	return cli.messageHandler != nil || cli.depthHandler != nil || cli.statusHandler != nil ||
+		cli.iexQuoteHandler != nil || cli.quoddQuoteHandler != nil || cli.quoddTradeHandler != nil ||
+		cli.tradeHandler != nil || cli.bidHandler != nil || cli.askHandler != nil || cli.normalizedHandler != nil ||
+		cli.optionTradeHandler != nil || cli.optionQuoteHandler != nil || cli.cryptoTradeHandler != nil ||
+		cli.cryptoBookHandler != nil || cli.fxQuoteHandler != nil || len(cli.typedHandlers) != 0
+}
+
+// decodeNumbers is json.Unmarshal keeping numbers as json.Number.
+func decodeNumbers(data []byte, v interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(v); err != nil {
+		return err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return errors.New("invalid character after top-level value")
+	}
+	return nil
+}
+
+// splitNumbers turns the numbers in v, decoded with UseNumber, into float64
+// in place, but for the fields exactField keeps; key is the key of v for
+// numbers and the keys of the objects within. It returns the view of v with
+// those as float64 as well and whether that is not v itself: only the maps
+// and slices on the way to a kept number are copied, and shallowly, so a
+// frame without one is decoded into a single tree.
+func splitNumbers(v interface{}, key string) (interface{}, bool, error) {
+	switch v := v.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, exactField(key), err
+	case map[string]interface{}:
+		var view map[string]interface{}
+		for k, x := range v {
+			y, split, err := splitNumbers(x, k)
+			if err != nil {
+				return nil, false, err
+			}
+			if !split {
+				v[k] = y
+			} else if view == nil {
+				// The keys not visited yet are set below as they come.
+				view = make(map[string]interface{}, len(v))
+				for k, x := range v {
+					view[k] = x
+				}
+			}
+			if view != nil {
+				view[k] = y
+			}
+		}
+		if view == nil {
+			return v, false, nil
+		}
+		return view, true, nil
+	case []interface{}:
+		var view []interface{}
+		for i, x := range v {
+			y, split, err := splitNumbers(x, key)
+			if err != nil {
+				return nil, false, err
+			}
+			if !split {
+				v[i] = y
+				if view != nil {
+					view[i] = y
+				}
+				continue
+			}
+			if view == nil {
+				view = append([]interface{}(nil), v...)
+			}
+			view[i] = y
+		}
+		if view == nil {
+			return v, false, nil
+		}
+		return view, true, nil
+	}
+	return v, false, nil
+}
+
+// countField reports the fields that hold a number of shares or contracts:
+// the IEX and depth size, QUODD's *_size and *_volume fields and OPRA's
+// open_interest.
+func countField(key string) bool {
+	return key == "size" || strings.HasSuffix(key, "_size") || strings.HasSuffix(key, "_volume") || key == "open_interest"
+}
+
+// exactField reports the fields decodeFrame keeps as json.Number: the share
+// counts, IEX's timestamp and QUODD's *_time fields.
+func exactField(key string) bool {
+	return countField(key) || key == "timestamp" || strings.HasSuffix(key, "_time")
+}
@@ -0,0 +1,224 @@
+package intriniorealtime
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// countingDecoder decodes with encoding/json and counts what it decoded.
+type countingDecoder struct {
+	calls int32
+}
+
+func (d *countingDecoder) Unmarshal(data []byte, v interface{}) error {
+	atomic.AddInt32(&d.calls, 1)
+	return json.Unmarshal(data, v)
+}
+
+func TestClientWithDecoder(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	decoder := &countingDecoder{}
+	quotes := make(chan map[string]interface{}, 1)
+	typed := make(chan myIEXQuote, 1)
+	sut := server.newClient(IEX, WithDecoder(decoder))
+	sut.OnQuote(func(quote map[string]interface{}) { quotes <- quote })
+	OnQuoteAs(sut, func(q myIEXQuote) { typed <- q })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	before := atomic.LoadInt32(&decoder.calls)
+	server.broadcast(loadFixture(t, "iex_quote.json"))
+	select {
+	case quote := <-quotes:
+		if quote["event"] != "quote" {
+			t.Errorf("OnQuote() = %v, want the quote", quote)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnQuote() was not called")
+	}
+	select {
+	case q := <-typed:
+		if q.Symbol != "GE" {
+			t.Errorf("OnQuoteAs() = %+v, want GE", q)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnQuoteAs() was not called")
+	}
+	// The frame and the payload for OnQuoteAs.
+	if got := atomic.LoadInt32(&decoder.calls) - before; got != 2 {
+		t.Errorf("decoder calls = %d, want 2", got)
+	}
+}
+
+func TestWithDecoderErrors(t *testing.T) {
+	decoder := DecoderFunc(json.Unmarshal)
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "nilのデコーダーはエラーにすること", opts: []Option{WithDecoder(nil)}},
+		{name: "固定小数点の後にデコーダーを指定したらエラーにすること", opts: []Option{WithFixedPointPrices(), WithDecoder(decoder)}},
+		{name: "デコーダーの後に固定小数点を指定したらエラーにすること", opts: []Option{WithDecoder(decoder), WithFixedPointPrices()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewWithOptions(yourIntrinioAPIUserName, yourIntrinioAPIPassword, IEX, tt.opts...); err == nil {
+				t.Error("NewWithOptions() error = nil, want an error")
+			}
+		})
+	}
+}
+
+// BenchmarkDecode decodes captured IEX frames as the read loop does, with
+// OnQuote alone or with a typed handler that needs the exact map. To compare
+// a Decoder of your own, add it to the table.
+func BenchmarkDecode(b *testing.B) {
+	frame, err := ioutil.ReadFile(filepath.Join("testdata", "iex_quote.json"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	frames := [][]byte{
+		frame,
+		[]byte(`{"topic":"iex:securities:AAPL","event":"quote","payload":{"type":"last","timestamp":1493409509.3932788,"ticker":"AAPL","size":100,"price":143.65,"conditions":64},"ref":null}`),
+		[]byte(`{"topic":"iex:securities:MSFT","event":"quote","payload":{"type":"bid","timestamp":1493409509.4011234,"ticker":"MSFT","size":300,"price":68.41},"ref":null}`),
+	}
+	for _, bm := range []struct {
+		name  string
+		opts  []Option
+		typed bool
+	}{
+		{name: "encoding/json"},
+		{name: "encoding/json typed", typed: true},
+		{name: "encoding/json UseNumber", opts: []Option{WithFixedPointPrices()}},
+		{name: "DecoderFunc", opts: []Option{WithDecoder(DecoderFunc(json.Unmarshal))}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			sut := New(yourIntrinioAPIUserName, yourIntrinioAPIPassword, IEX, bm.opts...)
+			if bm.typed {
+				sut.OnIEXQuote(func(IEXQuote) {})
+			}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := sut.decodeReceived(frames[i%len(frames)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeFrame(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []Option
+		frame     string
+		wantSize  interface{}
+		wantPrice interface{}
+		wantExact interface{}
+		wantErr   bool
+	}{
+		{
+			name:      "数量だけjson.Numberのまま残すこと",
+			frame:     `{"event":"quote","data":{"ticker":"SPY.NB","bid_size":9007199254740993,"bid_price_4d":4301200,"levels":[{"size":9007199254740993}]}}`,
+			wantSize:  float64(9007199254740993),
+			wantPrice: float64(4301200),
+			wantExact: json.Number("9007199254740993"),
+		},
+		{
+			name:      "WithFixedPointPricesならどちらもjson.Numberにすること",
+			opts:      []Option{WithFixedPointPrices()},
+			frame:     `{"event":"quote","data":{"ticker":"SPY.NB","bid_size":9007199254740993,"bid_price_4d":4301200,"levels":[{"size":9007199254740993}]}}`,
+			wantSize:  json.Number("9007199254740993"),
+			wantPrice: json.Number("4301200"),
+			wantExact: json.Number("9007199254740993"),
+		},
+		{name: "後ろに余計な文字があればエラーにすること", frame: `{"event":"quote"} x`, wantErr: true},
+		{name: "float64に収まらない数はエラーにすること", frame: `{"event":"quote","data":{"bid_price_4d":1e400}}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := New(yourIntrinioAPIUserName, yourIntrinioAPIPassword, QUODD, tt.opts...)
+			msg, exact, err := sut.decodeFrame([]byte(tt.frame))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeFrame() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			data, exactData := msg["data"].(map[string]interface{}), exact["data"].(map[string]interface{})
+			if data["bid_size"] != tt.wantSize || data["bid_price_4d"] != tt.wantPrice {
+				t.Errorf("decodeFrame() msg = %#v, want bid_size %#v and bid_price_4d %#v", data, tt.wantSize, tt.wantPrice)
+			}
+			if exactData["bid_size"] != tt.wantExact || exactData["bid_price_4d"] != tt.wantPrice {
+				t.Errorf("decodeFrame() exact = %#v, want bid_size %#v and bid_price_4d %#v", exactData, tt.wantExact, tt.wantPrice)
+			}
+			level := exactData["levels"].([]interface{})[0].(map[string]interface{})
+			if level["size"] != tt.wantExact {
+				t.Errorf("decodeFrame() exact level = %#v, want size %#v", level, tt.wantExact)
+			}
+		})
+	}
+}
+
+func TestClientExactSizes(t *testing.T) {
+	tests := []struct {
+		name string
+		size string
+		want int64
+	}{
+		{name: "2^53-1を正確に読むこと", size: "9007199254740991", want: 1<<53 - 1},
+		{name: "2^53を正確に読むこと", size: "9007199254740992", want: 1 << 53},
+		{name: "2^53+1を正確に読むこと", size: "9007199254740993", want: 1<<53 + 1},
+		{name: "int64の最大値を正確に読むこと", size: "9223372036854775807", want: 1<<63 - 1},
+	}
+	server := newFakeServer()
+	defer server.Close()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trades := make(chan Trade, 2)
+			quodd := make(chan QuoddTradeData, 1)
+			quotes := make(chan map[string]interface{}, 2)
+			iex := server.newClient(IEX)
+			iex.OnTrade(func(trade Trade) { trades <- trade })
+			iex.OnQuote(func(quote map[string]interface{}) { quotes <- quote })
+			sut := server.newClient(QUODD)
+			sut.OnQuoddTrade(func(trade QuoddTradeData) { quodd <- trade })
+			sut.OnTrade(func(trade Trade) { trades <- trade })
+			for _, c := range []*Client{iex, sut} {
+				if err := c.Connect(); err != nil {
+					t.Fatalf("connect() error = %v", err)
+				}
+				defer c.Disconnect()
+			}
+
+			server.broadcastRaw(websocket.TextMessage, []byte(`{"topic":"iex:securities:GE","event":"quote","payload":{"type":"last","ticker":"GE","price":12.5,"size":`+tt.size+`}}`))
+			trade := receive(t, "OnTrade()", trades)
+			if trade.Size != tt.want {
+				t.Errorf("OnTrade() size = %d, want %d", trade.Size, tt.want)
+			}
+			if size := receive(t, "OnQuote()", quotes)["payload"].(map[string]interface{})["size"]; size != float64(tt.want) {
+				t.Errorf("OnQuote() size = %#v, want the float64 %v as before", size, float64(tt.want))
+			}
+			iex.Disconnect()
+
+			server.broadcastRaw(websocket.TextMessage, []byte(`{"event":"trade","data":{"ticker":"SPY.NB","last_price_4d":4301200,"trade_volume":`+tt.size+`,"total_volume":`+tt.size+`}}`))
+			got := receive(t, "OnQuoddTrade()", quodd)
+			if got.TotalVolume == nil || *got.TotalVolume != tt.want {
+				t.Errorf("OnQuoddTrade() total volume = %v, want %d", got.TotalVolume, tt.want)
+			}
+			if trade := receive(t, "OnTrade()", trades); trade.Size != tt.want {
+				t.Errorf("OnTrade() size = %d, want %d", trade.Size, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package intriniorealtime
+
+import "strings"
+
+// depthPrefix marks a QUODD market depth channel, e.g. "$depth:AAPL".
+const depthPrefix = "$depth:"
+
+// Depth is one price level of a QUODD market depth (level 2) update.
+type Depth struct {
+	Ticker      string
+	Side        string // "bid" or "ask"
+	Level       int    // 1 is the top of the book
+	Price       float64
+	Size        int64
+	MarketMaker string
+	FixedPrice  Price // with WithFixedPointPrices
+}
+
+// DepthChannel returns the channel that subscribes to the market depth of
+// ticker. It can be passed to Join and Leave like any other channel and
+// mixed with top-of-book channels on the same connection.
+func DepthChannel(ticker string) string {
+	return normalizeChannel(depthPrefix + ticker)
+}
+
+// JoinDepth joins the market depth channels of the given tickers. Depth is
+// only available from QUODD; with other providers ErrDepthUnsupported is
+// reported through OnError.
+func (cli *Client) JoinDepth(tickers ...string) {
+	if cli.provider != QUODD {
+		cli.onError(ErrDepthUnsupported)
+		return
+	}
+	cli.Join(depthChannels(tickers)...)
+}
+
+// LeaveDepth leaves the market depth channels of the given tickers.
+func (cli *Client) LeaveDepth(tickers ...string) {
+	cli.Leave(depthChannels(tickers)...)
+}
+
+func depthChannels(tickers []string) []string {
+	channels := make([]string, len(tickers))
+	for i, ticker := range tickers {
+		channels[i] = DepthChannel(ticker)
+	}
+	return channels
+}
+
+// OnDepth registers the handler for market depth updates. They are not
+// passed to OnQuote.
+func (cli *Client) OnDepth(f func(depth Depth)) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
+	cli.depthHandler = f
+}
+
+func (cli *Client) onDepth(depth Depth) {
+	cli.debug("depth", "channel", depth.Ticker)
+	cli.handlerMu.RLock()
+	f := cli.depthHandler
+	cli.handlerMu.RUnlock()
+	if f != nil {
+		cli.callHandler("OnDepth", func() { f(depth) })
+	}
+}
+
+// makeQUODDData returns the data of a QUODD subscribe or unsubscribe for
+// channel, which asks for market depth when channel is a depth channel.
+func makeQUODDData(channel, action string) map[string]string {
+	if strings.HasPrefix(channel, depthPrefix) {
+		return map[string]string{
+			"ticker": strings.TrimPrefix(channel, depthPrefix),
+			"action": action,
+			"type":   "depth",
+		}
+	}
+	return map[string]string{
+		"ticker": channel,
+		"action": action,
+	}
+}
+
+// parseDepth returns the depth update carried by msg, if it is one.
+func parseDepth(provider provider, msg map[string]interface{}) (Depth, bool) {
+	if provider != QUODD || msg["event"] != "depth" {
+		return Depth{}, false
+	}
+	data, ok := msg["data"].(map[string]interface{})
+	if !ok {
+		return Depth{}, false
+	}
+	depth := Depth{}
+	depth.Ticker, _ = data["ticker"].(string)
+	depth.MarketMaker, _ = data["market_maker"].(string)
+	switch side, _ := data["side"].(string); side {
+	case "b", "bid":
+		depth.Side = "bid"
+	case "a", "ask":
+		depth.Side = "ask"
+	default:
+		depth.Side = side
+	}
+	if level, ok := integer(data["level"]); ok {
+		depth.Level = int(level)
+	}
+	if price, ok := number(data["price_4d"]); ok {
+		depth.Price = price / priceScale
+	}
+	depth.FixedPrice, _ = fixedPrice4d(data["price_4d"])
+	depth.Size, _ = integer(data["size"])
+	return depth, true
+}
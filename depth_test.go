@@ -0,0 +1,154 @@
+package intriniorealtime
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseDepth(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider provider
+		msg      map[string]interface{}
+		want     Depth
+		wantOK   bool
+	}{
+		{
+			name:     "QUODDの板情報を解析すること",
+			provider: QUODD,
+			msg: map[string]interface{}{"event": "depth", "data": map[string]interface{}{
+				"ticker": "AAPL.NB", "side": "b", "level": float64(2), "price_4d": float64(1594800), "size": float64(300), "market_maker": "NSDQ",
+			}},
+			want:   Depth{Ticker: "AAPL.NB", Side: "bid", Level: 2, Price: 159.48, Size: 300, MarketMaker: "NSDQ"},
+			wantOK: true,
+		},
+		{
+			name:     "売り側をaskにすること",
+			provider: QUODD,
+			msg:      map[string]interface{}{"event": "depth", "data": map[string]interface{}{"ticker": "GE", "side": "a"}},
+			want:     Depth{Ticker: "GE", Side: "ask"},
+			wantOK:   true,
+		},
+		{
+			name:     "板情報以外は解析しないこと",
+			provider: QUODD,
+			msg:      map[string]interface{}{"event": "quote", "data": map[string]interface{}{"ticker": "GE"}},
+		},
+		{
+			name:     "IEXでは解析しないこと",
+			provider: IEX,
+			msg:      map[string]interface{}{"event": "depth", "data": map[string]interface{}{"ticker": "GE"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseDepth(tt.provider, tt.msg)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("parseDepth() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestMakeDepthMessages(t *testing.T) {
+	tests := []struct {
+		name string
+		make func(provider, string) map[string]interface{}
+		want map[string]interface{}
+	}{
+		{
+			name: "板情報のJoinはtypeにdepthを指定すること",
+			make: makeJoinMessage,
+			want: map[string]interface{}{"event": "subscribe", "data": map[string]string{"ticker": "AAPL", "action": "subscribe", "type": "depth"}},
+		},
+		{
+			name: "板情報のLeaveはtypeにdepthを指定すること",
+			make: makeLeaveMessage,
+			want: map[string]interface{}{"event": "unsubscribe", "data": map[string]string{"ticker": "AAPL", "action": "unsubscribe", "type": "depth"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.make(QUODD, DepthChannel(" AAPL ")); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("message = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientJoinDepth(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	depths := make(chan Depth, 1)
+	quotes := make(chan map[string]interface{}, 1)
+	sut := server.newClient(QUODD)
+	sut.OnDepth(func(depth Depth) { depths <- depth })
+	sut.OnQuote(func(quote map[string]interface{}) { quotes <- quote })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	sut.Join("AAPL")
+	sut.JoinDepth("AAPL")
+	if !waitUntil(5*time.Second, func() bool { return len(server.messagesWithEvent("subscribe")) == 2 }) {
+		t.Fatalf("subscribes = %v", server.messagesWithEvent("subscribe"))
+	}
+	var types []interface{}
+	for _, msg := range server.messagesWithEvent("subscribe") {
+		types = append(types, msg["data"].(map[string]interface{})["type"])
+	}
+	if !reflect.DeepEqual(types, []interface{}{nil, "depth"}) {
+		t.Errorf("subscribe types = %v, want [<nil> depth]", types)
+	}
+
+	server.broadcast(map[string]interface{}{"event": "depth", "data": map[string]interface{}{"ticker": "AAPL", "side": "b", "level": 1, "price_4d": 1594800, "size": 100}})
+	server.broadcast(map[string]interface{}{"event": "quote", "data": map[string]interface{}{"ticker": "AAPL"}})
+	select {
+	case depth := <-depths:
+		if depth.Ticker != "AAPL" || depth.Side != "bid" || depth.Price != 159.48 {
+			t.Errorf("OnDepth() depth = %+v", depth)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnDepth() was not called")
+	}
+	select {
+	case quote := <-quotes:
+		if quote["event"] != "quote" {
+			t.Errorf("OnQuote() quote = %v", quote)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnQuote() was not called")
+	}
+
+	sut.LeaveDepth("AAPL")
+	if !waitUntil(5*time.Second, func() bool { return len(server.messagesWithEvent("unsubscribe")) == 1 }) {
+		t.Fatal("depth channel was not left")
+	}
+	if data := server.messagesWithEvent("unsubscribe")[0]["data"].(map[string]interface{}); data["type"] != "depth" || data["ticker"] != "AAPL" {
+		t.Errorf("unsubscribe = %v", data)
+	}
+	if got := sut.SubscriptionRefs("AAPL"); got != 1 {
+		t.Errorf("top-of-book AAPL was left with the depth channel")
+	}
+}
+
+func TestClientJoinDepthIEX(t *testing.T) {
+	errs := make(chan error, 1)
+	sut := New(yourIntrinioAPIUserName, yourIntrinioAPIPassword, IEX)
+	sut.OnError(func(err error) { errs <- err })
+	sut.JoinDepth("AAPL")
+	select {
+	case err := <-errs:
+		if err != ErrDepthUnsupported {
+			t.Errorf("OnError() err = %v, want %v", err, ErrDepthUnsupported)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnError() was not called")
+	}
+	if got := sut.SubscriptionRefs(DepthChannel("AAPL")); got != 0 {
+		t.Errorf("SubscriptionRefs() = %d, want 0", got)
+	}
+}
@@ -0,0 +1,158 @@
+package intriniorealtime
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// dispatchQueueSize bounds the messages waiting for a handler. What happens
+// when it is full is up to the OverflowPolicy.
+const dispatchQueueSize = 1024
+
+// dispatcher hands messages to a pool of workers so a slow OnQuote handler
+// doesn't stall the read loop. Every worker has its own queue and all
+// messages for one symbol go to the same worker, so they are handled in the
+// order they arrived.
+type dispatcher struct {
+	qs       []chan Message
+	stop     chan struct{}
+	finished chan struct{}
+}
+
+// queue returns the queue of the worker that handles msg's symbol.
+func (d *dispatcher) queue(msg Message) chan Message {
+	return d.qs[worker(msg.Channel, len(d.qs))]
+}
+
+// worker picks one of n workers for symbol.
+func worker(symbol string, n int) int {
+	if n == 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(symbol))
+	return int(h.Sum32() % uint32(n))
+}
+
+// startDispatcher starts the workers configured with WithDispatchWorkers.
+// Without workers messages keep being handled on the read loop.
+func (cli *Client) startDispatcher() {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	if cli.dispatchWorkers <= 0 || cli.dispatcher != nil {
+		return
+	}
+	d := &dispatcher{
+		qs:       make([]chan Message, cli.dispatchWorkers),
+		stop:     make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+	// The queues share the room of one, rounded up.
+	size := (cli.inboundQueueLen + cli.dispatchWorkers - 1) / cli.dispatchWorkers
+	var wg sync.WaitGroup
+	for i := range d.qs {
+		q := make(chan Message, size)
+		d.qs[i] = q
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cli.dispatchWorker(q, d.stop)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(d.finished)
+	}()
+	cli.dispatcher = d
+}
+
+// dispatchWorker handles messages until the dispatcher is stopped, then
+// finishes whatever is still queued.
+func (cli *Client) dispatchWorker(q chan Message, stop chan struct{}) {
+	for {
+		select {
+		case msg := <-q:
+			cli.handleQuote(msg)
+		case <-stop:
+			for {
+				select {
+				case msg := <-q:
+					cli.handleQuote(msg)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// stopDispatcher tells the workers to finish and returns a channel closed
+// once they have, or nil when there were no workers.
+func (cli *Client) stopDispatcher() <-chan struct{} {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	d := cli.dispatcher
+	if d == nil {
+		return nil
+	}
+	cli.dispatcher = nil
+	close(d.stop)
+	return d.finished
+}
+
+// dispatch delivers msg to OnQuote, through the workers when there are any.
+func (cli *Client) dispatch(msg Message) {
+	cli.mu.Lock()
+	d := cli.dispatcher
+	cli.mu.Unlock()
+	if d == nil {
+		cli.onQuote(msg)
+		return
+	}
+	q := d.queue(msg)
+	for {
+		select {
+		case q <- msg:
+			return
+		case <-d.stop:
+			// Disconnecting; the workers are only draining what was queued.
+			return
+		default:
+		}
+		switch cli.overflowPolicy {
+		case OverflowDropNewest:
+			cli.overflowed(msg)
+			return
+		case OverflowDropOldest:
+			select {
+			case old := <-q:
+				cli.overflowed(old)
+			default:
+			}
+		default:
+			select {
+			case q <- msg:
+			case <-d.stop:
+			}
+			return
+		}
+	}
+}
+
+// DispatchQueueLen returns how many received messages and other callbacks
+// are waiting for a handler, or for a dispatch worker with
+// WithDispatchWorkers. A queue that keeps growing means the handlers can't
+// keep up.
+func (cli *Client) DispatchQueueLen() int {
+	cli.mu.Lock()
+	d := cli.dispatcher
+	cli.mu.Unlock()
+	if d == nil {
+		return cli.callbacks.len()
+	}
+	n := 0
+	for _, q := range d.qs {
+		n += len(q)
+	}
+	return n
+}
@@ -0,0 +1,165 @@
+package intriniorealtime
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientDispatchWorkers(t *testing.T) {
+	tests := []struct {
+		name    string
+		workers int
+		tickers []string
+		want    int32
+	}{
+		{name: "ワーカーなしのときは受信ループで順番に処理すること", workers: 0, tickers: spreadTickers(1, 8), want: 1},
+		{name: "ワーカーがあるときは遅いハンドラーを並行して処理すること", workers: 4, tickers: spreadTickers(4, 2), want: 4},
+		{name: "ワーカーがあっても同じ銘柄は並行して処理しないこと", workers: 4, tickers: []string{"AAPL", "AAPL", "AAPL", "AAPL", "AAPL", "AAPL", "AAPL", "AAPL"}, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+
+			var running, peak, handled int32
+			release := make(chan struct{})
+			sut := server.newClient(QUODD, WithDispatchWorkers(tt.workers))
+			sut.OnQuote(func(map[string]interface{}) {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					p := atomic.LoadInt32(&peak)
+					if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+						break
+					}
+				}
+				<-release
+				atomic.AddInt32(&running, -1)
+				atomic.AddInt32(&handled, 1)
+			})
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			for _, ticker := range tt.tickers {
+				server.broadcast(map[string]interface{}{"event": "quote", "data": map[string]interface{}{"ticker": ticker}})
+			}
+			waitUntil(time.Second, func() bool { return atomic.LoadInt32(&running) == tt.want })
+			if got := atomic.LoadInt32(&peak); got != tt.want {
+				t.Errorf("concurrent handlers = %d, want %d", got, tt.want)
+			}
+			if want := 8 - int(tt.want); 0 < tt.workers && !waitUntil(time.Second, func() bool { return sut.DispatchQueueLen() == want }) {
+				t.Errorf("DispatchQueueLen() = %d, want %d", sut.DispatchQueueLen(), want)
+			}
+			close(release)
+			sut.Disconnect()
+			sut.Wait()
+			if 0 < tt.workers && atomic.LoadInt32(&handled) != 8 {
+				t.Errorf("handled = %d after Wait(), want 8", handled)
+			}
+			if got := sut.DispatchQueueLen(); got != 0 {
+				t.Errorf("DispatchQueueLen() = %d after Disconnect, want 0", got)
+			}
+		})
+	}
+}
+
+func TestClientDispatchDisconnectFromHandler(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	sut := server.newClient(QUODD, WithDispatchWorkers(2))
+	sut.OnQuote(func(map[string]interface{}) { sut.Disconnect() })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	server.broadcast(map[string]interface{}{"event": "quote", "data": map[string]interface{}{"ticker": "AAPL"}})
+	select {
+	case <-sut.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Disconnect from a handler did not shut the client down")
+	}
+}
+
+// BenchmarkDispatch compares a handler that takes a millisecond run on the
+// read loop against the same handler behind dispatch workers.
+func BenchmarkDispatch(b *testing.B) {
+	for _, workers := range []int{0, 4, 16} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			var wg sync.WaitGroup
+			sut := New(yourIntrinioAPIUserName, yourIntrinioAPIPassword, QUODD, WithDispatchWorkers(workers))
+			sut.OnQuote(func(map[string]interface{}) {
+				time.Sleep(time.Millisecond)
+				wg.Done()
+			})
+			sut.startDispatcher()
+			msg := map[string]interface{}{"event": "quote"}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				wg.Add(1)
+				sut.dispatch(sut.newMessage(nil, msg, msg, time.Now()))
+			}
+			wg.Wait()
+			b.StopTimer()
+			if finished := sut.stopDispatcher(); finished != nil {
+				<-finished
+			}
+		})
+	}
+}
+
+// spreadTickers returns tickers that put per messages on each of n workers.
+func spreadTickers(n, per int) []string {
+	var ret []string
+	count := make([]int, n)
+	for i := 0; len(ret) < n*per; i++ {
+		ticker := fmt.Sprintf("T%d", i)
+		if w := worker(ticker, n); count[w] < per {
+			count[w]++
+			ret = append(ret, ticker)
+		}
+	}
+	return ret
+}
+
+func TestClientDispatchOrderPerSymbol(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	const n = 200
+	var mu sync.Mutex
+	last := map[string]int{}
+	var outOfOrder, handled int32
+	sut := server.newClient(QUODD, WithDispatchWorkers(8))
+	sut.OnQuote(func(quote map[string]interface{}) {
+		data := quote["data"].(map[string]interface{})
+		ticker, seq := data["ticker"].(string), int(data["seq"].(float64))
+		// Vary the handling time so a worker that is free sooner would
+		// overtake one that is still busy.
+		time.Sleep(time.Duration(seq%3) * 100 * time.Microsecond)
+		mu.Lock()
+		if seq <= last[ticker] {
+			atomic.AddInt32(&outOfOrder, 1)
+		}
+		last[ticker] = seq
+		mu.Unlock()
+		atomic.AddInt32(&handled, 1)
+	})
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	for seq := 1; seq <= n; seq++ {
+		for _, ticker := range []string{"AAPL", "MSFT"} {
+			server.broadcast(map[string]interface{}{"event": "quote", "data": map[string]interface{}{"ticker": ticker, "seq": seq}})
+		}
+	}
+	if !waitUntil(10*time.Second, func() bool { return atomic.LoadInt32(&handled) == 2*n }) {
+		t.Fatalf("handled = %d, want %d", atomic.LoadInt32(&handled), 2*n)
+	}
+	if got := atomic.LoadInt32(&outOfOrder); got != 0 {
+		t.Errorf("%d quotes were handled out of order for their symbol", got)
+	}
+}
@@ -0,0 +1,136 @@
+package intriniorealtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// primaryRetryWait is how long the client sticks to a fallback endpoint
+// before trying the primary again.
+const primaryRetryWait = 10 * time.Minute
+
+// DialError is returned when none of the websocket endpoints could be
+// dialed. Errors holds the failure of each endpoint in URLs.
+type DialError struct {
+	URLs   []string
+	Errors []error
+}
+
+func (e *DialError) Error() string {
+	msgs := make([]string, len(e.URLs))
+	for i, url := range e.URLs {
+		msgs[i] = fmt.Sprintf("%s: %v", url, e.Errors[i])
+	}
+	return "no websocket endpoint could be dialed: " + strings.Join(msgs, "; ")
+}
+
+// badHandshake reports whether any endpoint refused the token.
+func (e *DialError) badHandshake() bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, websocket.ErrBadHandshake) {
+			return true
+		}
+	}
+	return false
+}
+
+// HandshakeError is a websocket handshake the server refused, among the
+// Errors of a DialError, with the status it answered. It matches
+// websocket.ErrBadHandshake with errors.Is.
+type HandshakeError struct {
+	StatusCode int
+	Status     string // such as "401 Unauthorized"
+	Err        error
+}
+
+func (e *HandshakeError) Error() string {
+	return fmt.Sprintf("%v: %s", e.Err, e.Status)
+}
+
+func (e *HandshakeError) Unwrap() error {
+	return e.Err
+}
+
+func isBadHandshake(err error) bool {
+	de, ok := err.(*DialError)
+	return ok && de.badHandshake()
+}
+
+// checkWebsocketURL returns why s can't be dialed as a websocket base URL,
+// or nil when it can.
+func checkWebsocketURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+		return fmt.Errorf("websocket URL %q is not a ws or wss URL", s)
+	}
+	return nil
+}
+
+// checkAuthURL returns why s can't be an auth URL, or nil when it can be.
+func checkAuthURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("auth URL %q is not an http or https URL", s)
+	}
+	return nil
+}
+
+// dialEndpoints dials the websocket endpoints in order, starting with the one
+// that worked last time. After primaryRetryWait on a fallback the primary is
+// tried first again.
+func (cli *Client) dialEndpoints(ctx context.Context) (*websocket.Conn, error) {
+	cli.mu.Lock()
+	urls, err := cli.soketEndpoints()
+	if err != nil {
+		cli.mu.Unlock()
+		return nil, err
+	}
+	start := cli.endpoint
+	if len(urls) <= start || 0 < start && primaryRetryWait <= time.Since(cli.endpointAt) {
+		start = 0
+	}
+	token := cli.token
+	cli.mu.Unlock()
+	if cli.feed != nil {
+		urls, start = []string{cli.feed.WebsocketURL(token)}, 0
+	}
+
+	dialer := cli.websocketDialer()
+	dialErr := &DialError{}
+	for i := range urls {
+		n := (start + i) % len(urls)
+		u := cli.soketURL(urls[n], token)
+		c, resp, err := dialer.DialContext(ctx, u, cli.headers)
+		if err == nil {
+			cli.mu.Lock()
+			if n != start || n != cli.endpoint {
+				cli.endpoint = n
+				cli.endpointAt = time.Now()
+			}
+			cli.mu.Unlock()
+			return c, nil
+		}
+		if err == websocket.ErrBadHandshake && resp != nil {
+			err = &HandshakeError{StatusCode: resp.StatusCode, Status: resp.Status, Err: err}
+		}
+		err = dialProxyError(dialer, u, err)
+		shown := urls[n]
+		if cli.feed != nil && token != "" {
+			// A Provider of the user's own may put the token anywhere.
+			shown = strings.ReplaceAll(shown, token, "REDACTED")
+		}
+		cli.warn("endpoint failed", "url", shown, "err", err)
+		dialErr.URLs = append(dialErr.URLs, shown)
+		dialErr.Errors = append(dialErr.Errors, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, dialErr
+}
@@ -0,0 +1,225 @@
+package intriniorealtime
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newDownEndpoint returns a websocket URL whose server drops every
+// connection, and a counter of the dials it received.
+func newDownEndpoint() (*httptest.Server, string, *int32) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	return srv, "ws" + strings.TrimPrefix(srv.URL, "http") + "/socket", &hits
+}
+
+func TestClientEndpointFailover(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+	down, downURL, hits := newDownEndpoint()
+	defer down.Close()
+
+	reconnected := make(chan struct{}, 1)
+	sut := server.newClient(QUODD, WithWebsocketEndpoints(downURL, server.soketURL()))
+	sut.OnReconnect(func(error) { reconnected <- struct{}{} })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	tests := []struct {
+		name      string
+		prepare   func()
+		wantHits  int32
+		wantIndex int
+	}{
+		{
+			name:      "接続できないエンドポイントの次のエンドポイントに接続すること",
+			prepare:   func() {},
+			wantHits:  1,
+			wantIndex: 1,
+		},
+		{
+			name: "再接続では前回接続できたエンドポイントから試すこと",
+			prepare: func() {
+				server.kick(websocket.CloseGoingAway, "restart")
+				<-reconnected
+			},
+			wantHits:  1,
+			wantIndex: 1,
+		},
+		{
+			name: "しばらくするとプライマリのエンドポイントを試し直すこと",
+			prepare: func() {
+				sut.mu.Lock()
+				sut.endpointAt = time.Now().Add(-primaryRetryWait)
+				sut.mu.Unlock()
+				server.kick(websocket.CloseGoingAway, "restart")
+				<-reconnected
+			},
+			wantHits:  2,
+			wantIndex: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.prepare()
+			if got := atomic.LoadInt32(hits); got != tt.wantHits {
+				t.Errorf("dials to the down endpoint = %d, want %d", got, tt.wantHits)
+			}
+			sut.mu.Lock()
+			got, at := sut.endpoint, sut.endpointAt
+			sut.mu.Unlock()
+			if got != tt.wantIndex {
+				t.Errorf("endpoint = %d, want %d", got, tt.wantIndex)
+			}
+			if primaryRetryWait <= time.Since(at) {
+				t.Errorf("endpointAt = %v, the fallback was not restarted", at)
+			}
+			if !sut.Connected() {
+				t.Error("Connected() = false")
+			}
+		})
+	}
+}
+
+func TestClientEndpointsAllDown(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+	down1, url1, _ := newDownEndpoint()
+	defer down1.Close()
+	down2, url2, _ := newDownEndpoint()
+	defer down2.Close()
+
+	sut := server.newClient(QUODD, WithWebsocketEndpoints(url1, url2))
+	err := sut.Connect()
+	defer sut.Disconnect()
+	de, ok := err.(*DialError)
+	if !ok {
+		t.Fatalf("connect() error = %v, want *DialError", err)
+	}
+	if len(de.URLs) != 2 || de.URLs[0] != url1 || de.URLs[1] != url2 || len(de.Errors) != 2 {
+		t.Errorf("DialError = %+v", de)
+	}
+}
+
+func TestURLOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opt     Option
+		wantErr bool
+	}{
+		{name: "wssのURLを受け付けること", opt: WithWebsocketURL("wss://staging.example.com/socket/websocket")},
+		{name: "ポート付きのwsのURLを受け付けること", opt: WithWebsocketURL("ws://127.0.0.1:4000/socket")},
+		{name: "httpsのWebSocketのURLを弾くこと", opt: WithWebsocketURL("https://staging.example.com/socket"), wantErr: true},
+		{name: "ホストのないWebSocketのURLを弾くこと", opt: WithWebsocketURL("ws:///socket"), wantErr: true},
+		{name: "解釈できないWebSocketのURLを弾くこと", opt: WithWebsocketURL("ws://%zz"), wantErr: true},
+		{name: "httpsの認証URLを受け付けること", opt: WithAuthURL("https://staging.example.com/auth")},
+		{name: "wsの認証URLを弾くこと", opt: WithAuthURL("ws://staging.example.com/auth"), wantErr: true},
+		{name: "空の認証URLを弾くこと", opt: WithAuthURL(""), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opt(New("user", "pass", IEX))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("option error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClientURLOptions(t *testing.T) {
+	for _, provider := range []provider{IEX, QUODD, REALTIME} {
+		t.Run(string(provider), func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+			sut := New("user", "pass", provider, WithAuthURL(server.authURL()), WithWebsocketURL(server.soketURL()))
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			defer sut.Disconnect()
+			server.mu.Lock()
+			tokens := server.dialTokens
+			server.mu.Unlock()
+			if len(tokens) != 1 || tokens[0] != "token-1" {
+				t.Errorf("dialed with tokens %v, want the one from the auth URL", tokens)
+			}
+		})
+	}
+}
+
+func TestClientWithDialer(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	var mu sync.Mutex
+	var dialed []string
+	dialer := &websocket.Dialer{NetDial: func(network, addr string) (net.Conn, error) {
+		mu.Lock()
+		dialed = append(dialed, addr)
+		mu.Unlock()
+		return net.Dial(network, addr)
+	}}
+	reconnected := make(chan error, 1)
+	sut := server.newClient(IEX, WithDialer(dialer))
+	sut.OnReconnect(func(cause error) { reconnected <- cause })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+	if !waitUntil(5*time.Second, func() bool { return len(server.connections()) == 1 }) {
+		t.Fatal("the server never saw the connection")
+	}
+	server.kick(websocket.CloseGoingAway, "restart")
+	receive(t, "OnReconnect()", reconnected)
+
+	host := strings.TrimPrefix(server.srv.URL, "http://")
+	mu.Lock()
+	defer mu.Unlock()
+	if len(dialed) != 2 || dialed[0] != host || dialed[1] != host {
+		t.Errorf("dialer dialed %v, want %s on connect and on reconnect", dialed, host)
+	}
+
+	if err := New("", "", IEX, WithDialer(nil)).Connect(); err == nil {
+		t.Error("Connect() with a nil dialer error = nil")
+	}
+}
+
+func TestClientHandshakeError(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+	server.revoke("token-1")
+
+	sut := server.newClient(IEX)
+	err := sut.Connect()
+	defer sut.Disconnect()
+	de, ok := err.(*DialError)
+	if !ok || len(de.Errors) != 1 {
+		t.Fatalf("connect() error = %v, want *DialError", err)
+	}
+	var he *HandshakeError
+	if !errors.As(de.Errors[0], &he) {
+		t.Fatalf("DialError.Errors = %v, want a *HandshakeError", de.Errors)
+	}
+	if he.StatusCode != http.StatusUnauthorized || !strings.Contains(err.Error(), "401 Unauthorized") {
+		t.Errorf("HandshakeError = %+v, want the 401 in the error %q", he, err)
+	}
+	if !errors.Is(he, websocket.ErrBadHandshake) {
+		t.Errorf("errors.Is(%v, websocket.ErrBadHandshake) = false", he)
+	}
+}
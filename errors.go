@@ -0,0 +1,387 @@
+package intriniorealtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var (
+	// ErrDisconnectTimeout is returned by DisconnectWithTimeout when the
+	// connection could not be closed gracefully in time.
+	ErrDisconnectTimeout = errors.New("disconnect timed out")
+
+	// ErrTokenRejected is reported when the server rejects the auth token of
+	// an established connection.
+	ErrTokenRejected = errors.New("token rejected by server")
+
+	// ErrStaleConnection is reported when no frame has been received for
+	// longer than the stale timeout.
+	ErrStaleConnection = errors.New("no message received within the stale timeout")
+
+	// ErrNotConnected is returned by operations that need a live connection.
+	ErrNotConnected = errors.New("not connected")
+
+	// ErrPingTimeout is returned by Ping when no pong arrived in time.
+	ErrPingTimeout = errors.New("ping timed out")
+
+	// ErrStaticToken is returned by ForceTokenRefresh when the token was
+	// supplied with WithToken.
+	ErrStaticToken = errors.New("token was supplied with WithToken and cannot be refreshed")
+
+	// ErrStaticTokenRejected is returned when the server rejects a token
+	// supplied with WithToken.
+	ErrStaticTokenRejected = errors.New("token supplied with WithToken was rejected")
+
+	// ErrClientClosed is returned when the client was disconnected while a
+	// connection was being established.
+	ErrClientClosed = errors.New("client closed")
+
+	// ErrSendQueueFull is the reason of a DroppedMessageError when the send
+	// queue had no room within the time set with WithSendQueueTimeout.
+	ErrSendQueueFull = errors.New("send queue full")
+
+	// ErrInvalidSymbol is matched by the *SymbolError JoinChecked returns.
+	ErrInvalidSymbol = errors.New("invalid symbol")
+
+	// ErrDepthUnsupported is reported by JoinDepth when the provider has no
+	// market depth channels.
+	ErrDepthUnsupported = errors.New("market depth is only available from QUODD")
+
+	// ErrConflictingAuth is returned by Connect when WithAPIKey was given
+	// together with a username or password.
+	ErrConflictingAuth = errors.New("an API key and a username or password are mutually exclusive")
+
+	// ErrNoRedial is returned when the connection given with WithConn is
+	// lost and there is no redial function to replace it.
+	ErrNoRedial = errors.New("connection given with WithConn was lost and there is no redial function")
+)
+
+// IsFatal reports whether err is a failure that retrying cannot fix, such as
+// rejected credentials or a protocol violation. The reconnect loop gives up
+// as soon as it sees one.
+func IsFatal(err error) bool {
+	for err != nil {
+		switch err {
+		case ErrStaticToken, ErrStaticTokenRejected, ErrNoRedial, context.Canceled, context.DeadlineExceeded:
+			return true
+		}
+		if f, ok := err.(interface{ Fatal() bool }); ok {
+			return f.Fatal()
+		}
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return false
+		}
+		err = u.Unwrap()
+	}
+	return false
+}
+
+// IsTransient reports whether err is a failure that may go away on retry,
+// such as a network error, a 5xx from the auth endpoint or an abnormal
+// closure.
+func IsTransient(err error) bool {
+	return err != nil && !IsFatal(err)
+}
+
+// HeartbeatTimeoutError is reported through OnError when the server stopped
+// acknowledging heartbeats and the connection is being replaced.
+type HeartbeatTimeoutError struct {
+	Missed int
+}
+
+func (e *HeartbeatTimeoutError) Error() string {
+	return fmt.Sprintf("%d heartbeats were not acknowledged", e.Missed)
+}
+
+// CloseError is reported through OnError whenever the websocket is closed
+// with a close frame, including normal closures. Code is one of the close
+// codes defined by RFC 6455, e.g. websocket.CloseNormalClosure (1000),
+// websocket.ClosePolicyViolation (1008) or websocket.CloseTryAgainLater (1013).
+type CloseError struct {
+	Code int
+	Text string
+	// ByServer is true when the server closed the connection rather than
+	// the client.
+	ByServer bool
+}
+
+// Fatal reports whether the close code means the server will not accept the
+// session as it is: a protocol error, unsupported or invalid data, a policy
+// violation or a missing extension.
+func (e *CloseError) Fatal() bool {
+	switch e.Code {
+	case websocket.CloseProtocolError, websocket.CloseUnsupportedData, websocket.CloseInvalidFramePayloadData,
+		websocket.ClosePolicyViolation, websocket.CloseMandatoryExtension:
+		return true
+	}
+	return false
+}
+
+func (e *CloseError) Error() string {
+	by := "client"
+	if e.ByServer {
+		by = "server"
+	}
+	return fmt.Sprintf("websocket closed by %s: %d %s", by, e.Code, e.Text)
+}
+
+// DroppedMessageError is reported through OnError when a join or leave could
+// not be handed to the websocket. Err is ErrSendQueueFull when the send queue
+// had no room, and ErrClientClosed when the connection was closing. A join or
+// leave dropped for a full queue is tried again on the next Join or Leave.
+// Message is nil for a message of a Provider of your own that isn't a map.
+type DroppedMessageError struct {
+	Message map[string]interface{}
+	Err     error
+}
+
+func (e *DroppedMessageError) Error() string {
+	if e.Err == ErrSendQueueFull {
+		return fmt.Sprintf("message dropped, send queue full: %v", e.Message)
+	}
+	return fmt.Sprintf("message dropped while closing: %v", e.Message)
+}
+
+func (e *DroppedMessageError) Unwrap() error {
+	return e.Err
+}
+
+// AuthError is returned when no token could be obtained from the auth
+// endpoint. StatusCode is the last HTTP status received, or zero when the
+// last attempt failed before a response arrived.
+type AuthError struct {
+	StatusCode int
+	Attempts   int
+	Err        error
+}
+
+func (e *AuthError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("auth failed after %d attempts: %v", e.Attempts, e.Err)
+	}
+	return fmt.Sprintf("auth failed with status %d after %d attempts", e.StatusCode, e.Attempts)
+}
+
+// Fatal reports whether the auth endpoint refused the request for good. Any
+// 4xx other than 408 Request Timeout and 429 Too Many Requests is fatal.
+func (e *AuthError) Fatal() bool {
+	return 400 <= e.StatusCode && e.StatusCode < 500 &&
+		e.StatusCode != http.StatusRequestTimeout && e.StatusCode != http.StatusTooManyRequests
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// RateLimitedError is returned when the auth endpoint kept answering 429 Too
+// Many Requests. RetryAfter is the wait the server asked for last.
+type RateLimitedError struct {
+	RetryAfter time.Duration
+	Attempts   int
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("auth rate limited after %d attempts, retry after %v", e.Attempts, e.RetryAfter)
+}
+
+// LeaveError is reported through OnError when Disconnect could not
+// unsubscribe some channels before closing the connection.
+type LeaveError struct {
+	Channels []string
+	Err      error
+}
+
+func (e *LeaveError) Error() string {
+	return fmt.Sprintf("could not leave %v: %v", e.Channels, e.Err)
+}
+
+func (e *LeaveError) Unwrap() error {
+	return e.Err
+}
+
+// JoinError is passed to OnJoinError when the server rejected a join. Err
+// is an *EntitlementError when the account isn't entitled to the channel,
+// nil otherwise.
+type JoinError struct {
+	Channel string
+	Reason  string
+	Err     error
+}
+
+func (e *JoinError) Error() string {
+	return fmt.Sprintf("join %s rejected: %s", e.Channel, e.Reason)
+}
+
+func (e *JoinError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeError is reported through OnError when a payload could not be
+// decoded into the type of an OnQuoteAs handler. Data is the payload as
+// JSON.
+type DecodeError struct {
+	Target string
+	Data   []byte
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("decode payload into %s: %v", e.Target, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// MalformedMessageError is reported through OnError with WithStrictDecoding
+// for a frame that isn't valid JSON or doesn't look like a message of the
+// provider. The frame isn't passed to the quote handlers; Data is the frame
+// as received.
+type MalformedMessageError struct {
+	Data []byte
+	Err  error
+}
+
+func (e *MalformedMessageError) Error() string {
+	return fmt.Sprintf("malformed message: %v", e.Err)
+}
+
+func (e *MalformedMessageError) Unwrap() error {
+	return e.Err
+}
+
+// EntitlementError is reported through OnError when the server said the
+// account isn't entitled to a channel: Ticker is the channel as joined, such
+// as "$lobby" or "TSLA.NB", or empty when QUODD said so in general. Message
+// is the server's own text.
+type EntitlementError struct {
+	Ticker  string
+	Message string
+}
+
+func (e *EntitlementError) Error() string {
+	if e.Ticker == "" {
+		return fmt.Sprintf("not entitled: %s", e.Message)
+	}
+	return fmt.Sprintf("not entitled to %s: %s", e.Ticker, e.Message)
+}
+
+// SymbolError is returned by JoinChecked for a channel that isn't a valid
+// symbol of the provider. It matches ErrInvalidSymbol.
+type SymbolError struct {
+	Symbol string
+	Reason string
+}
+
+func (e *SymbolError) Error() string {
+	return fmt.Sprintf("invalid symbol %q: %s", e.Symbol, e.Reason)
+}
+
+func (e *SymbolError) Unwrap() error {
+	return ErrInvalidSymbol
+}
+
+// AmbiguousSymbolError is returned for a bare symbol whose QUODD exchange
+// isn't known: none was given for it, or Exchanges are all the ones that
+// were. It matches ErrInvalidSymbol.
+type AmbiguousSymbolError struct {
+	Symbol    string
+	Exchanges []string
+}
+
+func (e *AmbiguousSymbolError) Error() string {
+	if len(e.Exchanges) == 0 {
+		return fmt.Sprintf("no exchange for symbol %q", e.Symbol)
+	}
+	return fmt.Sprintf("symbol %q is on several exchanges: %s", e.Symbol, strings.Join(e.Exchanges, ", "))
+}
+
+func (e *AmbiguousSymbolError) Unwrap() error {
+	return ErrInvalidSymbol
+}
+
+// HandlerPanicError is reported through OnError when a handler panicked. The
+// client recovers and carries on; Stack is the panicking goroutine's stack.
+type HandlerPanicError struct {
+	Handler string
+	Value   interface{}
+	Stack   []byte
+}
+
+func (e *HandlerPanicError) Error() string {
+	return fmt.Sprintf("%s handler panicked: %v", e.Handler, e.Value)
+}
+
+// WriteError is reported through OnError when a message could not be written
+// to the websocket. Event is the message's event, and Channel the channel it
+// joins or leaves, if any. The connection is replaced afterwards, which
+// rejoins the channels.
+type WriteError struct {
+	Event   string
+	Channel string
+	Err     error
+}
+
+func newWriteError(frame interface{}, err error) *WriteError {
+	e := &WriteError{Err: err}
+	msg, _ := frame.(map[string]interface{})
+	e.Event, _ = msg["event"].(string)
+	if data, ok := msg["data"].(map[string]string); ok {
+		e.Channel = data["ticker"]
+		if data["type"] == "depth" {
+			e.Channel = DepthChannel(e.Channel)
+		}
+	} else if topic, ok := msg["topic"].(string); ok {
+		e.Channel = topic
+	}
+	return e
+}
+
+func (e *WriteError) Error() string {
+	if e.Channel == "" {
+		return fmt.Sprintf("write %s failed: %v", e.Event, e.Err)
+	}
+	return fmt.Sprintf("write %s for %s failed: %v", e.Event, e.Channel, e.Err)
+}
+
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+// ProviderError is an error of one of a MultiClient's connections. The
+// errors of a MultiClient's Connect and Disconnect are ProviderErrors, joined
+// when several connections failed; errors.As finds them.
+type ProviderError struct {
+	Provider provider
+	Err      error
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Provider, e.Err)
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigError is a field of the Config of NewFromConfig, or an environment
+// variable of NewFromEnv, that is wrong.
+type ConfigError struct {
+	Field string // such as "Provider" or "INTRINIO_PROVIDER"
+	Err   error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Field, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,51 @@
+package intriniorealtime
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestIsFatal(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "認証APIの401は致命的であること", err: &AuthError{StatusCode: http.StatusUnauthorized, Attempts: 1}, want: true},
+		{name: "認証APIの403は致命的であること", err: &AuthError{StatusCode: http.StatusForbidden, Attempts: 1}, want: true},
+		{name: "認証APIの408は一時的であること", err: &AuthError{StatusCode: http.StatusRequestTimeout, Attempts: 1}, want: false},
+		{name: "認証APIの503は一時的であること", err: &AuthError{StatusCode: http.StatusServiceUnavailable, Attempts: 4}, want: false},
+		{name: "認証APIに届かなかったときは一時的であること", err: &AuthError{Attempts: 4, Err: io.ErrUnexpectedEOF}, want: false},
+		{name: "レート制限は一時的であること", err: &RateLimitedError{Attempts: 4}, want: false},
+		{name: "ポリシー違反のクローズは致命的であること", err: &CloseError{Code: websocket.ClosePolicyViolation, ByServer: true}, want: true},
+		{name: "プロトコルエラーのクローズは致命的であること", err: &CloseError{Code: websocket.CloseProtocolError, ByServer: true}, want: true},
+		{name: "異常終了のクローズは一時的であること", err: &CloseError{Code: websocket.CloseAbnormalClosure}, want: false},
+		{name: "GoingAwayのクローズは一時的であること", err: &CloseError{Code: websocket.CloseGoingAway, ByServer: true}, want: false},
+		{name: "TryAgainLaterのクローズは一時的であること", err: &CloseError{Code: websocket.CloseTryAgainLater, ByServer: true}, want: false},
+		{name: "ダイヤルのタイムアウトは一時的であること", err: &net.OpError{Op: "dial", Err: errors.New("i/o timeout")}, want: false},
+		{name: "接続できるエンドポイントがないときは一時的であること", err: &DialError{URLs: []string{"wss://a"}, Errors: []error{io.EOF}}, want: false},
+		{name: "WithTokenのトークンが拒否されたら致命的であること", err: ErrStaticTokenRejected, want: true},
+		{name: "コンテキストのキャンセルは致命的であること", err: context.Canceled, want: true},
+		{name: "ハートビートの応答切れは一時的であること", err: &HeartbeatTimeoutError{Missed: 3}, want: false},
+		{name: "無通信による再接続は一時的であること", err: ErrStaleConnection, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsFatal(tt.err); got != tt.want {
+				t.Errorf("IsFatal(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+			if got := IsTransient(tt.err); got == tt.want {
+				t.Errorf("IsTransient(%v) = %v, want %v", tt.err, got, !tt.want)
+			}
+		})
+	}
+	if IsFatal(nil) || IsTransient(nil) {
+		t.Error("nil is classified")
+	}
+}
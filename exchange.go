@@ -0,0 +1,123 @@
+package intriniorealtime
+
+import "strings"
+
+// Exchange is the market center a quote or trade came from.
+type Exchange struct {
+	// Code is the code as the feed sent it.
+	Code string
+
+	name        string
+	offExchange bool
+}
+
+// Name returns the name of the market center, or Code when it isn't in the
+// tables of this package.
+func (e Exchange) Name() string {
+	if e.name == "" {
+		return e.Code
+	}
+	return e.name
+}
+
+// Known reports whether Code is in the tables of this package.
+func (e Exchange) Known() bool {
+	return e.name != ""
+}
+
+// OffExchange reports a FINRA facility, the alternative display facility or
+// a trade reporting facility, which is where dark pool and other
+// off-exchange trades are reported.
+func (e Exchange) OffExchange() bool {
+	return e.offExchange
+}
+
+func (e Exchange) String() string {
+	return e.Name()
+}
+
+type exchangeInfo struct {
+	name        string
+	offExchange bool
+}
+
+// quoddExchanges are the one letter participant IDs of the consolidated
+// tapes, which QUODD sends in lower case.
+var quoddExchanges = map[string]exchangeInfo{
+	"A": {name: "NYSE American"},
+	"B": {name: "Nasdaq BX"},
+	"C": {name: "NYSE National"},
+	"D": {name: "FINRA ADF", offExchange: true},
+	"H": {name: "MIAX Pearl"},
+	"I": {name: "Nasdaq ISE"},
+	"J": {name: "Cboe EDGA"},
+	"K": {name: "Cboe EDGX"},
+	"L": {name: "Long-Term Stock Exchange"},
+	"M": {name: "NYSE Chicago"},
+	"N": {name: "New York Stock Exchange"},
+	"P": {name: "NYSE Arca"},
+	"Q": {name: "Nasdaq"},
+	"T": {name: "Nasdaq"},
+	"U": {name: "MEMX"},
+	"V": {name: "IEX"},
+	"W": {name: "Cboe"},
+	"X": {name: "Nasdaq PSX"},
+	"Y": {name: "Cboe BYX"},
+	"Z": {name: "Cboe BZX"},
+}
+
+// iexExchanges are the ISO 10383 market identifier codes IEX uses for
+// venues, its own being IEXG.
+var iexExchanges = map[string]exchangeInfo{
+	"IEXG": {name: "IEX"},
+	"XASE": {name: "NYSE American"},
+	"XBOS": {name: "Nasdaq BX"},
+	"XCIS": {name: "NYSE National"},
+	"XADF": {name: "FINRA ADF", offExchange: true},
+	"FINN": {name: "FINRA/NYSE TRF", offExchange: true},
+	"FINC": {name: "FINRA/Nasdaq TRF Carteret", offExchange: true},
+	"FINY": {name: "FINRA/Nasdaq TRF Chicago", offExchange: true},
+	"MPRL": {name: "MIAX Pearl"},
+	"XISX": {name: "Nasdaq ISE"},
+	"EDGA": {name: "Cboe EDGA"},
+	"EDGX": {name: "Cboe EDGX"},
+	"LTSE": {name: "Long-Term Stock Exchange"},
+	"XCHI": {name: "NYSE Chicago"},
+	"XNYS": {name: "New York Stock Exchange"},
+	"ARCX": {name: "NYSE Arca"},
+	"XNAS": {name: "Nasdaq"},
+	"MEMX": {name: "MEMX"},
+	"XPHL": {name: "Nasdaq PSX"},
+	"BATY": {name: "Cboe BYX"},
+	"BATS": {name: "Cboe BZX"},
+}
+
+// iexVenue is where everything on the IEX feed happened: IEX only sends its
+// own quotes and trades.
+var iexVenue = LookupExchange("IEXG")
+
+// LookupExchange returns the market center code stands for: a one letter
+// participant ID as QUODD sends it, or a four letter market identifier code
+// as IEX uses them. Codes in neither table are returned untranslated, with
+// Name returning the code itself.
+func LookupExchange(code string) Exchange {
+	table := iexExchanges
+	if len(code) == 1 {
+		table = quoddExchanges
+	}
+	info, ok := table[strings.ToUpper(code)]
+	if !ok {
+		return Exchange{Code: code}
+	}
+	return Exchange{Code: code, name: info.name, offExchange: info.offExchange}
+}
+
+// optExchange looks up the string field key of data, if it is there.
+func optExchange(data map[string]interface{}, key string) *Exchange {
+	code := optString(data, key)
+	if code == nil {
+		return nil
+	}
+	e := LookupExchange(*code)
+	return &e
+}
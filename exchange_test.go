@@ -0,0 +1,73 @@
+package intriniorealtime
+
+import "testing"
+
+func exchangep(code string) *Exchange {
+	e := LookupExchange(code)
+	return &e
+}
+
+func TestLookupExchange(t *testing.T) {
+	tests := []struct {
+		name            string
+		code            string
+		wantName        string
+		wantKnown       bool
+		wantOffExchange bool
+	}{
+		{name: "QUODDの小文字のコードを引くこと", code: "t", wantName: "Nasdaq", wantKnown: true},
+		{name: "QUODDの大文字のコードも引くこと", code: "N", wantName: "New York Stock Exchange", wantKnown: true},
+		{name: "QUODDのIEXを引くこと", code: "v", wantName: "IEX", wantKnown: true},
+		{name: "QUODDのFINRA ADFを取引所外にすること", code: "d", wantName: "FINRA ADF", wantKnown: true, wantOffExchange: true},
+		{name: "IEXの自身のコードを引くこと", code: "IEXG", wantName: "IEX", wantKnown: true},
+		{name: "IEXの取引所のコードを引くこと", code: "XNYS", wantName: "New York Stock Exchange", wantKnown: true},
+		{name: "FINRA ADFのMICを取引所外にすること", code: "XADF", wantName: "FINRA ADF", wantKnown: true, wantOffExchange: true},
+		{name: "FINRA/NYSE TRFを区別すること", code: "FINN", wantName: "FINRA/NYSE TRF", wantKnown: true, wantOffExchange: true},
+		{name: "FINRA/Nasdaq TRF Carteretを区別すること", code: "FINC", wantName: "FINRA/Nasdaq TRF Carteret", wantKnown: true, wantOffExchange: true},
+		{name: "FINRA/Nasdaq TRF Chicagoを区別すること", code: "FINY", wantName: "FINRA/Nasdaq TRF Chicago", wantKnown: true, wantOffExchange: true},
+		{name: "知らない1文字のコードはそのまま返すこと", code: "g", wantName: "g"},
+		{name: "知らないMICはそのまま返すこと", code: "XLON", wantName: "XLON"},
+		{name: "空のコードは空のまま返すこと", code: "", wantName: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := LookupExchange(tt.code)
+			if got.Code != tt.code || got.Name() != tt.wantName || got.Known() != tt.wantKnown || got.OffExchange() != tt.wantOffExchange {
+				t.Errorf("LookupExchange(%q) = %q %q known=%v off=%v, want %q known=%v off=%v",
+					tt.code, got.Code, got.Name(), got.Known(), got.OffExchange(), tt.wantName, tt.wantKnown, tt.wantOffExchange)
+			}
+		})
+	}
+}
+
+func TestTradeVenue(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider provider
+		msg      map[string]interface{}
+		want     string
+	}{
+		{name: "IEXのトレードはIEXにすること", provider: IEX, msg: loadFixture(t, "iex_quote.json"), want: "IEX"},
+		{name: "QUODDのトレードは約定した取引所にすること", provider: QUODD, msg: loadFixture(t, "quodd_trade.json"), want: "Nasdaq"},
+		{
+			name:     "QUODDの時間外のトレードは時間外の取引所にすること",
+			provider: QUODD,
+			msg:      map[string]interface{}{"event": "trade", "data": map[string]interface{}{"ticker": "AAPL.NB", "ext_last_price_4d": float64(1579000), "ext_trade_exchange": "d"}},
+			want:     "FINRA ADF",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.provider == IEX {
+				tt.msg["payload"].(map[string]interface{})["type"] = "last"
+			}
+			trade, ok := parseTrade(tt.provider, tt.msg)
+			if !ok {
+				t.Fatal("parseTrade() ok = false")
+			}
+			if trade.Venue.Name() != tt.want {
+				t.Errorf("Venue = %q, want %q", trade.Venue.Name(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,376 @@
+package intriniorealtime
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeServer is a local stand-in for the Intrinio auth endpoint and websocket.
+type fakeServer struct {
+	srv      *httptest.Server
+	upgrader websocket.Upgrader
+
+	mu         sync.Mutex
+	authCalls  int
+	apiKeys    []string
+	authReqs   []*http.Request
+	dialTokens []string
+	upgrades   []http.Header // the headers of every websocket handshake
+	authFail   int
+	authFailN  int
+	retryAfter string
+	revoked    map[string]bool
+	conns      []*websocket.Conn
+	v2         map[*websocket.Conn]bool // dialed with PhoenixV2
+	received   []map[string]interface{}
+	stall      bool
+	readDelay  time.Duration // before every frame the server reads
+	closes     []int
+
+	// reply is called for every frame the server receives, when set.
+	reply func(s *fakeServer, conn *websocket.Conn, msg map[string]interface{})
+}
+
+func newFakeServer() *fakeServer {
+	s := &fakeServer{}
+	s.srv = httptest.NewServer(s.handler())
+	return s
+}
+
+// newFakeTLSServer is newFakeServer on https and wss, with a certificate of
+// httptest's own CA. With clientCAs it also requires a client certificate
+// signed by one of them.
+func newFakeTLSServer(clientCAs *x509.CertPool) *fakeServer {
+	s := &fakeServer{}
+	s.srv = httptest.NewUnstartedServer(s.handler())
+	if clientCAs != nil {
+		s.srv.TLS = &tls.Config{ClientCAs: clientCAs, ClientAuth: tls.RequireAndVerifyClientCert}
+	}
+	s.srv.StartTLS()
+	return s
+}
+
+func (s *fakeServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/auth", s.handleAuth)
+	mux.HandleFunc("/socket", s.handleSocket)
+	mux.HandleFunc("/socket/", s.handleSocket)
+	return mux
+}
+
+func (s *fakeServer) handleAuth(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.authCalls++
+	s.authReqs = append(s.authReqs, r.Clone(r.Context()))
+	if key := r.URL.Query().Get("api_key"); key != "" {
+		s.apiKeys = append(s.apiKeys, key)
+	}
+	status := s.authFail
+	if 0 < s.authFailN {
+		s.authFailN--
+		if s.authFailN == 0 {
+			s.authFail = 0
+		}
+	}
+	token := fmt.Sprintf("token-%d", s.authCalls)
+	retryAfter := s.retryAfter
+	s.mu.Unlock()
+	if retryAfter != "" {
+		w.Header().Set("Retry-After", retryAfter)
+	}
+	if status != 0 {
+		w.WriteHeader(status)
+		return
+	}
+	w.Write([]byte(token))
+}
+
+func (s *fakeServer) handleSocket(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	revoked := s.revoked[requestToken(r)]
+	s.dialTokens = append(s.dialTokens, requestToken(r))
+	s.upgrades = append(s.upgrades, r.Header.Clone())
+	s.mu.Unlock()
+	if revoked {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	s.conns = append(s.conns, conn)
+	if r.URL.Query().Get("vsn") == PhoenixV2 {
+		if s.v2 == nil {
+			s.v2 = make(map[*websocket.Conn]bool)
+		}
+		s.v2[conn] = true
+	}
+	stall := s.stall
+	s.mu.Unlock()
+	if stall {
+		// Never read, so the client's writes eventually block.
+		return
+	}
+	for {
+		s.mu.Lock()
+		delay := s.readDelay
+		s.mu.Unlock()
+		time.Sleep(delay)
+		var msg map[string]interface{}
+		_, data, err := conn.ReadMessage()
+		if err == nil {
+			// Array frames are read like the objects of PhoenixV1.
+			if data, err = phoenixEnvelope(data); err == nil {
+				err = json.Unmarshal(data, &msg)
+			}
+		}
+		if err != nil {
+			if ce, ok := err.(*websocket.CloseError); ok {
+				s.mu.Lock()
+				s.closes = append(s.closes, ce.Code)
+				s.mu.Unlock()
+			}
+			conn.Close()
+			return
+		}
+		s.mu.Lock()
+		s.received = append(s.received, msg)
+		reply := s.reply
+		s.mu.Unlock()
+		if reply != nil {
+			reply(s, conn, msg)
+		}
+	}
+}
+
+// ackHeartbeats answers heartbeats the way the real providers do.
+func ackHeartbeats(s *fakeServer, conn *websocket.Conn, msg map[string]interface{}) {
+	if msg["event"] == "heartbeat" {
+		if msg["topic"] == "phoenix" {
+			s.send(conn, map[string]interface{}{
+				"topic":   "phoenix",
+				"event":   "phx_reply",
+				"payload": map[string]interface{}{"status": "ok", "response": map[string]interface{}{}},
+				"ref":     nil,
+			})
+			return
+		}
+		s.send(conn, msg)
+	}
+}
+
+func (s *fakeServer) setReply(reply func(s *fakeServer, conn *websocket.Conn, msg map[string]interface{})) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reply = reply
+}
+
+// requestToken extracts the token from either provider's websocket URL.
+func requestToken(r *http.Request) string {
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token
+	}
+	return strings.TrimPrefix(r.URL.Path, "/socket/")
+}
+
+// revoke makes the websocket endpoint reject token.
+func (s *fakeServer) revoke(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.revoked == nil {
+		s.revoked = make(map[string]bool)
+	}
+	s.revoked[token] = true
+}
+
+// setAuthStatus makes the auth endpoint answer with status; zero restores 200.
+func (s *fakeServer) setAuthStatus(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authFail = status
+}
+
+// failAuth makes the next n auth calls answer with status.
+func (s *fakeServer) failAuth(status, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authFail = status
+	s.authFailN = n
+}
+
+// setRetryAfter makes failed auth calls carry a Retry-After header.
+func (s *fakeServer) setRetryAfter(v string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retryAfter = v
+}
+
+func (s *fakeServer) setStall(stall bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stall = stall
+}
+
+func (s *fakeServer) authURL() string {
+	return s.srv.URL + "/auth"
+}
+
+func (s *fakeServer) soketURL() string {
+	return "ws" + strings.TrimPrefix(s.srv.URL, "http") + "/socket"
+}
+
+// fastReconnect keeps reconnect tests quick.
+var fastReconnect = ReconnectPolicy{
+	InitialDelay: 10 * time.Millisecond,
+	Multiplier:   2,
+	MaxDelay:     50 * time.Millisecond,
+}
+
+// fastAuthRetry keeps auth retries quick.
+var fastAuthRetry = ReconnectPolicy{
+	InitialDelay: time.Millisecond,
+	Multiplier:   2,
+	MaxDelay:     5 * time.Millisecond,
+	MaxAttempts:  4,
+}
+
+func (s *fakeServer) newClient(provider provider, opts ...Option) *Client {
+	defaults := []Option{WithReconnectPolicy(fastReconnect), WithAuthURL(s.authURL()), WithWebsocketURL(s.soketURL())}
+	cli := New("user", "pass", provider, append(defaults, opts...)...)
+	cli.authRetry = fastAuthRetry
+	return cli
+}
+
+// send writes v to conn, as an array frame if v is a phoenix message and
+// conn was dialed with PhoenixV2.
+func (s *fakeServer) send(conn *websocket.Conn, v interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if msg, ok := v.(map[string]interface{}); ok && s.v2[conn] && msg["topic"] != nil {
+		v = []interface{}{msg["join_ref"], msg["ref"], msg["topic"], msg["event"], msg["payload"]}
+	}
+	return conn.WriteJSON(v)
+}
+
+// broadcastRaw sends data as is to every connection.
+func (s *fakeServer) broadcastRaw(messageType int, data []byte) {
+	for _, conn := range s.connections() {
+		s.mu.Lock()
+		conn.WriteMessage(messageType, data)
+		s.mu.Unlock()
+	}
+}
+
+func (s *fakeServer) broadcast(v interface{}) {
+	for _, conn := range s.connections() {
+		s.send(conn, v)
+	}
+}
+
+// kick closes every live connection with the given close code.
+func (s *fakeServer) kick(code int, text string) {
+	for _, conn := range s.connections() {
+		s.mu.Lock()
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(time.Second))
+		s.mu.Unlock()
+		conn.Close()
+	}
+	s.mu.Lock()
+	s.conns = nil
+	s.mu.Unlock()
+}
+
+func (s *fakeServer) connections() []*websocket.Conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*websocket.Conn(nil), s.conns...)
+}
+
+func (s *fakeServer) messages() []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]map[string]interface{}(nil), s.received...)
+}
+
+func (s *fakeServer) messagesWithEvent(event string) []map[string]interface{} {
+	var ret []map[string]interface{}
+	for _, msg := range s.messages() {
+		if msg["event"] == event {
+			ret = append(ret, msg)
+		}
+	}
+	return ret
+}
+
+// closeCodes returns the close codes the server received from clients.
+func (s *fakeServer) closeCodes() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int(nil), s.closes...)
+}
+
+// authKeys returns the API keys REALTIME clients passed to the auth
+// endpoint.
+func (s *fakeServer) authKeys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.apiKeys...)
+}
+
+func (s *fakeServer) authCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.authCalls
+}
+
+func (s *fakeServer) Close() {
+	for _, conn := range s.connections() {
+		conn.Close()
+	}
+	s.srv.Close()
+}
+
+func waitUntil(d time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(d)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return cond()
+}
+
+// quoteOnSubscribe answers every QUODD subscribe with a quote for the ticker.
+func quoteOnSubscribe(s *fakeServer, conn *websocket.Conn, msg map[string]interface{}) {
+	if msg["event"] != "subscribe" {
+		return
+	}
+	data, _ := msg["data"].(map[string]interface{})
+	s.send(conn, map[string]interface{}{
+		"event": "quote",
+		"data":  map[string]interface{}{"ticker": data["ticker"], "bid_price_4d": 1594800},
+	})
+}
+
+// subscribedTickers returns the tickers the server saw QUODD subscribes for.
+func (s *fakeServer) subscribedTickers() []string {
+	var ret []string
+	for _, msg := range s.messagesWithEvent("subscribe") {
+		data, _ := msg["data"].(map[string]interface{})
+		ticker, _ := data["ticker"].(string)
+		ret = append(ret, ticker)
+	}
+	return ret
+}
@@ -0,0 +1,105 @@
+package intriniorealtime
+
+import (
+	"strings"
+	"time"
+)
+
+// fxPrefix starts the topic of an FXCM channel, a currency pair.
+const fxPrefix = "fxcm:"
+
+// fxPairSep separates the base and the quote currency of an FXCM channel,
+// as in "EUR/USD".
+const fxPairSep = "/"
+
+// FxChannel returns the FXCM channel of pair, a base and a quote currency
+// written "EUR/USD", "EURUSD", "EUR-USD" or "eur_usd". It can be passed to
+// Join and Leave like any other channel. A pair it can't split into two
+// codes is returned normalized, like Join would.
+func FxChannel(pair string) string {
+	p := normalizeChannel(pair)
+	compact := strings.NewReplacer("/", "", "-", "", "_", "", " ", "").Replace(p)
+	if len(compact) == 6 && fxCurrency(compact[:3]) && fxCurrency(compact[3:]) {
+		return compact[:3] + fxPairSep + compact[3:]
+	}
+	return p
+}
+
+// validateFxChannel checks a normalized FXCM channel: two currency codes of
+// three letters separated by a slash.
+func validateFxChannel(channel string) error {
+	currencies := strings.Split(channel, fxPairSep)
+	if len(currencies) != 2 || !fxCurrency(currencies[0]) || !fxCurrency(currencies[1]) {
+		return &SymbolError{Symbol: channel, Reason: "not a currency pair such as EUR/USD, see FxChannel"}
+	}
+	return nil
+}
+
+func fxCurrency(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, r := range s {
+		if r < 'A' || 'Z' < r {
+			return false
+		}
+	}
+	return true
+}
+
+// FxQuote is the bid and ask of a currency pair. FX trades around the clock
+// from Sunday evening to Friday evening, New York time, so quotes come
+// outside equity market hours too, and stop over the weekend.
+type FxQuote struct {
+	Pair         string // e.g. "EUR/USD"
+	Bid          float64
+	Ask          float64
+	Timestamp    time.Time
+	RawTimestamp RawTime
+
+	// Raw is the payload as received; don't modify it.
+	Raw map[string]interface{}
+}
+
+// OnFxQuote registers a handler for FXCM quotes. They go to OnQuote as
+// well, but not to OnBid and OnAsk, which are sized.
+func (cli *Client) OnFxQuote(f func(quote FxQuote)) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
+	cli.fxQuoteHandler = f
+}
+
+// fxPayload returns the payload of msg if it is an FXCM message with one of
+// the given events.
+//
+// FXCM sends the prices of a pair on its channel as price_update events
+// whose payload has the pair's code, bid_price, ask_price and the timestamp
+// in milliseconds since the epoch. Prices have up to five decimals and
+// there are no sizes.
+func fxPayload(provider provider, msg map[string]interface{}, events ...string) (map[string]interface{}, bool) {
+	if provider != FXCM {
+		return nil, false
+	}
+	for _, event := range events {
+		if msg["event"] == event {
+			payload, ok := msg["payload"].(map[string]interface{})
+			return payload, ok
+		}
+	}
+	return nil, false
+}
+
+// parseFxQuote returns the quote carried by msg, if it is one.
+func parseFxQuote(provider provider, msg map[string]interface{}) (FxQuote, bool) {
+	payload, ok := fxPayload(provider, msg, "price_update")
+	if !ok {
+		return FxQuote{}, false
+	}
+	quote := FxQuote{Raw: payload}
+	quote.Pair, _ = SymbolFromMessage(provider, msg)
+	quote.Bid, _ = number(payload["bid_price"])
+	quote.Ask, _ = number(payload["ask_price"])
+	at := payload["timestamp"]
+	quote.Timestamp, quote.RawTimestamp = unixTime(at, timeUnit(provider, "timestamp", at))
+	return quote, true
+}
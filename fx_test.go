@@ -0,0 +1,89 @@
+package intriniorealtime
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFxChannel(t *testing.T) {
+	tests := []struct {
+		name string
+		pair string
+		want string
+	}{
+		{name: "スラッシュ区切りを正規化すること", pair: " eur/usd ", want: "EUR/USD"},
+		{name: "区切りのない組を分けること", pair: "eurusd", want: "EUR/USD"},
+		{name: "ハイフン区切りをスラッシュにすること", pair: "EUR-USD", want: "EUR/USD"},
+		{name: "アンダースコア区切りをスラッシュにすること", pair: "usd_jpy", want: "USD/JPY"},
+		{name: "分けられない組はそのまま正規化すること", pair: "us30", want: "US30"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FxChannel(tt.pair)
+			if got != tt.want {
+				t.Fatalf("FxChannel() = %q, want %q", got, tt.want)
+			}
+			topic := parseTopic(FXCM, got)
+			if topic != "fxcm:"+tt.want {
+				t.Errorf("parseTopic() = %q, want %q", topic, "fxcm:"+tt.want)
+			}
+			if channel := topicChannel(topic); channel != got {
+				t.Errorf("topicChannel(%q) = %q, want %q", topic, channel, got)
+			}
+		})
+	}
+}
+
+func TestParseFxQuote(t *testing.T) {
+	cli := New(yourIntrinioAPIUserName, "", FXCM)
+
+	msg := decodeFixture(t, cli, "fx_price_update.json")
+	quote, ok := parseFxQuote(FXCM, msg)
+	want := FxQuote{Pair: "EUR/USD", Bid: 1.16523, Ask: 1.16531,
+		Timestamp: time.Unix(1760621400, 789000000).UTC(), RawTimestamp: RawTime{Value: 1760621400789, Unit: Milliseconds}}
+	quote.Raw = nil
+	if !ok || !reflect.DeepEqual(quote, want) {
+		t.Errorf("parseFxQuote() = %+v, %v, want %+v", quote, ok, want)
+	}
+	if got := Classify(FXCM, msg); got != MessageQuote {
+		t.Errorf("Classify() = %v, want %v", got, MessageQuote)
+	}
+	if err := checkSchema(FXCM, msg); err != nil {
+		t.Errorf("checkSchema() = %v, want nil", err)
+	}
+	if _, ok := parseFxQuote(CRYPTOQUOTE, msg); ok {
+		t.Error("parseFxQuote(CRYPTOQUOTE) = true, want false")
+	}
+}
+
+func TestClientFXCM(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+	server.setReply(realtimeDialect(loadBinary(t, "fx_price_update.json")))
+
+	quotes := make(chan FxQuote, 10)
+	sut := server.newClient(FXCM)
+	sut.OnFxQuote(func(quote FxQuote) { quotes <- quote })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+	channel := FxChannel("eurusd")
+	if err := sut.JoinChecked(channel); err != nil {
+		t.Fatalf("JoinChecked(%q) error = %v", channel, err)
+	}
+
+	if quote := receive(t, "OnFxQuote()", quotes); quote.Pair != "EUR/USD" || quote.Bid != 1.16523 || quote.Ask != 1.16531 {
+		t.Errorf("OnFxQuote() = %+v, want EUR/USD at 1.16523/1.16531", quote)
+	}
+	if !waitUntil(time.Second, func() bool { return sut.Confirmed(channel) }) {
+		t.Errorf("Confirmed(%s) = false, want the join answered", channel)
+	}
+	if joins := server.messagesWithEvent("phx_join"); len(joins) != 1 || joins[0]["topic"] != "fxcm:EUR/USD" {
+		t.Errorf("joins = %v, want one of fxcm:EUR/USD", joins)
+	}
+	if keys := server.authKeys(); len(keys) == 0 || keys[0] != "user" {
+		t.Errorf("auth api_key = %v, want user", keys)
+	}
+}
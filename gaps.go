@@ -0,0 +1,131 @@
+package intriniorealtime
+
+import (
+	"sync"
+	"time"
+)
+
+// OnGap registers a handler for gaps in a channel's messages, with
+// WithGapTolerance. Neither feed numbers its messages, so a gap is two
+// consecutive messages for a symbol whose timestamps are further apart than
+// the tolerance; from and to are those timestamps. What was last seen is
+// kept across reconnects, so the first message after one reports what the
+// outage missed.
+func (cli *Client) OnGap(f func(channel string, from, to time.Time)) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
+	cli.gapHandler = f
+}
+
+func (cli *Client) onGap(channel string, from, to time.Time) {
+	cli.warn("gap", "channel", channel, "from", from, "to", to)
+	cli.handlerMu.RLock()
+	f := cli.gapHandler
+	cli.handlerMu.RUnlock()
+	if f != nil {
+		cli.callHandler("OnGap", func() { f(channel, from, to) })
+	}
+}
+
+// gapTracker holds the timestamp of the last message of each symbol.
+type gapTracker struct {
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+// observe notes a message for symbol at and returns the previous timestamp
+// if the two are more than tolerance apart. Messages older than the last
+// one don't move it back.
+func (g *gapTracker) observe(symbol string, at time.Time, tolerance time.Duration) (time.Time, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.last == nil {
+		g.last = make(map[string]time.Time)
+	}
+	last, seen := g.last[symbol]
+	if seen && !at.After(last) {
+		return time.Time{}, false
+	}
+	g.last[symbol] = at
+	return last, seen && tolerance < at.Sub(last)
+}
+
+// prune forgets the symbols keep returns false for.
+func (g *gapTracker) prune(keep func(symbol string) bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for symbol := range g.last {
+		if !keep(symbol) {
+			delete(g.last, symbol)
+		}
+	}
+}
+
+// len returns the number of symbols tracked.
+func (g *gapTracker) len() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.last)
+}
+
+// checkGap reports a gap before msg, if there is one.
+func (cli *Client) checkGap(msg map[string]interface{}) {
+	if cli.gapTolerance <= 0 {
+		return
+	}
+	symbol, at, ok := messageTime(cli.provider, msg)
+	if !ok {
+		return
+	}
+	if from, gap := cli.gaps.observe(symbol, at, cli.gapTolerance); gap {
+		cli.onGap(symbol, from, at)
+	}
+}
+
+// pruneGaps forgets the symbols that aren't joined any more. The symbols of a
+// lobby are kept as long as it is joined. mu must be held.
+func (cli *Client) pruneGaps() {
+	if cli.gapTolerance <= 0 {
+		return
+	}
+	lobby := 0 < cli.channels["$lobby"] || 0 < cli.channels["$lobby_last_price"]
+	cli.gaps.prune(func(symbol string) bool {
+		return lobby || 0 < cli.channels[symbol]
+	})
+}
+
+// messageTime returns the symbol and the timestamp of a quote or trade.
+func messageTime(provider provider, msg map[string]interface{}) (string, time.Time, bool) {
+	symbol, ok := SymbolFromMessage(provider, msg)
+	if !ok {
+		return "", time.Time{}, false
+	}
+	switch provider {
+	case IEX:
+		if quote, ok := parseIEXQuote(provider, msg); ok && !quote.Timestamp.IsZero() {
+			return symbol, quote.Timestamp, true
+		}
+	case REALTIME, DELAYED_SIP:
+		if trade, ok := parseRealtimeTrade(provider, msg); ok && !trade.Timestamp.IsZero() {
+			return symbol, trade.Timestamp, true
+		}
+		if side, _ := parseRealtimeSide(provider, msg); side != nil && !side.Timestamp.IsZero() {
+			return symbol, side.Timestamp, true
+		}
+	case NASDAQ_BASIC, OPRA, CRYPTOQUOTE, FXCM:
+		if payload, ok := quotePayload(provider, msg); ok {
+			at := payload["timestamp"]
+			if t, _ := unixTime(at, timeUnit(provider, "timestamp", at)); !t.IsZero() {
+				return symbol, t, true
+			}
+		}
+	case QUODD:
+		data, _ := quoddData(provider, msg, "quote", "quote_data", "trade", "trade_data")
+		for _, key := range []string{"quote_time", "trade_time", "ext_trade_time"} {
+			if at := unixMillis(data[key]); !at.IsZero() {
+				return symbol, at, true
+			}
+		}
+	}
+	return "", time.Time{}, false
+}
@@ -0,0 +1,144 @@
+package intriniorealtime
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestGapTrackerObserve(t *testing.T) {
+	t0 := time.Date(2017, 10, 16, 14, 44, 30, 0, time.UTC)
+	tests := []struct {
+		name     string
+		times    []time.Duration
+		wantGaps []time.Duration
+	}{
+		{name: "許容範囲内なら報告しないこと", times: []time.Duration{0, time.Second, 2 * time.Second}},
+		{name: "許容範囲を超えたら直前の時刻から報告すること", times: []time.Duration{0, time.Second, 5 * time.Second}, wantGaps: []time.Duration{time.Second}},
+		{name: "ちょうど許容範囲なら報告しないこと", times: []time.Duration{0, 2 * time.Second}},
+		{name: "遅れて届いた古いメッセージでは戻らないこと", times: []time.Duration{0, 3 * time.Second, time.Second, 4 * time.Second}, wantGaps: []time.Duration{0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var g gapTracker
+			var gaps []time.Duration
+			for _, d := range tt.times {
+				if from, gap := g.observe("AAPL.NB", t0.Add(d), 2*time.Second); gap {
+					gaps = append(gaps, from.Sub(t0))
+				}
+			}
+			if fmt.Sprint(gaps) != fmt.Sprint(tt.wantGaps) {
+				t.Errorf("gaps from = %v, want %v", gaps, tt.wantGaps)
+			}
+		})
+	}
+}
+
+// gap is a call of OnGap.
+type gap struct {
+	channel  string
+	from, to time.Time
+}
+
+func quoddTradeAt(ticker string, millis int64) []byte {
+	return []byte(fmt.Sprintf(`{"event":"trade","data":{"ticker":%q,"last_price_4d":1594900,"trade_volume":100,"trade_time":%d}}`, ticker, millis))
+}
+
+func TestClientOnGap(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	gaps := make(chan gap, 10)
+	sut := server.newClient(QUODD, WithGapTolerance(time.Second))
+	sut.OnGap(func(channel string, from, to time.Time) { gaps <- gap{channel, from, to} })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+	sut.Join("AAPL.NB", "MSFT.NB")
+
+	const t0 = 1508165070000
+	for _, frame := range [][]byte{
+		quoddTradeAt("AAPL.NB", t0),
+		quoddTradeAt("MSFT.NB", t0),
+		quoddTradeAt("AAPL.NB", t0+500),
+		quoddTradeAt("MSFT.NB", t0+900),
+		quoddTradeAt("AAPL.NB", t0+5000),
+		quoddTradeAt("MSFT.NB", t0+1800),
+	} {
+		server.broadcastRaw(websocket.TextMessage, frame)
+	}
+	want := gap{"AAPL.NB", millisTime(t0 + 500), millisTime(t0 + 5000)}
+	select {
+	case got := <-gaps:
+		if got.channel != want.channel || !got.from.Equal(want.from) || !got.to.Equal(want.to) {
+			t.Errorf("OnGap() = %v, want %v", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnGap() was not called")
+	}
+	select {
+	case got := <-gaps:
+		t.Errorf("OnGap() = %v, want one call", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	sut.Leave("MSFT.NB")
+	if !waitUntil(5*time.Second, func() bool { return sut.gaps.len() == 1 }) {
+		t.Errorf("tracked symbols = %d after Leave, want 1", sut.gaps.len())
+	}
+}
+
+func TestClientOnGapAcrossReconnect(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	gaps := make(chan gap, 10)
+	sut := server.newClient(QUODD, WithGapTolerance(time.Second))
+	sut.OnGap(func(channel string, from, to time.Time) { gaps <- gap{channel, from, to} })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+	sut.Join("AAPL.NB")
+	if !waitUntil(5*time.Second, func() bool { return len(server.subscribedTickers()) == 1 }) {
+		t.Fatal("AAPL.NB was not subscribed")
+	}
+
+	const t0 = 1508165070000
+	server.broadcastRaw(websocket.TextMessage, quoddTradeAt("AAPL.NB", t0))
+	if !waitUntil(5*time.Second, func() bool { return sut.gaps.len() == 1 }) {
+		t.Fatal("the trade was not tracked")
+	}
+	server.kick(websocket.CloseGoingAway, "restart")
+	if !waitUntil(5*time.Second, func() bool { return len(server.subscribedTickers()) == 2 }) {
+		t.Fatal("AAPL.NB was not subscribed again")
+	}
+	server.broadcastRaw(websocket.TextMessage, quoddTradeAt("AAPL.NB", t0+30000))
+	select {
+	case got := <-gaps:
+		if !got.from.Equal(millisTime(t0)) || !got.to.Equal(millisTime(t0+30000)) {
+			t.Errorf("OnGap() = %v, want the outage", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnGap() was not called after the reconnect")
+	}
+
+	sut.Disconnect()
+	if n := sut.gaps.len(); n != 0 {
+		t.Errorf("tracked symbols = %d after Disconnect, want 0", n)
+	}
+}
+
+func millisTime(millis int64) time.Time {
+	return time.Unix(0, millis*int64(time.Millisecond)).UTC()
+}
+
+func TestWithGapTolerance(t *testing.T) {
+	sut := New(yourIntrinioAPIUserName, yourIntrinioAPIPassword, QUODD, WithGapTolerance(-time.Second))
+	if sut.optionErr == nil {
+		t.Error("WithGapTolerance(-1s) error = nil, want an error")
+	}
+}
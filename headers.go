@@ -0,0 +1,54 @@
+package intriniorealtime
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// WithHeader adds a header to the token requests and to the websocket
+// handshake, such as an identifying User-Agent or the credentials of a
+// gateway. It replaces a header of the same name the request would have
+// had. The headers of the handshake that the websocket library sets itself,
+// Connection, Upgrade and Sec-WebSocket-*, are an error.
+func WithHeader(key, value string) Option {
+	return func(cli *Client) error {
+		return cli.addHeader(key, value)
+	}
+}
+
+// WithHeaders is WithHeader for every value of h.
+func WithHeaders(h http.Header) Option {
+	return func(cli *Client) error {
+		for key, values := range h {
+			for _, value := range values {
+				if err := cli.addHeader(key, value); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+}
+
+func (cli *Client) addHeader(key, value string) error {
+	key = http.CanonicalHeaderKey(strings.TrimSpace(key))
+	switch {
+	case key == "":
+		return fmt.Errorf("header name must not be empty")
+	case key == "Connection" || key == "Upgrade" || strings.HasPrefix(key, "Sec-Websocket-"):
+		return fmt.Errorf("header %s is set by the websocket handshake", key)
+	}
+	if cli.headers == nil {
+		cli.headers = make(http.Header)
+	}
+	cli.headers.Add(key, value)
+	return nil
+}
+
+// setHeaders puts the headers of WithHeader on req.
+func (cli *Client) setHeaders(req *http.Request) {
+	for key, values := range cli.headers {
+		req.Header[key] = append([]string(nil), values...)
+	}
+}
@@ -0,0 +1,61 @@
+package intriniorealtime
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestClientWithHeaders(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	sut := server.newClient(IEX,
+		WithHeader("User-Agent", "acme-ticker/1.2"),
+		WithHeaders(http.Header{"X-Gateway-Key": {"k1"}, "x-trace": {"a", "b"}}))
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	server.mu.Lock()
+	auth := server.authReqs[0].Header
+	upgrade := server.upgrades[0]
+	server.mu.Unlock()
+	want := map[string][]string{
+		"User-Agent":    {"acme-ticker/1.2"},
+		"X-Gateway-Key": {"k1"},
+		"X-Trace":       {"a", "b"},
+	}
+	for key, values := range want {
+		if got := auth.Values(key); !reflect.DeepEqual(got, values) {
+			t.Errorf("auth request %s = %v, want %v", key, got, values)
+		}
+		if got := upgrade.Values(key); !reflect.DeepEqual(got, values) {
+			t.Errorf("websocket handshake %s = %v, want %v", key, got, values)
+		}
+	}
+}
+
+func TestWithHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		opt     Option
+		wantErr bool
+	}{
+		{name: "任意のヘッダーを指定できること", opt: WithHeader("X-Gateway-Key", "k1")},
+		{name: "Sec-WebSocket-Keyはエラーになること", opt: WithHeader("Sec-WebSocket-Key", "x"), wantErr: true},
+		{name: "小文字のsec-websocket-protocolもエラーになること", opt: WithHeader("sec-websocket-protocol", "x"), wantErr: true},
+		{name: "Upgradeはエラーになること", opt: WithHeaders(http.Header{"Upgrade": {"websocket"}}), wantErr: true},
+		{name: "Connectionはエラーになること", opt: WithHeader("Connection", "close"), wantErr: true},
+		{name: "空の名前はエラーになること", opt: WithHeader(" ", "x"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewWithOptions("", "", IEX, tt.opt)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewWithOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
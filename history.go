@@ -0,0 +1,79 @@
+package intriniorealtime
+
+import (
+	"sync"
+	"time"
+)
+
+const stateHistorySize = 50
+
+// TransitionKind names a change in the client's connection state.
+type TransitionKind string
+
+const (
+	// TransitionConnected is recorded when a websocket has been established.
+	TransitionConnected TransitionKind = "connected"
+	// TransitionConnectionLost is recorded when the connection ended
+	// unexpectedly. Err is the cause.
+	TransitionConnectionLost TransitionKind = "connection lost"
+	// TransitionReconnectAttempt is recorded after every reconnect attempt.
+	// Err is nil when the attempt succeeded.
+	TransitionReconnectAttempt TransitionKind = "reconnect attempt"
+	// TransitionTokenRefreshed is recorded when a new auth token was fetched.
+	TransitionTokenRefreshed TransitionKind = "token refreshed"
+	// TransitionDisconnected is recorded when the client has shut down. Err
+	// is nil when Disconnect was called and the reason otherwise.
+	TransitionDisconnected TransitionKind = "disconnected"
+)
+
+// StateTransition is one entry of the client's state history.
+type StateTransition struct {
+	At      time.Time
+	Kind    TransitionKind
+	Attempt int
+	Err     error
+}
+
+// stateHistory is a fixed-size ring of the most recent transitions.
+type stateHistory struct {
+	mu      sync.Mutex
+	entries []StateTransition
+	next    int
+	full    bool
+}
+
+func newStateHistory(size int) *stateHistory {
+	return &stateHistory{entries: make([]StateTransition, size)}
+}
+
+func (h *stateHistory) add(t StateTransition) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.entries) == 0 {
+		return
+	}
+	h.entries[h.next] = t
+	h.next = (h.next + 1) % len(h.entries)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+func (h *stateHistory) list() []StateTransition {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.full {
+		return append([]StateTransition(nil), h.entries[:h.next]...)
+	}
+	return append(append([]StateTransition(nil), h.entries[h.next:]...), h.entries[:h.next]...)
+}
+
+// StateHistory returns the most recent connection state transitions, oldest
+// first. It is safe to call while the client is running.
+func (cli *Client) StateHistory() []StateTransition {
+	return cli.history.list()
+}
+
+func (cli *Client) record(kind TransitionKind, attempt int, err error) {
+	cli.history.add(StateTransition{At: cli.now(), Kind: kind, Attempt: attempt, Err: err})
+}
@@ -0,0 +1,82 @@
+package intriniorealtime
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestStateHistory(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+		add  int
+		want []int
+	}{
+		{name: "空のときは何も返さないこと", size: 3, add: 0, want: nil},
+		{name: "サイズ未満なら追加した順に返すこと", size: 3, add: 2, want: []int{1, 2}},
+		{name: "サイズを超えたら古いものから捨てること", size: 3, add: 5, want: []int{3, 4, 5}},
+		{name: "サイズが0なら記録しないこと", size: 0, add: 2, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := newStateHistory(tt.size)
+			for i := 1; i <= tt.add; i++ {
+				sut.add(StateTransition{Attempt: i})
+			}
+			var got []int
+			for _, tr := range sut.list() {
+				got = append(got, tr.Attempt)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("list() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientStateHistory(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	reconnected := make(chan error, 1)
+	sut := server.newClient(QUODD)
+	sut.OnReconnect(func(cause error) { reconnected <- cause })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	server.kick(websocket.CloseGoingAway, "restart")
+	select {
+	case <-reconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("client did not reconnect")
+	}
+	sut.Disconnect()
+
+	var got []TransitionKind
+	for _, tr := range sut.StateHistory() {
+		if tr.At.IsZero() {
+			t.Errorf("transition %v has no timestamp", tr.Kind)
+		}
+		if tr.Kind == TransitionConnectionLost && tr.Err == nil {
+			t.Error("connection lost transition has no error")
+		}
+		if tr.Kind == TransitionReconnectAttempt && tr.Attempt != 1 {
+			t.Errorf("reconnect attempt = %d, want 1", tr.Attempt)
+		}
+		got = append(got, tr.Kind)
+	}
+	want := []TransitionKind{
+		TransitionTokenRefreshed,
+		TransitionConnected,
+		TransitionConnectionLost,
+		TransitionConnected,
+		TransitionReconnectAttempt,
+		TransitionDisconnected,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("StateHistory() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,72 @@
+package intriniorealtime
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	idleReadWait      = 10 * time.Minute
+	idleHeartbeatWait = time.Minute
+)
+
+// SetIdle switches the client in and out of idle mode, e.g. around market
+// hours. While idle the read deadline is lengthened, heartbeats are sent less
+// often and the stale-connection watchdog does not reconnect a quiet
+// connection. Leaving idle mode restores the normal settings and starts the
+// stale timeout over.
+//
+// Only the reader may set the read deadline, which it does before every read
+// and on every pong. SetIdle pings the server so that the pong makes the
+// reader take the new deadline on right away instead of after a wait as long
+// as the old one.
+func (cli *Client) SetIdle(idle bool) {
+	var n int32
+	if idle {
+		n = 1
+	}
+	if atomic.SwapInt32(&cli.idle, n) == n {
+		return
+	}
+	if !idle {
+		cli.touch()
+	}
+	if ws := cli.conn(); ws != nil {
+		// WriteControl is safe alongside the sender's writes.
+		if err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(cli.writeDeadline)); err != nil {
+			cli.debug("idle ping failed", "err", err)
+		}
+	}
+	cli.onIdleChange(idle)
+}
+
+// Idle reports whether the client is in idle mode.
+func (cli *Client) Idle() bool {
+	return atomic.LoadInt32(&cli.idle) == 1
+}
+
+// OnIdleChange registers a callback invoked whenever SetIdle switches modes.
+func (cli *Client) OnIdleChange(f func(idle bool)) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
+	cli.idleHandler = f
+}
+
+func (cli *Client) onIdleChange(idle bool) {
+	cli.info("idle mode", "idle", idle)
+	cli.handlerMu.RLock()
+	f := cli.idleHandler
+	cli.handlerMu.RUnlock()
+	if f != nil {
+		cli.callHandler("OnIdleChange", func() { f(idle) })
+	}
+}
+
+func (cli *Client) currentReadDeadline() time.Duration {
+	if cli.Idle() {
+		return cli.idleReadDeadline
+	}
+	return cli.readDeadline
+}
@@ -0,0 +1,205 @@
+package intriniorealtime
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClientIdle(t *testing.T) {
+	tests := []struct {
+		name          string
+		idle          bool
+		wantReconnect bool
+	}{
+		{name: "アイドル中は8時間メッセージがなくても再接続しないこと", idle: true, wantReconnect: false},
+		{name: "アイドルでなければメッセージがないと再接続すること", idle: false, wantReconnect: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+			server.setReply(ackHeartbeats)
+
+			var mu sync.Mutex
+			var offset time.Duration
+			tickers := make(map[time.Duration]chan time.Time)
+			reconnected := make(chan struct{}, 1)
+			sut := server.newClient(QUODD, WithStaleTimeout(time.Minute))
+			sut.now = func() time.Time {
+				mu.Lock()
+				defer mu.Unlock()
+				return time.Now().Add(offset)
+			}
+			sut.newTicker = func(d time.Duration) (<-chan time.Time, func()) {
+				mu.Lock()
+				defer mu.Unlock()
+				if _, ok := tickers[d]; !ok {
+					tickers[d] = make(chan time.Time)
+				}
+				return tickers[d], func() {}
+			}
+			sut.OnReconnect(func(error) {
+				select {
+				case reconnected <- struct{}{}:
+				default:
+				}
+			})
+			sut.SetIdle(tt.idle)
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			defer sut.Disconnect()
+			if !waitUntil(time.Second, func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return tickers[15*time.Second] != nil
+			}) {
+				t.Fatal("watchdog did not start")
+			}
+			mu.Lock()
+			watchdog := tickers[15*time.Second]
+			mu.Unlock()
+
+			for elapsed := time.Duration(0); elapsed < 8*time.Hour; elapsed += 15 * time.Minute {
+				mu.Lock()
+				offset = elapsed + 15*time.Minute
+				mu.Unlock()
+				select {
+				case watchdog <- time.Now():
+				case <-reconnected:
+					if !tt.wantReconnect {
+						t.Fatalf("reconnected after %v of idle time", elapsed)
+					}
+					return
+				case <-time.After(time.Second):
+					t.Fatal("watchdog stopped ticking")
+				}
+			}
+			if tt.wantReconnect {
+				t.Fatal("client did not reconnect a silent connection")
+			}
+		})
+	}
+}
+
+func TestClientSetIdle(t *testing.T) {
+	var mu sync.Mutex
+	var changes []bool
+	sut := New(yourIntrinioAPIUserName, yourIntrinioAPIPassword, IEX, WithIdleTimings(time.Hour, 5*time.Minute))
+	sut.OnIdleChange(func(idle bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, idle)
+	})
+
+	tests := []struct {
+		name         string
+		idle         bool
+		wantDeadline time.Duration
+		wantChanges  int
+	}{
+		{name: "アイドルにすると読み込みデッドラインが延びること", idle: true, wantDeadline: time.Hour, wantChanges: 1},
+		{name: "同じ状態を指定しても通知されないこと", idle: true, wantDeadline: time.Hour, wantChanges: 1},
+		{name: "アイドルを解除すると元の設定に戻ること", idle: false, wantDeadline: readWait, wantChanges: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut.SetIdle(tt.idle)
+			if got := sut.Idle(); got != tt.idle {
+				t.Errorf("Idle() = %v, want %v", got, tt.idle)
+			}
+			if got := sut.currentReadDeadline(); got != tt.wantDeadline {
+				t.Errorf("read deadline = %v, want %v", got, tt.wantDeadline)
+			}
+			notified := waitUntil(time.Second, func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(changes) == tt.wantChanges && changes[len(changes)-1] == tt.idle
+			})
+			if !notified {
+				mu.Lock()
+				t.Errorf("OnIdleChange() calls = %v", changes)
+				mu.Unlock()
+			}
+		})
+	}
+}
+
+func TestClientLeaveIdleCutsReadShort(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	lost := make(chan error, 1)
+	sut := server.newClient(QUODD, WithoutHeartbeat(), WithPingInterval(-1), WithStaleTimeout(0), WithoutReconnect(),
+		WithReadDeadline(300*time.Millisecond), WithWriteDeadline(300*time.Millisecond), WithIdleTimings(time.Hour, time.Minute))
+	sut.OnError(func(err error) {
+		select {
+		case lost <- err:
+		default:
+		}
+	})
+	sut.SetIdle(true)
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	select {
+	case err := <-lost:
+		t.Fatalf("connection lost while idle: %v", err)
+	case <-time.After(time.Second):
+	}
+	sut.SetIdle(false)
+	select {
+	case <-lost:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the read deadline of an hour was still waited out after SetIdle(false)")
+	}
+}
+
+func TestClientIdleReadDeadline(t *testing.T) {
+	tests := []struct {
+		name     string
+		idle     bool
+		wantLost bool
+	}{
+		{name: "アイドル中は静かな接続を読み込みデッドラインで切らないこと", idle: true},
+		{name: "アイドルでなければ静かな接続を読み込みデッドラインで切ること", wantLost: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+
+			lost := make(chan error, 1)
+			sut := server.newClient(QUODD, WithoutHeartbeat(), WithPingInterval(-1), WithoutReconnect(),
+				WithReadDeadline(200*time.Millisecond), WithWriteDeadline(200*time.Millisecond), WithIdleTimings(time.Hour, time.Minute))
+			sut.OnError(func(err error) {
+				select {
+				case lost <- err:
+				default:
+				}
+			})
+			sut.SetIdle(tt.idle)
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			defer sut.Disconnect()
+
+			select {
+			case err := <-lost:
+				if !tt.wantLost {
+					t.Errorf("connection lost while idle: %v", err)
+				}
+			case <-time.After(time.Second):
+				if tt.wantLost {
+					t.Error("a silent connection outlived the read deadline")
+				}
+				if !sut.Connected() {
+					t.Error("Connected() = false while idle")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package intriniorealtime
+
+import "time"
+
+// IEXQuote is a quote from the IEX feed.
+type IEXQuote struct {
+	Type      string // "bid", "ask" or "last"
+	Ticker    string
+	Price     float64
+	Size      int64
+	Timestamp time.Time // UTC, zero when it wasn't sent
+
+	// RawTimestamp is the timestamp as IEX sent it, to the nanosecond.
+	RawTimestamp RawTime
+
+	// Conditions are the sale conditions of a "last" quote.
+	Conditions TradeConditions
+
+	// Venue is always IEX, which only sends its own quotes and trades.
+	Venue Exchange
+
+	// FixedPrice is Price, exactly, with WithFixedPointPrices.
+	FixedPrice Price
+
+	// Raw is the payload as received, with the size and the timestamp as
+	// json.Number; don't modify it.
+	Raw map[string]interface{}
+}
+
+// OnIEXQuote registers a handler for IEX quotes. It gets the same quotes as
+// OnQuote, in the same order, decoded; replies and heartbeat acks are left
+// out. Both handlers can be registered at once.
+func (cli *Client) OnIEXQuote(f func(quote IEXQuote)) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
+	cli.iexQuoteHandler = f
+}
+
+// parseIEXQuote returns the quote carried by msg, if it is one. Fields
+// missing from the payload are left zero.
+func parseIEXQuote(provider provider, msg map[string]interface{}) (IEXQuote, bool) {
+	if provider != IEX || msg["event"] != "quote" {
+		return IEXQuote{}, false
+	}
+	payload, ok := msg["payload"].(map[string]interface{})
+	if !ok {
+		return IEXQuote{}, false
+	}
+	quote := IEXQuote{Venue: iexVenue, Raw: payload}
+	quote.Type, _ = payload["type"].(string)
+	// The same symbol whether it came on its own channel or a lobby.
+	quote.Ticker, _ = SymbolFromMessage(provider, msg)
+	quote.Price, _ = number(payload["price"])
+	quote.FixedPrice, _ = fixedPrice(payload["price"])
+	quote.Size, _ = integer(payload["size"])
+	at := payload["timestamp"]
+	quote.Timestamp, quote.RawTimestamp = unixTime(at, timeUnit(provider, "timestamp", at))
+	quote.Conditions = parseConditions(payload["conditions"])
+	return quote, true
+}
@@ -0,0 +1,135 @@
+package intriniorealtime
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseIEXQuote(t *testing.T) {
+	ts := time.Unix(1493409509, 393279000)
+	exact := time.Unix(1493409509, 393278800)
+	tests := []struct {
+		name     string
+		provider provider
+		msg      map[string]interface{}
+		want     IEXQuote
+		wantOK   bool
+	}{
+		{
+			name:     "IEXのクォートを解析すること",
+			provider: IEX,
+			msg: map[string]interface{}{"topic": "iex:securities:GE", "event": "quote", "payload": map[string]interface{}{
+				"type": "ask", "timestamp": 1493409509.3932788, "ticker": "GE", "size": float64(13750), "price": 28.97,
+			}},
+			want: IEXQuote{Type: "ask", Ticker: "GE", Price: 28.97, Size: 13750, Timestamp: ts,
+				RawTimestamp: RawTime{Value: 1493409509393279000, Unit: Nanoseconds}},
+			wantOK: true,
+		},
+		{
+			name:     "文字列のタイムスタンプを解析すること",
+			provider: IEX,
+			msg: map[string]interface{}{"event": "quote", "payload": map[string]interface{}{
+				"type": "last", "timestamp": "1493409509.3932788", "ticker": "GE",
+			}},
+			want:   IEXQuote{Type: "last", Ticker: "GE", Timestamp: exact, RawTimestamp: RawTime{Value: 1493409509393278800, Unit: Nanoseconds}},
+			wantOK: true,
+		},
+		{
+			name:     "json.Numberのタイムスタンプを桁を落とさず解析すること",
+			provider: IEX,
+			msg: map[string]interface{}{"event": "quote", "payload": map[string]interface{}{
+				"type": "bid", "timestamp": json.Number("1493409509.393278812"), "ticker": "GE",
+			}},
+			want:   IEXQuote{Type: "bid", Ticker: "GE", Timestamp: time.Unix(1493409509, 393278812), RawTimestamp: RawTime{Value: 1493409509393278812, Unit: Nanoseconds}},
+			wantOK: true,
+		},
+		{
+			name:     "サイズがなければ0にすること",
+			provider: IEX,
+			msg:      map[string]interface{}{"event": "quote", "payload": map[string]interface{}{"type": "bid", "ticker": "GE", "price": 28.96}},
+			want:     IEXQuote{Type: "bid", Ticker: "GE", Price: 28.96},
+			wantOK:   true,
+		},
+		{
+			name:     "価格がnullなら0にすること",
+			provider: IEX,
+			msg:      map[string]interface{}{"event": "quote", "payload": map[string]interface{}{"type": "bid", "ticker": "GE", "price": nil, "size": float64(100)}},
+			want:     IEXQuote{Type: "bid", Ticker: "GE", Size: 100},
+			wantOK:   true,
+		},
+		{
+			name:     "解析できないタイムスタンプはゼロ値にすること",
+			provider: IEX,
+			msg:      map[string]interface{}{"event": "quote", "payload": map[string]interface{}{"ticker": "GE", "timestamp": "yesterday"}},
+			want:     IEXQuote{Ticker: "GE"},
+			wantOK:   true,
+		},
+		{
+			name:     "phx_replyは解析しないこと",
+			provider: IEX,
+			msg:      map[string]interface{}{"topic": "iex:securities:GE", "event": "phx_reply", "payload": map[string]interface{}{"status": "ok"}},
+		},
+		{
+			name:     "ハートビートの応答は解析しないこと",
+			provider: IEX,
+			msg:      map[string]interface{}{"topic": "phoenix", "event": "phx_reply", "payload": map[string]interface{}{"status": "ok", "response": map[string]interface{}{}}},
+		},
+		{
+			name:     "QUODDでは解析しないこと",
+			provider: QUODD,
+			msg:      map[string]interface{}{"event": "quote", "payload": map[string]interface{}{"ticker": "GE"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseIEXQuote(tt.provider, tt.msg)
+			if ok != tt.wantOK || got.Type != tt.want.Type || got.Ticker != tt.want.Ticker || got.Price != tt.want.Price ||
+				got.Size != tt.want.Size || !got.Timestamp.Equal(tt.want.Timestamp) || got.RawTimestamp != tt.want.RawTimestamp {
+				t.Errorf("parseIEXQuote() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestClientOnIEXQuote(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	quotes := make(chan IEXQuote, 10)
+	maps := make(chan map[string]interface{}, 10)
+	sut := server.newClient(IEX)
+	sut.OnIEXQuote(func(quote IEXQuote) { quotes <- quote })
+	sut.OnQuote(func(quote map[string]interface{}) { maps <- quote })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	server.broadcast(map[string]interface{}{"topic": "phoenix", "event": "phx_reply", "payload": map[string]interface{}{"status": "ok"}})
+	server.broadcast(map[string]interface{}{"topic": "iex:securities:GE", "event": "quote", "payload": map[string]interface{}{
+		"type": "last", "timestamp": 1493409509.3932788, "ticker": "GE", "size": 100, "price": 28.97,
+	}})
+	select {
+	case quote := <-quotes:
+		if quote.Type != "last" || quote.Ticker != "GE" || quote.Price != 28.97 || quote.Size != 100 {
+			t.Errorf("OnIEXQuote() quote = %+v", quote)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnIEXQuote() was not called")
+	}
+	// The heartbeat ack no longer goes to OnQuote either.
+	select {
+	case quote := <-maps:
+		if quote["event"] != "quote" {
+			t.Errorf("OnQuote() event = %v, want quote", quote["event"])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnQuote() was not called for the quote")
+	}
+	select {
+	case quote := <-quotes:
+		t.Errorf("OnIEXQuote() was called for %+v", quote)
+	default:
+	}
+}
@@ -0,0 +1,202 @@
+package intriniorealtime
+
+import (
+	"strings"
+	"unicode"
+)
+
+// InfoKind tells what an administrative message is about.
+type InfoKind int
+
+// The kinds of administrative messages.
+const (
+	InfoOther InfoKind = iota
+	InfoSubscribed
+	InfoUnsubscribed
+	InfoAuthFailure
+	InfoReply
+	InfoHeartbeat
+	InfoError
+)
+
+func (k InfoKind) String() string {
+	switch k {
+	case InfoSubscribed:
+		return "subscribed"
+	case InfoUnsubscribed:
+		return "unsubscribed"
+	case InfoAuthFailure:
+		return "auth failure"
+	case InfoReply:
+		return "reply"
+	case InfoHeartbeat:
+		return "heartbeat"
+	case InfoError:
+		return "error"
+	default:
+		return "other"
+	}
+}
+
+// InfoMessage is an administrative message: anything the server sends that
+// isn't market data. Channel is the channel the message is about, as passed
+// to Join, or empty when it isn't about one.
+type InfoMessage struct {
+	Kind    InfoKind
+	Channel string
+
+	// Message is the server's own text: the status of an IEX reply, or the
+	// reason when a join failed, and the message of QUODD info and errors.
+	Message string
+
+	// Raw is the payload as received, the IEX "payload" or the QUODD
+	// "data", shared with OnMessage; don't modify it.
+	Raw map[string]interface{}
+}
+
+// OnInfo registers a handler for administrative messages: the IEX replies to
+// joins, leaves and heartbeats and its errors, and QUODD's heartbeats,
+// errors and info messages, which confirm subscriptions and unsubscriptions,
+// report authorization failures and whatever else QUODD has to say. These
+// are not passed to OnQuote unless WithControlMessagesInOnQuote was given.
+func (cli *Client) OnInfo(f func(info InfoMessage)) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
+	cli.infoHandler = f
+}
+
+// authFailures are what the servers say when the account may not see a
+// channel.
+var authFailures = []string{"not authorized", "unauthorized", "not entitled", "entitlement", "permission denied", "access denied", "forbidden"}
+
+// parseInfo returns the administrative message carried by msg, if it is
+// one.
+func parseInfo(provider provider, msg map[string]interface{}) (InfoMessage, bool) {
+	switch provider {
+	case IEX, REALTIME, DELAYED_SIP, NASDAQ_BASIC, OPRA, CRYPTOQUOTE, FXCM:
+		return parseIEXInfo(msg)
+	case QUODD:
+		return parseQuoddInfo(msg)
+	}
+	return InfoMessage{}, false
+}
+
+func parseIEXInfo(msg map[string]interface{}) (InfoMessage, bool) {
+	topic, _ := msg["topic"].(string)
+	payload, _ := msg["payload"].(map[string]interface{})
+	info := InfoMessage{Raw: payload}
+	switch msg["event"] {
+	case "phx_reply":
+		if topic == "phoenix" {
+			info.Kind = InfoHeartbeat
+			return info, true
+		}
+		info.Kind = InfoReply
+		info.Message, _ = payload["status"].(string)
+		if response, ok := payload["response"].(map[string]interface{}); ok {
+			if reason, ok := response["reason"].(string); ok {
+				info.Message = reason
+			}
+		}
+	case "phx_error":
+		info.Kind = InfoError
+	default:
+		return InfoMessage{}, false
+	}
+	info.Channel = topicChannel(topic)
+	return info, true
+}
+
+func parseQuoddInfo(msg map[string]interface{}) (InfoMessage, bool) {
+	data, ok := msg["data"].(map[string]interface{})
+	info := InfoMessage{Raw: data}
+	info.Message, _ = data["message"].(string)
+	switch msg["event"] {
+	case "info":
+		if !ok {
+			return InfoMessage{}, false
+		}
+	case "heartbeat":
+		info.Kind = InfoHeartbeat
+		return info, true
+	case "error":
+		info.Kind = InfoError
+		info.Channel, _ = data["ticker"].(string)
+		return info, true
+	default:
+		return InfoMessage{}, false
+	}
+	info.Message, _ = data["message"].(string)
+	action, _ := data["action"].(string)
+	text := strings.ToLower(info.Message)
+	switch {
+	case containsAny(text, authFailures):
+		info.Kind = InfoAuthFailure
+	case strings.Contains(text, "unsubscribed"), action == "unsubscribe":
+		info.Kind = InfoUnsubscribed
+	case strings.Contains(text, "subscribed"), action == "subscribe":
+		info.Kind = InfoSubscribed
+	}
+	if ticker, ok := data["ticker"].(string); ok {
+		info.Channel = ticker
+	} else {
+		info.Channel = tickerIn(info.Message)
+	}
+	return info, true
+}
+
+func containsAny(s string, substrs []string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// tickerIn returns the ticker QUODD puts at the end of its messages, as in
+// "Successfully subscribed to AAPL.NB", or "" when the last word isn't one.
+func tickerIn(message string) string {
+	words := strings.Fields(message)
+	if len(words) == 0 {
+		return ""
+	}
+	word := strings.TrimRight(words[len(words)-1], ".,:;!")
+	if strings.IndexFunc(word, unicode.IsUpper) < 0 || strings.IndexFunc(word, unicode.IsLower) >= 0 {
+		return ""
+	}
+	return word
+}
+
+// infoMessage keeps Confirmed up to date with the subscriptions QUODD
+// confirmed, reports authorization failures and passes info on to OnInfo.
+func (cli *Client) infoMessage(info InfoMessage) {
+	if info.Channel != "" {
+		cli.mu.Lock()
+		switch info.Kind {
+		case InfoSubscribed:
+			if cli.joinedChannels[info.Channel] {
+				cli.confirmed[info.Channel] = true
+			}
+		case InfoUnsubscribed, InfoAuthFailure:
+			delete(cli.confirmed, info.Channel)
+		}
+		cli.mu.Unlock()
+	}
+	if info.Kind == InfoAuthFailure {
+		cli.mu.Lock()
+		joined := info.Channel != "" && 0 < cli.channels[info.Channel]
+		cli.mu.Unlock()
+		if joined {
+			cli.notEntitled(info.Channel, info.Message)
+		} else {
+			cli.onError(&EntitlementError{Ticker: info.Channel, Message: info.Message})
+		}
+	}
+	cli.handlerMu.RLock()
+	f := cli.infoHandler
+	cli.handlerMu.RUnlock()
+	if f != nil {
+		cli.callHandler("OnInfo", func() { f(info) })
+	}
+}
@@ -0,0 +1,214 @@
+package intriniorealtime
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseInfo(t *testing.T) {
+	tests := []struct {
+		name        string
+		provider    provider
+		msg         map[string]interface{}
+		wantKind    InfoKind
+		wantChannel string
+		wantMessage string
+		wantOK      bool
+	}{
+		{name: "接続の案内はOtherにすること", provider: QUODD, msg: loadFixture(t, "quodd_info_connected.json"), wantKind: InfoOther, wantOK: true},
+		{name: "購読の確認をSubscribedにすること", provider: QUODD, msg: loadFixture(t, "quodd_info_subscribed.json"), wantKind: InfoSubscribed, wantChannel: "AAPL.NB", wantOK: true},
+		{name: "購読解除の確認をUnsubscribedにすること", provider: QUODD, msg: loadFixture(t, "quodd_info_unsubscribed.json"), wantKind: InfoUnsubscribed, wantChannel: "AAPL.NB", wantOK: true},
+		{name: "権限がなければAuthFailureにすること", provider: QUODD, msg: loadFixture(t, "quodd_info_unauthorized.json"), wantKind: InfoAuthFailure, wantChannel: "TSLA.NB", wantOK: true},
+		{
+			name:        "tickerの項目があればそれを使うこと",
+			provider:    QUODD,
+			msg:         map[string]interface{}{"event": "info", "data": map[string]interface{}{"message": "subscribed", "ticker": "MSFT.NB"}},
+			wantKind:    InfoSubscribed,
+			wantChannel: "MSFT.NB",
+			wantOK:      true,
+		},
+		{
+			name:     "actionだけでも種類を決めること",
+			provider: QUODD,
+			msg:      map[string]interface{}{"event": "info", "data": map[string]interface{}{"action": "unsubscribe"}},
+			wantKind: InfoUnsubscribed,
+			wantOK:   true,
+		},
+		{name: "QUODDのハートビートをHeartbeatにすること", provider: QUODD, msg: map[string]interface{}{"event": "heartbeat", "data": map[string]interface{}{"action": "heartbeat"}}, wantKind: InfoHeartbeat, wantOK: true},
+		{name: "dataのないハートビートも処理すること", provider: QUODD, msg: map[string]interface{}{"event": "heartbeat"}, wantKind: InfoHeartbeat, wantOK: true},
+		{
+			name:        "QUODDのエラーをErrorにすること",
+			provider:    QUODD,
+			msg:         map[string]interface{}{"event": "error", "data": map[string]interface{}{"message": "Invalid ticker", "ticker": "NOPE.NB"}},
+			wantKind:    InfoError,
+			wantChannel: "NOPE.NB",
+			wantMessage: "Invalid ticker",
+			wantOK:      true,
+		},
+		{name: "dataのないinfoは処理しないこと", provider: QUODD, msg: map[string]interface{}{"event": "info"}},
+		{name: "info以外は処理しないこと", provider: QUODD, msg: loadFixture(t, "quodd_quote.json")},
+		{name: "IEXではQUODDのinfoを処理しないこと", provider: IEX, msg: loadFixture(t, "quodd_info_subscribed.json")},
+		{name: "IEXの参加の応答をReplyにすること", provider: IEX, msg: loadFixture(t, "iex_join_ok.json"), wantKind: InfoReply, wantChannel: "AAPL", wantMessage: "ok", wantOK: true},
+		{name: "IEXの参加の失敗は理由を入れること", provider: IEX, msg: loadFixture(t, "iex_join_error.json"), wantKind: InfoReply, wantChannel: "NOPE", wantMessage: "invalid security", wantOK: true},
+		{
+			name:        "IEXのロビーの応答はチャンネル名にすること",
+			provider:    IEX,
+			msg:         map[string]interface{}{"topic": "iex:lobby:last_price", "event": "phx_reply", "payload": map[string]interface{}{"status": "ok"}},
+			wantKind:    InfoReply,
+			wantChannel: "$lobby_last_price",
+			wantMessage: "ok",
+			wantOK:      true,
+		},
+		{
+			name:     "IEXのハートビートの応答をHeartbeatにすること",
+			provider: IEX,
+			msg:      map[string]interface{}{"topic": "phoenix", "event": "phx_reply", "payload": map[string]interface{}{"status": "ok", "response": map[string]interface{}{}}},
+			wantKind: InfoHeartbeat,
+			wantOK:   true,
+		},
+		{
+			name:        "IEXのphx_errorをErrorにすること",
+			provider:    IEX,
+			msg:         map[string]interface{}{"topic": "iex:securities:MSFT", "event": "phx_error", "payload": map[string]interface{}{}},
+			wantKind:    InfoError,
+			wantChannel: "MSFT",
+			wantOK:      true,
+		},
+		{name: "IEXの気配は処理しないこと", provider: IEX, msg: loadFixture(t, "iex_quote.json")},
+		{name: "QUODDではIEXの応答を処理しないこと", provider: QUODD, msg: loadFixture(t, "iex_join_ok.json")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseInfo(tt.provider, tt.msg)
+			if ok != tt.wantOK {
+				t.Fatalf("parseInfo() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if got.Kind != tt.wantKind || got.Channel != tt.wantChannel {
+				t.Errorf("parseInfo() = %v %q, want %v %q", got.Kind, got.Channel, tt.wantKind, tt.wantChannel)
+			}
+			if tt.wantMessage != "" && got.Message != tt.wantMessage {
+				t.Errorf("parseInfo() Message = %q, want %q", got.Message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestClientOnInfo(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	infos := make(chan InfoMessage, 10)
+	errs := make(chan error, 10)
+	quotes := make(chan map[string]interface{}, 10)
+	sut := server.newClient(QUODD)
+	sut.OnInfo(func(info InfoMessage) { infos <- info })
+	sut.OnError(func(err error) { errs <- err })
+	sut.OnQuote(func(quote map[string]interface{}) { quotes <- quote })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+	sut.Join("AAPL.NB", "TSLA.NB")
+	if !waitUntil(5*time.Second, func() bool { return len(server.subscribedTickers()) == 2 }) {
+		t.Fatalf("subscribed = %v", server.subscribedTickers())
+	}
+
+	next := func(want InfoKind) {
+		t.Helper()
+		select {
+		case info := <-infos:
+			if info.Kind != want {
+				t.Errorf("OnInfo() kind = %v, want %v", info.Kind, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("OnInfo() was not called for %v", want)
+		}
+	}
+
+	server.broadcast(loadFixture(t, "quodd_info_subscribed.json"))
+	next(InfoSubscribed)
+	if !sut.Confirmed("AAPL.NB") {
+		t.Error("Confirmed(AAPL.NB) = false after the subscription was confirmed")
+	}
+
+	server.broadcast(loadFixture(t, "quodd_info_unauthorized.json"))
+	next(InfoAuthFailure)
+	select {
+	case err := <-errs:
+		var ee *EntitlementError
+		if !errors.As(err, &ee) || ee.Ticker != "TSLA.NB" {
+			t.Errorf("OnError() error = %v, want an *EntitlementError for TSLA.NB", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnError() was not called")
+	}
+	if got, want := sut.Subscriptions(), []string{"AAPL.NB"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Subscriptions() = %v, want %v after TSLA.NB was refused", got, want)
+	}
+
+	server.broadcast(loadFixture(t, "quodd_info_unsubscribed.json"))
+	next(InfoUnsubscribed)
+	if sut.Confirmed("AAPL.NB") {
+		t.Error("Confirmed(AAPL.NB) = true after it was unsubscribed")
+	}
+	select {
+	case quote := <-quotes:
+		t.Errorf("OnQuote() got %v, want no info messages", quote)
+	default:
+	}
+}
+
+func TestClientOnInfoIEX(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []Option
+		wantQuotes int
+	}{
+		{name: "応答はOnInfoだけに渡すこと"},
+		{name: "互換オプションがあればOnQuoteにも渡すこと", opts: []Option{WithControlMessagesInOnQuote()}, wantQuotes: 2},
+	}
+	server := newFakeServer()
+	defer server.Close()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var infos []InfoMessage
+			var quotes int
+			sut := server.newClient(IEX, tt.opts...)
+			sut.OnInfo(func(info InfoMessage) {
+				mu.Lock()
+				defer mu.Unlock()
+				infos = append(infos, info)
+			})
+			sut.OnQuote(func(map[string]interface{}) {
+				mu.Lock()
+				defer mu.Unlock()
+				quotes++
+			})
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			defer sut.Disconnect()
+
+			server.broadcast(loadFixture(t, "iex_join_ok.json"))
+			server.broadcast(map[string]interface{}{"topic": "iex:securities:MSFT", "event": "phx_error", "payload": map[string]interface{}{}})
+			if !waitUntil(5*time.Second, func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(infos) == 2 && quotes == tt.wantQuotes
+			}) {
+				mu.Lock()
+				defer mu.Unlock()
+				t.Fatalf("OnInfo() got %v and OnQuote() %d messages, want 2 and %d", infos, quotes, tt.wantQuotes)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if infos[0].Kind != InfoReply || infos[0].Channel != "AAPL" || infos[1].Kind != InfoError || infos[1].Channel != "MSFT" {
+				t.Errorf("OnInfo() got %v, want the reply for AAPL and the error for MSFT", infos)
+			}
+		})
+	}
+}
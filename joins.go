@@ -0,0 +1,131 @@
+package intriniorealtime
+
+import "strings"
+
+// OnJoinError registers a handler for joins the server rejected, for example
+// for an unknown symbol or a channel the account isn't entitled to. reason
+// is a *JoinError. The channel stays joined on the client side, so it is
+// tried again with the next Join or Leave and after a reconnect; Leave it to
+// give up. The phoenix providers answer joins, and QUODD says when the
+// account isn't authorized for a ticker.
+//
+// A channel the account isn't entitled to, such as an IEX lobby without the
+// firehose or a QUODD ticker outside its coverage, isn't tried again: it is
+// left on the client side, and the *JoinError wraps an *EntitlementError,
+// which goes to OnError as well.
+func (cli *Client) OnJoinError(f func(channel string, reason error)) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
+	cli.joinErrorHandler = f
+}
+
+func (cli *Client) onJoinError(channel string, reason error) {
+	cli.warn("join failed", "channel", channel, "err", reason)
+	cli.handlerMu.RLock()
+	f := cli.joinErrorHandler
+	cli.handlerMu.RUnlock()
+	if f != nil {
+		cli.callHandler("OnJoinError", func() { f(channel, reason) })
+	}
+}
+
+// Confirmed reports whether the server acknowledged the join of channel on
+// the live connection. For QUODD that is an info message saying the ticker
+// was subscribed.
+func (cli *Client) Confirmed(channel string) bool {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	return cli.confirmed[channel]
+}
+
+// awaitJoin notes that a join for channel is being sent. mu must be held.
+func (cli *Client) awaitJoin(channel string) {
+	delete(cli.confirmed, channel)
+	if phoenix(cli.provider) {
+		cli.pendingJoins[parseTopic(cli.provider, channel)] = channel
+	}
+}
+
+// forgetJoin drops what is known about the join of channel, as it is being
+// left or was never sent. mu must be held.
+func (cli *Client) forgetJoin(channel string) {
+	delete(cli.confirmed, channel)
+	if phoenix(cli.provider) {
+		delete(cli.pendingJoins, parseTopic(cli.provider, channel))
+	}
+}
+
+// joinReply handles msg and returns true if it is the reply to a join that
+// is waiting for one, or a reply nothing waits for any more. A rejected
+// channel is dropped from joinedChannels so it is joined again by the next
+// refresh.
+func (cli *Client) joinReply(msg map[string]interface{}) bool {
+	if !phoenix(cli.provider) || msg["event"] != "phx_reply" {
+		return false
+	}
+	topic, _ := msg["topic"].(string)
+	cli.mu.Lock()
+	// Servers that don't echo refs are matched by the topic alone.
+	sent, ref, matched := cli.matchReply(msg)
+	switch {
+	case ref != "" && !matched:
+		cli.mu.Unlock()
+		return true
+	case matched && sent.event != "phx_join":
+		cli.mu.Unlock()
+		return false
+	case matched && cli.joinRefs[topic] != ref:
+		// The topic was left, or joined again, since.
+		cli.mu.Unlock()
+		cli.debug("late join reply", "ref", ref, "topic", topic)
+		return true
+	}
+	channel, ok := cli.pendingJoins[topic]
+	if !ok {
+		cli.mu.Unlock()
+		return false
+	}
+	delete(cli.pendingJoins, topic)
+	payload, _ := msg["payload"].(map[string]interface{})
+	status, _ := payload["status"].(string)
+	if status == "ok" {
+		cli.confirmed[channel] = true
+		cli.mu.Unlock()
+		return true
+	}
+	delete(cli.joinedChannels, channel)
+	cli.mu.Unlock()
+
+	reason := status
+	if response, ok := payload["response"].(map[string]interface{}); ok {
+		if r, ok := response["reason"].(string); ok {
+			reason = r
+		}
+	}
+	if entitlementFailure(reason) {
+		cli.notEntitled(channel, reason)
+		return true
+	}
+	cli.onJoinError(channel, &JoinError{Channel: channel, Reason: reason})
+	return true
+}
+
+// entitlementFailure reports whether the reason a server gave for refusing
+// a channel says the account isn't entitled to it.
+func entitlementFailure(reason string) bool {
+	return containsAny(strings.ToLower(reason), authFailures)
+}
+
+// notEntitled gives up channel, which the account isn't entitled to: it is
+// left, so it isn't joined again, and reported through OnError and
+// OnJoinError.
+func (cli *Client) notEntitled(channel, message string) {
+	cli.mu.Lock()
+	delete(cli.channels, channel)
+	delete(cli.joinedChannels, channel)
+	cli.forgetJoin(channel)
+	cli.mu.Unlock()
+	err := &EntitlementError{Ticker: channel, Message: message}
+	cli.onError(err)
+	cli.onJoinError(channel, &JoinError{Channel: channel, Reason: message, Err: err})
+}
@@ -0,0 +1,237 @@
+package intriniorealtime
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestClientJoinReply(t *testing.T) {
+	tests := []struct {
+		name          string
+		provider      provider
+		join          string
+		fixture       string
+		wantHandled   bool
+		wantConfirmed bool
+		wantJoined    bool
+		wantErr       *JoinError
+	}{
+		{
+			name:          "okの応答でJoinを確定すること",
+			provider:      IEX,
+			join:          "AAPL",
+			fixture:       "iex_join_ok.json",
+			wantHandled:   true,
+			wantConfirmed: true,
+			wantJoined:    true,
+		},
+		{
+			name:        "errorの応答でJoinErrorを通知し送信済みから外すこと",
+			provider:    IEX,
+			join:        "NOPE",
+			fixture:     "iex_join_error.json",
+			wantHandled: true,
+			wantErr:     &JoinError{Channel: "NOPE", Reason: "invalid security"},
+		},
+		{
+			name:       "待っていないトピックの応答は処理しないこと",
+			provider:   IEX,
+			join:       "MSFT",
+			fixture:    "iex_join_ok.json",
+			wantJoined: true,
+		},
+		{
+			name:       "QUODDでは処理しないこと",
+			provider:   QUODD,
+			join:       "AAPL",
+			fixture:    "iex_join_ok.json",
+			wantJoined: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := make(chan error, 1)
+			sut := New(yourIntrinioAPIUserName, yourIntrinioAPIPassword, tt.provider)
+			sut.OnJoinError(func(channel string, reason error) {
+				if channel != reason.(*JoinError).Channel {
+					t.Errorf("OnJoinError() channel = %s, reason = %v", channel, reason)
+				}
+				errs <- reason
+			})
+			sut.mu.Lock()
+			sut.joinedChannels[tt.join] = true
+			sut.awaitJoin(tt.join)
+			sut.mu.Unlock()
+
+			if got := sut.joinReply(loadFixture(t, tt.fixture)); got != tt.wantHandled {
+				t.Errorf("joinReply() = %v, want %v", got, tt.wantHandled)
+			}
+			if got := sut.Confirmed(tt.join); got != tt.wantConfirmed {
+				t.Errorf("Confirmed() = %v, want %v", got, tt.wantConfirmed)
+			}
+			sut.mu.Lock()
+			joined := sut.joinedChannels[tt.join]
+			sut.mu.Unlock()
+			if joined != tt.wantJoined {
+				t.Errorf("joinedChannels[%s] = %v, want %v", tt.join, joined, tt.wantJoined)
+			}
+			if tt.wantErr == nil {
+				return
+			}
+			select {
+			case err := <-errs:
+				if je, ok := err.(*JoinError); !ok || *je != *tt.wantErr {
+					t.Errorf("OnJoinError() reason = %#v, want %#v", err, tt.wantErr)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("OnJoinError() was not called")
+			}
+		})
+	}
+}
+
+// rejectJoins answers IEX joins, rejecting those for the given topic.
+func rejectJoins(topic string) func(s *fakeServer, conn *websocket.Conn, msg map[string]interface{}) {
+	return rejectJoinsWith(topic, "invalid security")
+}
+
+// rejectJoinsWith answers IEX joins, rejecting those for the given topic
+// with reason.
+func rejectJoinsWith(topic, reason string) func(s *fakeServer, conn *websocket.Conn, msg map[string]interface{}) {
+	return func(s *fakeServer, conn *websocket.Conn, msg map[string]interface{}) {
+		if msg["event"] != "phx_join" {
+			return
+		}
+		payload := map[string]interface{}{"status": "ok", "response": map[string]interface{}{}}
+		if msg["topic"] == topic {
+			payload = map[string]interface{}{"status": "error", "response": map[string]interface{}{"reason": reason}}
+		}
+		s.send(conn, map[string]interface{}{"topic": msg["topic"], "event": "phx_reply", "payload": payload, "ref": nil})
+	}
+}
+
+func TestClientOnJoinError(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+	server.setReply(rejectJoins("iex:securities:NOPE"))
+
+	type joinError struct {
+		channel string
+		reason  error
+	}
+	errs := make(chan joinError, 10)
+	quotes := make(chan map[string]interface{}, 10)
+	sut := server.newClient(IEX)
+	sut.OnJoinError(func(channel string, reason error) { errs <- joinError{channel, reason} })
+	sut.OnQuote(func(quote map[string]interface{}) { quotes <- quote })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	sut.Join("AAPL", "NOPE")
+	select {
+	case got := <-errs:
+		if got.channel != "NOPE" || got.reason.Error() != "join NOPE rejected: invalid security" {
+			t.Errorf("OnJoinError() = %s, %v", got.channel, got.reason)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnJoinError() was not called")
+	}
+	if !waitUntil(5*time.Second, func() bool { return sut.Confirmed("AAPL") }) {
+		t.Error("AAPL was not confirmed")
+	}
+	if sut.Confirmed("NOPE") {
+		t.Error("Confirmed(NOPE) = true")
+	}
+	if len(quotes) != 0 {
+		t.Errorf("join replies were passed to OnQuote: %v", <-quotes)
+	}
+
+	// The rejected channel is tried again with the next join.
+	sut.Join("MSFT")
+	if !waitUntil(5*time.Second, func() bool { return len(errs) == 1 }) {
+		t.Fatal("NOPE was not joined again")
+	}
+	var topics []interface{}
+	for _, msg := range server.messagesWithEvent("phx_join") {
+		topics = append(topics, msg["topic"])
+	}
+	if len(topics) != 4 || !(topics[2] == "iex:securities:NOPE" && topics[3] == "iex:securities:MSFT" ||
+		topics[2] == "iex:securities:MSFT" && topics[3] == "iex:securities:NOPE") {
+		t.Errorf("joins = %v, want AAPL and NOPE, then MSFT and NOPE", topics)
+	}
+}
+
+func TestClientJoinNotEntitled(t *testing.T) {
+	tests := []struct {
+		name   string
+		reason string
+	}{
+		{name: "権限のないチャンネルを外すこと", reason: "not entitled to the firehose"},
+		// The word a rejected token is told by, which must not be taken as one.
+		{name: "unauthorizedで拒否されたチャンネルも外すこと", reason: "unauthorized"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+			server.setReply(rejectJoinsWith("iex:lobby", tt.reason))
+
+			errs := make(chan error, 10)
+			joinErrs := make(chan error, 10)
+			sut := server.newClient(IEX)
+			sut.OnError(func(err error) { errs <- err })
+			sut.OnJoinError(func(channel string, reason error) { joinErrs <- reason })
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			defer sut.Disconnect()
+
+			sut.Join("$lobby", "AAPL")
+			select {
+			case err := <-errs:
+				var ee *EntitlementError
+				if !errors.As(err, &ee) || ee.Ticker != "$lobby" || ee.Message != tt.reason {
+					t.Errorf("OnError() error = %v, want an *EntitlementError for $lobby", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("OnError() was not called")
+			}
+			select {
+			case err := <-joinErrs:
+				var ee *EntitlementError
+				if !errors.As(err, &ee) || ee.Ticker != "$lobby" {
+					t.Errorf("OnJoinError() reason = %v, want one wrapping an *EntitlementError", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("OnJoinError() was not called")
+			}
+			if got, want := sut.Subscriptions(), []string{"AAPL"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("Subscriptions() = %v, want %v", got, want)
+			}
+
+			// The lobby isn't tried again with the next join.
+			sut.Join("MSFT")
+			if !waitUntil(5*time.Second, func() bool { return sut.Confirmed("MSFT") }) {
+				t.Fatal("MSFT was not confirmed")
+			}
+			var lobbies int
+			for _, msg := range server.messagesWithEvent("phx_join") {
+				if msg["topic"] == "iex:lobby" {
+					lobbies++
+				}
+			}
+			if lobbies != 1 {
+				t.Errorf("lobby joins = %d, want 1", lobbies)
+			}
+			if got := server.authCount(); got != 1 {
+				t.Errorf("auth calls = %d, want 1", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,11 @@
+package intriniorealtime
+
+// offLastPriceLobby reports a message on the IEX last price lobby that isn't
+// a last price. The lobby is for last prices only, so they are dropped
+// before the handlers see them.
+func offLastPriceLobby(provider provider, msg map[string]interface{}) bool {
+	if provider != IEX || msg["topic"] != "iex:lobby:last_price" {
+		return false
+	}
+	return Classify(provider, msg) == MessageQuote
+}
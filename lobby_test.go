@@ -0,0 +1,131 @@
+package intriniorealtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// loadFrames returns the frames of a fixture with one frame per line.
+func loadFrames(t *testing.T, name string) [][]byte {
+	t.Helper()
+	b, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Split(bytes.TrimSpace(b), []byte("\n"))
+}
+
+// withTopic returns frame with its topic replaced; a topic ending in ":"
+// gets the payload's ticker appended.
+func withTopic(t *testing.T, frame []byte, topic string) []byte {
+	t.Helper()
+	var msg map[string]interface{}
+	if err := json.Unmarshal(frame, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if topic[len(topic)-1] == ':' {
+		topic += msg["payload"].(map[string]interface{})["ticker"].(string)
+	}
+	msg["topic"] = topic
+	b, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestClientLobbyFanOut(t *testing.T) {
+	tests := []struct {
+		name  string
+		join  []string
+		topic string
+	}{
+		{name: "ロビーのメッセージを銘柄ごとに振り分けること", join: []string{"$lobby"}, topic: "iex:lobby"},
+		{name: "銘柄のチャネルと同じように振り分けること", join: []string{"GE", "AAPL", "MSFT"}, topic: "iex:securities:"},
+	}
+	want := map[string][]string{
+		"GE":   {"quote bid", "quote last", "trade 28.97x300", "quote last", "trade 28.97x13750"},
+		"AAPL": {"quote ask", "quote last", "trade 143.66x100"},
+		"MSFT": {"quote bid"},
+	}
+	server := newFakeServer()
+	defer server.Close()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			got := make(map[string][]string)
+			add := func(symbol, s string) {
+				mu.Lock()
+				defer mu.Unlock()
+				got[symbol] = append(got[symbol], s)
+			}
+			sut := server.newClient(IEX, WithDispatchWorkers(3))
+			sut.OnIEXQuote(func(quote IEXQuote) { add(quote.Ticker, "quote "+quote.Type) })
+			sut.OnTrade(func(trade Trade) { add(trade.Symbol, fmt.Sprintf("trade %vx%d", trade.Price, trade.Size)) })
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			defer sut.Disconnect()
+			sut.Join(tt.join...)
+
+			for _, frame := range loadFrames(t, "iex_lobby.jsonl") {
+				server.broadcastRaw(websocket.TextMessage, withTopic(t, frame, tt.topic))
+			}
+			done := waitUntil(5*time.Second, func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(got["GE"])+len(got["AAPL"])+len(got["MSFT"]) == 9
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			if !done || !reflect.DeepEqual(got, want) {
+				t.Errorf("handled = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestClientLobbyLastPrice(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	var mu sync.Mutex
+	var got []string
+	sut := server.newClient(IEX)
+	sut.OnQuote(func(quote map[string]interface{}) {
+		payload := quote["payload"].(map[string]interface{})
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, fmt.Sprintf("%s %s", payload["ticker"], payload["type"]))
+	})
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+	sut.Join("$lobby_last_price")
+
+	for _, frame := range loadFrames(t, "iex_lobby.jsonl") {
+		server.broadcastRaw(websocket.TextMessage, withTopic(t, frame, "iex:lobby:last_price"))
+	}
+	want := []string{"GE last", "AAPL last", "GE last"}
+	done := waitUntil(5*time.Second, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got) == len(want)
+	})
+	// Anything dropped would have come before the last frame.
+	mu.Lock()
+	defer mu.Unlock()
+	if !done || !reflect.DeepEqual(got, want) {
+		t.Errorf("OnQuote() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,88 @@
+package intriniorealtime
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Logger receives what the client does as events: msg is a short, fixed
+// description such as "connected", and keyvals alternate keys and values,
+// such as "channel", "AAPL". Every event carries the provider. Tokens,
+// passwords and API keys are never among them. A *slog.Logger is a Logger.
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+// WithLogger sends the client's events to l instead of printing them when
+// DebugMode is set.
+func WithLogger(l Logger) Option {
+	return func(cli *Client) error {
+		if l == nil {
+			return fmt.Errorf("logger must not be nil")
+		}
+		cli.logger = l
+		return nil
+	}
+}
+
+// stdoutLogger prints every event on a line of its own, the way DebugMode
+// always has.
+type stdoutLogger struct{}
+
+func (stdoutLogger) Debug(msg string, keyvals ...interface{}) { printEvent("DEBUG", msg, keyvals) }
+func (stdoutLogger) Info(msg string, keyvals ...interface{})  { printEvent("INFO", msg, keyvals) }
+func (stdoutLogger) Warn(msg string, keyvals ...interface{})  { printEvent("WARN", msg, keyvals) }
+func (stdoutLogger) Error(msg string, keyvals ...interface{}) { printEvent("ERROR", msg, keyvals) }
+
+func printEvent(level, msg string, keyvals []interface{}) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "IntrinioRealtime | %s %s", level, msg)
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", keyvals[i], keyvals[i+1])
+	}
+	fmt.Println(b.String())
+}
+
+// log returns where events go: the Logger of WithLogger, a stdoutLogger
+// while DebugMode is set, or nowhere.
+func (cli *Client) log() Logger {
+	if cli.logger != nil {
+		return cli.logger
+	}
+	if cli.DebugMode {
+		return stdoutLogger{}
+	}
+	return nil
+}
+
+// event adds the provider to the keyvals of an event.
+func (cli *Client) event(keyvals []interface{}) []interface{} {
+	return append([]interface{}{"provider", string(cli.provider)}, keyvals...)
+}
+
+func (cli *Client) debug(msg string, keyvals ...interface{}) {
+	if l := cli.log(); l != nil {
+		l.Debug(msg, cli.event(keyvals)...)
+	}
+}
+
+func (cli *Client) info(msg string, keyvals ...interface{}) {
+	if l := cli.log(); l != nil {
+		l.Info(msg, cli.event(keyvals)...)
+	}
+}
+
+func (cli *Client) warn(msg string, keyvals ...interface{}) {
+	if l := cli.log(); l != nil {
+		l.Warn(msg, cli.event(keyvals)...)
+	}
+}
+
+func (cli *Client) logError(msg string, keyvals ...interface{}) {
+	if l := cli.log(); l != nil {
+		l.Error(msg, cli.event(keyvals)...)
+	}
+}
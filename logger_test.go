@@ -0,0 +1,107 @@
+package intriniorealtime
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var _ Logger = (*slog.Logger)(nil)
+
+type loggedEvent struct {
+	level   string
+	msg     string
+	keyvals []interface{}
+}
+
+// recordingLogger keeps every event it receives.
+type recordingLogger struct {
+	mu     sync.Mutex
+	events []loggedEvent
+}
+
+func (l *recordingLogger) add(level, msg string, keyvals []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, loggedEvent{level: level, msg: msg, keyvals: keyvals})
+}
+
+func (l *recordingLogger) Debug(msg string, keyvals ...interface{}) { l.add("debug", msg, keyvals) }
+func (l *recordingLogger) Info(msg string, keyvals ...interface{})  { l.add("info", msg, keyvals) }
+func (l *recordingLogger) Warn(msg string, keyvals ...interface{})  { l.add("warn", msg, keyvals) }
+func (l *recordingLogger) Error(msg string, keyvals ...interface{}) { l.add("error", msg, keyvals) }
+
+func (l *recordingLogger) logged() []loggedEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]loggedEvent(nil), l.events...)
+}
+
+func (l *recordingLogger) has(level, msg string) bool {
+	for _, e := range l.logged() {
+		if e.level == level && e.msg == msg {
+			return true
+		}
+	}
+	return false
+}
+
+func TestClientWithLogger(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+	server.setReply(quoteOnSubscribe)
+
+	logger := &recordingLogger{}
+	sut := New("user", "s3cret-pw", QUODD, WithLogger(logger), WithReconnectPolicy(fastReconnect),
+		WithAuthURL(server.authURL()), WithWebsocketURL(server.soketURL()))
+	quotes := make(chan map[string]interface{}, 10)
+	sut.OnQuote(func(quote map[string]interface{}) { quotes <- quote })
+	sut.Join("AAPL.NB")
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	receive(t, "OnQuote()", quotes)
+	server.kick(websocket.CloseGoingAway, "restart")
+	if !waitUntil(5*time.Second, func() bool { return logger.has("info", "reconnected") }) {
+		t.Fatal("reconnected was not logged")
+	}
+	if err := sut.Disconnect(); err != nil {
+		t.Fatalf("Disconnect() error = %v", err)
+	}
+
+	for _, want := range []struct{ level, msg string }{
+		{"info", "connecting"},
+		{"info", "connected"},
+		{"debug", "send"},
+		{"debug", "message"},
+		{"warn", "connection lost"},
+		{"info", "reconnecting"},
+		{"info", "reconnected"},
+		{"info", "closed"},
+	} {
+		if !logger.has(want.level, want.msg) {
+			t.Errorf("no %s event %q", want.level, want.msg)
+		}
+	}
+	for _, e := range logger.logged() {
+		if len(e.keyvals) < 2 || e.keyvals[0] != "provider" || e.keyvals[1] != "quodd" || len(e.keyvals)%2 != 0 {
+			t.Errorf("%s event %q keyvals = %v, want the provider and pairs", e.level, e.msg, e.keyvals)
+		}
+		if e.msg == "message" && (e.keyvals[3] != MessageQuote || e.keyvals[5] != "AAPL.NB") {
+			t.Errorf("message event keyvals = %v, want the type and channel", e.keyvals)
+		}
+		logged := fmt.Sprint(e.keyvals...)
+		if strings.Contains(logged, "token-") || strings.Contains(logged, "s3cret-pw") {
+			t.Errorf("%s event %q keyvals = %v, leak a secret", e.level, e.msg, e.keyvals)
+		}
+	}
+
+	if err := New("", "", IEX, WithLogger(nil)).Connect(); err == nil {
+		t.Error("Connect() with a nil logger error = nil")
+	}
+}
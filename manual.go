@@ -0,0 +1,96 @@
+package intriniorealtime
+
+import "fmt"
+
+// manualEndpoint is where a MANUAL client connects and which provider's
+// messages the server there speaks.
+type manualEndpoint struct {
+	dialect      provider
+	authURL      string
+	websocketURL string
+}
+
+// WithManualEndpoint points a MANUAL client at a server of the caller's,
+// e.g. a gateway that relays Intrinio's data: tokens are fetched from
+// authURL and the websocket dialed at websocketURL, a ws or wss URL with
+// the host and port of the server. dialect is the provider whose messages
+// the server speaks, IEX-style phoenix or QUODD for example; its join,
+// leave and heartbeat messages are sent and its quotes parsed, and the
+// received Messages carry it as their Provider. authURL may be empty when
+// the token comes from WithToken. The option is an error for the other
+// providers.
+func WithManualEndpoint(dialect provider, authURL, websocketURL string) Option {
+	return func(cli *Client) error {
+		if cli.provider != MANUAL {
+			return fmt.Errorf("WithManualEndpoint is for the MANUAL provider, not %s", cli.provider)
+		}
+		if dialect == MANUAL || makeSoketBaseURLs(dialect) == nil {
+			return fmt.Errorf("unknown dialect %q", dialect)
+		}
+		if err := checkWebsocketURL(websocketURL); err != nil {
+			return err
+		}
+		if authURL != "" {
+			if err := checkAuthURL(authURL); err != nil {
+				return err
+			}
+		}
+		cli.manual = &manualEndpoint{dialect: dialect, authURL: authURL, websocketURL: websocketURL}
+		return nil
+	}
+}
+
+// resolveManual makes a MANUAL client the client of its dialect, at its
+// endpoint. It runs after all options have been applied, so their order
+// does not matter.
+func (cli *Client) resolveManual() error {
+	if cli.provider != MANUAL {
+		return nil
+	}
+	m := cli.manual
+	if m == nil {
+		return fmt.Errorf("the MANUAL provider needs WithManualEndpoint")
+	}
+	if m.authURL != "" {
+		cli.authURL = m.authURL
+	}
+	if cli.authURL == "" && !cli.staticToken {
+		return fmt.Errorf("the MANUAL provider needs an auth URL or WithToken")
+	}
+	cli.provider = m.dialect
+	if cli.tokenTTL == manualTokenTTL {
+		cli.tokenTTL = defaultTokenTTL(m.dialect)
+	}
+	if len(cli.soketURLs) == 0 {
+		cli.soketURLs = []string{m.websocketURL}
+	}
+	return nil
+}
+
+// authEndpoint returns the URL tokens are fetched from: the client's own
+// or else its provider's.
+func (cli *Client) authEndpoint() (string, error) {
+	if cli.authURL != "" {
+		return cli.authURL, nil
+	}
+	if url := makeAuthURL(cli.provider); url != "" {
+		return url, nil
+	}
+	return "", fmt.Errorf("no auth URL for provider %q", cli.provider)
+}
+
+// soketEndpoints returns the websocket base URLs to dial: the client's own
+// or else its provider's. A Provider of the user's own has none, as
+// dialEndpoints asks it for the URL with the token.
+func (cli *Client) soketEndpoints() ([]string, error) {
+	if len(cli.soketURLs) != 0 {
+		return cli.soketURLs, nil
+	}
+	if cli.feed != nil {
+		return nil, nil
+	}
+	if urls := makeSoketBaseURLs(cli.provider); len(urls) != 0 {
+		return urls, nil
+	}
+	return nil, fmt.Errorf("no websocket URL for provider %q", cli.provider)
+}
@@ -0,0 +1,110 @@
+package intriniorealtime
+
+import (
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestManualEndpointErrors(t *testing.T) {
+	const auth, ws = "http://gateway.internal:8080/auth", "ws://gateway.internal:8081/socket"
+	tests := []struct {
+		name     string
+		provider provider
+		opts     []Option
+	}{
+		{name: "エンドポイントがなければエラーにすること", provider: MANUAL},
+		{name: "MANUAL以外のプロバイダではエラーにすること", provider: IEX, opts: []Option{WithManualEndpoint(IEX, auth, ws)}},
+		{name: "MANUALの方言はエラーにすること", provider: MANUAL, opts: []Option{WithManualEndpoint(MANUAL, auth, ws)}},
+		{name: "未知の方言はエラーにすること", provider: MANUAL, opts: []Option{WithManualEndpoint("bogus", auth, ws)}},
+		{name: "wsでないWebSocketのURLはエラーにすること", provider: MANUAL, opts: []Option{WithManualEndpoint(IEX, auth, "http://gateway.internal:8081/socket")}},
+		{name: "ホストのないWebSocketのURLはエラーにすること", provider: MANUAL, opts: []Option{WithManualEndpoint(IEX, auth, "ws:///socket")}},
+		{name: "httpでない認証URLはエラーにすること", provider: MANUAL, opts: []Option{WithManualEndpoint(IEX, "ftp://gateway.internal/auth", ws)}},
+		{name: "認証URLもトークンもなければエラーにすること", provider: MANUAL, opts: []Option{WithManualEndpoint(QUODD, "", ws)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := New("user", "pass", tt.provider, tt.opts...)
+			if err := sut.Connect(); err == nil {
+				sut.Disconnect()
+				t.Fatal("Connect() error = nil, want the invalid option")
+			}
+		})
+	}
+}
+
+func TestClientManual(t *testing.T) {
+	tests := []struct {
+		name      string
+		dialect   provider
+		token     bool
+		channel   string
+		reply     func(t *testing.T) func(s *fakeServer, conn *websocket.Conn, msg map[string]interface{})
+		wantAuths int
+	}{
+		{
+			name:    "IEXの方言で受け取ること",
+			dialect: IEX,
+			channel: "GE",
+			reply: func(t *testing.T) func(s *fakeServer, conn *websocket.Conn, msg map[string]interface{}) {
+				return realtimeDialect(loadBinary(t, "iex_quote.json"))
+			},
+			wantAuths: 1,
+		},
+		{
+			name:    "QUODDの方言で受け取ること",
+			dialect: QUODD,
+			channel: "GE.NB",
+			reply: func(t *testing.T) func(s *fakeServer, conn *websocket.Conn, msg map[string]interface{}) {
+				return quoteOnSubscribe
+			},
+			wantAuths: 1,
+		},
+		{
+			name:    "発行済みのトークンなら認証しないこと",
+			dialect: IEX,
+			token:   true,
+			channel: "GE",
+			reply: func(t *testing.T) func(s *fakeServer, conn *websocket.Conn, msg map[string]interface{}) {
+				return realtimeDialect(loadBinary(t, "iex_quote.json"))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+			server.setReply(tt.reply(t))
+
+			authURL := server.authURL()
+			opts := []Option{WithReconnectPolicy(fastReconnect)}
+			if tt.token {
+				authURL = ""
+				opts = append(opts, WithToken("issued-by-the-gateway"))
+			}
+			sut := New("user", "pass", MANUAL, append(opts, WithManualEndpoint(tt.dialect, authURL, server.soketURL()))...)
+			messages := make(chan Message, 10)
+			sut.OnMessage(func(msg Message) {
+				if msg.Type == MessageQuote {
+					messages <- msg
+				}
+			})
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("Connect() error = %v", err)
+			}
+			defer sut.Disconnect()
+			sut.Join(tt.channel)
+
+			msg := receive(t, "OnMessage()", messages)
+			if msg.Provider != tt.dialect || msg.Channel != tt.channel {
+				t.Errorf("OnMessage() = %s message on %q, want a %s one on %q", msg.Provider, msg.Channel, tt.dialect, tt.channel)
+			}
+			server.mu.Lock()
+			auths := server.authCalls
+			server.mu.Unlock()
+			if auths != tt.wantAuths {
+				t.Errorf("auth calls = %d, want %d", auths, tt.wantAuths)
+			}
+		})
+	}
+}
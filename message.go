@@ -0,0 +1,209 @@
+package intriniorealtime
+
+import "time"
+
+// MessageType is the kind of a received message.
+type MessageType int
+
+// The kinds of received messages.
+const (
+	MessageUnknown MessageType = iota
+	MessageQuote
+	MessageTrade
+	MessageDepth
+	MessageJoinReply
+	MessageHeartbeatAck
+	MessageInfo
+	MessageError
+	MessageStatus
+)
+
+func (t MessageType) String() string {
+	switch t {
+	case MessageQuote:
+		return "quote"
+	case MessageTrade:
+		return "trade"
+	case MessageDepth:
+		return "depth"
+	case MessageJoinReply:
+		return "join reply"
+	case MessageHeartbeatAck:
+		return "heartbeat ack"
+	case MessageInfo:
+		return "info"
+	case MessageError:
+		return "error"
+	case MessageStatus:
+		return "status"
+	default:
+		return "unknown"
+	}
+}
+
+// control reports the kinds that carry no market data and are kept from
+// OnQuote unless WithControlMessagesInOnQuote was given.
+func (t MessageType) control() bool {
+	switch t {
+	case MessageJoinReply, MessageHeartbeatAck, MessageInfo, MessageError:
+		return true
+	}
+	return false
+}
+
+// Classify returns the kind of a received message.
+//
+// IEX quotes of type "last" are trades and the other quotes are quotes. A
+// phx_reply on the phoenix topic acknowledges a heartbeat; on any other
+// topic it answers a join or leave. phx_error is an error and
+// trading_status a status. REALTIME, DELAYED_SIP, NASDAQ_BASIC and OPRA
+// send trade and quote events and answer like IEX; so does CRYPTOQUOTE,
+// whose book_update events are quotes, and FXCM, whose price_update events
+// are.
+//
+// QUODD's events say it themselves: quote and quote_data, trade and
+// trade_data, depth, heartbeat, info and error, and status and luld are
+// statuses.
+func Classify(provider provider, msg map[string]interface{}) MessageType {
+	event, _ := msg["event"].(string)
+	switch provider {
+	case IEX:
+		switch event {
+		case "quote":
+			payload, _ := msg["payload"].(map[string]interface{})
+			if payload["type"] == "last" {
+				return MessageTrade
+			}
+			return MessageQuote
+		case "trading_status":
+			return MessageStatus
+		}
+		return classifyPhoenix(msg)
+	case REALTIME, DELAYED_SIP, NASDAQ_BASIC:
+		switch event {
+		case "trade":
+			return MessageTrade
+		case "quote":
+			return MessageQuote
+		}
+		return classifyPhoenix(msg)
+	case OPRA:
+		switch event {
+		case "trade":
+			return MessageTrade
+		case "quote":
+			return MessageQuote
+		}
+		return classifyPhoenix(msg)
+	case CRYPTOQUOTE:
+		switch event {
+		case "trade":
+			return MessageTrade
+		case "book_update":
+			return MessageQuote
+		}
+		return classifyPhoenix(msg)
+	case FXCM:
+		if event == "price_update" {
+			return MessageQuote
+		}
+		return classifyPhoenix(msg)
+	case QUODD:
+		switch event {
+		case "quote", "quote_data":
+			return MessageQuote
+		case "trade", "trade_data":
+			return MessageTrade
+		case "depth":
+			return MessageDepth
+		case "heartbeat":
+			return MessageHeartbeatAck
+		case "info":
+			return MessageInfo
+		case "error":
+			return MessageError
+		case "status", "luld":
+			return MessageStatus
+		}
+	}
+	return MessageUnknown
+}
+
+// classifyPhoenix returns the kind of the messages of the phoenix transport
+// itself: replies and errors.
+func classifyPhoenix(msg map[string]interface{}) MessageType {
+	switch msg["event"] {
+	case "phx_reply":
+		if msg["topic"] == "phoenix" {
+			return MessageHeartbeatAck
+		}
+		return MessageJoinReply
+	case "phx_error":
+		return MessageError
+	}
+	return MessageUnknown
+}
+
+// Message is a received text message together with what the client knows
+// about it. The frame is decoded once and the same Message is passed on to
+// OnQuote and the typed handlers, so don't modify Raw or Payload.
+type Message struct {
+	Provider provider
+	Type     MessageType
+
+	// Channel is the symbol the message is about, or for other messages the
+	// IEX topic they were sent on. It is empty when there is neither.
+	Channel string
+
+	// ReceivedAt is when the frame was read off the socket, before it was
+	// decoded.
+	ReceivedAt time.Time
+
+	// Raw is the frame as received and Payload the frame decoded. For an
+	// event of a binary frame Raw is the event's own bytes and Payload is
+	// nil.
+	Raw     []byte
+	Payload map[string]interface{}
+
+	// Delayed is set for the messages of a delayed feed, DELAYED_SIP, whose
+	// data is 15 minutes old and mustn't be taken for the market now.
+	Delayed bool
+
+	// exact is Payload with the sizes and volumes as json.Number, see
+	// decodeFrame.
+	exact  map[string]interface{}
+	binary *binaryEvent
+}
+
+// newMessage wraps the frame data, decoded into payload and exact, that was
+// read at receivedAt.
+func (cli *Client) newMessage(data []byte, payload, exact map[string]interface{}, receivedAt time.Time) Message {
+	return Message{
+		Provider:   cli.provider,
+		Type:       Classify(cli.provider, payload),
+		Channel:    messageChannel(cli.provider, payload),
+		ReceivedAt: receivedAt,
+		Raw:        data,
+		Payload:    payload,
+		Delayed:    delayed(cli.provider),
+		exact:      exact,
+	}
+}
+
+// OnMessage registers a handler for every received text message, whatever
+// its kind: also heartbeat acks, join replies and depth updates, which don't
+// go to OnQuote. It runs before the other handlers get the message.
+func (cli *Client) OnMessage(f func(msg Message)) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
+	cli.messageHandler = f
+}
+
+func (cli *Client) onMessage(msg Message) {
+	cli.handlerMu.RLock()
+	f := cli.messageHandler
+	cli.handlerMu.RUnlock()
+	if f != nil {
+		cli.callHandler("OnMessage", func() { f(msg) })
+	}
+}
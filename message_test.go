@@ -0,0 +1,170 @@
+package intriniorealtime
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider provider
+		msg      map[string]interface{}
+		want     MessageType
+	}{
+		{name: "IEXのbidはクォートにすること", provider: IEX, msg: loadFixture(t, "iex_quote.json"), want: MessageQuote},
+		{
+			name:     "IEXのlastはトレードにすること",
+			provider: IEX,
+			msg:      map[string]interface{}{"topic": "iex:lobby:last_price", "event": "quote", "payload": map[string]interface{}{"type": "last", "ticker": "GE"}},
+			want:     MessageTrade,
+		},
+		{
+			name:     "IEXのpayloadがないクォートもクォートにすること",
+			provider: IEX,
+			msg:      map[string]interface{}{"topic": "iex:securities:GE", "event": "quote"},
+			want:     MessageQuote,
+		},
+		{
+			name:     "IEXのphoenixトピックの応答はハートビートの応答にすること",
+			provider: IEX,
+			msg:      map[string]interface{}{"topic": "phoenix", "event": "phx_reply", "payload": map[string]interface{}{"status": "ok"}},
+			want:     MessageHeartbeatAck,
+		},
+		{name: "IEXのJoinの応答はJoinの応答にすること", provider: IEX, msg: loadFixture(t, "iex_join_ok.json"), want: MessageJoinReply},
+		{name: "IEXのJoinのエラーもJoinの応答にすること", provider: IEX, msg: loadFixture(t, "iex_join_error.json"), want: MessageJoinReply},
+		{
+			name:     "IEXのphx_errorはエラーにすること",
+			provider: IEX,
+			msg:      map[string]interface{}{"topic": "iex:securities:GE", "event": "phx_error", "payload": map[string]interface{}{}},
+			want:     MessageError,
+		},
+		{
+			name:     "IEXのtrading_statusは状態にすること",
+			provider: IEX,
+			msg:      map[string]interface{}{"topic": "iex:securities:GE", "event": "trading_status", "payload": map[string]interface{}{"status": "H"}},
+			want:     MessageStatus,
+		},
+		{
+			name:     "IEXの知らないイベントはUnknownにすること",
+			provider: IEX,
+			msg:      map[string]interface{}{"topic": "iex:lobby", "event": "presence_state"},
+			want:     MessageUnknown,
+		},
+		{name: "IEXでQUODDのハートビートはUnknownにすること", provider: IEX, msg: map[string]interface{}{"event": "heartbeat"}, want: MessageUnknown},
+		{name: "QUODDのクォートはクォートにすること", provider: QUODD, msg: loadFixture(t, "quodd_quote.json"), want: MessageQuote},
+		{name: "QUODDのquote_dataはクォートにすること", provider: QUODD, msg: loadFixture(t, "quodd_quote_partial.json"), want: MessageQuote},
+		{name: "QUODDのトレードはトレードにすること", provider: QUODD, msg: loadFixture(t, "quodd_trade.json"), want: MessageTrade},
+		{name: "QUODDのtrade_dataはトレードにすること", provider: QUODD, msg: loadFixture(t, "quodd_trade_partial.json"), want: MessageTrade},
+		{name: "QUODDのdepthは板情報にすること", provider: QUODD, msg: map[string]interface{}{"event": "depth", "data": map[string]interface{}{"ticker": "AAPL.NB"}}, want: MessageDepth},
+		{name: "QUODDのheartbeatはハートビートの応答にすること", provider: QUODD, msg: map[string]interface{}{"event": "heartbeat", "data": map[string]interface{}{"action": "heartbeat"}}, want: MessageHeartbeatAck},
+		{name: "QUODDのinfoはinfoにすること", provider: QUODD, msg: loadFixture(t, "quodd_info_subscribed.json"), want: MessageInfo},
+		{name: "QUODDのerrorはエラーにすること", provider: QUODD, msg: map[string]interface{}{"event": "error", "data": map[string]interface{}{"message": "bad request"}}, want: MessageError},
+		{name: "QUODDのstatusは状態にすること", provider: QUODD, msg: map[string]interface{}{"event": "status", "data": map[string]interface{}{"ticker": "AAPL.NB"}}, want: MessageStatus},
+		{name: "QUODDのluldは状態にすること", provider: QUODD, msg: map[string]interface{}{"event": "luld", "data": map[string]interface{}{"ticker": "AAPL.NB"}}, want: MessageStatus},
+		{name: "QUODDの知らないイベントはUnknownにすること", provider: QUODD, msg: map[string]interface{}{"event": "news"}, want: MessageUnknown},
+		{name: "QUODDでIEXの応答はUnknownにすること", provider: QUODD, msg: loadFixture(t, "iex_join_ok.json"), want: MessageUnknown},
+		{name: "eventがなければUnknownにすること", provider: QUODD, msg: map[string]interface{}{"data": map[string]interface{}{}}, want: MessageUnknown},
+		{name: "nilはUnknownにすること", provider: IEX, want: MessageUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify(tt.provider, tt.msg); got != tt.want {
+				t.Errorf("Classify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientControlMessages(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{name: "ハートビートの応答とphx_replyはOnQuoteに渡さないこと", want: []string{"quote"}},
+		{name: "互換オプションがあればOnQuoteに渡すこと", opts: []Option{WithControlMessagesInOnQuote()}, want: []string{"phx_reply", "phx_reply", "quote"}},
+	}
+	server := newFakeServer()
+	defer server.Close()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var events []string
+			var types []MessageType
+			done := make(chan struct{})
+			sut := server.newClient(IEX, tt.opts...)
+			sut.OnMessage(func(msg Message) { types = append(types, msg.Type) })
+			sut.OnQuote(func(quote map[string]interface{}) {
+				events = append(events, quote["event"].(string))
+				if quote["event"] == "quote" {
+					close(done)
+				}
+			})
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			defer sut.Disconnect()
+
+			server.broadcast(map[string]interface{}{"topic": "phoenix", "event": "phx_reply", "payload": map[string]interface{}{"status": "ok"}})
+			server.broadcast(map[string]interface{}{"topic": "iex:securities:MSFT", "event": "phx_reply", "payload": map[string]interface{}{"status": "ok"}})
+			server.broadcast(loadFixture(t, "iex_quote.json"))
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("OnQuote() got %v", events)
+			}
+			if !reflect.DeepEqual(events, tt.want) {
+				t.Errorf("OnQuote() events = %v, want %v", events, tt.want)
+			}
+			if want := []MessageType{MessageHeartbeatAck, MessageJoinReply, MessageQuote}; !reflect.DeepEqual(types, want) {
+				t.Errorf("OnMessage() types = %v, want %v", types, want)
+			}
+		})
+	}
+}
+
+func TestClientOnMessageEnvelope(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+	receivedAt := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	got := make(chan Message, 1)
+	var payload map[string]interface{}
+	quoted := make(chan struct{})
+	sut := server.newClient(IEX)
+	sut.now = func() time.Time { return receivedAt }
+	sut.OnMessage(func(msg Message) {
+		if msg.Payload["event"] == "quote" {
+			got <- msg
+		}
+	})
+	sut.OnQuote(func(quote map[string]interface{}) {
+		payload = quote
+		close(quoted)
+	})
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	raw := []byte(`{"topic":"iex:securities:AAPL","event":"quote","payload":{"type":"last","timestamp":1527598810.6203403,"ticker":"AAPL","size":100,"price":187.5}}`)
+	server.broadcastRaw(websocket.TextMessage, raw)
+	var msg Message
+	select {
+	case msg = <-got:
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnMessage() got no quote")
+	}
+	<-quoted
+	if msg.Provider != IEX || msg.Channel != "AAPL" || msg.Type != MessageTrade || !msg.ReceivedAt.Equal(receivedAt) {
+		t.Errorf("OnMessage() = %v %q %v %v, want iex \"AAPL\" trade %v", msg.Provider, msg.Channel, msg.Type, msg.ReceivedAt, receivedAt)
+	}
+	if string(msg.Raw) != string(raw) {
+		t.Errorf("OnMessage() Raw = %s, want %s", msg.Raw, raw)
+	}
+	if reflect.ValueOf(msg.Payload).Pointer() != reflect.ValueOf(payload).Pointer() {
+		t.Error("OnMessage() and OnQuote() got separately decoded payloads, want the same")
+	}
+}
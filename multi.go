@@ -0,0 +1,329 @@
+package intriniorealtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MultiClient runs one Client per provider, e.g. IEX and QUODD for the same
+// symbols, and delivers their messages through shared handlers. Each
+// connection reconnects on its own, so one provider being down doesn't stop
+// the others from streaming; State tells which are up.
+//
+// The MultiClient registers OnMessage, OnError, OnReconnect and OnDisconnect
+// of every Client. The other handlers, such as OnIEXQuote or OnQuoddQuote,
+// are registered on the Client itself, see Client.
+type MultiClient struct {
+	clients []*Client // in the order given to NewMulti
+	stats   map[provider]*multiStats
+
+	mu     sync.Mutex
+	mapper func(s ProviderSymbol) (string, bool)
+	errs   map[provider]error // why a connection is down, see State
+
+	handlerMu         sync.RWMutex
+	messageHandler    func(msg Message)
+	errorHandler      func(err error)
+	reconnectHandler  func(cause error)
+	disconnectHandler func(state ProviderState)
+}
+
+// ProviderSymbol is a symbol to be named for a provider, see
+// SetSymbolMapper.
+type ProviderSymbol struct {
+	Provider provider
+	Symbol   string
+}
+
+// multiStats are the counters behind ProviderStats.
+type multiStats struct {
+	messages      uint64
+	errors        uint64
+	reconnects    uint64
+	lastMessageAt int64 // UnixNano
+}
+
+// ProviderState is the state of one of a MultiClient's connections.
+type ProviderState struct {
+	Provider      provider
+	Connected     bool
+	Subscriptions []string
+
+	// Err is why the connection is down while it isn't connected: the error
+	// Connect returned, the one it was lost with or the one the client gave
+	// up on. It is nil while connected and after Disconnect.
+	Err error
+}
+
+// ProviderStats counts what one of a MultiClient's connections delivered.
+type ProviderStats struct {
+	Provider      provider
+	Messages      uint64 // passed to OnMessage
+	Errors        uint64 // passed to OnError
+	Reconnects    uint64
+	LastMessageAt time.Time // zero before the first message
+}
+
+// NewMulti returns a MultiClient over clients, which must be of different
+// providers and not connected yet. Make them with New or NewWithAPIKey and
+// the options each provider needs; the MultiClient takes them over.
+func NewMulti(clients ...*Client) (*MultiClient, error) {
+	if len(clients) == 0 {
+		return nil, errors.New("NewMulti needs at least one client")
+	}
+	m := &MultiClient{
+		clients: clients,
+		stats:   make(map[provider]*multiStats),
+		errs:    make(map[provider]error),
+		mapper:  func(s ProviderSymbol) (string, bool) { return s.Symbol, true },
+	}
+	for _, cli := range clients {
+		if cli == nil {
+			return nil, errors.New("NewMulti got a nil client")
+		}
+		if _, ok := m.stats[cli.provider]; ok {
+			if cli.provider == custom {
+				return nil, errors.New("NewMulti got two custom clients; give their Providers a Name method to tell them apart")
+			}
+			return nil, fmt.Errorf("NewMulti got two %s clients", cli.provider)
+		}
+		m.stats[cli.provider] = &multiStats{}
+		m.watch(cli)
+	}
+	return m, nil
+}
+
+// watch registers the handlers that feed the shared ones with the messages
+// and errors of cli.
+func (m *MultiClient) watch(cli *Client) {
+	p, stats := cli.provider, m.stats[cli.provider]
+	cli.OnMessage(func(msg Message) {
+		atomic.AddUint64(&stats.messages, 1)
+		atomic.StoreInt64(&stats.lastMessageAt, msg.ReceivedAt.UnixNano())
+		m.handlerMu.RLock()
+		f := m.messageHandler
+		m.handlerMu.RUnlock()
+		if f != nil {
+			f(msg)
+		}
+	})
+	cli.OnError(func(err error) {
+		atomic.AddUint64(&stats.errors, 1)
+		m.handlerMu.RLock()
+		f := m.errorHandler
+		m.handlerMu.RUnlock()
+		if f != nil {
+			f(&ProviderError{Provider: p, Err: err})
+		}
+	})
+	cli.OnReconnect(func(cause error) {
+		atomic.AddUint64(&stats.reconnects, 1)
+		m.setErr(p, nil)
+		m.handlerMu.RLock()
+		f := m.reconnectHandler
+		m.handlerMu.RUnlock()
+		if f != nil {
+			f(&ProviderError{Provider: p, Err: cause})
+		}
+	})
+	cli.OnDisconnect(func(cause error) {
+		m.setErr(p, cause)
+		m.handlerMu.RLock()
+		f := m.disconnectHandler
+		m.handlerMu.RUnlock()
+		if f != nil {
+			f(ProviderState{Provider: p, Subscriptions: cli.Subscriptions(), Err: cause})
+		}
+	})
+}
+
+func (m *MultiClient) setErr(p provider, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errs[p] = err
+}
+
+// Client returns the Client of provider p, or nil when there is none, to
+// register the handlers of its provider on.
+func (m *MultiClient) Client(p provider) *Client {
+	for _, cli := range m.clients {
+		if cli.provider == p {
+			return cli
+		}
+	}
+	return nil
+}
+
+// OnMessage registers a handler for the messages of every connection; their
+// Provider tells them apart. Like all of the MultiClient's handlers it may
+// be called from the connections at the same time.
+func (m *MultiClient) OnMessage(f func(msg Message)) {
+	m.handlerMu.Lock()
+	defer m.handlerMu.Unlock()
+	m.messageHandler = f
+}
+
+// OnError registers a handler for the errors of every connection. err is a
+// *ProviderError naming the connection.
+func (m *MultiClient) OnError(f func(err error)) {
+	m.handlerMu.Lock()
+	defer m.handlerMu.Unlock()
+	m.errorHandler = f
+}
+
+// OnReconnect registers a handler called after a connection has been
+// re-established, see Client.OnReconnect. cause is a *ProviderError naming
+// the connection.
+func (m *MultiClient) OnReconnect(f func(cause error)) {
+	m.handlerMu.Lock()
+	defer m.handlerMu.Unlock()
+	m.reconnectHandler = f
+}
+
+// OnDisconnect registers a handler called once a connection has shut down,
+// see Client.OnDisconnect. state.Err is nil after Disconnect.
+func (m *MultiClient) OnDisconnect(f func(state ProviderState)) {
+	m.handlerMu.Lock()
+	defer m.handlerMu.Unlock()
+	m.disconnectHandler = f
+}
+
+// SetSymbolMapper sets how Join and Leave name a symbol for each provider:
+// f returns the channel of s.Symbol for s.Provider, or false when that
+// provider shouldn't join it. By default every provider joins the symbol as
+// it is.
+func (m *MultiClient) SetSymbolMapper(f func(s ProviderSymbol) (channel string, ok bool)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mapper = f
+}
+
+// Join joins symbols on every connection, each named for its provider by
+// the symbol mapper.
+func (m *MultiClient) Join(symbols ...string) {
+	for cli, channels := range m.mapSymbols(symbols) {
+		cli.Join(channels...)
+	}
+}
+
+// Leave leaves symbols on every connection, named like Join does.
+func (m *MultiClient) Leave(symbols ...string) {
+	for cli, channels := range m.mapSymbols(symbols) {
+		cli.Leave(channels...)
+	}
+}
+
+// JoinProvider joins channels, named the way p names them, on the connection
+// of p only. It does nothing when there is none.
+func (m *MultiClient) JoinProvider(p provider, channels ...string) {
+	if cli := m.Client(p); cli != nil {
+		cli.Join(channels...)
+	}
+}
+
+// LeaveAll leaves every channel on every connection.
+func (m *MultiClient) LeaveAll() {
+	for _, cli := range m.clients {
+		cli.LeaveAll()
+	}
+}
+
+// mapSymbols returns the channels of symbols on each connection.
+func (m *MultiClient) mapSymbols(symbols []string) map[*Client][]string {
+	m.mu.Lock()
+	mapper := m.mapper
+	m.mu.Unlock()
+	ret := make(map[*Client][]string, len(m.clients))
+	for _, cli := range m.clients {
+		for _, symbol := range symbols {
+			if channel, ok := mapper(ProviderSymbol{Provider: cli.provider, Symbol: symbol}); ok {
+				ret[cli] = append(ret[cli], channel)
+			}
+		}
+	}
+	return ret
+}
+
+// Connect connects every client, see ConnectContext.
+func (m *MultiClient) Connect() error {
+	return m.ConnectContext(context.Background())
+}
+
+// ConnectContext connects every client at the same time. The ones that
+// failed are returned as ProviderErrors while the others stay connected, so
+// the error may mean a partial failure; Disconnect the MultiClient to give
+// up on all of them.
+func (m *MultiClient) ConnectContext(ctx context.Context) error {
+	return m.fanOut(func(cli *Client) error {
+		err := cli.ConnectContext(ctx)
+		m.setErr(cli.provider, err)
+		return err
+	})
+}
+
+// Disconnect disconnects every client at the same time and returns the
+// errors of the ones that failed as ProviderErrors.
+func (m *MultiClient) Disconnect() error {
+	return m.fanOut(func(cli *Client) error { return cli.Disconnect() })
+}
+
+// fanOut calls f for every client at the same time and joins the errors.
+func (m *MultiClient) fanOut(f func(cli *Client) error) error {
+	errs := make([]error, len(m.clients))
+	var wg sync.WaitGroup
+	for i, cli := range m.clients {
+		wg.Add(1)
+		go func(i int, cli *Client) {
+			defer wg.Done()
+			if err := f(cli); err != nil {
+				errs[i] = &ProviderError{Provider: cli.provider, Err: err}
+			}
+		}(i, cli)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// State returns the state of every connection, in the order of the clients
+// given to NewMulti.
+func (m *MultiClient) State() []ProviderState {
+	ret := make([]ProviderState, 0, len(m.clients))
+	for _, cli := range m.clients {
+		s := ProviderState{Provider: cli.provider, Connected: cli.Connected(), Subscriptions: cli.Subscriptions()}
+		if !s.Connected {
+			m.mu.Lock()
+			s.Err = m.errs[cli.provider]
+			m.mu.Unlock()
+			if history := cli.StateHistory(); s.Err == nil && 0 < len(history) {
+				// Lost and reconnecting: the latest transition says why.
+				s.Err = history[len(history)-1].Err
+			}
+		}
+		ret = append(ret, s)
+	}
+	return ret
+}
+
+// Stats returns the counters of every connection, in the order of the
+// clients given to NewMulti.
+func (m *MultiClient) Stats() []ProviderStats {
+	ret := make([]ProviderStats, 0, len(m.clients))
+	for _, cli := range m.clients {
+		stats := m.stats[cli.provider]
+		s := ProviderStats{
+			Provider:   cli.provider,
+			Messages:   atomic.LoadUint64(&stats.messages),
+			Errors:     atomic.LoadUint64(&stats.errors),
+			Reconnects: atomic.LoadUint64(&stats.reconnects),
+		}
+		if at := atomic.LoadInt64(&stats.lastMessageAt); at != 0 {
+			s.LastMessageAt = time.Unix(0, at)
+		}
+		ret = append(ret, s)
+	}
+	return ret
+}
@@ -0,0 +1,187 @@
+package intriniorealtime
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestNewMultiErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		clients []*Client
+	}{
+		{name: "クライアントがなければエラーにすること"},
+		{name: "nilのクライアントはエラーにすること", clients: []*Client{New("", "", IEX), nil}},
+		{name: "同じプロバイダが二つあればエラーにすること", clients: []*Client{New("", "", IEX), New("", "", IEX)}},
+		{name: "名前のない独自のプロバイダが二つあればエラーにすること", clients: []*Client{New("", "", tickFeed{}), New("", "", tickFeed{authURL: "other"})}},
+		{name: "同じ名前の独自のプロバイダが二つあればエラーにすること", clients: []*Client{New("", "", namedTickFeed{name: "ticks"}), New("", "", namedTickFeed{name: "ticks"})}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewMulti(tt.clients...); err == nil {
+				t.Error("NewMulti() error = nil, want one")
+			}
+		})
+	}
+}
+
+func TestNewMultiCustomProviders(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+	server.setReply(tickServer)
+
+	feed := tickFeed{authURL: server.authURL(), soketURL: server.soketURL()}
+	sut, err := NewMulti(New("", "", namedTickFeed{feed, "primary"}), New("", "", namedTickFeed{feed, "backup"}))
+	if err != nil {
+		t.Fatalf("NewMulti() error = %v", err)
+	}
+	ticks := make(chan Message, 10)
+	sut.OnMessage(func(msg Message) {
+		if msg.Type == MessageTrade {
+			ticks <- msg
+		}
+	})
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+	sut.Join("AAPL")
+	got := map[provider]bool{}
+	for i := 0; i < 2; i++ {
+		got[receive(t, "OnMessage()", ticks).Provider] = true
+	}
+	if !got["primary"] || !got["backup"] {
+		t.Errorf("OnMessage() providers = %v, want primary and backup", got)
+	}
+	if c := sut.Client("backup"); c == nil || c.provider != "backup" {
+		t.Errorf("Client(backup) = %v, want the backup feed's client", c)
+	}
+}
+
+// quoddSuffix names symbols the way QUODD does for the MultiClient tests.
+func quoddSuffix(s ProviderSymbol) (string, bool) {
+	if s.Provider == QUODD {
+		return s.Symbol + ".NB", true
+	}
+	return s.Symbol, true
+}
+
+func TestMultiClientReconnectsIndependently(t *testing.T) {
+	iex, quodd := newFakeServer(), newFakeServer()
+	defer iex.Close()
+	defer quodd.Close()
+	iex.setReply(realtimeDialect(loadBinary(t, "iex_quote.json")))
+	quodd.setReply(quoteOnSubscribe)
+
+	sut, err := NewMulti(iex.newClient(IEX), quodd.newClient(QUODD))
+	if err != nil {
+		t.Fatalf("NewMulti() error = %v", err)
+	}
+	sut.SetSymbolMapper(quoddSuffix)
+	var mu sync.Mutex
+	channels := make(map[provider][]string)
+	sut.OnMessage(func(msg Message) {
+		if msg.Type == MessageQuote {
+			mu.Lock()
+			channels[msg.Provider] = append(channels[msg.Provider], msg.Channel)
+			mu.Unlock()
+		}
+	})
+	quotes := func(p provider) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(channels[p])
+	}
+	reconnects := make(chan provider, 10)
+	sut.OnReconnect(func(cause error) { reconnects <- cause.(*ProviderError).Provider })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	sut.Join("GE")
+	if !waitUntil(5*time.Second, func() bool { return quotes(IEX) == 1 && quotes(QUODD) == 1 }) {
+		t.Fatalf("quotes = %v, want one of each provider", channels)
+	}
+	if got := sut.Client(QUODD).Subscriptions(); len(got) != 1 || got[0] != "GE.NB" {
+		t.Errorf("QUODD Subscriptions() = %v, want GE.NB", got)
+	}
+
+	// IEX goes away; QUODD keeps streaming while IEX reconnects on its own.
+	iex.kick(websocket.CloseGoingAway, "restart")
+	quodd.broadcast(map[string]interface{}{"event": "quote", "data": map[string]interface{}{"ticker": "GE.NB", "bid_price_4d": 1594900}})
+	if p := receive(t, "OnReconnect()", reconnects); p != IEX {
+		t.Errorf("OnReconnect() provider = %s, want IEX", p)
+	}
+	// The rejoin after the reconnect brings another IEX quote.
+	if !waitUntil(5*time.Second, func() bool { return quotes(IEX) == 2 && quotes(QUODD) == 2 }) {
+		t.Errorf("quotes = %v, want two of each provider", channels)
+	}
+	for _, s := range sut.State() {
+		if !s.Connected || s.Err != nil {
+			t.Errorf("State() = %+v, want %s connected", s, s.Provider)
+		}
+	}
+	for _, s := range sut.Stats() {
+		want := uint64(0)
+		if s.Provider == IEX {
+			want = 1
+		}
+		if s.Reconnects != want || s.Messages == 0 || s.LastMessageAt.IsZero() {
+			t.Errorf("Stats() = %+v, want %d reconnects and some messages", s, want)
+		}
+	}
+	select {
+	case p := <-reconnects:
+		t.Errorf("OnReconnect() called for %s as well", p)
+	default:
+	}
+}
+
+func TestMultiClientPartialFailure(t *testing.T) {
+	iex, quodd := newFakeServer(), newFakeServer()
+	defer iex.Close()
+	defer quodd.Close()
+	iex.setReply(ackHeartbeats)
+	quodd.setAuthStatus(http.StatusUnauthorized)
+
+	sut, err := NewMulti(iex.newClient(IEX), quodd.newClient(QUODD))
+	if err != nil {
+		t.Fatalf("NewMulti() error = %v", err)
+	}
+	disconnects := make(chan ProviderState, 10)
+	sut.OnDisconnect(func(state ProviderState) { disconnects <- state })
+	err = sut.Connect()
+	var pe *ProviderError
+	if !errors.As(err, &pe) || pe.Provider != QUODD || strings.Contains(err.Error(), "iex") {
+		t.Fatalf("Connect() error = %v, want a ProviderError of QUODD only", err)
+	}
+	defer sut.Disconnect()
+
+	state := sut.State()
+	if len(state) != 2 || state[0].Provider != IEX || !state[0].Connected || state[0].Err != nil {
+		t.Errorf("State()[0] = %+v, want IEX connected", state[0])
+	}
+	if len(state) != 2 || state[1].Provider != QUODD || state[1].Connected || state[1].Err == nil {
+		t.Errorf("State()[1] = %+v, want QUODD down with the auth error", state[1])
+	}
+	if err := sut.Disconnect(); err != nil {
+		t.Errorf("Disconnect() error = %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if s := receive(t, "OnDisconnect()", disconnects); s.Err != nil {
+			t.Errorf("OnDisconnect() = %+v, want no error after Disconnect", s)
+		}
+	}
+	for _, s := range sut.State() {
+		if s.Connected {
+			t.Errorf("State() = %+v after Disconnect", s)
+		}
+	}
+}
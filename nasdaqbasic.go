@@ -0,0 +1,72 @@
+package intriniorealtime
+
+import "strings"
+
+// tradesPrefix marks a NASDAQ_BASIC trades-only channel, e.g. "$trades:AAPL".
+const tradesPrefix = "$trades:"
+
+// TradesOnlyChannel returns the NASDAQ_BASIC channel that subscribes to the
+// trades of ticker without its quote updates. It can be passed to Join and
+// Leave like any other channel, but not be joined together with the
+// ticker's own channel: both are the same topic on the server.
+func TradesOnlyChannel(ticker string) string {
+	return normalizeChannel(tradesPrefix + ticker)
+}
+
+// tradesOnly reports whether channel is a trades-only channel.
+func tradesOnly(channel string) bool {
+	return strings.HasPrefix(channel, tradesPrefix)
+}
+
+// parseNasdaqBasicSides returns the bid and ask carried by msg, nil for a
+// side it doesn't have.
+//
+// NASDAQ_BASIC sends Nasdaq's last sales as trade events with the payload of
+// REALTIME's, from Nasdaq (Q) or reported to the FINRA/Nasdaq TRF (D). Its
+// best bid and offer come as quote events that carry both sides at once:
+// the symbol, bid_price, bid_size, ask_price, ask_size and the timestamp in
+// nanoseconds since the epoch.
+func parseNasdaqBasicSides(provider provider, msg map[string]interface{}) (bid, ask *QuoteSide) {
+	if provider != NASDAQ_BASIC {
+		return nil, nil
+	}
+	payload, ok := realtimePayload(provider, msg, "quote")
+	if !ok {
+		return nil, nil
+	}
+	symbol, _ := SymbolFromMessage(provider, msg)
+	at := payload["timestamp"]
+	side := func(prefix string) *QuoteSide {
+		price, ok := number(payload[prefix+"_price"])
+		if !ok {
+			return nil
+		}
+		s := &QuoteSide{Symbol: symbol, Price: price}
+		s.FixedPrice, _ = fixedPrice(payload[prefix+"_price"])
+		s.Size, _ = integer(payload[prefix+"_size"])
+		s.Timestamp, s.RawTimestamp = unixTime(at, timeUnit(provider, "timestamp", at))
+		return s
+	}
+	return side("bid"), side("ask")
+}
+
+// normalizeNasdaqBasicQuote returns the sides of a NASDAQ_BASIC quote,
+// normalized: the bid and then the ask.
+func normalizeNasdaqBasicQuote(provider provider, msg map[string]interface{}) []NormalizedQuote {
+	bid, ask := parseNasdaqBasicSides(provider, msg)
+	var quotes []NormalizedQuote
+	for _, s := range []struct {
+		name string
+		side *QuoteSide
+	}{{"bid", bid}, {"ask", ask}} {
+		if s.side == nil {
+			continue
+		}
+		n := NormalizedQuote{Symbol: s.side.Symbol, Side: s.name, Price: &s.side.Price, Size: &s.side.Size}
+		if !s.side.Timestamp.IsZero() {
+			n.Timestamp = &s.side.Timestamp
+		}
+		quotes = append(quotes, n)
+	}
+	return quotes
+}
@@ -0,0 +1,168 @@
+package intriniorealtime
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestParseNasdaqBasicSides(t *testing.T) {
+	at := time.Unix(1760621400, 123458000).UTC()
+	raw := RawTime{Value: 1760621400123458000, Unit: Nanoseconds}
+	tests := []struct {
+		name    string
+		msg     func(t *testing.T, cli *Client) map[string]interface{}
+		wantBid *QuoteSide
+		wantAsk *QuoteSide
+	}{
+		{
+			name: "気配の両側を解析すること",
+			msg: func(t *testing.T, cli *Client) map[string]interface{} {
+				return decodeFixture(t, cli, "nasdaq_basic_quote.json")
+			},
+			wantBid: &QuoteSide{Symbol: "AAPL", Price: 187.36, Size: 200, Timestamp: at, RawTimestamp: raw},
+			wantAsk: &QuoteSide{Symbol: "AAPL", Price: 187.38, Size: 300, Timestamp: at, RawTimestamp: raw},
+		},
+		{
+			name: "片側だけの気配はその側だけ返すこと",
+			msg: func(t *testing.T, cli *Client) map[string]interface{} {
+				return map[string]interface{}{"topic": "iex:securities:MSFT", "event": "quote", "payload": map[string]interface{}{
+					"bid_price": 415.5, "bid_size": float64(100),
+				}}
+			},
+			wantBid: &QuoteSide{Symbol: "MSFT", Price: 415.5, Size: 100},
+		},
+		{
+			name: "約定は気配にしないこと",
+			msg: func(t *testing.T, cli *Client) map[string]interface{} {
+				return decodeFixture(t, cli, "nasdaq_basic_trade.json")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := New(yourIntrinioAPIUserName, "", NASDAQ_BASIC)
+			var book topOfBook
+			bid, ask := book.sides(NASDAQ_BASIC, tt.msg(t, cli))
+			if !reflect.DeepEqual(bid, tt.wantBid) || !reflect.DeepEqual(ask, tt.wantAsk) {
+				t.Errorf("sides() = %+v %+v, want %+v %+v", bid, ask, tt.wantBid, tt.wantAsk)
+			}
+		})
+	}
+}
+
+func TestParseNasdaqBasicTrade(t *testing.T) {
+	cli := New(yourIntrinioAPIUserName, "", NASDAQ_BASIC)
+	want := Trade{Symbol: "AAPL", Price: 187.37, Size: 100, Timestamp: time.Unix(1760621400, 123456789).UTC(),
+		RawTimestamp: RawTime{Value: 1760621400123456789, Unit: Nanoseconds}, Venue: LookupExchange("Q")}
+	if got, ok := parseTrade(NASDAQ_BASIC, decodeFixture(t, cli, "nasdaq_basic_trade.json")); !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("parseTrade() = %+v, %v, want %+v", got, ok, want)
+	}
+	if got, ok := parseTrade(NASDAQ_BASIC, decodeFixture(t, cli, "nasdaq_basic_quote.json")); ok {
+		t.Errorf("parseTrade() of a quote = %+v, want none", got)
+	}
+	for name, want := range map[string]MessageType{"nasdaq_basic_trade.json": MessageTrade, "nasdaq_basic_quote.json": MessageQuote} {
+		if got := Classify(NASDAQ_BASIC, decodeFixture(t, cli, name)); got != want {
+			t.Errorf("Classify(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestMakeNasdaqBasicMessages(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  map[string]interface{}
+		want map[string]interface{}
+	}{
+		{
+			name: "銘柄のJoinは気配も求めること",
+			msg:  makeJoinMessage(NASDAQ_BASIC, "AAPL"),
+			want: map[string]interface{}{"topic": "iex:securities:AAPL", "event": "phx_join", "payload": map[string]interface{}{"trades_only": false}, "ref": nil},
+		},
+		{
+			name: "約定だけのチャンネルのJoinは約定だけを求めること",
+			msg:  makeJoinMessage(NASDAQ_BASIC, TradesOnlyChannel(" aapl ")),
+			want: map[string]interface{}{"topic": "iex:securities:AAPL", "event": "phx_join", "payload": map[string]interface{}{"trades_only": true}, "ref": nil},
+		},
+		{
+			name: "約定だけのチャンネルのLeaveは銘柄のトピックにすること",
+			msg:  makeLeaveMessage(NASDAQ_BASIC, TradesOnlyChannel("AAPL")),
+			want: map[string]interface{}{"topic": "iex:securities:AAPL", "event": "phx_leave", "payload": map[string]interface{}{}, "ref": nil},
+		},
+		{
+			name: "IEXのJoinは何も求めないこと",
+			msg:  makeJoinMessage(IEX, "AAPL"),
+			want: map[string]interface{}{"topic": "iex:securities:AAPL", "event": "phx_join", "payload": map[string]interface{}{}, "ref": nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.msg, tt.want) {
+				t.Errorf("message = %v, want %v", tt.msg, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientNasdaqBasic(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+	server.setReply(realtimeDialect(loadBinary(t, "nasdaq_basic_trade.json")))
+
+	trades := make(chan Trade, 10)
+	bids := make(chan QuoteSide, 10)
+	asks := make(chan QuoteSide, 10)
+	normalized := make(chan NormalizedQuote, 10)
+	sut := server.newClient(NASDAQ_BASIC)
+	sut.OnTrade(func(trade Trade) { trades <- trade })
+	sut.OnBid(func(bid QuoteSide) { bids <- bid })
+	sut.OnAsk(func(ask QuoteSide) { asks <- ask })
+	sut.OnNormalizedQuote(func(quote NormalizedQuote) { normalized <- quote })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+	if err := sut.JoinChecked(TradesOnlyChannel("aapl")); err != nil {
+		t.Fatalf("JoinChecked() error = %v", err)
+	}
+
+	if trade := receive(t, "OnTrade()", trades); trade.Symbol != "AAPL" || trade.Price != 187.37 || trade.Venue.Name() != "Nasdaq" {
+		t.Errorf("OnTrade() = %+v, want AAPL at 187.37 on Nasdaq", trade)
+	}
+	if quote := receive(t, "OnNormalizedQuote()", normalized); quote.Side != "last" {
+		t.Errorf("OnNormalizedQuote() = %+v, want the last", quote)
+	}
+	if !waitUntil(time.Second, func() bool { return sut.Confirmed("$trades:AAPL") }) {
+		t.Error("Confirmed($trades:AAPL) = false, want the join answered")
+	}
+	joins := server.messagesWithEvent("phx_join")
+	if len(joins) != 1 || joins[0]["topic"] != "iex:securities:AAPL" ||
+		!reflect.DeepEqual(joins[0]["payload"], map[string]interface{}{"trades_only": true}) {
+		t.Errorf("joins = %v, want one trades-only join of AAPL", joins)
+	}
+
+	server.broadcastRaw(websocket.TextMessage, loadBinary(t, "nasdaq_basic_quote.json"))
+	if bid := receive(t, "OnBid()", bids); bid.Price != 187.36 || bid.Size != 200 {
+		t.Errorf("OnBid() = %+v, want 200 at 187.36", bid)
+	}
+	if ask := receive(t, "OnAsk()", asks); ask.Price != 187.38 || ask.Size != 300 {
+		t.Errorf("OnAsk() = %+v, want 300 at 187.38", ask)
+	}
+	var sides []string
+	for i := 0; i < 2; i++ {
+		sides = append(sides, receive(t, "OnNormalizedQuote()", normalized).Side)
+	}
+	if want := []string{"bid", "ask"}; !reflect.DeepEqual(sides, want) {
+		t.Errorf("OnNormalizedQuote() sides = %v, want %v", sides, want)
+	}
+	select {
+	case trade := <-trades:
+		t.Errorf("OnTrade() = %+v for a quote", trade)
+	default:
+	}
+	if keys := server.authKeys(); len(keys) == 0 || keys[0] != "user" {
+		t.Errorf("auth api_key = %v, want user", keys)
+	}
+}
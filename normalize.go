@@ -0,0 +1,111 @@
+package intriniorealtime
+
+import "time"
+
+// NormalizedQuote is a quote in the same shape for any provider. Fields a
+// message didn't carry, or its provider never sends, are nil.
+type NormalizedQuote struct {
+	Symbol    string
+	Side      string // "bid", "ask" or "last"
+	Price     *float64
+	Size      *int64
+	Exchange  *string // QUODD, REALTIME and DELAYED_SIP
+	Timestamp *time.Time
+	Delayed   bool // DELAYED_SIP, 15 minutes behind the market
+}
+
+// OnNormalizedQuote registers a handler for quotes and trades from any
+// provider, normalized. A QUODD quote message changing both sides is passed
+// on as a bid and then an ask. It gets them right after OnQuote, in the
+// same order.
+func (cli *Client) OnNormalizedQuote(f func(quote NormalizedQuote)) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
+	cli.normalizedHandler = f
+}
+
+// quoddSideFields maps the normalized fields of each side to the QUODD
+// fields they come from.
+var quoddSideFields = []struct {
+	side, price, size, exchange, time string
+	trade                             bool
+}{
+	{side: "bid", price: "bid_price_4d", size: "bid_size", exchange: "bid_exchange", time: "quote_time"},
+	{side: "ask", price: "ask_price_4d", size: "ask_size", exchange: "ask_exchange", time: "quote_time"},
+	{side: "last", price: "last_price_4d", size: "trade_volume", exchange: "trade_exchange", time: "trade_time", trade: true},
+}
+
+// normalize returns the quotes carried by msg, in the order of
+// quoddSideFields for QUODD.
+func normalize(provider provider, msg map[string]interface{}) []NormalizedQuote {
+	switch provider {
+	case IEX:
+		quote, ok := parseIEXQuote(provider, msg)
+		if !ok {
+			return nil
+		}
+		n := NormalizedQuote{Symbol: quote.Ticker, Side: quote.Type}
+		if price, ok := number(quote.Raw["price"]); ok {
+			n.Price = &price
+		}
+		n.Size = optInt(quote.Raw, "size")
+		if !quote.Timestamp.IsZero() {
+			n.Timestamp = &quote.Timestamp
+		}
+		return []NormalizedQuote{n}
+	case REALTIME, DELAYED_SIP, NASDAQ_BASIC:
+		if provider == NASDAQ_BASIC && msg["event"] == "quote" {
+			return normalizeNasdaqBasicQuote(provider, msg)
+		}
+		payload, ok := realtimePayload(provider, msg, "trade", "quote")
+		if !ok {
+			return nil
+		}
+		symbol, _ := SymbolFromMessage(provider, msg)
+		n := NormalizedQuote{Symbol: symbol, Side: "last", Delayed: delayed(provider)}
+		if msg["event"] == "quote" {
+			n.Side, _ = payload["type"].(string)
+		}
+		if price, ok := number(payload["price"]); ok {
+			n.Price = &price
+		}
+		n.Size = optInt(payload, "size")
+		n.Exchange = optString(payload, "market_center")
+		at := payload["timestamp"]
+		if t, _ := unixTime(at, timeUnit(provider, "timestamp", at)); !t.IsZero() {
+			n.Timestamp = &t
+		}
+		return []NormalizedQuote{n}
+	case QUODD:
+		data, trade := quoddData(provider, msg, "trade", "trade_data")
+		if !trade {
+			var ok bool
+			if data, ok = quoddData(provider, msg, "quote", "quote_data"); !ok {
+				return nil
+			}
+		}
+		ticker, _ := data["ticker"].(string)
+		var ret []NormalizedQuote
+		for _, f := range quoddSideFields {
+			if f.trade != trade {
+				continue
+			}
+			n := NormalizedQuote{
+				Symbol:   ticker,
+				Side:     f.side,
+				Price:    optPrice(data, f.price),
+				Size:     optInt(data, f.size),
+				Exchange: optString(data, f.exchange),
+			}
+			if n.Price == nil && n.Size == nil && n.Exchange == nil {
+				continue
+			}
+			if t := unixMillis(data[f.time]); !t.IsZero() {
+				n.Timestamp = &t
+			}
+			ret = append(ret, n)
+		}
+		return ret
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package intriniorealtime
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider provider
+		fixture  string
+		want     []NormalizedQuote
+	}{
+		{
+			name:     "IEXのクォートを正規化すること",
+			provider: IEX,
+			fixture:  "iex_quote.json",
+			want: []NormalizedQuote{{
+				Symbol:    "GE",
+				Side:      "ask",
+				Price:     float64p(28.97),
+				Size:      int64p(13750),
+				Timestamp: timep(time.Date(2017, 4, 28, 19, 58, 29, 393279000, time.UTC)),
+			}},
+		},
+		{
+			name:     "QUODDのクォートを買いと売りに分けること",
+			provider: QUODD,
+			fixture:  "quodd_quote.json",
+			want: []NormalizedQuote{
+				{Symbol: "AAPL.NB", Side: "bid", Price: float64p(159.48), Size: int64p(500), Exchange: strp("t"), Timestamp: millisp(1508165070850)},
+				{Symbol: "AAPL.NB", Side: "ask", Price: float64p(159.49), Size: int64p(600), Exchange: strp("t"), Timestamp: millisp(1508165070850)},
+			},
+		},
+		{
+			name:     "QUODDの差分のクォートは届いた項目だけ設定すること",
+			provider: QUODD,
+			fixture:  "quodd_quote_partial.json",
+			want: []NormalizedQuote{
+				{Symbol: "AAPL.NB", Side: "bid", Price: float64p(159.47), Size: int64p(0), Timestamp: millisp(1508165071130)},
+			},
+		},
+		{
+			name:     "QUODDのトレードをlastにすること",
+			provider: QUODD,
+			fixture:  "quodd_trade.json",
+			want: []NormalizedQuote{
+				{Symbol: "AAPL.NB", Side: "last", Price: float64p(159.485), Size: int64p(100), Exchange: strp("t"), Timestamp: millisp(1508165070052)},
+			},
+		},
+		{
+			name:     "QUODDの差分のトレードは取引所をnilにすること",
+			provider: QUODD,
+			fixture:  "quodd_trade_partial.json",
+			want: []NormalizedQuote{
+				{Symbol: "AAPL.NB", Side: "last", Price: float64p(159.49), Size: int64p(0), Timestamp: millisp(1508165071002)},
+			},
+		},
+		{
+			name:     "IEXの応答は正規化しないこと",
+			provider: IEX,
+			fixture:  "iex_join_ok.json",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := normalize(tt.provider, loadFixture(t, tt.fixture))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("normalize() = %s, want %s", describeNormalized(got), describeNormalized(tt.want))
+			}
+		})
+	}
+}
+
+func TestNormalizeIEXAbsentFields(t *testing.T) {
+	msg := map[string]interface{}{"event": "quote", "payload": map[string]interface{}{"type": "bid", "ticker": "GE", "price": nil}}
+	got := normalize(IEX, msg)
+	want := []NormalizedQuote{{Symbol: "GE", Side: "bid"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("normalize() = %s, want %s", describeNormalized(got), describeNormalized(want))
+	}
+}
+
+func timep(t time.Time) *time.Time { return &t }
+
+// describeNormalized prints quotes with the pointers followed.
+func describeNormalized(quotes []NormalizedQuote) string {
+	var s []string
+	for _, q := range quotes {
+		fields := []string{q.Symbol, q.Side}
+		if q.Price != nil {
+			fields = append(fields, fmt.Sprintf("price=%v", *q.Price))
+		}
+		if q.Size != nil {
+			fields = append(fields, fmt.Sprintf("size=%d", *q.Size))
+		}
+		if q.Exchange != nil {
+			fields = append(fields, "exchange="+*q.Exchange)
+		}
+		if q.Timestamp != nil {
+			fields = append(fields, "at="+q.Timestamp.String())
+		}
+		s = append(s, strings.Join(fields, " "))
+	}
+	return "[" + strings.Join(s, "; ") + "]"
+}
+
+func TestClientOnNormalizedQuote(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	quotes := make(chan NormalizedQuote, 10)
+	sut := server.newClient(QUODD)
+	sut.OnNormalizedQuote(func(quote NormalizedQuote) { quotes <- quote })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	server.broadcast(loadFixture(t, "quodd_quote.json"))
+	server.broadcast(loadFixture(t, "quodd_trade.json"))
+	var sides []string
+	for i := 0; i < 3; i++ {
+		select {
+		case quote := <-quotes:
+			sides = append(sides, quote.Side)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("OnNormalizedQuote() got %v", sides)
+		}
+	}
+	if !reflect.DeepEqual(sides, []string{"bid", "ask", "last"}) {
+		t.Errorf("sides = %v, want [bid ask last]", sides)
+	}
+}
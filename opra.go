@@ -0,0 +1,254 @@
+package intriniorealtime
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// optionsPrefix starts the topic of an OPRA channel, a contract or the chain
+// of an underlying.
+const optionsPrefix = "options:"
+
+// OptionRight is whether an option contract is a call or a put.
+type OptionRight byte
+
+// The rights of option contracts, as their symbols write them.
+const (
+	Call OptionRight = 'C'
+	Put  OptionRight = 'P'
+)
+
+func (r OptionRight) String() string {
+	switch r {
+	case Call:
+		return "call"
+	case Put:
+		return "put"
+	default:
+		return "unknown"
+	}
+}
+
+// OptionContract is an option contract, the components of its symbol.
+type OptionContract struct {
+	Underlying string
+	Expiration time.Time // the expiration date, at midnight UTC
+	Strike     float64   // in USD, to the thousandth
+	Right      OptionRight
+}
+
+// An OPRA contract symbol is the underlying padded with underscores to
+// optionUnderlyingLen, the expiration date as YYMMDD, C or P and the strike
+// in thousandths of a dollar padded with zeros to optionStrikeLen digits:
+// AAPL__251017C00150000 is the AAPL call at 150 that expires on October 17,
+// 2025.
+const (
+	optionUnderlyingLen = 6
+	optionStrikeLen     = 8
+	optionTailLen       = len("060102") + 1 + optionStrikeLen
+	maxOptionStrike     = 99999.999
+)
+
+// BuildOptionSymbol returns the OPRA symbol of the contract on underlying
+// that expires on the date of expiry, whatever its time and location, at
+// strike. It can be passed to Join and Leave like any other channel. The
+// error is a *SymbolError, for an underlying that isn't one to six letters
+// and digits, an expiration outside 2000 to 2099, or a strike that isn't
+// positive, is too large or is finer than a thousandth.
+func BuildOptionSymbol(underlying string, expiry time.Time, strike float64, right OptionRight) (string, error) {
+	u := strings.ToUpper(strings.TrimSpace(underlying))
+	invalid := func(reason string) (string, error) {
+		return "", &SymbolError{Symbol: u, Reason: reason}
+	}
+	if !optionUnderlying(u) {
+		return invalid("not an underlying of one to six letters and digits")
+	}
+	if expiry.Year() < 2000 || 2099 < expiry.Year() {
+		return invalid("expiration outside 2000 to 2099")
+	}
+	if right != Call && right != Put {
+		return invalid("right is neither Call nor Put")
+	}
+	thousandths := math.Round(strike * 1000)
+	if !(0 < strike && strike <= maxOptionStrike) || 1e-6 < math.Abs(strike*1000-thousandths) {
+		return invalid(fmt.Sprintf("strike %v isn't a positive number of thousandths below 100000", strike))
+	}
+	return fmt.Sprintf("%s%s%c%0*d", u+strings.Repeat("_", optionUnderlyingLen-len(u)), expiry.Format("060102"),
+		right, optionStrikeLen, int64(thousandths)), nil
+}
+
+// ParseOptionSymbol returns the contract of an OPRA symbol, the reverse of
+// BuildOptionSymbol. The underlying may also be padded with spaces, as in OCC
+// symbols, or not at all. The error is a *SymbolError.
+func ParseOptionSymbol(symbol string) (OptionContract, error) {
+	s := strings.ToUpper(strings.TrimSpace(symbol))
+	invalid := func(reason string) (OptionContract, error) {
+		return OptionContract{}, &SymbolError{Symbol: s, Reason: reason}
+	}
+	if len(s) <= optionTailLen {
+		return invalid("too short for an option symbol")
+	}
+	head, tail := s[:len(s)-optionTailLen], s[len(s)-optionTailLen:]
+	c := OptionContract{Underlying: strings.TrimRight(head, "_ ")}
+	if !optionUnderlying(c.Underlying) || optionUnderlyingLen < len(head) {
+		return invalid("not an underlying of one to six letters and digits")
+	}
+	expiration, err := time.Parse("060102", tail[:6])
+	if err != nil {
+		return invalid("not an expiration date")
+	}
+	if expiration.Year() < 2000 {
+		// time.Parse takes 69 to 99 for the last century.
+		expiration = expiration.AddDate(100, 0, 0)
+	}
+	c.Expiration = expiration
+	c.Right = OptionRight(tail[6])
+	if c.Right != Call && c.Right != Put {
+		return invalid("right is neither C nor P")
+	}
+	thousandths, err := strconv.ParseUint(tail[7:], 10, 64)
+	if err != nil || thousandths == 0 {
+		return invalid("not a strike")
+	}
+	c.Strike = float64(thousandths) / 1000
+	return c, nil
+}
+
+// optionUnderlying reports whether s can be the underlying of a contract.
+func optionUnderlying(s string) bool {
+	if s == "" || optionUnderlyingLen < len(s) {
+		return false
+	}
+	for _, r := range s {
+		if !('A' <= r && r <= 'Z' || '0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// OptionTrade is a trade of an option contract.
+type OptionTrade struct {
+	Symbol       string // the contract's symbol
+	Contract     OptionContract
+	Price        float64
+	Size         int64 // contracts
+	TotalVolume  int64 // the contract's volume of the day so far
+	Timestamp    time.Time
+	RawTimestamp RawTime
+
+	// OpenInterest is the contract's open interest, nil when the trade
+	// didn't carry it.
+	OpenInterest *int64
+
+	// FixedPrice is Price, exactly, with WithFixedPointPrices.
+	FixedPrice Price
+
+	// Raw is the payload as received; don't modify it.
+	Raw map[string]interface{}
+}
+
+// OptionQuote is the best bid and ask of an option contract.
+type OptionQuote struct {
+	Symbol       string // the contract's symbol
+	Contract     OptionContract
+	BidPrice     float64
+	BidSize      int64
+	AskPrice     float64
+	AskSize      int64
+	Timestamp    time.Time
+	RawTimestamp RawTime
+
+	// OpenInterest is the contract's open interest, nil when the quote
+	// didn't carry it.
+	OpenInterest *int64
+
+	// BidPriceFixed and AskPriceFixed are the prices, exactly, with
+	// WithFixedPointPrices.
+	BidPriceFixed Price
+	AskPriceFixed Price
+
+	// Raw is the payload as received; don't modify it.
+	Raw map[string]interface{}
+}
+
+// OnOptionTrade registers a handler for OPRA trades. They go to OnQuote as
+// well, but not to OnTrade, whose Trade has no room for the contract.
+func (cli *Client) OnOptionTrade(f func(trade OptionTrade)) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
+	cli.optionTradeHandler = f
+}
+
+// OnOptionQuote registers a handler for OPRA quotes. They go to OnQuote as
+// well, but not to OnBid and OnAsk.
+func (cli *Client) OnOptionQuote(f func(quote OptionQuote)) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
+	cli.optionQuoteHandler = f
+}
+
+// optionPayload returns the payload of msg if it is an OPRA message with one
+// of the given events.
+//
+// OPRA sends trades and quotes on the channel of the contract, or of its
+// underlying for a chain, as trade and quote events. A trade's payload has
+// the contract symbol, the price, the size in contracts, the day's
+// total_volume and the timestamp in seconds since the epoch; a quote's the
+// contract, bid_price, bid_size, ask_price, ask_size and the timestamp. Either
+// may carry the contract's open_interest.
+func optionPayload(provider provider, msg map[string]interface{}, events ...string) (map[string]interface{}, bool) {
+	if provider != OPRA {
+		return nil, false
+	}
+	for _, event := range events {
+		if msg["event"] == event {
+			payload, ok := msg["payload"].(map[string]interface{})
+			return payload, ok
+		}
+	}
+	return nil, false
+}
+
+// parseOptionTrade returns the trade carried by msg, if it is one.
+func parseOptionTrade(provider provider, msg map[string]interface{}) (OptionTrade, bool) {
+	payload, ok := optionPayload(provider, msg, "trade")
+	if !ok {
+		return OptionTrade{}, false
+	}
+	trade := OptionTrade{Raw: payload}
+	trade.Symbol, _ = SymbolFromMessage(provider, msg)
+	trade.Contract, _ = ParseOptionSymbol(trade.Symbol)
+	trade.Price, _ = number(payload["price"])
+	trade.FixedPrice, _ = fixedPrice(payload["price"])
+	trade.Size, _ = integer(payload["size"])
+	trade.TotalVolume, _ = integer(payload["total_volume"])
+	trade.OpenInterest = optInt(payload, "open_interest")
+	at := payload["timestamp"]
+	trade.Timestamp, trade.RawTimestamp = unixTime(at, timeUnit(provider, "timestamp", at))
+	return trade, true
+}
+
+// parseOptionQuote returns the quote carried by msg, if it is one.
+func parseOptionQuote(provider provider, msg map[string]interface{}) (OptionQuote, bool) {
+	payload, ok := optionPayload(provider, msg, "quote")
+	if !ok {
+		return OptionQuote{}, false
+	}
+	quote := OptionQuote{Raw: payload}
+	quote.Symbol, _ = SymbolFromMessage(provider, msg)
+	quote.Contract, _ = ParseOptionSymbol(quote.Symbol)
+	quote.BidPrice, _ = number(payload["bid_price"])
+	quote.BidPriceFixed, _ = fixedPrice(payload["bid_price"])
+	quote.BidSize, _ = integer(payload["bid_size"])
+	quote.AskPrice, _ = number(payload["ask_price"])
+	quote.AskPriceFixed, _ = fixedPrice(payload["ask_price"])
+	quote.AskSize, _ = integer(payload["ask_size"])
+	quote.OpenInterest = optInt(payload, "open_interest")
+	at := payload["timestamp"]
+	quote.Timestamp, quote.RawTimestamp = unixTime(at, timeUnit(provider, "timestamp", at))
+	return quote, true
+}
@@ -0,0 +1,194 @@
+package intriniorealtime
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestBuildOptionSymbol(t *testing.T) {
+	expiry := time.Date(2025, 10, 17, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		underlying string
+		expiry     time.Time
+		strike     float64
+		right      OptionRight
+		want       string
+		wantErr    bool
+	}{
+		{name: "コールの銘柄を組み立てること", underlying: "AAPL", expiry: expiry, strike: 150, right: Call, want: "AAPL__251017C00150000"},
+		{name: "プットの銘柄を組み立てること", underlying: "AAPL", expiry: expiry, strike: 150, right: Put, want: "AAPL__251017P00150000"},
+		{name: "半端な行使価格を千分の一まで書くこと", underlying: "F", expiry: expiry, strike: 12.5, right: Call, want: "F_____251017C00012500"},
+		{name: "千分の一の行使価格を書くこと", underlying: "SPY", expiry: expiry, strike: 0.001, right: Put, want: "SPY___251017P00000001"},
+		{name: "小数第3位までの行使価格を書くこと", underlying: "GME", expiry: expiry, strike: 21.375, right: Call, want: "GME___251017C00021375"},
+		{name: "浮動小数点の誤差を丸めること", underlying: "AAPL", expiry: expiry, strike: 0.1 + 0.2, right: Call, want: "AAPL__251017C00000300"},
+		{name: "最大の行使価格を書くこと", underlying: "NVR", expiry: expiry, strike: 99999.999, right: Call, want: "NVR___251017C99999999"},
+		{name: "6文字の原資産は詰めないこと", underlying: "GOOGL1", expiry: expiry, strike: 150, right: Call, want: "GOOGL1251017C00150000"},
+		{name: "原資産を正規化すること", underlying: " aapl ", expiry: expiry, strike: 150, right: Call, want: "AAPL__251017C00150000"},
+		{name: "満期は時刻と場所にかかわらずその日付にすること", underlying: "AAPL", expiry: time.Date(2025, 10, 17, 23, 30, 0, 0, time.FixedZone("EST", -5*3600)), strike: 150, right: Call, want: "AAPL__251017C00150000"},
+		{name: "千分の一より細かい行使価格は弾くこと", underlying: "AAPL", expiry: expiry, strike: 150.0005, right: Call, wantErr: true},
+		{name: "0の行使価格は弾くこと", underlying: "AAPL", expiry: expiry, strike: 0, right: Call, wantErr: true},
+		{name: "負の行使価格は弾くこと", underlying: "AAPL", expiry: expiry, strike: -150, right: Call, wantErr: true},
+		{name: "大きすぎる行使価格は弾くこと", underlying: "AAPL", expiry: expiry, strike: 100000, right: Call, wantErr: true},
+		{name: "長すぎる原資産は弾くこと", underlying: "ABCDEFG", expiry: expiry, strike: 150, right: Call, wantErr: true},
+		{name: "記号を含む原資産は弾くこと", underlying: "BRK.B", expiry: expiry, strike: 150, right: Call, wantErr: true},
+		{name: "空の原資産は弾くこと", underlying: "", expiry: expiry, strike: 150, right: Call, wantErr: true},
+		{name: "2100年の満期は弾くこと", underlying: "AAPL", expiry: time.Date(2100, 1, 15, 0, 0, 0, 0, time.UTC), strike: 150, right: Call, wantErr: true},
+		{name: "知らない権利は弾くこと", underlying: "AAPL", expiry: expiry, strike: 150, right: 'X', wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BuildOptionSymbol(tt.underlying, tt.expiry, tt.strike, tt.right)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BuildOptionSymbol() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidSymbol) {
+				t.Errorf("BuildOptionSymbol() error = %v, want ErrInvalidSymbol", err)
+			}
+			if got != tt.want {
+				t.Errorf("BuildOptionSymbol() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOptionSymbol(t *testing.T) {
+	expiry := time.Date(2025, 10, 17, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		symbol  string
+		want    OptionContract
+		wantErr bool
+	}{
+		{name: "アンダースコアで詰めた銘柄を解析すること", symbol: "AAPL__251017C00150000", want: OptionContract{Underlying: "AAPL", Expiration: expiry, Strike: 150, Right: Call}},
+		{name: "OCCの空白で詰めた銘柄を解析すること", symbol: "AAPL  251017P00150000", want: OptionContract{Underlying: "AAPL", Expiration: expiry, Strike: 150, Right: Put}},
+		{name: "詰めていない銘柄を解析すること", symbol: "aapl251017c00012500", want: OptionContract{Underlying: "AAPL", Expiration: expiry, Strike: 12.5, Right: Call}},
+		{name: "千分の一の行使価格を読むこと", symbol: "SPY___251017P00000001", want: OptionContract{Underlying: "SPY", Expiration: expiry, Strike: 0.001, Right: Put}},
+		{name: "99年は2099年にすること", symbol: "AAPL__991217C00150000", want: OptionContract{Underlying: "AAPL", Expiration: time.Date(2099, 12, 17, 0, 0, 0, 0, time.UTC), Strike: 150, Right: Call}},
+		{name: "原資産だけは弾くこと", symbol: "AAPL", wantErr: true},
+		{name: "原資産のない銘柄は弾くこと", symbol: "______251017C00150000", wantErr: true},
+		{name: "長すぎる原資産は弾くこと", symbol: "ABCDEFG251017C00150000", wantErr: true},
+		{name: "ありえない日付は弾くこと", symbol: "AAPL__251317C00150000", wantErr: true},
+		{name: "知らない権利は弾くこと", symbol: "AAPL__251017X00150000", wantErr: true},
+		{name: "0の行使価格は弾くこと", symbol: "AAPL__251017C00000000", wantErr: true},
+		{name: "数字でない行使価格は弾くこと", symbol: "AAPL__251017C0015000A", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOptionSymbol(tt.symbol)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseOptionSymbol(%q) error = %v, wantErr %v", tt.symbol, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseOptionSymbol(%q) = %+v, want %+v", tt.symbol, got, tt.want)
+			}
+			if err != nil {
+				return
+			}
+			symbol, err := BuildOptionSymbol(got.Underlying, got.Expiration, got.Strike, got.Right)
+			if again, _ := ParseOptionSymbol(symbol); err != nil || !reflect.DeepEqual(again, got) {
+				t.Errorf("BuildOptionSymbol(%+v) = %q, %v, want the same contract", got, symbol, err)
+			}
+		})
+	}
+}
+
+func TestParseOptionMessages(t *testing.T) {
+	cli := New(yourIntrinioAPIUserName, "", OPRA, WithFixedPointPrices())
+	expiry := time.Date(2025, 10, 17, 0, 0, 0, 0, time.UTC)
+	openInterest := int64(48210)
+
+	msg := decodeFixture(t, cli, "opra_trade.json")
+	trade, ok := parseOptionTrade(OPRA, msg)
+	wantTrade := OptionTrade{Symbol: "AAPL__251017C00150000", Contract: OptionContract{Underlying: "AAPL", Expiration: expiry, Strike: 150, Right: Call},
+		Price: 37.45, FixedPrice: 374500, Size: 10, TotalVolume: 1532, OpenInterest: &openInterest,
+		Timestamp: time.Unix(1760621400, 123456000).UTC(), RawTimestamp: RawTime{Value: 1760621400123456000, Unit: Nanoseconds}}
+	trade.Raw = nil
+	if !ok || !reflect.DeepEqual(trade, wantTrade) {
+		t.Errorf("parseOptionTrade() = %+v, %v, want %+v", trade, ok, wantTrade)
+	}
+	if _, ok := parseOptionQuote(OPRA, msg); ok {
+		t.Error("parseOptionQuote() of a trade = true, want false")
+	}
+
+	msg = decodeFixture(t, cli, "opra_quote.json")
+	quote, ok := parseOptionQuote(OPRA, msg)
+	wantQuote := OptionQuote{Symbol: "AAPL__251017P00162500", Contract: OptionContract{Underlying: "AAPL", Expiration: expiry, Strike: 162.5, Right: Put},
+		BidPrice: 1.02, BidPriceFixed: 10200, BidSize: 85, AskPrice: 1.05, AskPriceFixed: 10500, AskSize: 120,
+		Timestamp: time.Unix(1760621400, 500000000).UTC(), RawTimestamp: RawTime{Value: 1760621400500000000, Unit: Nanoseconds}}
+	quote.Raw = nil
+	if !ok || !reflect.DeepEqual(quote, wantQuote) {
+		t.Errorf("parseOptionQuote() = %+v, %v, want %+v", quote, ok, wantQuote)
+	}
+	if got := Classify(OPRA, msg); got != MessageQuote {
+		t.Errorf("Classify() = %v, want %v", got, MessageQuote)
+	}
+	if err := checkSchema(OPRA, msg); err != nil {
+		t.Errorf("checkSchema() = %v, want nil", err)
+	}
+	if _, ok := parseTrade(OPRA, msg); ok {
+		t.Error("parseTrade() of an option = true, want it left to OnOptionTrade")
+	}
+}
+
+func TestClientOPRA(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+	server.setReply(realtimeDialect(loadBinary(t, "opra_trade.json")))
+
+	trades := make(chan OptionTrade, 10)
+	quotes := make(chan OptionQuote, 10)
+	stockTrades := make(chan Trade, 10)
+	sut := server.newClient(OPRA)
+	sut.OnOptionTrade(func(trade OptionTrade) { trades <- trade })
+	sut.OnOptionQuote(func(quote OptionQuote) { quotes <- quote })
+	sut.OnTrade(func(trade Trade) { stockTrades <- trade })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+	contract, err := BuildOptionSymbol("AAPL", time.Date(2025, 10, 17, 0, 0, 0, 0, time.UTC), 150, Call)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sut.JoinChecked(contract); err != nil {
+		t.Fatalf("JoinChecked(%q) error = %v", contract, err)
+	}
+
+	if trade := receive(t, "OnOptionTrade()", trades); trade.Symbol != contract || trade.Contract.Strike != 150 || trade.OpenInterest == nil {
+		t.Errorf("OnOptionTrade() = %+v, want a trade of %s with its open interest", trade, contract)
+	}
+	if !waitUntil(time.Second, func() bool { return sut.Confirmed(contract) }) {
+		t.Errorf("Confirmed(%s) = false, want the join answered", contract)
+	}
+	if err := sut.JoinChecked("aapl"); err != nil {
+		t.Fatalf("JoinChecked(aapl) error = %v", err)
+	}
+	if !waitUntil(time.Second, func() bool { return sut.Confirmed("AAPL") }) {
+		t.Error("Confirmed(AAPL) = false, want the chain joined")
+	}
+	var topics []string
+	for _, join := range server.messagesWithEvent("phx_join") {
+		topics = append(topics, join["topic"].(string))
+	}
+	if want := []string{"options:" + contract, "options:AAPL"}; !reflect.DeepEqual(topics, want) {
+		t.Errorf("join topics = %v, want %v", topics, want)
+	}
+
+	server.broadcastRaw(websocket.TextMessage, loadBinary(t, "opra_quote.json"))
+	if quote := receive(t, "OnOptionQuote()", quotes); quote.Symbol != "AAPL__251017P00162500" || quote.Contract.Right != Put || quote.AskSize != 120 {
+		t.Errorf("OnOptionQuote() = %+v, want the 162.5 put", quote)
+	}
+	select {
+	case trade := <-stockTrades:
+		t.Errorf("OnTrade() = %+v for an option", trade)
+	default:
+	}
+	if err := sut.JoinChecked("BRK.B"); !errors.Is(err, ErrInvalidSymbol) {
+		t.Errorf("JoinChecked(BRK.B) error = %v, want ErrInvalidSymbol", err)
+	}
+}
@@ -0,0 +1,443 @@
+package intriniorealtime
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Option configures a Client. Options are passed to New, where an invalid
+// option makes Connect return its error, or to NewWithOptions, which returns
+// it right away. Without options a client has the defaults documented on
+// each option.
+type Option func(cli *Client) error
+
+// WithDebug prints what the client does, like setting DebugMode.
+func WithDebug() Option {
+	return func(cli *Client) error {
+		cli.DebugMode = true
+		return nil
+	}
+}
+
+// WithStaleTimeout sets how long the connection may go without receiving a
+// frame before it is considered dead and reconnected. Zero disables the
+// watchdog. The default is 60 seconds.
+func WithStaleTimeout(d time.Duration) Option {
+	return func(cli *Client) error {
+		if d < 0 {
+			return fmt.Errorf("stale timeout must not be negative: %v", d)
+		}
+		cli.staleTimeout = d
+		return nil
+	}
+}
+
+// WithPingInterval sets how often websocket ping frames are sent to keep the
+// connection alive. By default it is 80% of the read deadline. A negative
+// value disables pings.
+func WithPingInterval(d time.Duration) Option {
+	return func(cli *Client) error {
+		cli.pingInterval = d
+		return nil
+	}
+}
+
+// WithMaxMissedHeartbeats sets how many consecutive heartbeats may go
+// unacknowledged before the connection is considered dead and reconnected.
+// Zero disables the check. The default is 3.
+func WithMaxMissedHeartbeats(n int) Option {
+	return func(cli *Client) error {
+		if n < 0 {
+			return fmt.Errorf("max missed heartbeats must not be negative: %d", n)
+		}
+		cli.maxMissedHeartbeats = int32(n)
+		return nil
+	}
+}
+
+// WithReadDeadline sets how long a read may wait for the next frame, pongs
+// included, before the connection is treated as broken. The default is 30
+// seconds.
+func WithReadDeadline(d time.Duration) Option {
+	return func(cli *Client) error {
+		if d <= 0 {
+			return fmt.Errorf("read deadline must be positive: %v", d)
+		}
+		cli.readDeadline = d
+		return nil
+	}
+}
+
+// WithWriteDeadline sets how long a single write may block. The default is
+// 10 seconds.
+func WithWriteDeadline(d time.Duration) Option {
+	return func(cli *Client) error {
+		if d <= 0 {
+			return fmt.Errorf("write deadline must be positive: %v", d)
+		}
+		cli.writeDeadline = d
+		return nil
+	}
+}
+
+// WithHeartbeatInterval sets how often the provider-level JSON heartbeat is
+// sent. It must be shorter than the read deadline. The default is 3 seconds.
+func WithHeartbeatInterval(d time.Duration) Option {
+	return func(cli *Client) error {
+		if d <= 0 {
+			return fmt.Errorf("heartbeat interval must be positive: %v", d)
+		}
+		cli.heartbeatInterval = d
+		return nil
+	}
+}
+
+// WithoutHeartbeat stops the client from sending JSON heartbeats, for
+// deployments that rely on websocket pings alone.
+func WithoutHeartbeat() Option {
+	return func(cli *Client) error {
+		cli.heartbeatInterval = 0
+		return nil
+	}
+}
+
+// WithIdleTimings sets the read deadline and heartbeat interval used while
+// the client is idle, see SetIdle. The defaults are 10 minutes and 1 minute.
+func WithIdleTimings(readDeadline, heartbeatInterval time.Duration) Option {
+	return func(cli *Client) error {
+		if readDeadline <= 0 || heartbeatInterval <= 0 {
+			return fmt.Errorf("idle timings must be positive: %v, %v", readDeadline, heartbeatInterval)
+		}
+		if readDeadline <= heartbeatInterval {
+			return fmt.Errorf("idle heartbeat interval %v must be shorter than the idle read deadline %v", heartbeatInterval, readDeadline)
+		}
+		cli.idleReadDeadline = readDeadline
+		cli.idleHeartbeatInterval = heartbeatInterval
+		return nil
+	}
+}
+
+// WithWebsocketEndpoints sets the websocket base URLs to dial, in order of
+// preference. When one cannot be dialed the next is tried. The default is
+// the provider's own endpoint.
+func WithWebsocketEndpoints(urls ...string) Option {
+	return func(cli *Client) error {
+		if len(urls) == 0 {
+			return fmt.Errorf("at least one websocket endpoint is required")
+		}
+		cli.soketURLs = urls
+		return nil
+	}
+}
+
+// WithWebsocketURL sets the websocket base URL to dial instead of the
+// provider's, e.g. of a staging environment or a relay. The token is added
+// to it the provider's way. It must be a ws or wss URL.
+func WithWebsocketURL(u string) Option {
+	return func(cli *Client) error {
+		if err := checkWebsocketURL(u); err != nil {
+			return err
+		}
+		cli.soketURLs = []string{u}
+		return nil
+	}
+}
+
+// WithAuthURL sets the URL tokens are fetched from instead of the
+// provider's. The credentials are added to the request the provider's way.
+// It must be an http or https URL.
+func WithAuthURL(u string) Option {
+	return func(cli *Client) error {
+		if err := checkAuthURL(u); err != nil {
+			return err
+		}
+		cli.authURL = u
+		return nil
+	}
+}
+
+// WithHTTPClient makes the client fetch tokens with c, with its transport,
+// proxy and TLS settings, instead of a client of its own that gives every
+// request 10 seconds. The time a request may take is then up to c's Timeout
+// and the context passed to ConnectContext.
+func WithHTTPClient(c *http.Client) Option {
+	return func(cli *Client) error {
+		if c == nil {
+			return fmt.Errorf("HTTP client must not be nil")
+		}
+		cli.httpClient = c
+		return nil
+	}
+}
+
+// WithDialer makes the client dial the websocket with d, on every connect
+// and reconnect, for its proxy, TLS config, handshake timeout or
+// NetDialContext. Without it websocket.DefaultDialer is used.
+func WithDialer(d *websocket.Dialer) Option {
+	return func(cli *Client) error {
+		if d == nil {
+			return fmt.Errorf("dialer must not be nil")
+		}
+		cli.dialer = d
+		return nil
+	}
+}
+
+// WithoutReconnect makes the client disconnect, instead of reconnecting,
+// when the connection is lost.
+func WithoutReconnect() Option {
+	return func(cli *Client) error {
+		cli.noReconnect = true
+		return nil
+	}
+}
+
+// WithReconnectPolicy sets how a lost connection is retried. The default is
+// DefaultReconnectPolicy.
+func WithReconnectPolicy(p ReconnectPolicy) Option {
+	return func(cli *Client) error {
+		if err := p.validate(); err != nil {
+			return err
+		}
+		cli.reconnectPolicy = p
+		return nil
+	}
+}
+
+// WithTokenTTL sets how long an auth token is assumed to stay valid. Within
+// this period reconnects reuse the cached token instead of calling the auth
+// endpoint again. Zero disables the cache.
+func WithTokenTTL(d time.Duration) Option {
+	return func(cli *Client) error {
+		if d < 0 {
+			return fmt.Errorf("token TTL must not be negative: %v", d)
+		}
+		cli.tokenTTL = d
+		return nil
+	}
+}
+
+// WithRateLimitRetries sets how many times a 429 answer from the auth
+// endpoint is retried after waiting for its Retry-After. The default is 3.
+func WithRateLimitRetries(n int) Option {
+	return func(cli *Client) error {
+		if n < 0 {
+			return fmt.Errorf("rate limit retries must not be negative: %d", n)
+		}
+		cli.rateLimitRetry = n
+		return nil
+	}
+}
+
+// WithTokenRefreshMargin sets how long before the assumed expiry the token
+// is refreshed in the background. Zero turns the background refresh off. The
+// default is 5 minutes.
+func WithTokenRefreshMargin(d time.Duration) Option {
+	return func(cli *Client) error {
+		if d < 0 {
+			return fmt.Errorf("token refresh margin must not be negative: %v", d)
+		}
+		cli.tokenMargin = d
+		return nil
+	}
+}
+
+// WithDispatchWorkers hands received messages to n goroutines that call
+// OnQuote in parallel. Messages for one symbol always go to the same worker
+// and keep their order, but different symbols are handled out of order and
+// OnQuote may run concurrently with itself and the other handlers. Zero, the
+// default, calls every handler one at a time. Done is closed once the
+// workers have finished the queued messages.
+func WithDispatchWorkers(n int) Option {
+	return func(cli *Client) error {
+		if n < 0 {
+			return fmt.Errorf("dispatch workers must not be negative: %d", n)
+		}
+		cli.dispatchWorkers = n
+		return nil
+	}
+}
+
+// WithRefCountedSubscriptions counts how often each channel is joined, so
+// that independent parts of a program can share the client: Leave only
+// unsubscribes once it has been called as often as Join. LeaveAll still
+// leaves everything.
+func WithRefCountedSubscriptions() Option {
+	return func(cli *Client) error {
+		cli.refCounted = true
+		return nil
+	}
+}
+
+// WithSendQueueSize sets how many joins and leaves may wait for the sender,
+// so Join doesn't block on the write of every single message. The default
+// is 256.
+func WithSendQueueSize(n int) Option {
+	return func(cli *Client) error {
+		if n < 0 {
+			return fmt.Errorf("send queue size must not be negative: %d", n)
+		}
+		cli.sendQueueSize = n
+		return nil
+	}
+}
+
+// WithSendQueueTimeout sets how long Join and Leave wait for room in a full
+// send queue. Messages that don't get in are reported through OnError as a
+// DroppedMessageError with ErrSendQueueFull. Zero, the default, waits as
+// long as the connection is up; a negative value doesn't wait at all.
+func WithSendQueueTimeout(d time.Duration) Option {
+	return func(cli *Client) error {
+		cli.sendQueueTimeout = d
+		return nil
+	}
+}
+
+// WithConcurrentCallbacks calls handlers straight from the goroutine where
+// the event happened. By default all handlers run one at a time on a single
+// goroutine, in the order the events happened; with this option they may run
+// concurrently and need their own locking.
+func WithConcurrentCallbacks() Option {
+	return func(cli *Client) error {
+		cli.concurrentCallbacks = true
+		return nil
+	}
+}
+
+// WithoutPanicRecovery lets a panic in a handler crash the program instead of
+// being recovered and reported through OnError.
+func WithoutPanicRecovery() Option {
+	return func(cli *Client) error {
+		cli.noRecover = true
+		return nil
+	}
+}
+
+// WithStateHistorySize sets how many transitions StateHistory keeps. Zero
+// turns the history off. The default is 50.
+func WithStateHistorySize(n int) Option {
+	return func(cli *Client) error {
+		if n < 0 {
+			return fmt.Errorf("state history size must not be negative: %d", n)
+		}
+		cli.history = newStateHistory(n)
+		return nil
+	}
+}
+
+// WithToken makes the client use a token that was obtained elsewhere. Connect
+// then never calls the auth endpoint, and ErrStaticTokenRejected is returned
+// once the server stops accepting the token.
+func WithToken(token string) Option {
+	return func(cli *Client) error {
+		if token == "" {
+			return fmt.Errorf("token must not be empty")
+		}
+		cli.token = token
+		cli.tokenAt = cli.now()
+		cli.staticToken = true
+		return nil
+	}
+}
+
+// errFixedPointDecoder is the error of WithFixedPointPrices and WithDecoder
+// given together.
+var errFixedPointDecoder = errors.New("WithFixedPointPrices and WithDecoder can't be combined: a Decoder of your own has to keep numbers as json.Number itself")
+
+// WithFixedPointPrices decodes numbers in received messages as json.Number
+// instead of float64, so the typed models can carry exact prices in their
+// Fixed fields. OnQuote then sees json.Number values as well. It is an error
+// together with WithDecoder.
+func WithFixedPointPrices() Option {
+	return func(cli *Client) error {
+		if cli.decoder != nil {
+			return errFixedPointDecoder
+		}
+		cli.useNumber = true
+		return nil
+	}
+}
+
+// WithDecoder decodes received frames, and the payloads for OnQuoteAs, with
+// d instead of encoding/json. Numbers only come as json.Number, and the
+// Fixed fields of the typed models are only filled, if d keeps them that
+// way. As the client can't make d do so, WithFixedPointPrices is an error
+// together with it.
+func WithDecoder(d Decoder) Option {
+	return func(cli *Client) error {
+		if d == nil {
+			return fmt.Errorf("decoder must not be nil")
+		}
+		if cli.useNumber {
+			return errFixedPointDecoder
+		}
+		cli.decoder = d
+		return nil
+	}
+}
+
+// WithStrictDecoding checks every received frame against what its provider
+// sends: an event and, for IEX, a topic, and the fields of quotes and trades
+// with their types. A frame that doesn't pass, or isn't JSON at all, goes to
+// OnError as a *MalformedMessageError instead of to the handlers, and the
+// connection carries on. Without it such frames are passed on as they are,
+// and a frame that isn't JSON drops the connection.
+func WithStrictDecoding() Option {
+	return func(cli *Client) error {
+		cli.strict = true
+		return nil
+	}
+}
+
+// WithGapTolerance reports a gap through OnGap when two consecutive messages
+// for a symbol are more than d apart. Zero, the default, turns the tracking
+// off. Pick d with the quietest joined symbol in mind: an illiquid one can
+// go minutes without a quote.
+func WithGapTolerance(d time.Duration) Option {
+	return func(cli *Client) error {
+		if d < 0 {
+			return fmt.Errorf("gap tolerance must not be negative: %v", d)
+		}
+		cli.gapTolerance = d
+		return nil
+	}
+}
+
+// WithControlMessagesInOnQuote passes heartbeat acks, the replies to joins
+// and leaves, QUODD info messages and server errors to OnQuote again, as the
+// client did before it classified messages, for handlers that still look for
+// them there.
+func WithControlMessagesInOnQuote() Option {
+	return func(cli *Client) error {
+		cli.controlToQuote = true
+		return nil
+	}
+}
+
+// WithoutSymbolValidation lets JoinChecked join any channel, for symbols the
+// checks don't know.
+func WithoutSymbolValidation() Option {
+	return func(cli *Client) error {
+		cli.noSymbolValidation = true
+		return nil
+	}
+}
+
+// validateTimings checks the options that only make sense together. It runs
+// after all options have been applied, so their order does not matter.
+func (cli *Client) validateTimings() error {
+	if 0 < cli.heartbeatInterval && cli.readDeadline <= cli.heartbeatInterval {
+		return fmt.Errorf("heartbeat interval %v must be shorter than the read deadline %v", cli.heartbeatInterval, cli.readDeadline)
+	}
+	if cli.readDeadline < cli.writeDeadline {
+		return fmt.Errorf("write deadline %v must not be longer than the read deadline %v", cli.writeDeadline, cli.readDeadline)
+	}
+	if 0 < cli.pingInterval && cli.readDeadline <= cli.pingInterval {
+		return fmt.Errorf("ping interval %v must be shorter than the read deadline %v", cli.pingInterval, cli.readDeadline)
+	}
+	return nil
+}
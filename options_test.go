@@ -0,0 +1,88 @@
+package intriniorealtime
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestNewDefaults pins what a client without options is configured with, so
+// that a new option can't quietly change it.
+func TestNewDefaults(t *testing.T) {
+	sut := New(yourIntrinioAPIUserName, yourIntrinioAPIPassword, IEX)
+	if sut.optionErr != nil {
+		t.Fatalf("New() error = %v", sut.optionErr)
+	}
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{name: "DebugMode", got: sut.DebugMode, want: false},
+		{name: "phoenixVsn", got: sut.phoenixVsn, want: PhoenixV1},
+		{name: "writeDeadline", got: sut.writeDeadline, want: 10 * time.Second},
+		{name: "readDeadline", got: sut.readDeadline, want: 30 * time.Second},
+		{name: "heartbeatInterval", got: sut.heartbeatInterval, want: 3 * time.Second},
+		{name: "maxMissedHeartbeats", got: sut.maxMissedHeartbeats, want: int32(3)},
+		{name: "staleTimeout", got: sut.staleTimeout, want: 60 * time.Second},
+		{name: "idleReadDeadline", got: sut.idleReadDeadline, want: 10 * time.Minute},
+		{name: "idleHeartbeatInterval", got: sut.idleHeartbeatInterval, want: time.Minute},
+		{name: "pingInterval", got: sut.pingInterval, want: time.Duration(0)},
+		{name: "authTimeout", got: sut.authTimeout, want: 30 * time.Second},
+		{name: "tokenTTL", got: sut.tokenTTL, want: time.Hour},
+		{name: "tokenMargin", got: sut.tokenMargin, want: 5 * time.Minute},
+		{name: "rateLimitRetry", got: sut.rateLimitRetry, want: 3},
+		{name: "reconnectPolicy", got: sut.reconnectPolicy, want: ReconnectPolicy{InitialDelay: time.Second, Multiplier: 2, MaxDelay: 30 * time.Second, Jitter: true, ResetAfter: time.Minute}},
+		{name: "authRetry", got: sut.authRetry, want: ReconnectPolicy{InitialDelay: 200 * time.Millisecond, Multiplier: 2, MaxDelay: 2 * time.Second, Jitter: true, MaxAttempts: 4}},
+		{name: "sendQueueSize", got: sut.sendQueueSize, want: 256},
+		{name: "sendStallThreshold", got: sut.sendStallThreshold, want: 5 * time.Second},
+		{name: "inboundQueueLen", got: sut.inboundQueueLen, want: 1024},
+		{name: "history", got: len(sut.history.entries), want: 50},
+		{name: "dataTypes", got: sut.dataTypes, want: AllData},
+		{name: "transport", got: sut.transport == nil, want: true},
+		{name: "httpClient", got: sut.httpClient == nil, want: true},
+		{name: "dialer", got: sut.dialer == nil, want: true},
+		{name: "proxied", got: sut.proxied, want: false},
+		{name: "tlsConfig", got: sut.tlsConfig == nil, want: true},
+		{name: "headers", got: len(sut.headers), want: 0},
+		{name: "logger", got: sut.logger == nil, want: true},
+		{name: "staticToken", got: sut.staticToken, want: false},
+		{name: "useNumber", got: sut.useNumber, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewWithOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr bool
+	}{
+		{name: "オプションなしで作れること"},
+		{name: "WithDebugでデバッグ出力を有効にできること", opts: []Option{WithDebug()}},
+		{name: "不正なオプションはすぐにエラーを返すこと", opts: []Option{WithHeartbeatInterval(0)}, wantErr: true},
+		{name: "オプションの組み合わせの誤りもすぐに返すこと", opts: []Option{WithHeartbeatInterval(time.Minute)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut, err := NewWithOptions(yourIntrinioAPIUserName, yourIntrinioAPIPassword, IEX, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewWithOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (sut == nil) != tt.wantErr {
+				t.Errorf("NewWithOptions() = %v, want a client only without an error", sut)
+			}
+		})
+	}
+
+	sut, err := NewWithOptions(yourIntrinioAPIUserName, yourIntrinioAPIPassword, IEX, WithDebug())
+	if err != nil || !sut.DebugMode {
+		t.Errorf("NewWithOptions(WithDebug()) = %v, %v, want DebugMode", sut, err)
+	}
+}
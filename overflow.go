@@ -0,0 +1,105 @@
+package intriniorealtime
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// overflowReportInterval is the least time between two OnOverflow calls.
+const overflowReportInterval = time.Second
+
+// OverflowPolicy decides what happens to a received message when the
+// handlers are so far behind that the inbound queue is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock stops reading until there is room again. Nothing is
+	// lost, but the server's buffer fills up meanwhile.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest queued message to make room, which
+	// suits market data where a newer quote supersedes an older one.
+	OverflowDropOldest
+	// OverflowDropNewest drops the message that was just received.
+	OverflowDropNewest
+)
+
+// WithOverflowPolicy sets what happens to received messages when the
+// handlers can't keep up. The default is OverflowBlock.
+func WithOverflowPolicy(p OverflowPolicy) Option {
+	return func(cli *Client) error {
+		if p < OverflowBlock || OverflowDropNewest < p {
+			return fmt.Errorf("unknown overflow policy: %d", p)
+		}
+		cli.overflowPolicy = p
+		return nil
+	}
+}
+
+// OnOverflow registers a callback invoked when received messages were
+// dropped by the overflow policy. It is called at most once a second with
+// the number of messages dropped since the previous call and the channel of
+// the last one.
+func (cli *Client) OnOverflow(f func(dropped int, channel string)) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
+	cli.overflowHandler = f
+}
+
+// DroppedMessages returns how many received messages the overflow policy
+// has dropped since the client was created.
+func (cli *Client) DroppedMessages() uint64 {
+	return atomic.LoadUint64(&cli.droppedMessages)
+}
+
+// overflowed counts a dropped message and reports it, or schedules the
+// report when the last one was less than overflowReportInterval ago.
+func (cli *Client) overflowed(msg Message) {
+	atomic.AddUint64(&cli.droppedMessages, 1)
+	cli.mu.Lock()
+	cli.overflowPending++
+	cli.overflowChannel = msg.Channel
+	if cli.overflowTimer {
+		cli.mu.Unlock()
+		return
+	}
+	if wait := overflowReportInterval - cli.now().Sub(cli.overflowReportedAt); 0 < wait {
+		cli.overflowTimer = true
+		cli.mu.Unlock()
+		time.AfterFunc(wait, cli.reportOverflow)
+		return
+	}
+	cli.mu.Unlock()
+	cli.reportOverflow()
+}
+
+// reportOverflow hands the drops counted so far to OnOverflow.
+func (cli *Client) reportOverflow() {
+	cli.mu.Lock()
+	dropped, channel := cli.overflowPending, cli.overflowChannel
+	cli.overflowTimer = false
+	cli.overflowPending = 0
+	if dropped == 0 {
+		cli.mu.Unlock()
+		return
+	}
+	cli.overflowReportedAt = cli.now()
+	cli.mu.Unlock()
+	cli.warn("messages dropped", "dropped", dropped, "channel", channel)
+	cli.handlerMu.RLock()
+	f := cli.overflowHandler
+	cli.handlerMu.RUnlock()
+	if f != nil {
+		cli.callHandler("OnOverflow", func() { f(dropped, channel) })
+	}
+}
+
+// messageChannel returns the symbol a received message is about, or its
+// topic when it names no symbol.
+func messageChannel(provider provider, msg map[string]interface{}) string {
+	if symbol, ok := SymbolFromMessage(provider, msg); ok {
+		return symbol
+	}
+	topic, _ := msg["topic"].(string)
+	return topic
+}
@@ -0,0 +1,111 @@
+package intriniorealtime
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientOverflowPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      OverflowPolicy
+		workers     int
+		wantHandled []string
+		wantDropped int
+	}{
+		{name: "Blockのときは取りこぼさないこと", policy: OverflowBlock, wantHandled: []string{"T1", "T2", "T3", "T4", "T5", "T6"}},
+		{name: "DropOldestのときは古いメッセージを捨てること", policy: OverflowDropOldest, wantHandled: []string{"T1", "T5", "T6"}, wantDropped: 3},
+		{name: "DropNewestのときは新しいメッセージを捨てること", policy: OverflowDropNewest, wantHandled: []string{"T1", "T2", "T3"}, wantDropped: 3},
+		{name: "ワーカーがあってもDropOldestで古いメッセージを捨てること", policy: OverflowDropOldest, workers: 1, wantHandled: []string{"T1", "T5", "T6"}, wantDropped: 3},
+		{name: "ワーカーがあってもDropNewestで新しいメッセージを捨てること", policy: OverflowDropNewest, workers: 1, wantHandled: []string{"T1", "T2", "T3"}, wantDropped: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+
+			var mu sync.Mutex
+			var handled []string
+			var overflowed int
+			var entered int32
+			release := make(chan struct{})
+			sut := server.newClient(QUODD, WithOverflowPolicy(tt.policy), WithDispatchWorkers(tt.workers))
+			sut.inboundQueueLen = 2
+			sut.OnQuote(func(quote map[string]interface{}) {
+				if atomic.AddInt32(&entered, 1) == 1 {
+					<-release
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				handled = append(handled, messageChannel(QUODD, quote))
+			})
+			sut.OnOverflow(func(dropped int, channel string) {
+				mu.Lock()
+				defer mu.Unlock()
+				overflowed += dropped
+			})
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			defer sut.Disconnect()
+
+			quote := func(i int) map[string]interface{} {
+				return map[string]interface{}{"event": "quote", "data": map[string]interface{}{"ticker": fmt.Sprintf("T%d", i)}}
+			}
+			server.broadcast(quote(1))
+			if !waitUntil(5*time.Second, func() bool { return atomic.LoadInt32(&entered) == 1 }) {
+				t.Fatal("OnQuote() was not called")
+			}
+			for i := 2; i <= 6; i++ {
+				server.broadcast(quote(i))
+			}
+			waitUntil(5*time.Second, func() bool {
+				return 2 <= sut.DispatchQueueLen() && sut.DroppedMessages() == uint64(tt.wantDropped)
+			})
+			// Give a blocked read loop the chance to misbehave.
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+
+			done := waitUntil(5*time.Second, func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return len(handled) == len(tt.wantHandled) && overflowed == tt.wantDropped
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			if !done || !reflect.DeepEqual(handled, tt.wantHandled) {
+				t.Errorf("handled = %v, want %v", handled, tt.wantHandled)
+			}
+			if got := sut.DroppedMessages(); got != uint64(tt.wantDropped) {
+				t.Errorf("DroppedMessages() = %d, want %d", got, tt.wantDropped)
+			}
+			if overflowed != tt.wantDropped {
+				t.Errorf("OnOverflow() dropped = %d, want %d", overflowed, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestMessageChannel(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider provider
+		msg      map[string]interface{}
+		want     string
+	}{
+		{name: "QUODDのメッセージからティッカーを取り出すこと", provider: QUODD, msg: map[string]interface{}{"event": "quote", "data": map[string]interface{}{"ticker": "AAPL.NB"}}, want: "AAPL.NB"},
+		{name: "IEXのメッセージからティッカーを取り出すこと", provider: IEX, msg: map[string]interface{}{"topic": "iex:securities:GE", "event": "quote", "payload": map[string]interface{}{"ticker": "GE"}}, want: "GE"},
+		{name: "ティッカーがなければトピックを返すこと", provider: IEX, msg: map[string]interface{}{"topic": "phoenix", "event": "phx_reply"}, want: "phoenix"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := messageChannel(tt.provider, tt.msg); got != tt.want {
+				t.Errorf("messageChannel() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
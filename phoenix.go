@@ -0,0 +1,159 @@
+package intriniorealtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+)
+
+// The phoenix wire formats, the vsn the websocket of a phoenix provider is
+// dialed with.
+const (
+	// PhoenixV1 frames are JSON objects with a topic, event, payload and
+	// ref. It is the default.
+	PhoenixV1 = "1.0.0"
+	// PhoenixV2 frames are JSON arrays of the join_ref, ref, topic, event
+	// and payload.
+	PhoenixV2 = "2.0.0"
+)
+
+// phoenixV2Fields are the fields of a v1 frame in the order of a v2 one.
+var phoenixV2Fields = []string{"join_ref", "ref", "topic", "event", "payload"}
+
+// maxPendingReplies bounds the messages awaiting a reply on a connection,
+// so a server that doesn't answer heartbeats doesn't grow the table; the
+// oldest message is given up first.
+const maxPendingReplies = 256
+
+// pendingReply is a phoenix message sent on the live connection whose
+// phx_reply hasn't come yet.
+type pendingReply struct {
+	event string // phx_join, phx_leave or heartbeat
+	topic string
+	seq   uint64 // the order it was sent in
+}
+
+// WithPhoenixVersion sets the phoenix wire format the client speaks,
+// PhoenixV1 or PhoenixV2. With PhoenixV2 joins carry a join_ref and array
+// frames from the server are handled like the objects of PhoenixV1. It is an error for QUODD, which doesn't
+// speak phoenix, for a Provider of your own and for any other version.
+func WithPhoenixVersion(vsn string) Option {
+	return func(cli *Client) error {
+		if cli.provider == QUODD || cli.feed != nil {
+			return fmt.Errorf("WithPhoenixVersion is for the phoenix providers, not %s", cli.provider)
+		}
+		switch vsn {
+		case PhoenixV1, PhoenixV2:
+			cli.phoenixVsn = vsn
+			return nil
+		}
+		return fmt.Errorf("unknown phoenix version %q", vsn)
+	}
+}
+
+// phoenixFrame returns msg as it is written on the websocket. A phoenix
+// message is stamped with the next ref of the connection, unless it has one
+// already like a CRYPTOQUOTE heartbeat, and awaits its reply under it. With
+// PhoenixV2 it is made an array, and a join gets a join_ref, which the later
+// messages on its topic carry. A message of a Provider of the user's own is
+// written as it is.
+func (cli *Client) phoenixFrame(frame interface{}) interface{} {
+	msg, ok := frame.(map[string]interface{})
+	if !ok {
+		return frame
+	}
+	topic, ok := msg["topic"].(string)
+	if !ok || !phoenix(cli.provider) {
+		return msg
+	}
+	event, _ := msg["event"].(string)
+	seq := atomic.AddUint64(&cli.phoenixRef, 1)
+	ref, _ := msg["ref"].(string)
+	if ref == "" {
+		ref = strconv.FormatUint(seq, 10)
+	}
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	cli.awaitReply(ref, pendingReply{event: event, topic: topic, seq: seq})
+	var joinRef interface{}
+	switch {
+	case event == "phx_join":
+		cli.joinRefs[topic] = ref
+		joinRef = ref
+	case cli.joinRefs[topic] != "":
+		joinRef = cli.joinRefs[topic]
+		if event == "phx_leave" {
+			delete(cli.joinRefs, topic)
+		}
+	}
+	if cli.phoenixVsn == PhoenixV2 {
+		return []interface{}{joinRef, ref, topic, event, msg["payload"]}
+	}
+	stamped := make(map[string]interface{}, len(msg))
+	for k, v := range msg {
+		stamped[k] = v
+	}
+	stamped["ref"] = ref
+	return stamped
+}
+
+// awaitReply notes that the reply to sent is awaited under ref, giving up
+// the oldest message once maxPendingReplies are. mu must be held.
+func (cli *Client) awaitReply(ref string, sent pendingReply) {
+	if maxPendingReplies <= len(cli.pendingReplies) {
+		var oldest string
+		for r, p := range cli.pendingReplies {
+			if oldest == "" || p.seq < cli.pendingReplies[oldest].seq {
+				oldest = r
+			}
+		}
+		cli.debug("no reply", "event", cli.pendingReplies[oldest].event, "topic", cli.pendingReplies[oldest].topic)
+		delete(cli.pendingReplies, oldest)
+	}
+	cli.pendingReplies[ref] = sent
+}
+
+// matchReply returns the message msg, a phx_reply, answers and stops
+// awaiting its reply. A reply whose ref isn't awaited, because it came too
+// late or names no message of this connection, is logged and ok is false.
+// mu must be held.
+func (cli *Client) matchReply(msg map[string]interface{}) (sent pendingReply, ref string, ok bool) {
+	ref, _ = msg["ref"].(string)
+	if ref == "" {
+		return pendingReply{}, "", false
+	}
+	if sent, ok = cli.pendingReplies[ref]; !ok {
+		cli.debug("unmatched reply", "ref", ref, "topic", msg["topic"])
+		return pendingReply{}, ref, false
+	}
+	delete(cli.pendingReplies, ref)
+	if sent.event == "phx_leave" {
+		if payload, _ := msg["payload"].(map[string]interface{}); payload["status"] != "ok" {
+			cli.warn("leave refused", "topic", sent.topic, "status", payload["status"])
+		}
+	}
+	return sent, ref, true
+}
+
+// phoenixEnvelope returns data, a PhoenixV2 frame, as the object of
+// PhoenixV1 with the join_ref as a field of its own. Frames that aren't
+// arrays are returned as they are.
+func phoenixEnvelope(data []byte) ([]byte, error) {
+	if trimmed := bytes.TrimLeft(data, " \t\r\n"); len(trimmed) == 0 || trimmed[0] != '[' {
+		return data, nil
+	}
+	var frame []json.RawMessage
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return nil, err
+	}
+	if len(frame) != len(phoenixV2Fields) {
+		return nil, fmt.Errorf("phoenix frame of %d elements, want %d", len(frame), len(phoenixV2Fields))
+	}
+	envelope := make(map[string]json.RawMessage, len(frame))
+	for i, field := range phoenixV2Fields {
+		envelope[field] = frame[i]
+	}
+	return json.Marshal(envelope)
+}
@@ -0,0 +1,246 @@
+package intriniorealtime
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestPhoenixFrame(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider provider
+		vsn      string
+		msgs     []map[string]interface{}
+		want     []string
+	}{
+		{
+			name:     "v1ではオブジェクトにrefを振って送ること",
+			provider: IEX,
+			vsn:      PhoenixV1,
+			msgs:     []map[string]interface{}{makeJoinMessage(IEX, "AAPL"), makeHeartbeatMessage(IEX), makeLeaveMessage(IEX, "AAPL")},
+			want: []string{
+				`{"event":"phx_join","payload":{},"ref":"1","topic":"iex:securities:AAPL"}`,
+				`{"event":"heartbeat","payload":{},"ref":"2","topic":"phoenix"}`,
+				`{"event":"phx_leave","payload":{},"ref":"3","topic":"iex:securities:AAPL"}`,
+			},
+		},
+		{
+			name:     "v2では配列にしてjoin_refとrefを振ること",
+			provider: IEX,
+			vsn:      PhoenixV2,
+			msgs: []map[string]interface{}{
+				makeJoinMessage(IEX, "AAPL"), makeHeartbeatMessage(IEX), makeLeaveMessage(IEX, "AAPL"), makeJoinMessage(IEX, "AAPL"),
+			},
+			want: []string{
+				`["1","1","iex:securities:AAPL","phx_join",{}]`,
+				`[null,"2","phoenix","heartbeat",{}]`,
+				`["1","3","iex:securities:AAPL","phx_leave",{}]`,
+				`["4","4","iex:securities:AAPL","phx_join",{}]`,
+			},
+		},
+		{
+			name:     "v2でもCRYPTOQUOTEのハートビートのrefはそのまま使うこと",
+			provider: CRYPTOQUOTE,
+			vsn:      PhoenixV2,
+			msgs: []map[string]interface{}{
+				{"topic": "phoenix", "event": "heartbeat", "payload": map[string]interface{}{}, "ref": "1760621400"},
+			},
+			want: []string{`[null,"1760621400","phoenix","heartbeat",{}]`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := New("", "", tt.provider, WithPhoenixVersion(tt.vsn))
+			for i, msg := range tt.msgs {
+				data, err := json.Marshal(sut.phoenixFrame(msg))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(data) != tt.want[i] {
+					t.Errorf("phoenixFrame(%v) = %s, want %s", msg, data, tt.want[i])
+				}
+				// What is sent reads back as the message it was made from.
+				_, got, err := sut.decodeFrame(data)
+				if err != nil {
+					t.Fatalf("decodeFrame(%s) error = %v", data, err)
+				}
+				if got["topic"] != msg["topic"] || got["event"] != msg["event"] {
+					t.Errorf("decodeFrame(%s) = %v, want the topic and event of %v", data, got, msg)
+				}
+			}
+		})
+	}
+}
+
+func TestPhoenixDecode(t *testing.T) {
+	v1 := New("", "", IEX)
+	tests := []struct {
+		name    string
+		vsn     string
+		fixture string
+		want    string
+		wantRef interface{}
+		wantErr bool
+	}{
+		{name: "v2の応答をv1と同じに読むこと", vsn: PhoenixV2, fixture: "phoenix_v2_join_ok.json", want: "iex_join_ok.json", wantRef: "1"},
+		{name: "v2の配信をv1と同じに読むこと", vsn: PhoenixV2, fixture: "phoenix_v2_quote.json", want: "iex_quote.json"},
+		{name: "v2でもオブジェクトはそのまま読むこと", vsn: PhoenixV2, fixture: "iex_quote.json", want: "iex_quote.json"},
+		{name: "v1では配列をエラーにすること", vsn: PhoenixV1, fixture: "phoenix_v2_quote.json", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := New("", "", IEX, WithPhoenixVersion(tt.vsn))
+			_, got, err := sut.decodeFrame(loadBinary(t, tt.fixture))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("decodeFrame() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeFrame() error = %v", err)
+			}
+			want := decodeFixture(t, v1, tt.want)
+			for _, field := range []string{"topic", "event", "payload"} {
+				if !reflect.DeepEqual(got[field], want[field]) {
+					t.Errorf("decodeFrame() %s = %v, want %v", field, got[field], want[field])
+				}
+			}
+			if got["join_ref"] != tt.wantRef || got["ref"] != tt.wantRef {
+				t.Errorf("decodeFrame() join_ref, ref = %v, %v, want %v", got["join_ref"], got["ref"], tt.wantRef)
+			}
+		})
+	}
+
+	if _, _, err := New("", "", IEX, WithPhoenixVersion(PhoenixV2)).decodeFrame([]byte(`[null, "topic", "event", {}]`)); err == nil {
+		t.Error("decodeFrame() of four elements error = nil")
+	}
+}
+
+func TestPhoenixVersionErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider provider
+		vsn      string
+	}{
+		{name: "QUODDではエラーにすること", provider: QUODD, vsn: PhoenixV2},
+		{name: "未知のバージョンはエラーにすること", provider: IEX, vsn: "3.0.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := New("user", "pass", tt.provider, WithPhoenixVersion(tt.vsn))
+			if err := sut.Connect(); err == nil {
+				sut.Disconnect()
+				t.Error("Connect() error = nil, want the invalid option")
+			}
+		})
+	}
+}
+
+func TestClientPhoenixV2(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+	server.setReply(func(s *fakeServer, conn *websocket.Conn, msg map[string]interface{}) {
+		ackHeartbeats(s, conn, msg)
+		if msg["event"] == "phx_join" {
+			s.send(conn, map[string]interface{}{
+				"join_ref": msg["join_ref"],
+				"ref":      msg["ref"],
+				"topic":    msg["topic"],
+				"event":    "phx_reply",
+				"payload":  map[string]interface{}{"status": "ok", "response": map[string]interface{}{}},
+			})
+		}
+	})
+
+	quotes := make(chan IEXQuote, 10)
+	sut := server.newClient(IEX, WithPhoenixVersion(PhoenixV2))
+	sut.OnIEXQuote(func(quote IEXQuote) { quotes <- quote })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	sut.Join("AAPL")
+	if !waitUntil(5*time.Second, func() bool { return sut.Confirmed("AAPL") }) {
+		t.Fatal("AAPL was not confirmed")
+	}
+	joins := server.messagesWithEvent("phx_join")
+	if len(joins) != 1 || joins[0]["join_ref"] == nil || joins[0]["join_ref"] != joins[0]["ref"] {
+		t.Errorf("joins = %v, want one whose join_ref is its ref", joins)
+	}
+
+	server.broadcastRaw(websocket.TextMessage, loadBinary(t, "phoenix_v2_quote.json"))
+	if quote := receive(t, "OnIEXQuote()", quotes); quote.Ticker != "GE" || quote.Price != 28.97 || quote.Size != 13750 {
+		t.Errorf("OnIEXQuote() = %+v, want the GE ask", quote)
+	}
+}
+
+func TestReplyCorrelation(t *testing.T) {
+	reply := func(ref, topic, status string) map[string]interface{} {
+		return map[string]interface{}{
+			"topic":   topic,
+			"event":   "phx_reply",
+			"payload": map[string]interface{}{"status": status, "response": map[string]interface{}{"reason": "invalid security"}},
+			"ref":     ref,
+		}
+	}
+	var rejected []string
+	sut := New("", "", IEX, WithConcurrentCallbacks())
+	sut.OnJoinError(func(channel string, reason error) { rejected = append(rejected, channel) })
+	join := func(channel string) {
+		sut.mu.Lock()
+		sut.joinedChannels[channel] = true
+		sut.awaitJoin(channel)
+		sut.mu.Unlock()
+		sut.phoenixFrame(makeJoinMessage(IEX, channel))
+	}
+	join("AAPL")                                  // ref 1
+	join("MSFT")                                  // ref 2
+	sut.phoenixFrame(makeHeartbeatMessage(IEX))   // ref 3
+	join("GE")                                    // ref 4
+	sut.phoenixFrame(makeLeaveMessage(IEX, "GE")) // ref 5
+	sut.mu.Lock()
+	sut.forgetJoin("GE")
+	sut.mu.Unlock()
+	join("GE") // ref 6
+
+	steps := []struct {
+		name        string
+		reply       map[string]interface{}
+		wantHandled bool
+	}{
+		{name: "ハートビートの応答は参加の応答にしないこと", reply: reply("3", "phoenix", "ok")},
+		{name: "後から送った参加の拒否を先に受けること", reply: reply("2", "iex:securities:MSFT", "error"), wantHandled: true},
+		{name: "先に送った参加の確定を後で受けること", reply: reply("1", "iex:securities:AAPL", "ok"), wantHandled: true},
+		{name: "同じrefの二度目の応答は捨てること", reply: reply("1", "iex:securities:AAPL", "error"), wantHandled: true},
+		{name: "参加し直す前の参加の応答は捨てること", reply: reply("4", "iex:securities:GE", "error"), wantHandled: true},
+		{name: "離脱の応答は参加の応答にしないこと", reply: reply("5", "iex:securities:GE", "ok")},
+		{name: "参加し直した参加の確定を受けること", reply: reply("6", "iex:securities:GE", "ok"), wantHandled: true},
+		{name: "送っていないrefの応答は捨てること", reply: reply("99", "iex:securities:GE", "error"), wantHandled: true},
+	}
+	for _, step := range steps {
+		if got := sut.joinReply(step.reply); got != step.wantHandled {
+			t.Errorf("%s: joinReply() = %v, want %v", step.name, got, step.wantHandled)
+		}
+	}
+
+	for channel, want := range map[string]bool{"AAPL": true, "MSFT": false, "GE": true} {
+		if got := sut.Confirmed(channel); got != want {
+			t.Errorf("Confirmed(%s) = %v, want %v", channel, got, want)
+		}
+	}
+	if !reflect.DeepEqual(rejected, []string{"MSFT"}) {
+		t.Errorf("OnJoinError() channels = %v, want MSFT only", rejected)
+	}
+	sut.mu.Lock()
+	pending := len(sut.pendingReplies)
+	sut.mu.Unlock()
+	if pending != 0 {
+		t.Errorf("pending replies = %d, want none", pending)
+	}
+}
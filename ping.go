@@ -0,0 +1,56 @@
+package intriniorealtime
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+)
+
+// Ping sends a websocket ping through the sender and waits for the matching
+// pong. It returns ErrNotConnected when there is no connection and
+// ErrPingTimeout when ctx is done before the pong arrives. Ping is safe to
+// call from any goroutine.
+func (cli *Client) Ping(ctx context.Context) error {
+	id := "ping-" + strconv.FormatInt(atomic.AddInt64(&cli.pingSeq, 1), 10)
+	pong := make(chan struct{})
+
+	cli.mu.Lock()
+	if cli.ws == nil || cli.closing {
+		cli.mu.Unlock()
+		return ErrNotConnected
+	}
+	pings, breakSender := cli.pings, cli.breakSender
+	pending := cli.pendingPings
+	pending[id] = pong
+	cli.mu.Unlock()
+	defer func() {
+		cli.mu.Lock()
+		delete(pending, id)
+		cli.mu.Unlock()
+	}()
+
+	select {
+	case pings <- id:
+	case <-breakSender:
+		return ErrNotConnected
+	case <-ctx.Done():
+		return ErrPingTimeout
+	}
+	select {
+	case <-pong:
+		return nil
+	case <-breakSender:
+		return ErrNotConnected
+	case <-ctx.Done():
+		return ErrPingTimeout
+	}
+}
+
+func (cli *Client) onPong(appData string) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	if pong, ok := cli.pendingPings[appData]; ok {
+		close(pong)
+		delete(cli.pendingPings, appData)
+	}
+}
@@ -0,0 +1,68 @@
+package intriniorealtime
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClientPing(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		connect bool
+		stall   bool
+		wantErr error
+	}{
+		{name: "接続中はpongが返ってくること", connect: true, stall: false, wantErr: nil},
+		{name: "接続していないときはErrNotConnectedになること", connect: false, stall: false, wantErr: ErrNotConnected},
+		{name: "pongが返ってこないときはErrPingTimeoutになること", connect: true, stall: true, wantErr: ErrPingTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.setStall(tt.stall)
+			sut := server.newClient(IEX)
+			if tt.connect {
+				if err := sut.Connect(); err != nil {
+					t.Fatalf("connect() error = %v", err)
+				}
+				defer sut.DisconnectWithTimeout(time.Second)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+			defer cancel()
+			if err := sut.Ping(ctx); err != tt.wantErr {
+				t.Errorf("Ping() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	server.setStall(false)
+}
+
+func TestClientPingConcurrently(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	sut := server.newClient(QUODD)
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+	sut.Join("AAPL.NB", "MSFT.NB")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			if err := sut.Ping(ctx); err != nil {
+				t.Errorf("Ping() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
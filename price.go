@@ -0,0 +1,128 @@
+package intriniorealtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Price is an exact price in ten-thousandths of a dollar, the precision of
+// QUODD's *_4d fields. With WithFixedPointPrices the typed models carry
+// prices as a Price as well, parsed from the JSON text without going through
+// float64.
+type Price int64
+
+// priceScale is one dollar as a Price.
+const priceScale = 10000
+
+// ParsePrice parses a decimal price such as "123.4567" exactly. More than
+// four decimal places, exponents and values out of range are errors.
+func ParsePrice(s string) (Price, error) {
+	text := s
+	neg := strings.HasPrefix(text, "-")
+	if neg {
+		text = text[1:]
+	}
+	whole, frac := text, ""
+	if i := strings.IndexByte(text, '.'); 0 <= i {
+		whole, frac = text[:i], text[i+1:]
+	}
+	frac = strings.TrimRight(frac, "0")
+	if whole == "" || 4 < len(frac) || strings.IndexAny(whole+frac, "+-") != -1 {
+		return 0, fmt.Errorf("invalid price %q", s)
+	}
+	dollars, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || (1<<63-1)/priceScale < dollars {
+		return 0, fmt.Errorf("invalid price %q", s)
+	}
+	var fraction int64
+	if frac != "" {
+		if fraction, err = strconv.ParseInt(frac, 10, 64); err != nil {
+			return 0, fmt.Errorf("invalid price %q", s)
+		}
+		fraction *= priceScale / pow10(len(frac))
+	}
+	p := dollars*priceScale + fraction
+	if p < 0 {
+		return 0, fmt.Errorf("invalid price %q", s)
+	}
+	if neg {
+		p = -p
+	}
+	return Price(p), nil
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for ; 0 < n; n-- {
+		p *= 10
+	}
+	return p
+}
+
+// Float64 returns p in dollars, as close as a float64 gets.
+func (p Price) Float64() float64 {
+	return float64(p) / priceScale
+}
+
+// String formats p with four decimal places, e.g. "123.4567".
+func (p Price) String() string {
+	sign, n := "", int64(p)
+	if n < 0 {
+		sign = "-"
+	}
+	whole, frac := n/priceScale, n%priceScale
+	if n < 0 {
+		whole, frac = -whole, -frac
+	}
+	return fmt.Sprintf("%s%d.%04d", sign, whole, frac)
+}
+
+// number returns v, a JSON number decoded with or without UseNumber, as a
+// float64.
+func number(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// integer returns v, a JSON number decoded with or without UseNumber, as an
+// int64.
+func integer(v interface{}) (int64, bool) {
+	if n, ok := v.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			return i, true
+		}
+	}
+	f, ok := number(v)
+	return int64(f), ok
+}
+
+// fixedPrice returns the dollar price v as a Price. It only does so for
+// numbers decoded with UseNumber, which is how WithFixedPointPrices asks for
+// them.
+func fixedPrice(v interface{}) (Price, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	p, err := ParsePrice(n.String())
+	return p, err == nil
+}
+
+// fixedPrice4d is fixedPrice for QUODD's *_4d fields, which are already in
+// ten-thousandths of a dollar.
+func fixedPrice4d(v interface{}) (Price, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	i, err := n.Int64()
+	return Price(i), err == nil
+}
@@ -0,0 +1,117 @@
+package intriniorealtime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    Price
+		wantStr string
+		wantErr bool
+	}{
+		{name: "小数4桁の価格をそのまま表すこと", text: "123.4567", want: 1234567, wantStr: "123.4567"},
+		{name: "小数2桁の価格を表すこと", text: "28.97", want: 289700, wantStr: "28.9700"},
+		{name: "整数の価格を表すこと", text: "42", want: 420000, wantStr: "42.0000"},
+		{name: "末尾の0は桁数に数えないこと", text: "1.230000", want: 12300, wantStr: "1.2300"},
+		{name: "負の価格を表すこと", text: "-0.05", want: -500, wantStr: "-0.0500"},
+		{name: "float64では表せない大きな値も正確に表すこと", text: "90071992547409.9993", want: 900719925474099993, wantStr: "90071992547409.9993"},
+		{name: "int64の上限まで表すこと", text: "922337203685477.5807", want: 1<<63 - 1, wantStr: "922337203685477.5807"},
+		{name: "int64を超える値はエラーにすること", text: "922337203685477.5808", wantErr: true},
+		{name: "小数5桁以上はエラーにすること", text: "1.23456", wantErr: true},
+		{name: "指数表記はエラーにすること", text: "1e3", wantErr: true},
+		{name: "数値でなければエラーにすること", text: "abc", wantErr: true},
+		{name: "空文字列はエラーにすること", text: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePrice(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePrice(%q) error = %v, wantErr %v", tt.text, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != tt.want || got.String() != tt.wantStr {
+				t.Errorf("ParsePrice(%q) = %d (%s), want %d (%s)", tt.text, int64(got), got, int64(tt.want), tt.wantStr)
+			}
+			if back, err := ParsePrice(got.String()); err != nil || back != got {
+				t.Errorf("ParsePrice(%q) = %d, %v, want %d", got.String(), int64(back), err, int64(got))
+			}
+		})
+	}
+}
+
+func TestPriceSum(t *testing.T) {
+	// 0.1 added up a thousand times as float64 is 99.9999999999986.
+	var sum Price
+	p, _ := ParsePrice("0.1")
+	for i := 0; i < 1000; i++ {
+		sum += p
+	}
+	if sum.String() != "100.0000" {
+		t.Errorf("sum = %s, want 100.0000", sum)
+	}
+}
+
+func TestClientFixedPointPrices(t *testing.T) {
+	tests := []struct {
+		name      string
+		provider  provider
+		opts      []Option
+		frame     string
+		want      Price
+		wantFloat float64
+	}{
+		{
+			name:      "IEXの価格をfloat64を通さずに読むこと",
+			provider:  IEX,
+			opts:      []Option{WithFixedPointPrices()},
+			frame:     `{"topic":"iex:securities:GE","event":"quote","payload":{"type":"last","ticker":"GE","price":123.4567,"size":100}}`,
+			want:      1234567,
+			wantFloat: 123.4567,
+		},
+		{
+			name:      "QUODDの大きな価格も正確に読むこと",
+			provider:  QUODD,
+			opts:      []Option{WithFixedPointPrices()},
+			frame:     `{"event":"trade","data":{"ticker":"SPX.IX","last_price_4d":9007199254740993,"trade_volume":1}}`,
+			want:      9007199254740993,
+			wantFloat: 9007199254740993.0 / 10000,
+		},
+		{
+			name:      "オプションがなければ固定小数点の価格は0のままにすること",
+			provider:  IEX,
+			frame:     `{"topic":"iex:securities:GE","event":"quote","payload":{"type":"last","ticker":"GE","price":123.4567,"size":100}}`,
+			wantFloat: 123.4567,
+		},
+	}
+	server := newFakeServer()
+	defer server.Close()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trades := make(chan Trade, 1)
+			sut := server.newClient(tt.provider, tt.opts...)
+			sut.OnTrade(func(trade Trade) { trades <- trade })
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			defer sut.Disconnect()
+
+			server.broadcastRaw(websocket.TextMessage, []byte(tt.frame))
+			select {
+			case trade := <-trades:
+				if trade.FixedPrice != tt.want || trade.Price != tt.wantFloat {
+					t.Errorf("OnTrade() price = %s, %v, want %s, %v", trade.FixedPrice, trade.Price, tt.want, tt.wantFloat)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("OnTrade() was not called")
+			}
+		})
+	}
+}
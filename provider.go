@@ -0,0 +1,224 @@
+package intriniorealtime
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Provider is a feed the client connects to. The provider constants, IEX,
+// QUODD and the others, are the built-in Providers; New takes any other
+// implementation as well, e.g. a feed of your own or a fake one in tests.
+//
+// The client calls a Provider of your own through the whole lifecycle:
+// AuthRequest for every token, WebsocketURL to dial, JoinMessage and
+// LeaveMessage as channels are joined and left, HeartbeatMessage every
+// heartbeat interval and ParseMessage for every text frame received. The
+// messages it returns are written as JSON. Options made for the Intrinio
+// feeds, such as WithAuthURL, WithWebsocketURL and the typed handlers like
+// OnIEXQuote, don't apply to it; OnMessage and OnQuote do.
+//
+// A Provider of your own may also have a method Name() string. The
+// Messages, errors and states of its client then carry that name as their
+// provider instead of "custom", which tells several feeds of your own apart
+// in a MultiClient. It must not be the name of a built-in provider.
+type Provider interface {
+	// AuthRequest returns the request whose response body is the token,
+	// or nil when the feed needs none; WebsocketURL then gets "".
+	AuthRequest() (*http.Request, error)
+	// WebsocketURL returns the ws or wss URL to dial with token.
+	WebsocketURL(token string) string
+	// JoinMessage returns the message that joins channel.
+	JoinMessage(channel string) interface{}
+	// LeaveMessage returns the message that leaves channel.
+	LeaveMessage(channel string) interface{}
+	// HeartbeatMessage returns the heartbeat to send, or nil when the feed
+	// has none, which is like WithoutHeartbeat. The server's answers must
+	// be parsed as MessageHeartbeatAck, or the connection is given up after
+	// the heartbeats WithMaxMissedHeartbeats allows.
+	HeartbeatMessage() interface{}
+	// ParseMessage returns the Message of a received text frame. Its Type
+	// decides where it goes: market data to OnQuote, which gets the
+	// Payload, and the control messages to OnMessage only. The client sets
+	// ReceivedAt and Raw, and Provider unless it is set. An error is
+	// treated like a frame that can't be decoded.
+	ParseMessage(raw []byte) (Message, error)
+}
+
+// custom is the provider of a client of a Provider of the user's own.
+const custom provider = "custom"
+
+// namedProvider is a Provider of the user's own with a name, see Provider.
+type namedProvider interface {
+	Name() string
+}
+
+// resolveProvider returns the provider constant p is, or for a Provider of
+// the user's own its name, or custom, and p.
+func resolveProvider(p Provider) (provider, Provider) {
+	if builtin, ok := p.(provider); ok {
+		return builtin, nil
+	}
+	if named, ok := p.(namedProvider); ok && named.Name() != "" {
+		return provider(named.Name()), p
+	}
+	return custom, p
+}
+
+// resolveFeed checks the Provider of the user's own a client was made with.
+// It runs after all options have been applied.
+func (cli *Client) resolveFeed() error {
+	if cli.feed == nil {
+		if cli.provider == custom {
+			return errors.New("New needs a provider")
+		}
+		return nil
+	}
+	for _, p := range providers {
+		if strings.EqualFold(string(cli.provider), string(p)) {
+			return fmt.Errorf("provider name %q is taken by a built-in provider", string(cli.provider))
+		}
+	}
+	if cli.feed.HeartbeatMessage() == nil {
+		cli.heartbeatInterval = 0
+	}
+	return nil
+}
+
+// AuthRequest returns the token request of p without the credentials,
+// which the client adds.
+func (p provider) AuthRequest() (*http.Request, error) {
+	url := makeAuthURL(p)
+	if url == "" {
+		return nil, errors.New("no auth URL for provider " + string(p))
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	return req, nil
+}
+
+// WebsocketURL returns the URL that dials the primary endpoint of p with
+// token, in PhoenixV1 for the phoenix providers. It is "" for MANUAL and
+// SIMULATED, which have no endpoint of their own.
+func (p provider) WebsocketURL(token string) string {
+	urls := makeSoketBaseURLs(p)
+	if len(urls) == 0 {
+		return ""
+	}
+	return makeSoketURL(p, urls[0], token, PhoenixV1)
+}
+
+// JoinMessage returns the message that joins channel on p, nil for MANUAL
+// and SIMULATED, which speak the messages of another provider.
+func (p provider) JoinMessage(channel string) interface{} {
+	if !p.speaks() {
+		return nil
+	}
+	return makeJoinMessage(p, channel)
+}
+
+// LeaveMessage returns the message that leaves channel on p, nil for
+// MANUAL and SIMULATED.
+func (p provider) LeaveMessage(channel string) interface{} {
+	if !p.speaks() {
+		return nil
+	}
+	return makeLeaveMessage(p, channel)
+}
+
+// HeartbeatMessage returns a heartbeat of p, nil for MANUAL and SIMULATED.
+func (p provider) HeartbeatMessage() interface{} {
+	if !p.speaks() {
+		return nil
+	}
+	return makeHeartbeatMessage(p)
+}
+
+// ParseMessage decodes a PhoenixV1 or QUODD text frame of p the way a
+// client of p does.
+func (p provider) ParseMessage(raw []byte) (Message, error) {
+	cli := &Client{provider: p}
+	payload, exact, err := cli.decodeFrame(raw)
+	if err != nil {
+		return Message{}, err
+	}
+	return cli.newMessage(raw, payload, exact, time.Time{}), nil
+}
+
+// speaks reports the providers that have messages of their own.
+func (p provider) speaks() bool {
+	return phoenix(p) || p == QUODD
+}
+
+// joinMessage returns the message that joins channel on the client's
+// provider.
+func (cli *Client) joinMessage(channel string) interface{} {
+	if cli.feed != nil {
+		return cli.feed.JoinMessage(channel)
+	}
+	msg := makeJoinMessage(cli.provider, channel)
+	if cli.provider == NASDAQ_BASIC && cli.dataTypes == TradesOnly {
+		// The server leaves the quote updates out, see WithDataTypes.
+		msg["payload"].(map[string]interface{})["trades_only"] = true
+	}
+	return msg
+}
+
+// leaveMessage returns the message that leaves channel on the client's
+// provider.
+func (cli *Client) leaveMessage(channel string) interface{} {
+	if cli.feed != nil {
+		return cli.feed.LeaveMessage(channel)
+	}
+	return makeLeaveMessage(cli.provider, channel)
+}
+
+// soketURL returns the URL that dials base with token. For a Provider of
+// the user's own base is that URL already, see dialEndpoints.
+func (cli *Client) soketURL(base, token string) string {
+	if cli.feed != nil {
+		return base
+	}
+	return makeSoketURL(cli.provider, base, token, cli.phoenixVsn)
+}
+
+// handleFeedFrame is handleFrame for the text frames of a Provider of the
+// user's own, which parses them itself.
+func (cli *Client) handleFeedFrame(data []byte, receivedAt time.Time) error {
+	msg, err := cli.feed.ParseMessage(data)
+	cli.onRawMessage(websocket.TextMessage, data)
+	if err != nil && cli.strict {
+		cli.onError(&MalformedMessageError{Data: data, Err: err})
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if msg.Provider == "" {
+		msg.Provider = cli.provider
+	}
+	msg.ReceivedAt, msg.Raw = receivedAt, data
+	if msg.exact == nil {
+		msg.exact = msg.Payload
+	}
+	if !cli.selected(msg.Type) {
+		return nil
+	}
+	if msg.Type == MessageHeartbeatAck {
+		atomic.StoreInt32(&cli.missedHeartbeats, 0)
+	}
+	cli.onMessage(msg)
+	if msg.Type.control() && !cli.controlToQuote {
+		return nil
+	}
+	cli.dispatch(msg)
+	return nil
+}
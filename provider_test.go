@@ -0,0 +1,195 @@
+package intriniorealtime
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// tickFeed is a feed of the user's own, the way another module would write
+// one: the token goes in the websocket path and the messages are actions
+// named by their event.
+type tickFeed struct {
+	authURL, soketURL string
+}
+
+type tickAction struct {
+	Event  string `json:"event"`
+	Symbol string `json:"symbol,omitempty"`
+}
+
+func (f tickFeed) AuthRequest() (*http.Request, error) {
+	return http.NewRequest("GET", f.authURL, nil)
+}
+
+func (f tickFeed) WebsocketURL(token string) string {
+	return f.soketURL + "/" + token
+}
+
+func (f tickFeed) JoinMessage(channel string) interface{} {
+	return tickAction{Event: "sub", Symbol: channel}
+}
+
+func (f tickFeed) LeaveMessage(channel string) interface{} {
+	return tickAction{Event: "unsub", Symbol: channel}
+}
+
+func (f tickFeed) HeartbeatMessage() interface{} {
+	return tickAction{Event: "ping"}
+}
+
+// namedTickFeed is a tickFeed with a name of its own.
+type namedTickFeed struct {
+	tickFeed
+	name string
+}
+
+func (f namedTickFeed) Name() string {
+	return f.name
+}
+
+func (f tickFeed) ParseMessage(raw []byte) (Message, error) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return Message{}, err
+	}
+	msg := Message{Payload: payload}
+	switch payload["event"] {
+	case "tick":
+		msg.Type = MessageTrade
+		msg.Channel, _ = payload["symbol"].(string)
+	case "pong":
+		msg.Type = MessageHeartbeatAck
+	}
+	return msg, nil
+}
+
+// tickServer answers tickFeed's actions: a tick for every sub and a pong
+// for every ping.
+func tickServer(s *fakeServer, conn *websocket.Conn, msg map[string]interface{}) {
+	switch msg["event"] {
+	case "sub":
+		s.send(conn, map[string]interface{}{"event": "tick", "symbol": msg["symbol"], "price": 12.5})
+	case "ping":
+		s.send(conn, map[string]interface{}{"event": "pong"})
+	}
+}
+
+func TestClientCustomProvider(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+	server.setReply(tickServer)
+
+	ticks := make(chan map[string]interface{}, 10)
+	acks := make(chan Message, 100)
+	sut := New("", "", tickFeed{authURL: server.authURL(), soketURL: server.soketURL()},
+		WithReconnectPolicy(fastReconnect), WithHeartbeatInterval(20*time.Millisecond))
+	sut.OnQuote(func(quote map[string]interface{}) { ticks <- quote })
+	sut.OnMessage(func(msg Message) {
+		if msg.Type == MessageHeartbeatAck {
+			acks <- msg
+		}
+	})
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	server.mu.Lock()
+	dialed := append([]string(nil), server.dialTokens...)
+	server.mu.Unlock()
+	if !reflect.DeepEqual(dialed, []string{"token-1"}) {
+		t.Errorf("dialed with tokens %v, want the one AuthRequest fetched", dialed)
+	}
+
+	sut.Join("AAPL")
+	if tick := receive(t, "OnQuote()", ticks); tick["symbol"] != "AAPL" || tick["price"] != 12.5 {
+		t.Errorf("OnQuote() = %v, want the AAPL tick", tick)
+	}
+	// Several heartbeats later the pongs have kept the connection up.
+	for i := 0; i < 5; i++ {
+		if ack := receive(t, "OnMessage()", acks); ack.Provider != custom || ack.Raw == nil {
+			t.Errorf("OnMessage() = %+v, want the pong as a custom message", ack)
+		}
+	}
+	if !sut.Connected() {
+		t.Error("Connected() = false with every heartbeat answered")
+	}
+
+	sut.Leave("AAPL")
+	if !waitUntil(5*time.Second, func() bool { return len(server.messagesWithEvent("unsub")) == 1 }) {
+		t.Fatalf("messages = %v, want an unsub", server.messages())
+	}
+	if sub := server.messagesWithEvent("sub"); len(sub) != 1 || sub[0]["symbol"] != "AAPL" {
+		t.Errorf("subs = %v, want AAPL's", sub)
+	}
+}
+
+func TestProviderErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider Provider
+		opts     []Option
+	}{
+		{name: "nilのプロバイダはエラーにすること", provider: nil},
+		{name: "独自のプロバイダにphoenixのバージョンはエラーにすること", provider: tickFeed{}, opts: []Option{WithPhoenixVersion(PhoenixV2)}},
+		{name: "組み込みのプロバイダの名前はエラーにすること", provider: namedTickFeed{name: "IEX"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := New("", "", tt.provider, tt.opts...)
+			if err := sut.Connect(); err == nil {
+				sut.Disconnect()
+				t.Error("Connect() error = nil, want the invalid provider")
+			}
+		})
+	}
+}
+
+func TestBuiltinProviders(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider provider
+		url      string
+		fixture  string
+		wantType MessageType
+	}{
+		{
+			name:     "IEXはphoenixのURLとメッセージを返すこと",
+			provider: IEX,
+			url:      "wss://realtime.intrinio.com/socket/websocket?vsn=1.0.0&token=t",
+			fixture:  "iex_quote.json",
+			wantType: MessageQuote,
+		},
+		{
+			name:     "QUODDはトークンをパスに入れること",
+			provider: QUODD,
+			url:      "wss://www5.quodd.com/websocket/webStreamer/intrinio/t",
+			fixture:  "quodd_trade.json",
+			wantType: MessageTrade,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p Provider = tt.provider
+			if got := p.WebsocketURL("t"); got != tt.url {
+				t.Errorf("WebsocketURL() = %s, want %s", got, tt.url)
+			}
+			if got, want := p.JoinMessage("AAPL"), makeJoinMessage(tt.provider, "AAPL"); !reflect.DeepEqual(got, want) {
+				t.Errorf("JoinMessage() = %v, want %v", got, want)
+			}
+			req, err := p.AuthRequest()
+			if err != nil || req.URL.String() != makeAuthURL(tt.provider) {
+				t.Errorf("AuthRequest() = %v, %v, want a request to %s", req, err, makeAuthURL(tt.provider))
+			}
+			msg, err := p.ParseMessage(loadBinary(t, tt.fixture))
+			if err != nil || msg.Provider != tt.provider || msg.Type != tt.wantType {
+				t.Errorf("ParseMessage() = %+v, %v, want a %v of %s", msg, err, tt.wantType, tt.provider)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package intriniorealtime
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/websocket"
+)
+
+// ProxyError is a token request or a websocket dial that failed on its way
+// through a proxy, be it the proxy refusing the tunnel or not being reached
+// at all.
+type ProxyError struct {
+	Proxy string // the proxy's URL, without credentials
+	Err   error
+}
+
+func (e *ProxyError) Error() string {
+	return fmt.Sprintf("through proxy %s: %v", e.Proxy, e.Err)
+}
+
+func (e *ProxyError) Unwrap() error {
+	return e.Err
+}
+
+// WithProxy makes the client fetch tokens and dial the websocket through the
+// proxy that proxy returns for each request, such as http.ProxyURL(u),
+// instead of the one the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment
+// variables name. A nil proxy connects directly. A client or dialer given
+// with WithHTTPClient or WithDialer keeps its own proxy.
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) Option {
+	return func(cli *Client) error {
+		cli.proxy = proxy
+		cli.proxied = true
+		return nil
+	}
+}
+
+// resolveTransport builds the transport of the token requests for WithProxy
+// and WithTLSConfig. Without them they go through http.DefaultTransport,
+// which like websocket.DefaultDialer honors the environment.
+func (cli *Client) resolveTransport() {
+	if !cli.proxied && cli.tlsConfig == nil || cli.httpClient != nil {
+		return
+	}
+	t, ok := http.DefaultTransport.(*http.Transport)
+	if ok {
+		t = t.Clone()
+	} else {
+		t = &http.Transport{Proxy: http.ProxyFromEnvironment}
+	}
+	if cli.proxied {
+		t.Proxy = cli.proxy
+	}
+	if cli.tlsConfig != nil {
+		// A clone of its own, as the transport adds its protocols to it.
+		t.TLSClientConfig = cli.tlsConfig.Clone()
+	}
+	cli.authTransport = t
+}
+
+// authClient returns the client tokens are requested with.
+func (cli *Client) authClient() *http.Client {
+	if cli.httpClient != nil {
+		return cli.httpClient
+	}
+	c := &http.Client{Timeout: authRequestWait}
+	if cli.authTransport != nil {
+		c.Transport = cli.authTransport
+	}
+	return c
+}
+
+// websocketDialer returns the dialer the websocket is dialed with.
+func (cli *Client) websocketDialer() *websocket.Dialer {
+	if cli.dialer != nil {
+		return cli.dialer
+	}
+	if !cli.proxied && cli.tlsConfig == nil {
+		return websocket.DefaultDialer
+	}
+	d := *websocket.DefaultDialer
+	if cli.proxied {
+		d.Proxy = cli.proxy
+	}
+	d.TLSClientConfig = cli.tlsConfig
+	return &d
+}
+
+// authProxyError names the proxy in err, the failure of the token request
+// req made with c, if the request went through one.
+func authProxyError(c *http.Client, req *http.Request, err error) error {
+	rt := c.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	t, ok := rt.(*http.Transport)
+	if !ok {
+		return err
+	}
+	return proxyError(t.Proxy, req, err)
+}
+
+// dialProxyError names the proxy in err, the failure of d to dial the
+// websocket at u, if the dial went through one. A refused handshake came
+// from the server itself and is returned as it is.
+func dialProxyError(d *websocket.Dialer, u string, err error) error {
+	if err == websocket.ErrBadHandshake {
+		return err
+	}
+	if _, ok := err.(*HandshakeError); ok {
+		return err
+	}
+	req, rerr := http.NewRequest("GET", u, nil)
+	if rerr != nil {
+		return err
+	}
+	// The proxy is chosen for the http URL the handshake is made on.
+	switch req.URL.Scheme {
+	case "ws":
+		req.URL.Scheme = "http"
+	case "wss":
+		req.URL.Scheme = "https"
+	}
+	return proxyError(d.Proxy, req, err)
+}
+
+func proxyError(proxy func(*http.Request) (*url.URL, error), req *http.Request, err error) error {
+	if proxy == nil || err == nil {
+		return err
+	}
+	u, perr := proxy(req)
+	if perr != nil || u == nil {
+		return err
+	}
+	redacted := *u
+	redacted.User = nil
+	return &ProxyError{Proxy: redacted.String(), Err: err}
+}
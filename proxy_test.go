@@ -0,0 +1,179 @@
+package intriniorealtime
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingProxy is a forward proxy that keeps the requests it was asked
+// for: the absolute URLs of plain requests and the hosts of CONNECT tunnels.
+type recordingProxy struct {
+	srv    *httptest.Server
+	refuse bool // answers every CONNECT with 403
+
+	mu       sync.Mutex
+	requests []string
+}
+
+func newRecordingProxy(refuse bool) *recordingProxy {
+	p := &recordingProxy{refuse: refuse}
+	p.srv = httptest.NewServer(http.HandlerFunc(p.serve))
+	return p
+}
+
+func (p *recordingProxy) serve(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	if r.Method == http.MethodConnect {
+		p.requests = append(p.requests, "CONNECT "+r.Host)
+	} else {
+		p.requests = append(p.requests, r.Method+" "+r.URL.Path)
+	}
+	p.mu.Unlock()
+	if r.Method != http.MethodConnect {
+		out := r.Clone(r.Context())
+		out.RequestURI = ""
+		resp, err := http.DefaultTransport.RoundTrip(out)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		for k, v := range resp.Header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+		return
+	}
+	if p.refuse {
+		http.Error(w, "tunnels are not allowed", http.StatusForbidden)
+		return
+	}
+	upstream, err := net.Dial("tcp", r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	conn, buf, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+	go func() {
+		io.Copy(upstream, buf)
+		upstream.Close()
+	}()
+	io.Copy(conn, upstream)
+	conn.Close()
+}
+
+func (p *recordingProxy) seen() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.requests...)
+}
+
+func (p *recordingProxy) url(t *testing.T) *url.URL {
+	u, err := url.Parse(p.srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestClientWithProxy(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+	proxy := newRecordingProxy(false)
+	defer proxy.srv.Close()
+
+	sut := server.newClient(IEX, WithProxy(http.ProxyURL(proxy.url(t))))
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+	host := strings.TrimPrefix(server.srv.URL, "http://")
+	got := proxy.seen()
+	if len(got) != 2 || got[0] != "GET /auth" || got[1] != "CONNECT "+host {
+		t.Errorf("proxy saw %v, want the token request and the websocket tunnel", got)
+	}
+}
+
+func TestClientProxyErrors(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+	refusing := newRecordingProxy(true)
+	defer refusing.srv.Close()
+	down := newRecordingProxy(false)
+	down.srv.Close()
+
+	tests := []struct {
+		name  string
+		proxy *url.URL
+		opts  []Option
+	}{
+		{
+			name:  "トンネルを拒否したプロキシを示すこと",
+			proxy: refusing.url(t),
+		},
+		{
+			name:  "つながらないプロキシを示すこと",
+			proxy: down.url(t),
+			opts:  []Option{WithToken("given")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := server.newClient(IEX, append([]Option{WithProxy(http.ProxyURL(tt.proxy))}, tt.opts...)...)
+			err := sut.Connect()
+			defer sut.Disconnect()
+			de, ok := err.(*DialError)
+			if !ok || len(de.Errors) != 1 {
+				t.Fatalf("connect() error = %v, want *DialError", err)
+			}
+			var pe *ProxyError
+			if !errors.As(de.Errors[0], &pe) || pe.Proxy != tt.proxy.String() {
+				t.Errorf("DialError.Errors = %v, want a *ProxyError naming %s", de.Errors, tt.proxy)
+			}
+		})
+	}
+
+	t.Run("トークンの取得で失敗したプロキシを示すこと", func(t *testing.T) {
+		u := down.url(t)
+		u.User = url.UserPassword("user", "secret")
+		sut := server.newClient(IEX, WithProxy(http.ProxyURL(u)))
+		err := sut.Connect()
+		defer sut.Disconnect()
+		var pe *ProxyError
+		if !errors.As(err, &pe) || pe.Proxy != down.srv.URL {
+			t.Fatalf("connect() error = %v, want a *ProxyError naming %s", err, down.srv.URL)
+		}
+		if strings.Contains(err.Error(), "secret") {
+			t.Errorf("connect() error = %v, leaks the proxy password", err)
+		}
+	})
+}
+
+func TestClientWithoutProxy(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+	proxy := newRecordingProxy(false)
+	defer proxy.srv.Close()
+
+	sut := server.newClient(IEX, WithProxy(http.ProxyURL(proxy.url(t))), WithProxy(nil))
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+	if got := proxy.seen(); len(got) != 0 {
+		t.Errorf("proxy saw %v, want a direct connection", got)
+	}
+}
@@ -0,0 +1,324 @@
+package intriniorealtime
+
+import "time"
+
+// QuoddQuoteData is a QUODD quote message. QUODD only sends the fields that
+// changed, so every field but Ticker is nil when it was missing from the
+// message. Prices are in USD, times in UTC.
+type QuoddQuoteData struct {
+	Ticker      string
+	RootTicker  *string
+	BidPrice    *float64
+	BidSize     *int64
+	BidExchange *string
+	AskPrice    *float64
+	AskSize     *int64
+	AskExchange *string
+	QuoteTime   *time.Time
+	ProtocolID  *int64
+	RTL         *int64
+
+	// The prices, exactly, with WithFixedPointPrices.
+	BidPriceFixed *Price
+	AskPriceFixed *Price
+
+	// The exchanges, looked up.
+	BidVenue *Exchange
+	AskVenue *Exchange
+
+	// Raw is the data as received, with sizes, volumes and times as
+	// json.Number; don't modify it.
+	Raw map[string]interface{}
+}
+
+// QuoddTradeData is a QUODD trade message. Like QuoddQuoteData, every field
+// but Ticker is nil when it was missing from the message. Prices are in USD
+// and percentages in percent.
+type QuoddTradeData struct {
+	Ticker            string
+	RootTicker        *string
+	LastPrice         *float64
+	TradeVolume       *int64
+	TradeExchange     *string
+	TradeTime         *time.Time
+	UpDown            *string
+	ChangePrice       *float64
+	PercentChange     *float64
+	TotalVolume       *int64
+	VolumePlus        *int64
+	VWAP              *float64
+	DayHigh           *float64
+	DayHighTime       *time.Time
+	DayLow            *float64
+	DayLowTime        *time.Time
+	PrevClose         *float64
+	OpenPrice         *float64
+	OpenVolume        *int64
+	OpenTime          *time.Time
+	ExtLastPrice      *float64
+	ExtTradeVolume    *int64
+	ExtTradeExchange  *string
+	ExtTradeTime      *time.Time
+	ExtUpDown         *string
+	ExtChangePrice    *float64
+	ExtPercentChange  *float64
+	IsHalted          *bool
+	IsShortRestricted *bool
+	ProtocolID        *int64
+	RTL               *int64
+
+	// The prices, exactly, with WithFixedPointPrices.
+	LastPriceFixed      *Price
+	ChangePriceFixed    *Price
+	VWAPFixed           *Price
+	DayHighFixed        *Price
+	DayLowFixed         *Price
+	PrevCloseFixed      *Price
+	OpenPriceFixed      *Price
+	ExtLastPriceFixed   *Price
+	ExtChangePriceFixed *Price
+
+	// The exchanges, looked up.
+	TradeVenue    *Exchange
+	ExtTradeVenue *Exchange
+
+	// Raw is the data as received, with sizes, volumes and times as
+	// json.Number; don't modify it.
+	Raw map[string]interface{}
+}
+
+// OnQuoddQuote registers a handler for QUODD quote messages. It gets them
+// right after OnQuote, in the same order, decoded.
+func (cli *Client) OnQuoddQuote(f func(quote QuoddQuoteData)) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
+	cli.quoddQuoteHandler = f
+}
+
+// OnQuoddTrade registers a handler for QUODD trade messages. It gets them
+// right after OnQuote, in the same order, decoded.
+func (cli *Client) OnQuoddTrade(f func(trade QuoddTradeData)) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
+	cli.quoddTradeHandler = f
+}
+
+// quoddData returns the data of a QUODD message whose event is one of
+// events.
+func quoddData(provider provider, msg map[string]interface{}, events ...string) (map[string]interface{}, bool) {
+	if provider != QUODD {
+		return nil, false
+	}
+	for _, event := range events {
+		if msg["event"] == event {
+			data, ok := msg["data"].(map[string]interface{})
+			return data, ok
+		}
+	}
+	return nil, false
+}
+
+// parseQuoddQuote returns the quote carried by msg, if it is one.
+func parseQuoddQuote(provider provider, msg map[string]interface{}) (QuoddQuoteData, bool) {
+	data, ok := quoddData(provider, msg, "quote", "quote_data")
+	if !ok {
+		return QuoddQuoteData{}, false
+	}
+	quote := QuoddQuoteData{
+		RootTicker:  optString(data, "root_ticker"),
+		BidPrice:    optPrice(data, "bid_price_4d"),
+		BidSize:     optInt(data, "bid_size"),
+		BidExchange: optString(data, "bid_exchange"),
+		AskPrice:    optPrice(data, "ask_price_4d"),
+		AskSize:     optInt(data, "ask_size"),
+		AskExchange: optString(data, "ask_exchange"),
+		QuoteTime:   optMillis(data, "quote_time"),
+		ProtocolID:  optInt(data, "protocol_id"),
+		RTL:         optInt(data, "rtl"),
+		Raw:         data,
+
+		BidPriceFixed: optFixed(data, "bid_price_4d"),
+		AskPriceFixed: optFixed(data, "ask_price_4d"),
+
+		BidVenue: optExchange(data, "bid_exchange"),
+		AskVenue: optExchange(data, "ask_exchange"),
+	}
+	quote.Ticker, _ = data["ticker"].(string)
+	return quote, true
+}
+
+// parseQuoddTrade returns the trade carried by msg, if it is one.
+func parseQuoddTrade(provider provider, msg map[string]interface{}) (QuoddTradeData, bool) {
+	data, ok := quoddData(provider, msg, "trade", "trade_data")
+	if !ok {
+		return QuoddTradeData{}, false
+	}
+	trade := QuoddTradeData{
+		RootTicker:        optString(data, "root_ticker"),
+		LastPrice:         optPrice(data, "last_price_4d"),
+		TradeVolume:       optInt(data, "trade_volume"),
+		TradeExchange:     optString(data, "trade_exchange"),
+		TradeTime:         optMillis(data, "trade_time"),
+		UpDown:            optString(data, "up_down"),
+		ChangePrice:       optPrice(data, "change_price_4d"),
+		PercentChange:     optPrice(data, "percent_change_4d"),
+		TotalVolume:       optInt(data, "total_volume"),
+		VolumePlus:        optInt(data, "volume_plus"),
+		VWAP:              optPrice(data, "vwap_4d"),
+		DayHigh:           optPrice(data, "day_high_4d"),
+		DayHighTime:       optMillis(data, "day_high_time"),
+		DayLow:            optPrice(data, "day_low_4d"),
+		DayLowTime:        optMillis(data, "day_low_time"),
+		PrevClose:         optPrice(data, "prev_close_4d"),
+		OpenPrice:         optPrice(data, "open_price_4d"),
+		OpenVolume:        optInt(data, "open_volume"),
+		OpenTime:          optMillis(data, "open_time"),
+		ExtLastPrice:      optPrice(data, "ext_last_price_4d"),
+		ExtTradeVolume:    optInt(data, "ext_trade_volume"),
+		ExtTradeExchange:  optString(data, "ext_trade_exchange"),
+		ExtTradeTime:      optMillis(data, "ext_trade_time"),
+		ExtUpDown:         optString(data, "ext_up_down"),
+		ExtChangePrice:    optPrice(data, "ext_change_price_4d"),
+		ExtPercentChange:  optPrice(data, "ext_percent_change_4d"),
+		IsHalted:          optBool(data, "is_halted"),
+		IsShortRestricted: optBool(data, "is_short_restricted"),
+		ProtocolID:        optInt(data, "protocol_id"),
+		RTL:               optInt(data, "rtl"),
+		Raw:               data,
+
+		LastPriceFixed:      optFixed(data, "last_price_4d"),
+		ChangePriceFixed:    optFixed(data, "change_price_4d"),
+		VWAPFixed:           optFixed(data, "vwap_4d"),
+		DayHighFixed:        optFixed(data, "day_high_4d"),
+		DayLowFixed:         optFixed(data, "day_low_4d"),
+		PrevCloseFixed:      optFixed(data, "prev_close_4d"),
+		OpenPriceFixed:      optFixed(data, "open_price_4d"),
+		ExtLastPriceFixed:   optFixed(data, "ext_last_price_4d"),
+		ExtChangePriceFixed: optFixed(data, "ext_change_price_4d"),
+
+		TradeVenue:    optExchange(data, "trade_exchange"),
+		ExtTradeVenue: optExchange(data, "ext_trade_exchange"),
+	}
+	trade.Ticker, _ = data["ticker"].(string)
+	return trade, true
+}
+
+func optString(data map[string]interface{}, key string) *string {
+	if v, ok := data[key].(string); ok {
+		return &v
+	}
+	return nil
+}
+
+func optBool(data map[string]interface{}, key string) *bool {
+	if v, ok := data[key].(bool); ok {
+		return &v
+	}
+	return nil
+}
+
+func optInt(data map[string]interface{}, key string) *int64 {
+	if v, ok := integer(data[key]); ok {
+		return &v
+	}
+	return nil
+}
+
+// optPrice converts one of QUODD's *_4d fixed point values, which carry
+// four decimal places.
+func optPrice(data map[string]interface{}, key string) *float64 {
+	if v, ok := number(data[key]); ok {
+		v /= priceScale
+		return &v
+	}
+	return nil
+}
+
+// optFixed is optPrice for WithFixedPointPrices, see fixedPrice4d.
+func optFixed(data map[string]interface{}, key string) *Price {
+	if v, ok := fixedPrice4d(data[key]); ok {
+		return &v
+	}
+	return nil
+}
+
+// optMillis converts a QUODD time, see unixMillis.
+func optMillis(data map[string]interface{}, key string) *time.Time {
+	if v, ok := data[key]; ok && v != nil {
+		t := unixMillis(v)
+		return &t
+	}
+	return nil
+}
+
+// quoddBeatsKept is how many of the heartbeats last sent to QUODD are
+// remembered, so that their echoes aren't taken for the server's own.
+const quoddBeatsKept = 8
+
+// heartbeatMessage returns the next heartbeat to send, noting the ticker of
+// a QUODD one.
+func (cli *Client) heartbeatMessage() interface{} {
+	if cli.feed != nil {
+		return cli.feed.HeartbeatMessage()
+	}
+	msg := makeHeartbeatMessage(cli.provider)
+	if cli.provider == QUODD {
+		data, _ := msg["data"].(map[string]interface{})
+		cli.sentHeartbeat(data["ticker"].(int64))
+	}
+	return msg
+}
+
+// sentHeartbeat notes the ticker of a heartbeat sent to QUODD.
+func (cli *Client) sentHeartbeat(ticker int64) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	cli.quoddBeats[cli.quoddBeat%quoddBeatsKept] = ticker
+	cli.quoddBeat++
+}
+
+// answerHeartbeat answers msg if it is a heartbeat QUODD sent of its own
+// accord rather than the echo of one of the client's: QUODD drops
+// connections that leave those unanswered. The answer echoes it, with the
+// server's ticker or, when it has none, the client's time, and jumps the
+// queue of joins and leaves like the client's own heartbeats. It is called
+// from the read loop, so it never waits for the sender: while a heartbeat
+// is already waiting to be sent the answer is dropped, as that one keeps
+// the connection alive as well.
+func (cli *Client) answerHeartbeat(msg map[string]interface{}) {
+	if cli.provider != QUODD || msg["event"] != "heartbeat" {
+		return
+	}
+	data, _ := msg["data"].(map[string]interface{})
+	ticker, ok := integer(data["ticker"])
+	if !ok {
+		ticker = time.Now().Unix()
+	}
+	cli.mu.Lock()
+	for i := 0; ok && i < quoddBeatsKept && i < cli.quoddBeat; i++ {
+		if cli.quoddBeats[i] == ticker {
+			cli.mu.Unlock()
+			return
+		}
+	}
+	if cli.ws == nil || cli.closing {
+		cli.mu.Unlock()
+		return
+	}
+	control := cli.control
+	cli.mu.Unlock()
+
+	answer := make(map[string]interface{}, len(data)+2)
+	for k, v := range data {
+		answer[k] = v
+	}
+	answer["action"] = "heartbeat"
+	answer["ticker"] = ticker
+	select {
+	case control <- outgoing{msg: map[string]interface{}{"event": "heartbeat", "data": answer}}:
+		cli.sentHeartbeat(ticker)
+		cli.debug("answering heartbeat", "ticker", ticker)
+	default:
+		cli.debug("dropping heartbeat answer, a heartbeat is waiting to be sent", "ticker", ticker)
+	}
+}
@@ -0,0 +1,293 @@
+package intriniorealtime
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func loadFixture(t *testing.T, name string) map[string]interface{} {
+	t.Helper()
+	b, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg map[string]interface{}
+	if err := json.Unmarshal(b, &msg); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return msg
+}
+
+func strp(v string) *string       { return &v }
+func boolp(v bool) *bool          { return &v }
+func int64p(v int64) *int64       { return &v }
+func float64p(v float64) *float64 { return &v }
+func millisp(ms int64) *time.Time {
+	t := time.Unix(0, ms*int64(time.Millisecond)).UTC()
+	return &t
+}
+
+func TestParseQuoddQuote(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider provider
+		fixture  string
+		want     QuoddQuoteData
+		wantOK   bool
+	}{
+		{
+			name:     "全項目のクォートを解析すること",
+			provider: QUODD,
+			fixture:  "quodd_quote.json",
+			want: QuoddQuoteData{
+				Ticker:      "AAPL.NB",
+				RootTicker:  strp("AAPL"),
+				BidPrice:    float64p(159.48),
+				BidSize:     int64p(500),
+				BidExchange: strp("t"),
+				AskPrice:    float64p(159.49),
+				AskSize:     int64p(600),
+				AskExchange: strp("t"),
+				QuoteTime:   millisp(1508165070850),
+				ProtocolID:  int64p(302),
+				RTL:         int64p(129739),
+				BidVenue:    exchangep("t"),
+				AskVenue:    exchangep("t"),
+			},
+			wantOK: true,
+		},
+		{
+			name:     "差分のクォートは届いた項目だけ設定すること",
+			provider: QUODD,
+			fixture:  "quodd_quote_partial.json",
+			want: QuoddQuoteData{
+				Ticker:    "AAPL.NB",
+				BidPrice:  float64p(159.47),
+				BidSize:   int64p(0),
+				QuoteTime: millisp(1508165071130),
+				RTL:       int64p(129740),
+			},
+			wantOK: true,
+		},
+		{
+			name:     "トレードはクォートとして解析しないこと",
+			provider: QUODD,
+			fixture:  "quodd_trade.json",
+		},
+		{
+			name:     "IEXでは解析しないこと",
+			provider: IEX,
+			fixture:  "quodd_quote.json",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseQuoddQuote(tt.provider, loadFixture(t, tt.fixture))
+			if ok && got.Raw["ticker"] != got.Ticker {
+				t.Errorf("parseQuoddQuote() Raw = %v", got.Raw)
+			}
+			got.Raw = nil
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseQuoddQuote() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseQuoddTrade(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider provider
+		fixture  string
+		want     QuoddTradeData
+		wantOK   bool
+	}{
+		{
+			name:     "全項目のトレードを解析すること",
+			provider: QUODD,
+			fixture:  "quodd_trade.json",
+			want: QuoddTradeData{
+				Ticker:            "AAPL.NB",
+				RootTicker:        strp("AAPL"),
+				LastPrice:         float64p(159.485),
+				TradeVolume:       int64p(100),
+				TradeExchange:     strp("t"),
+				TradeTime:         millisp(1508165070052),
+				UpDown:            strp("v"),
+				ChangePrice:       float64p(2.495),
+				PercentChange:     float64p(1.5892),
+				TotalVolume:       int64p(10209883),
+				VolumePlus:        int64p(6333150),
+				VWAP:              float64p(158.8482),
+				DayHigh:           float64p(159.66),
+				DayHighTime:       millisp(1508164532269),
+				DayLow:            float64p(157.65),
+				DayLowTime:        millisp(1508160605345),
+				PrevClose:         float64p(156.99),
+				OpenPrice:         float64p(158.22),
+				OpenVolume:        int64p(100),
+				OpenTime:          millisp(1508141103583),
+				ExtLastPrice:      float64p(157.9),
+				ExtTradeVolume:    int64p(100),
+				ExtTradeExchange:  strp("t"),
+				ExtTradeTime:      millisp(1508160600567),
+				ExtUpDown:         strp("-"),
+				ExtChangePrice:    float64p(0.91),
+				ExtPercentChange:  float64p(0.5796),
+				IsHalted:          boolp(false),
+				IsShortRestricted: boolp(false),
+				TradeVenue:        exchangep("t"),
+				ExtTradeVenue:     exchangep("t"),
+				ProtocolID:        int64p(301),
+				RTL:               int64p(30660),
+			},
+			wantOK: true,
+		},
+		{
+			name:     "差分のトレードは届いた項目だけ設定すること",
+			provider: QUODD,
+			fixture:  "quodd_trade_partial.json",
+			want: QuoddTradeData{
+				Ticker:      "AAPL.NB",
+				LastPrice:   float64p(159.49),
+				TradeVolume: int64p(0),
+				TradeTime:   millisp(1508165071002),
+				TotalVolume: int64p(10209983),
+				RTL:         int64p(30661),
+			},
+			wantOK: true,
+		},
+		{
+			name:     "クォートはトレードとして解析しないこと",
+			provider: QUODD,
+			fixture:  "quodd_quote.json",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseQuoddTrade(tt.provider, loadFixture(t, tt.fixture))
+			if ok && got.Raw["ticker"] != got.Ticker {
+				t.Errorf("parseQuoddTrade() Raw = %v", got.Raw)
+			}
+			got.Raw = nil
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseQuoddTrade() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestClientOnQuoddQuoteAndTrade(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	quotes := make(chan QuoddQuoteData, 10)
+	trades := make(chan QuoddTradeData, 10)
+	sut := server.newClient(QUODD)
+	sut.OnQuoddQuote(func(quote QuoddQuoteData) { quotes <- quote })
+	sut.OnQuoddTrade(func(trade QuoddTradeData) { trades <- trade })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	server.broadcast(loadFixture(t, "quodd_trade.json"))
+	server.broadcast(loadFixture(t, "quodd_quote.json"))
+	select {
+	case trade := <-trades:
+		if trade.Ticker != "AAPL.NB" || trade.LastPrice == nil || *trade.LastPrice != 159.485 {
+			t.Errorf("OnQuoddTrade() trade = %+v", trade)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnQuoddTrade() was not called")
+	}
+	select {
+	case quote := <-quotes:
+		if quote.Ticker != "AAPL.NB" || quote.BidSize == nil || *quote.BidSize != 500 {
+			t.Errorf("OnQuoddQuote() quote = %+v", quote)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnQuoddQuote() was not called")
+	}
+	if len(quotes) != 0 || len(trades) != 0 {
+		t.Errorf("extra quotes = %d, trades = %d", len(quotes), len(trades))
+	}
+}
+
+func TestClientAnswersQuoddHeartbeats(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+	// The server echoes heartbeats, answers included.
+	server.setReply(ackHeartbeats)
+
+	quotes := make(chan map[string]interface{}, 10)
+	sut := server.newClient(QUODD, WithoutHeartbeat())
+	sut.OnQuote(func(quote map[string]interface{}) { quotes <- quote })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	answers := func() int {
+		var n int
+		for _, msg := range server.messagesWithEvent("heartbeat") {
+			data, _ := msg["data"].(map[string]interface{})
+			if data["action"] == "heartbeat" && data["ticker"] == float64(1760621400) {
+				n++
+			}
+		}
+		return n
+	}
+	server.broadcast(map[string]interface{}{
+		"event": "heartbeat",
+		"data":  map[string]interface{}{"action": "heartbeat", "ticker": 1760621400},
+	})
+	if !waitUntil(writeWait, func() bool { return answers() == 1 }) {
+		t.Fatalf("answers = %d, want the heartbeat echoed within the write deadline", answers())
+	}
+	// The echo of the answer isn't answered again.
+	time.Sleep(100 * time.Millisecond)
+	if got := answers(); got != 1 {
+		t.Errorf("answers = %d, want 1", got)
+	}
+	select {
+	case quote := <-quotes:
+		t.Errorf("OnQuote() got %v, want no heartbeats", quote)
+	default:
+	}
+}
+
+func TestClientAnswerQuoddHeartbeatDoesNotWait(t *testing.T) {
+	sut := New(yourIntrinioAPIUserName, yourIntrinioAPIPassword, QUODD)
+	// A connection without a sender, with a heartbeat already waiting.
+	sut.channelInitialize()
+	sut.ws = &websocket.Conn{}
+	waiting := outgoing{msg: sut.heartbeatMessage()}
+	sut.control <- waiting
+
+	answered := make(chan struct{})
+	go func() {
+		defer close(answered)
+		sut.answerHeartbeat(map[string]interface{}{
+			"event": "heartbeat",
+			"data":  map[string]interface{}{"action": "heartbeat", "ticker": float64(1760621400)},
+		})
+	}()
+	select {
+	case <-answered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("answerHeartbeat() waited for the sender")
+	}
+	if got := <-sut.control; !reflect.DeepEqual(got, waiting) {
+		t.Errorf("control = %v, want the waiting heartbeat", got)
+	}
+	if n := len(sut.control); n != 0 {
+		t.Errorf("len(control) = %d, want the answer dropped", n)
+	}
+}
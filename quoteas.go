@@ -0,0 +1,101 @@
+package intriniorealtime
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// typedHandler is a handler registered with OnQuoteAs. key tells the target
+// types apart, f decodes the payload and calls the user's handler.
+type typedHandler struct {
+	key interface{}
+	f   func(payload []byte)
+}
+
+// OnQuoteAs registers a handler that gets the payload of every quote, decoded
+// into a T of the caller's own: the "payload" of IEX quotes and the "data" of
+// QUODD quote and trade messages, so T's json tags name the provider's
+// fields. Payloads are decoded the way the client decodes frames, keeping
+// numbers as json.Number with WithFixedPointPrices. A payload that doesn't
+// fit T is reported through OnError as a *DecodeError.
+//
+// There is one handler per target type, registering another replaces it and
+// nil removes it. Handlers for different types all run, in the order they
+// were first registered, right after the other quote handlers.
+func OnQuoteAs[T any](cli *Client, f func(T)) {
+	target := reflect.TypeOf((*T)(nil)).Elem().String()
+	h := typedHandler{key: (*T)(nil)}
+	if f != nil {
+		h.f = func(payload []byte) {
+			var v T
+			if err := cli.decode(payload, &v); err != nil {
+				cli.onError(&DecodeError{Target: target, Data: payload, Err: err})
+				return
+			}
+			cli.runHandler("OnQuoteAs", func() { f(v) })
+		}
+	}
+	cli.setTypedHandler(h)
+}
+
+// setTypedHandler replaces the handler with h's key, or adds it. The list is
+// copied, as handleQuote keeps using the one it read.
+func (cli *Client) setTypedHandler(h typedHandler) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
+	handlers := make([]typedHandler, 0, len(cli.typedHandlers)+1)
+	found := false
+	for _, old := range cli.typedHandlers {
+		if old.key == h.key {
+			found = true
+			if h.f == nil {
+				continue
+			}
+			old = h
+		}
+		handlers = append(handlers, old)
+	}
+	if !found && h.f != nil {
+		handlers = append(handlers, h)
+	}
+	cli.typedHandlers = handlers
+}
+
+// handleTyped runs the OnQuoteAs handlers for msg, if it is a quote.
+func (cli *Client) handleTyped(handlers []typedHandler, msg map[string]interface{}) {
+	payload, ok := quotePayload(cli.provider, msg)
+	if !ok {
+		return
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		cli.onError(err)
+		return
+	}
+	for _, h := range handlers {
+		h.f(data)
+	}
+}
+
+// quotePayload returns the part of msg that OnQuoteAs decodes.
+func quotePayload(provider provider, msg map[string]interface{}) (map[string]interface{}, bool) {
+	switch provider {
+	case IEX:
+		if msg["event"] != "quote" {
+			return nil, false
+		}
+		payload, ok := msg["payload"].(map[string]interface{})
+		return payload, ok
+	case REALTIME, DELAYED_SIP, NASDAQ_BASIC:
+		return realtimePayload(provider, msg, "quote", "trade")
+	case OPRA:
+		return optionPayload(provider, msg, "quote", "trade")
+	case CRYPTOQUOTE:
+		return cryptoPayload(provider, msg, "book_update", "trade")
+	case FXCM:
+		return fxPayload(provider, msg, "price_update")
+	case QUODD:
+		return quoddData(provider, msg, "quote", "quote_data", "trade", "trade_data")
+	}
+	return nil, false
+}
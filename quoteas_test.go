@@ -0,0 +1,172 @@
+package intriniorealtime
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type myIEXQuote struct {
+	Symbol string  `json:"ticker"`
+	Side   string  `json:"type"`
+	Price  float64 `json:"price"`
+	Size   int     `json:"size"`
+}
+
+type quoddSide struct {
+	Size     int64  `json:"bid_size"`
+	Exchange string `json:"bid_exchange"`
+}
+
+type myQuoddQuote struct {
+	quoddSide
+	Ticker string      `json:"ticker"`
+	Bid    json.Number `json:"bid_price_4d"`
+	Time   int64       `json:"quote_time"`
+}
+
+func TestOnQuoteAs(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	t.Run("IEXのペイロードを独自の構造体に読むこと", func(t *testing.T) {
+		got := make(chan myIEXQuote, 1)
+		sut := server.newClient(IEX)
+		OnQuoteAs(sut, func(q myIEXQuote) { got <- q })
+		if err := sut.Connect(); err != nil {
+			t.Fatalf("connect() error = %v", err)
+		}
+		defer sut.Disconnect()
+
+		server.broadcast(loadFixture(t, "iex_quote.json"))
+		want := myIEXQuote{Symbol: "GE", Side: "ask", Price: 28.97, Size: 13750}
+		select {
+		case q := <-got:
+			if q != want {
+				t.Errorf("OnQuoteAs() = %+v, want %+v", q, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("OnQuoteAs() was not called")
+		}
+	})
+
+	t.Run("QUODDのデータを埋め込んだ構造体のタグどおりに読むこと", func(t *testing.T) {
+		got := make(chan myQuoddQuote, 1)
+		sut := server.newClient(QUODD, WithFixedPointPrices())
+		OnQuoteAs(sut, func(q myQuoddQuote) { got <- q })
+		if err := sut.Connect(); err != nil {
+			t.Fatalf("connect() error = %v", err)
+		}
+		defer sut.Disconnect()
+
+		server.broadcast(loadFixture(t, "quodd_quote.json"))
+		want := myQuoddQuote{quoddSide: quoddSide{Size: 500, Exchange: "t"}, Ticker: "AAPL.NB", Bid: "1594800", Time: 1508165070850}
+		select {
+		case q := <-got:
+			if q != want {
+				t.Errorf("OnQuoteAs() = %+v, want %+v", q, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("OnQuoteAs() was not called")
+		}
+	})
+}
+
+func TestOnQuoteAsWithOnQuote(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	var order []string
+	done := make(chan struct{})
+	sut := server.newClient(IEX)
+	sut.OnQuote(func(quote map[string]interface{}) { order = append(order, "OnQuote") })
+	OnQuoteAs(sut, func(q myIEXQuote) { order = append(order, "myIEXQuote") })
+	OnQuoteAs(sut, func(q map[string]interface{}) {
+		order = append(order, "map")
+		close(done)
+	})
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	server.broadcast(loadFixture(t, "iex_quote.json"))
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("handlers called = %v", order)
+	}
+	if want := []string{"OnQuote", "myIEXQuote", "map"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("handlers called = %v, want %v", order, want)
+	}
+}
+
+func TestOnQuoteAsReplace(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	got := make(chan string, 10)
+	sut := server.newClient(IEX)
+	OnQuoteAs(sut, func(q myIEXQuote) { got <- "first" })
+	OnQuoteAs(sut, func(q myIEXQuote) { got <- "second" })
+	OnQuoteAs[map[string]interface{}](sut, func(q map[string]interface{}) { got <- "removed" })
+	OnQuoteAs[map[string]interface{}](sut, nil)
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	server.broadcast(loadFixture(t, "iex_quote.json"))
+	select {
+	case s := <-got:
+		if s != "second" {
+			t.Errorf("OnQuoteAs() handler = %s, want second", s)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnQuoteAs() was not called")
+	}
+	select {
+	case s := <-got:
+		t.Errorf("OnQuoteAs() handler %s was also called", s)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestOnQuoteAsDecodeError(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	errs := make(chan error, 1)
+	quotes := make(chan myIEXQuote, 1)
+	sut := server.newClient(IEX)
+	sut.OnError(func(err error) { errs <- err })
+	OnQuoteAs(sut, func(q myIEXQuote) { quotes <- q })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	server.broadcastRaw(websocket.TextMessage, []byte(`{"topic":"iex:securities:GE","event":"quote","payload":{"type":"last","ticker":"GE","price":"28.97"}}`))
+	select {
+	case err := <-errs:
+		var de *DecodeError
+		if !errors.As(err, &de) || de.Target != "intriniorealtime.myIEXQuote" {
+			t.Fatalf("OnError() error = %v, want a *DecodeError for myIEXQuote", err)
+		}
+		var te *json.UnmarshalTypeError
+		if !errors.As(err, &te) {
+			t.Errorf("OnError() error = %v, want it to wrap a *json.UnmarshalTypeError", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnError() was not called")
+	}
+	select {
+	case q := <-quotes:
+		t.Errorf("OnQuoteAs() = %+v, want no call", q)
+	default:
+	}
+}
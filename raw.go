@@ -0,0 +1,22 @@
+package intriniorealtime
+
+// OnRawMessage registers a handler that gets every frame as it came off the
+// wire, before the other handlers see it. messageType is
+// websocket.TextMessage or websocket.BinaryMessage. Every frame is read into
+// a buffer of its own, so data may be kept after the handler returns.
+// Binary frames of providers without a binary protocol only go to this
+// handler.
+func (cli *Client) OnRawMessage(f func(messageType int, data []byte)) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
+	cli.rawHandler = f
+}
+
+func (cli *Client) onRawMessage(messageType int, data []byte) {
+	cli.handlerMu.RLock()
+	f := cli.rawHandler
+	cli.handlerMu.RUnlock()
+	if f != nil {
+		cli.callHandler("OnRawMessage", func() { f(messageType, data) })
+	}
+}
@@ -0,0 +1,64 @@
+package intriniorealtime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestClientOnRawMessage(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	type frame struct {
+		messageType int
+		data        []byte
+	}
+	frames := make(chan frame, 10)
+	quotes := make(chan map[string]interface{}, 10)
+	sut := server.newClient(QUODD)
+	sut.OnRawMessage(func(messageType int, data []byte) { frames <- frame{messageType, data} })
+	sut.OnQuote(func(quote map[string]interface{}) { quotes <- quote })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	sent := []frame{
+		{websocket.TextMessage, []byte(`{ "event": "quote",  "data": {"ticker": "AAPL.NB", "bid_size": 500} }`)},
+		{websocket.BinaryMessage, []byte{0x00, 0xff, 0x10}},
+		{websocket.TextMessage, []byte(`{"event":"quote","data":{"ticker":"MSFT.NB"}}`)},
+	}
+	for _, f := range sent {
+		server.broadcastRaw(f.messageType, f.data)
+	}
+	var got []frame
+	for range sent {
+		select {
+		case f := <-frames:
+			// Kept past the handler, they must stay as they were.
+			got = append(got, f)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("OnRawMessage() got %d frames, want %d", len(got), len(sent))
+		}
+	}
+	for i, f := range got {
+		if f.messageType != sent[i].messageType || string(f.data) != string(sent[i].data) {
+			t.Errorf("frame #%d = %d %q, want %d %q", i, f.messageType, f.data, sent[i].messageType, sent[i].data)
+		}
+	}
+	for _, want := range []string{"AAPL.NB", "MSFT.NB"} {
+		select {
+		case quote := <-quotes:
+			if ticker := quote["data"].(map[string]interface{})["ticker"]; ticker != want {
+				t.Errorf("OnQuote() ticker = %v, want %v", ticker, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("OnQuote() was not called for %s", want)
+		}
+	}
+	if !sut.Connected() {
+		t.Error("binary frame dropped the connection")
+	}
+}
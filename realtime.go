@@ -0,0 +1,96 @@
+package intriniorealtime
+
+// phoenix reports the providers whose server is a Phoenix channel server:
+// channels are joined and left with phx_join and phx_leave on the IEX
+// topics, every join is answered with a phx_reply, and heartbeats go to the
+// phoenix topic. REALTIME, DELAYED_SIP, NASDAQ_BASIC, OPRA, CRYPTOQUOTE and
+// FXCM speak the same transport as IEX.
+func phoenix(provider provider) bool {
+	switch provider {
+	case IEX, REALTIME, DELAYED_SIP, NASDAQ_BASIC, OPRA, CRYPTOQUOTE, FXCM:
+		return true
+	}
+	return false
+}
+
+// realtimeFeed reports the providers that send REALTIME's messages.
+// DELAYED_SIP is the same feed, consolidated and 15 minutes late.
+func realtimeFeed(provider provider) bool {
+	return provider == REALTIME || provider == DELAYED_SIP
+}
+
+// delayed reports the providers whose data is delayed rather than real-time.
+func delayed(provider provider) bool {
+	return provider == DELAYED_SIP
+}
+
+// realtimePayload returns the payload of msg if it is a REALTIME,
+// DELAYED_SIP or NASDAQ_BASIC message with one of the given events.
+//
+// REALTIME sends trades and quotes of every exchange on the topic of their
+// symbol, as trade and quote events whose payload has the symbol, the price,
+// the size, the timestamp in nanoseconds since the epoch, the market_center
+// as a participant ID of the consolidated tapes and, for quotes, the type,
+// bid or ask. Trades also carry the day's total_volume and the sale
+// conditions as a string of the tape's codes.
+func realtimePayload(provider provider, msg map[string]interface{}, events ...string) (map[string]interface{}, bool) {
+	if !realtimeFeed(provider) && provider != NASDAQ_BASIC {
+		return nil, false
+	}
+	for _, event := range events {
+		if msg["event"] == event {
+			payload, ok := msg["payload"].(map[string]interface{})
+			return payload, ok
+		}
+	}
+	return nil, false
+}
+
+// parseRealtimeTrade returns the trade carried by msg, if it is one.
+func parseRealtimeTrade(provider provider, msg map[string]interface{}) (Trade, bool) {
+	payload, ok := realtimePayload(provider, msg, "trade")
+	if !ok {
+		return Trade{}, false
+	}
+	trade := Trade{}
+	trade.Symbol, _ = SymbolFromMessage(provider, msg)
+	trade.Price, _ = number(payload["price"])
+	trade.FixedPrice, _ = fixedPrice(payload["price"])
+	trade.Size, _ = integer(payload["size"])
+	at := payload["timestamp"]
+	trade.Timestamp, trade.RawTimestamp = unixTime(at, timeUnit(provider, "timestamp", at))
+	code, _ := payload["market_center"].(string)
+	if code != "" {
+		trade.Venue = LookupExchange(code)
+	}
+	trade.Darkpool = sipDarkpool(code)
+	conditions, _ := payload["conditions"].(string)
+	trade.Extended = extendedHours(conditions)
+	trade.Delayed = delayed(provider)
+	return trade, true
+}
+
+// parseRealtimeSide returns the bid or ask carried by msg, if it is one.
+func parseRealtimeSide(provider provider, msg map[string]interface{}) (side *QuoteSide, bid bool) {
+	payload, ok := realtimePayload(provider, msg, "quote")
+	if !ok {
+		return nil, false
+	}
+	typ, _ := payload["type"].(string)
+	if typ != "bid" && typ != "ask" {
+		return nil, false
+	}
+	side = &QuoteSide{}
+	side.Symbol, _ = SymbolFromMessage(provider, msg)
+	side.Price, _ = number(payload["price"])
+	side.FixedPrice, _ = fixedPrice(payload["price"])
+	side.Size, _ = integer(payload["size"])
+	at := payload["timestamp"]
+	side.Timestamp, side.RawTimestamp = unixTime(at, timeUnit(provider, "timestamp", at))
+	if code, _ := payload["market_center"].(string); code != "" {
+		side.Venue = LookupExchange(code)
+		side.Exchange = code
+	}
+	side.Delayed = delayed(provider)
+	return side, typ == "bid"
+}
@@ -0,0 +1,251 @@
+package intriniorealtime
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// realtimeDialect answers like the REALTIME server: every join gets a
+// phx_reply and then a trade for the joined symbol, and heartbeats are
+// acknowledged on the phoenix topic.
+func realtimeDialect(trade []byte) func(s *fakeServer, conn *websocket.Conn, msg map[string]interface{}) {
+	return func(s *fakeServer, conn *websocket.Conn, msg map[string]interface{}) {
+		ackHeartbeats(s, conn, msg)
+		if msg["event"] != "phx_join" {
+			return
+		}
+		s.send(conn, map[string]interface{}{
+			"topic":   msg["topic"],
+			"event":   "phx_reply",
+			"payload": map[string]interface{}{"status": "ok", "response": map[string]interface{}{}},
+			"ref":     nil,
+		})
+		s.mu.Lock()
+		conn.WriteMessage(websocket.TextMessage, trade)
+		s.mu.Unlock()
+	}
+}
+
+// decodeFixture decodes the frame in testdata/name the way cli does and
+// returns what the typed parsers get.
+func decodeFixture(t *testing.T, cli *Client, name string) map[string]interface{} {
+	t.Helper()
+	_, exact, err := cli.decodeFrame(loadBinary(t, name))
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return exact
+}
+
+func TestParseRealtimeTrade(t *testing.T) {
+	at := time.Unix(1760621400, 123456789).UTC()
+	tests := []struct {
+		name   string
+		opts   []Option
+		msg    func(t *testing.T, cli *Client) map[string]interface{}
+		want   Trade
+		wantOK bool
+	}{
+		{
+			name: "REALTIMEの約定を解析すること",
+			msg: func(t *testing.T, cli *Client) map[string]interface{} {
+				return decodeFixture(t, cli, "realtime_trade.json")
+			},
+			want: Trade{Symbol: "AAPL", Price: 187.37, Size: 100, Timestamp: at, RawTimestamp: RawTime{Value: 1760621400123456789, Unit: Nanoseconds},
+				Venue: LookupExchange("Q")},
+			wantOK: true,
+		},
+		{
+			name: "固定小数点で価格を読むこと",
+			opts: []Option{WithFixedPointPrices()},
+			msg: func(t *testing.T, cli *Client) map[string]interface{} {
+				return decodeFixture(t, cli, "realtime_trade.json")
+			},
+			want: Trade{Symbol: "AAPL", Price: 187.37, FixedPrice: 1873700, Size: 100, Timestamp: at, RawTimestamp: RawTime{Value: 1760621400123456789, Unit: Nanoseconds},
+				Venue: LookupExchange("Q")},
+			wantOK: true,
+		},
+		{
+			name: "FINRAに報告された時間外の約定を見分けること",
+			msg: func(t *testing.T, cli *Client) map[string]interface{} {
+				return map[string]interface{}{"topic": "iex:securities:BRK.B", "event": "trade", "payload": map[string]interface{}{
+					"price": 457.12, "size": float64(5), "market_center": "D", "conditions": "@ T",
+				}}
+			},
+			want:   Trade{Symbol: "BRK.B", Price: 457.12, Size: 5, Venue: LookupExchange("D"), Darkpool: true, Extended: true},
+			wantOK: true,
+		},
+		{
+			name: "市場のない約定はダークプールにすること",
+			msg: func(t *testing.T, cli *Client) map[string]interface{} {
+				return map[string]interface{}{"topic": "iex:lobby", "event": "trade", "payload": map[string]interface{}{"symbol": "MSFT", "price": 415.5, "size": float64(10)}}
+			},
+			want:   Trade{Symbol: "MSFT", Price: 415.5, Size: 10, Darkpool: true},
+			wantOK: true,
+		},
+		{
+			name: "気配は約定にしないこと",
+			msg: func(t *testing.T, cli *Client) map[string]interface{} {
+				return decodeFixture(t, cli, "realtime_quote.json")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := New(yourIntrinioAPIUserName, "", REALTIME, tt.opts...)
+			got, ok := parseTrade(REALTIME, tt.msg(t, cli))
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTrade() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseRealtimeSide(t *testing.T) {
+	cli := New(yourIntrinioAPIUserName, "", REALTIME)
+	var book topOfBook
+	bid, ask := book.sides(REALTIME, decodeFixture(t, cli, "realtime_quote.json"))
+	want := &QuoteSide{Symbol: "AAPL", Price: 187.38, Size: 300, Exchange: "P", Venue: LookupExchange("P"),
+		Timestamp: time.Unix(1760621400, 123457001).UTC(), RawTimestamp: RawTime{Value: 1760621400123457001, Unit: Nanoseconds}}
+	if bid != nil || !reflect.DeepEqual(ask, want) {
+		t.Errorf("sides() = %+v %+v, want <nil> %+v", bid, ask, want)
+	}
+	if bid, ask := book.sides(REALTIME, decodeFixture(t, cli, "realtime_trade.json")); bid != nil || ask != nil {
+		t.Errorf("sides() of a trade = %+v %+v, want neither", bid, ask)
+	}
+	if got := Classify(REALTIME, decodeFixture(t, cli, "realtime_quote.json")); got != MessageQuote {
+		t.Errorf("Classify() = %v, want %v", got, MessageQuote)
+	}
+	if got := Classify(REALTIME, decodeFixture(t, cli, "realtime_trade.json")); got != MessageTrade {
+		t.Errorf("Classify() = %v, want %v", got, MessageTrade)
+	}
+}
+
+func TestMakeAuthRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		provider  provider
+		wantQuery string
+		wantBasic bool
+	}{
+		{name: "REALTIMEはAPIキーをクエリで渡すこと", provider: REALTIME, wantQuery: "api_key=my+key"},
+		{name: "DELAYED_SIPはAPIキーをクエリで渡すこと", provider: DELAYED_SIP, wantQuery: "api_key=my+key"},
+		{name: "IEXはベーシック認証にすること", provider: IEX, wantBasic: true},
+		{name: "QUODDはベーシック認証にすること", provider: QUODD, wantBasic: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := makeAuthRequest(tt.provider, makeAuthURL(tt.provider), "my key", "secret")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if q := req.URL.Query().Get("api_key"); (q != "") != (tt.wantQuery != "") || tt.wantQuery != "" && req.URL.Query().Encode() != tt.wantQuery {
+				t.Errorf("query = %q, want %q", req.URL.RawQuery, tt.wantQuery)
+			}
+			if _, _, ok := req.BasicAuth(); ok != tt.wantBasic {
+				t.Errorf("basic auth = %v, want %v", ok, tt.wantBasic)
+			}
+		})
+	}
+}
+
+func TestRealtimeMessages(t *testing.T) {
+	if got, want := makeSoketURL(REALTIME, cRealtimeWebsocketURL, "tok", PhoenixV1), cRealtimeWebsocketURL+"?vsn=1.0.0&token=tok"; got != want {
+		t.Errorf("makeSoketURL() = %q, want %q", got, want)
+	}
+	join := makeJoinMessage(REALTIME, "AAPL")
+	if join["topic"] != "iex:securities:AAPL" || join["event"] != "phx_join" {
+		t.Errorf("makeJoinMessage() = %v", join)
+	}
+	leave := makeLeaveMessage(REALTIME, "$lobby")
+	if leave["topic"] != "iex:lobby" || leave["event"] != "phx_leave" {
+		t.Errorf("makeLeaveMessage() = %v", leave)
+	}
+	if heartbeat := makeHeartbeatMessage(REALTIME); heartbeat["topic"] != "phoenix" || heartbeat["event"] != "heartbeat" {
+		t.Errorf("makeHeartbeatMessage() = %v", heartbeat)
+	}
+}
+
+func TestClientRealtime(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+	server.setReply(realtimeDialect(loadBinary(t, "realtime_trade.json")))
+
+	trades := make(chan Trade, 10)
+	quotes := make(chan map[string]interface{}, 10)
+	sut := server.newClient(REALTIME)
+	sut.OnTrade(func(trade Trade) { trades <- trade })
+	sut.OnQuote(func(quote map[string]interface{}) { quotes <- quote })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+	sut.Join("aapl")
+
+	trade := receive(t, "OnTrade()", trades)
+	if trade.Symbol != "AAPL" || trade.Price != 187.37 || trade.Size != 100 || trade.Venue.Name() != "Nasdaq" {
+		t.Errorf("OnTrade() = %+v, want 100 AAPL at 187.37 on Nasdaq", trade)
+	}
+	if quote := receive(t, "OnQuote()", quotes); quote["event"] != "trade" {
+		t.Errorf("OnQuote() = %v, want the trade", quote)
+	}
+	if !waitUntil(time.Second, func() bool { return sut.Confirmed("AAPL") }) {
+		t.Error("Confirmed(AAPL) = false, want the join answered")
+	}
+	if keys := server.authKeys(); len(keys) == 0 || keys[0] != "user" {
+		t.Errorf("auth api_key = %v, want user", keys)
+	}
+}
+
+func TestClientDelayedSIP(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+	server.setReply(realtimeDialect(loadBinary(t, "realtime_trade.json")))
+
+	trades := make(chan Trade, 10)
+	asks := make(chan QuoteSide, 10)
+	normalized := make(chan NormalizedQuote, 10)
+	messages := make(chan Message, 10)
+	sut := server.newClient(DELAYED_SIP)
+	sut.OnTrade(func(trade Trade) { trades <- trade })
+	sut.OnAsk(func(ask QuoteSide) { asks <- ask })
+	sut.OnNormalizedQuote(func(quote NormalizedQuote) { normalized <- quote })
+	sut.OnMessage(func(msg Message) {
+		if msg.Type == MessageTrade || msg.Type == MessageQuote {
+			messages <- msg
+		}
+	})
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+	sut.Join("aapl")
+
+	if msg := receive(t, "OnMessage()", messages); !msg.Delayed || msg.Provider != DELAYED_SIP {
+		t.Errorf("OnMessage() = %+v, want a delayed DELAYED_SIP message", msg)
+	}
+	trade := receive(t, "OnTrade()", trades)
+	if trade.Symbol != "AAPL" || trade.Price != 187.37 || !trade.Delayed {
+		t.Errorf("OnTrade() = %+v, want a delayed AAPL trade at 187.37", trade)
+	}
+	if quote := receive(t, "OnNormalizedQuote()", normalized); quote.Side != "last" || !quote.Delayed {
+		t.Errorf("OnNormalizedQuote() = %+v, want a delayed last", quote)
+	}
+	if !waitUntil(time.Second, func() bool { return sut.Confirmed("AAPL") }) {
+		t.Error("Confirmed(AAPL) = false, want the join answered")
+	}
+
+	server.broadcastRaw(websocket.TextMessage, loadBinary(t, "realtime_quote.json"))
+	if msg := receive(t, "OnMessage()", messages); msg.Type != MessageQuote || !msg.Delayed {
+		t.Errorf("OnMessage() = %+v, want a delayed quote", msg)
+	}
+	if ask := receive(t, "OnAsk()", asks); ask.Price != 187.38 || !ask.Delayed {
+		t.Errorf("OnAsk() = %+v, want a delayed ask at 187.38", ask)
+	}
+	if keys := server.authKeys(); len(keys) == 0 || keys[0] != "user" {
+		t.Errorf("auth api_key = %v, want user", keys)
+	}
+}
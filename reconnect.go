@@ -0,0 +1,226 @@
+package intriniorealtime
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// OnReconnect registers a callback invoked after the client has transparently
+// re-established the connection. cause is the error that triggered it.
+func (cli *Client) OnReconnect(f func(cause error)) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
+	cli.reconnectHandler = f
+}
+
+func (cli *Client) onReconnect(cause error) {
+	cli.info("reconnected", "cause", cause)
+	cli.handlerMu.RLock()
+	f := cli.reconnectHandler
+	cli.handlerMu.RUnlock()
+	if f != nil {
+		cli.callHandler("OnReconnect", func() { f(cause) })
+	}
+}
+
+// OnReconnectFailed registers a callback invoked when the reconnect policy
+// has run out of attempts. The client is disconnected afterwards.
+func (cli *Client) OnReconnectFailed(f func(err error)) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
+	cli.reconnectFailedHandler = f
+}
+
+func (cli *Client) onReconnectFailed(err error) {
+	cli.logError("reconnect failed", "err", err)
+	cli.handlerMu.RLock()
+	f := cli.reconnectFailedHandler
+	cli.handlerMu.RUnlock()
+	if f != nil {
+		cli.callHandler("OnReconnectFailed", func() { f(err) })
+	}
+}
+
+// ReconnectPolicy controls how the client retries a lost connection.
+type ReconnectPolicy struct {
+	// InitialDelay is the wait before the first attempt.
+	InitialDelay time.Duration
+	// Multiplier grows the wait after every failed attempt.
+	Multiplier float64
+	// MaxDelay caps the wait between attempts.
+	MaxDelay time.Duration
+	// Jitter picks a random wait between zero and the computed delay.
+	Jitter bool
+	// MaxAttempts is the number of attempts before giving up. Zero retries
+	// forever.
+	MaxAttempts int
+	// ResetAfter is how long a connection has to stay up before the backoff
+	// starts over from InitialDelay.
+	ResetAfter time.Duration
+}
+
+// DefaultReconnectPolicy is used unless WithReconnectPolicy is given.
+var DefaultReconnectPolicy = ReconnectPolicy{
+	InitialDelay: time.Second,
+	Multiplier:   2,
+	MaxDelay:     30 * time.Second,
+	Jitter:       true,
+	MaxAttempts:  0,
+	ResetAfter:   time.Minute,
+}
+
+func (p ReconnectPolicy) validate() error {
+	if p.InitialDelay < 0 || p.MaxDelay < 0 || p.ResetAfter < 0 {
+		return fmt.Errorf("reconnect policy durations must not be negative: %+v", p)
+	}
+	if p.Multiplier < 1 {
+		return fmt.Errorf("reconnect policy multiplier must be at least 1: %v", p.Multiplier)
+	}
+	if p.MaxDelay < p.InitialDelay {
+		return fmt.Errorf("reconnect policy max delay %v is shorter than initial delay %v", p.MaxDelay, p.InitialDelay)
+	}
+	if p.MaxAttempts < 0 {
+		return fmt.Errorf("reconnect policy max attempts must not be negative: %d", p.MaxAttempts)
+	}
+	return nil
+}
+
+// delay returns the wait before the given attempt, counted from zero.
+func (p ReconnectPolicy) delay(attempt int) time.Duration {
+	d := float64(p.InitialDelay) * math.Pow(p.Multiplier, float64(attempt))
+	if float64(p.MaxDelay) < d {
+		d = float64(p.MaxDelay)
+	}
+	if p.Jitter && 0 < d {
+		return time.Duration(rand.Int63n(int64(d)))
+	}
+	return time.Duration(d)
+}
+
+// reconnect tears down ws and keeps redialing according to the reconnect
+// policy. It does nothing when ws is no longer the live connection or another
+// goroutine is already replacing it.
+func (cli *Client) reconnect(ws *websocket.Conn, cause error) {
+	cli.mu.Lock()
+	if cli.ws != ws || cli.closing || cli.stopped || cli.reconnecting {
+		cli.mu.Unlock()
+		return
+	}
+	cli.reconnecting = true
+	done := cli.done
+	policy := cli.reconnectPolicy
+	if 0 < policy.ResetAfter && policy.ResetAfter <= time.Since(cli.connectedAt) {
+		cli.backoff = 0
+		cli.authRejects = 0
+	}
+	if isAuthFailure(cause) {
+		cli.authRejects++
+	}
+	rejects := cli.authRejects
+	cli.mu.Unlock()
+	defer func() {
+		cli.mu.Lock()
+		cli.reconnecting = false
+		cli.mu.Unlock()
+	}()
+
+	cli.info("reconnecting", "cause", cause)
+	cli.closeConnection(nil)
+	// A rejected token gets one fresh token; being rejected again right
+	// away means the session itself is refused.
+	if isAuthFailure(cause) && rejects < 2 {
+		cli.invalidateToken()
+	} else if IsFatal(cause) {
+		cli.onReconnectFailed(cause)
+		cli.stop(cause)
+		return
+	}
+	for attempt := 1; ; attempt++ {
+		cli.mu.Lock()
+		delay := policy.delay(cli.backoff)
+		cli.backoff++
+		cli.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-done:
+			timer.Stop()
+			return
+		}
+
+		err := cli.redial()
+		if err != ErrClientClosed {
+			cli.record(TransitionReconnectAttempt, attempt, err)
+		}
+		if err == nil {
+			cli.onReconnect(cause)
+			return
+		}
+		if err == ErrClientClosed {
+			return
+		}
+		cli.onError(err)
+		if IsFatal(err) || 0 < policy.MaxAttempts && policy.MaxAttempts <= attempt {
+			cli.onReconnectFailed(err)
+			cli.stop(err)
+			return
+		}
+	}
+}
+
+// redial opens a fresh connection and rejoins the channels.
+func (cli *Client) redial() error {
+	cli.channelInitialize()
+	if err := cli.dial(context.Background()); err != nil {
+		return err
+	}
+	cli.resubscribe()
+	return nil
+}
+
+func isAuthFailure(err error) bool {
+	if ce, ok := err.(*CloseError); ok {
+		return ce.Code == websocket.ClosePolicyViolation
+	}
+	return err == ErrTokenRejected
+}
+
+// tokenRejected is isTokenRejected for msg, unless msg replies on the topic
+// of a channel that is being or was joined: servers refuse the join of a
+// channel the account may not see as "unauthorized" too, and joinReply
+// reports those.
+func (cli *Client) tokenRejected(msg map[string]interface{}) bool {
+	if !isTokenRejected(cli.provider, msg) {
+		return false
+	}
+	topic, _ := msg["topic"].(string)
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	_, joining := cli.pendingJoins[topic]
+	return !joining && cli.joinRefs[topic] == ""
+}
+
+// isTokenRejected reports whether msg is the server telling us our token is
+// no longer accepted.
+func isTokenRejected(provider provider, msg map[string]interface{}) bool {
+	if !phoenix(provider) || msg["event"] != "phx_reply" {
+		return false
+	}
+	payload, ok := msg["payload"].(map[string]interface{})
+	if !ok || payload["status"] != "error" {
+		return false
+	}
+	response, ok := payload["response"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	reason, _ := response["reason"].(string)
+	return strings.Contains(strings.ToLower(reason), "unauthorized")
+}
@@ -0,0 +1,471 @@
+package intriniorealtime
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestClientReconnectOnAuthFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		kick func(server *fakeServer)
+	}{
+		{
+			name: "ポリシー違反で切断されたら再認証して再接続すること",
+			kick: func(server *fakeServer) {
+				server.kick(websocket.ClosePolicyViolation, "invalid token")
+			},
+		},
+		{
+			name: "IEXからunauthorizedのphx_replyを受け取ったら再認証して再接続すること",
+			kick: func(server *fakeServer) {
+				server.broadcast(map[string]interface{}{
+					"topic": "phoenix",
+					"event": "phx_reply",
+					"payload": map[string]interface{}{
+						"status":   "error",
+						"response": map[string]interface{}{"reason": "unauthorized"},
+					},
+					"ref": nil,
+				})
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+
+			reconnected := make(chan error, 1)
+			sut := server.newClient(IEX)
+			sut.OnReconnect(func(cause error) {
+				reconnected <- cause
+			})
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			defer sut.Disconnect()
+			sut.Join("AAPL")
+			if !waitUntil(time.Second, func() bool { return len(server.messagesWithEvent("phx_join")) == 1 }) {
+				t.Fatal("join was not sent")
+			}
+
+			tt.kick(server)
+			select {
+			case cause := <-reconnected:
+				if !isAuthFailure(cause) {
+					t.Errorf("OnReconnect() cause = %v", cause)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("client did not reconnect")
+			}
+			if got := server.authCount(); got != 2 {
+				t.Errorf("auth calls = %d, want 2", got)
+			}
+			if !waitUntil(time.Second, func() bool { return len(server.messagesWithEvent("phx_join")) == 2 }) {
+				t.Error("channels were not rejoined")
+			}
+			if !sut.Connected() {
+				t.Error("Connected() = false after reconnect")
+			}
+			select {
+			case <-sut.Done():
+				t.Error("Done() closed after reconnect")
+			default:
+			}
+		})
+	}
+}
+
+func TestReconnectPolicyDelay(t *testing.T) {
+	policy := ReconnectPolicy{
+		InitialDelay: 100 * time.Millisecond,
+		Multiplier:   2,
+		MaxDelay:     time.Second,
+	}
+	tests := []struct {
+		name    string
+		attempt int
+		jitter  bool
+		want    time.Duration
+	}{
+		{name: "最初の試行はInitialDelayだけ待つこと", attempt: 0, want: 100 * time.Millisecond},
+		{name: "試行ごとにMultiplier倍になること", attempt: 3, want: 800 * time.Millisecond},
+		{name: "MaxDelayを超えないこと", attempt: 10, want: time.Second},
+		{name: "ジッターありのときは0からdelayの間になること", attempt: 2, jitter: true, want: 400 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := policy
+			p.Jitter = tt.jitter
+			got := p.delay(tt.attempt)
+			if !tt.jitter && got != tt.want {
+				t.Errorf("delay(%d) = %v, want %v", tt.attempt, got, tt.want)
+			}
+			if tt.jitter && (got < 0 || tt.want <= got) {
+				t.Errorf("delay(%d) = %v, want [0, %v)", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithReconnectPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  ReconnectPolicy
+		wantErr bool
+	}{
+		{name: "デフォルトのポリシーが指定できること", policy: DefaultReconnectPolicy, wantErr: false},
+		{name: "Multiplierが1未満のときはエラーになること", policy: ReconnectPolicy{Multiplier: 0.5}, wantErr: true},
+		{name: "MaxDelayがInitialDelayより短いときはエラーになること", policy: ReconnectPolicy{InitialDelay: time.Second, Multiplier: 1}, wantErr: true},
+		{name: "MaxAttemptsが負のときはエラーになること", policy: ReconnectPolicy{Multiplier: 1, MaxAttempts: -1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := New(yourIntrinioAPIUserName, yourIntrinioAPIPassword, IEX, WithReconnectPolicy(tt.policy))
+			if (sut.optionErr != nil) != tt.wantErr {
+				t.Errorf("WithReconnectPolicy() error = %v, wantErr %v", sut.optionErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClientReconnectFailed(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	policy := fastReconnect
+	policy.MaxAttempts = 3
+	failed := make(chan error, 1)
+	sut := server.newClient(QUODD, WithReconnectPolicy(policy))
+	sut.OnReconnectFailed(func(err error) {
+		failed <- err
+	})
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	server.setAuthStatus(http.StatusServiceUnavailable)
+	server.kick(websocket.ClosePolicyViolation, "invalid token")
+	select {
+	case err := <-failed:
+		if err == nil {
+			t.Error("OnReconnectFailed() err = nil")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnReconnectFailed() was not called")
+	}
+	// Every reconnect attempt retries the 503 before giving up.
+	if want := 1 + policy.MaxAttempts*fastAuthRetry.MaxAttempts; server.authCount() != want {
+		t.Errorf("auth calls = %d, want %d", server.authCount(), want)
+	}
+	select {
+	case <-sut.Done():
+	case <-time.After(time.Second):
+		t.Error("Done() was not closed after the reconnect budget was exhausted")
+	}
+}
+
+func TestClientReconnectBackoffReset(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		resetAfter  time.Duration
+		wantBackoff int
+	}{
+		{name: "接続が十分続いたらバックオフがリセットされること", resetAfter: time.Nanosecond, wantBackoff: 1},
+		{name: "接続がすぐに切れるとバックオフが積み上がること", resetAfter: time.Hour, wantBackoff: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := fastReconnect
+			policy.ResetAfter = tt.resetAfter
+			reconnected := make(chan struct{}, 3)
+			sut := server.newClient(QUODD, WithReconnectPolicy(policy))
+			sut.OnReconnect(func(error) { reconnected <- struct{}{} })
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			defer sut.Disconnect()
+			for i := 0; i < 3; i++ {
+				server.kick(websocket.CloseGoingAway, "restart")
+				select {
+				case <-reconnected:
+				case <-time.After(5 * time.Second):
+					t.Fatal("client did not reconnect")
+				}
+			}
+			sut.mu.Lock()
+			got := sut.backoff
+			sut.mu.Unlock()
+			if got != tt.wantBackoff {
+				t.Errorf("backoff = %d, want %d", got, tt.wantBackoff)
+			}
+		})
+	}
+}
+
+func TestClientCloseError(t *testing.T) {
+	tests := []struct {
+		name string
+		code int
+	}{
+		{name: "正常なクローズでもCloseErrorが通知されること", code: websocket.CloseNormalClosure},
+		{name: "GoingAwayでもCloseErrorが通知されること", code: websocket.CloseGoingAway},
+		{name: "TryAgainLaterのコードが通知されること", code: websocket.CloseTryAgainLater},
+		{name: "PolicyViolationのコードが通知されること", code: websocket.ClosePolicyViolation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+
+			closed := make(chan *CloseError, 1)
+			sut := server.newClient(QUODD)
+			sut.OnError(func(err error) {
+				if ce, ok := err.(*CloseError); ok {
+					select {
+					case closed <- ce:
+					default:
+					}
+				}
+			})
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			defer sut.Disconnect()
+
+			server.kick(tt.code, "bye")
+			select {
+			case ce := <-closed:
+				if ce.Code != tt.code || ce.Text != "bye" || !ce.ByServer {
+					t.Errorf("CloseError = %+v, want code %d from server", ce, tt.code)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("CloseError was not reported")
+			}
+		})
+	}
+}
+
+func TestNewDefaultReconnectPolicy(t *testing.T) {
+	sut := New(yourIntrinioAPIUserName, yourIntrinioAPIPassword, IEX)
+	if sut.reconnectPolicy != DefaultReconnectPolicy {
+		t.Errorf("reconnectPolicy = %+v, want %+v", sut.reconnectPolicy, DefaultReconnectPolicy)
+	}
+}
+
+func TestClientJoinWhileReconnecting(t *testing.T) {
+	tests := []struct {
+		name  string
+		join  []string
+		leave []string
+		want  []string
+	}{
+		{
+			name: "再接続中にJoinしたチャンネルが再接続後に購読されること",
+			join: []string{"MSFT.NB"},
+			want: []string{"AAPL.NB", "MSFT.NB"},
+		},
+		{
+			name:  "再接続中にLeaveしたチャンネルは再接続後に購読されないこと",
+			leave: []string{"AAPL.NB"},
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+
+			reconnected := make(chan struct{}, 1)
+			sut := server.newClient(QUODD)
+			sut.OnReconnect(func(error) { reconnected <- struct{}{} })
+			sut.Join("AAPL.NB")
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			defer sut.Disconnect()
+			if !waitUntil(time.Second, func() bool { return len(server.subscribedTickers()) == 1 }) {
+				t.Fatal("join was not sent")
+			}
+
+			server.setAuthStatus(http.StatusServiceUnavailable)
+			server.kick(websocket.ClosePolicyViolation, "invalid token")
+			if !waitUntil(5*time.Second, func() bool { return 3 <= server.authCount() }) {
+				t.Fatal("client did not try to reconnect")
+			}
+			if sut.Connected() {
+				t.Fatal("Connected() = true while the server is down")
+			}
+			sut.Join(tt.join...)
+			sut.Leave(tt.leave...)
+			server.setAuthStatus(0)
+
+			select {
+			case <-reconnected:
+			case <-time.After(5 * time.Second):
+				t.Fatal("client did not reconnect")
+			}
+			var got []string
+			waitUntil(time.Second, func() bool {
+				got = append([]string(nil), server.subscribedTickers()[1:]...)
+				return len(tt.want) <= len(got)
+			})
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("subscribed after reconnect = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientConnectionLost(t *testing.T) {
+	tests := []struct {
+		name          string
+		drop          func(server *fakeServer)
+		opts          []Option
+		wantErr       func(err error) bool
+		wantReconnect bool
+	}{
+		{
+			name: "GoingAwayで切断されたら通知して再接続すること",
+			drop: func(server *fakeServer) { server.kick(websocket.CloseGoingAway, "restart") },
+			wantErr: func(err error) bool {
+				ce, ok := err.(*CloseError)
+				return ok && ce.Code == websocket.CloseGoingAway && ce.ByServer
+			},
+			wantReconnect: true,
+		},
+		{
+			name: "クローズフレームなしで切断されたら通知して再接続すること",
+			drop: func(server *fakeServer) {
+				for _, conn := range server.connections() {
+					conn.Close()
+				}
+			},
+			wantErr: func(err error) bool {
+				ce, ok := err.(*CloseError)
+				return ok && ce.Code == websocket.CloseAbnormalClosure
+			},
+			wantReconnect: true,
+		},
+		{
+			name:          "再接続しない設定のときは通知して切断すること",
+			drop:          func(server *fakeServer) { server.kick(websocket.CloseGoingAway, "restart") },
+			opts:          []Option{WithoutReconnect()},
+			wantErr:       func(err error) bool { _, ok := err.(*CloseError); return ok },
+			wantReconnect: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+
+			errs := make(chan error, 10)
+			reconnected := make(chan error, 1)
+			sut := server.newClient(QUODD, tt.opts...)
+			sut.OnError(func(err error) { errs <- err })
+			sut.OnReconnect(func(cause error) { reconnected <- cause })
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			defer sut.Disconnect()
+
+			tt.drop(server)
+			select {
+			case err := <-errs:
+				if !tt.wantErr(err) {
+					t.Errorf("OnError() err = %#v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("OnError() was not called")
+			}
+			if tt.wantReconnect {
+				select {
+				case cause := <-reconnected:
+					if !tt.wantErr(cause) {
+						t.Errorf("OnReconnect() cause = %#v", cause)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatal("client did not reconnect")
+				}
+				return
+			}
+			select {
+			case <-sut.Done():
+			case <-time.After(5 * time.Second):
+				t.Fatal("Done() was not closed")
+			}
+		})
+	}
+}
+
+func TestClientReconnectFatal(t *testing.T) {
+	tests := []struct {
+		name          string
+		prepare       func(server *fakeServer)
+		kicks         int
+		wantAuthCalls int
+	}{
+		{
+			name:          "再接続中に認証APIが401を返したらすぐにあきらめること",
+			prepare:       func(server *fakeServer) { server.setAuthStatus(http.StatusUnauthorized) },
+			kicks:         1,
+			wantAuthCalls: 2,
+		},
+		{
+			name:          "新しいトークンでもポリシー違反で切断されたらあきらめること",
+			prepare:       func(server *fakeServer) {},
+			kicks:         2,
+			wantAuthCalls: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+
+			failed := make(chan error, 1)
+			sut := server.newClient(QUODD)
+			sut.OnReconnectFailed(func(err error) { failed <- err })
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			defer sut.Disconnect()
+
+			tt.prepare(server)
+			for i := 0; i < tt.kicks; i++ {
+				if !waitUntil(5*time.Second, func() bool { return len(server.connections()) == 1 }) {
+					t.Fatal("client did not reconnect")
+				}
+				server.kick(websocket.ClosePolicyViolation, "subscription expired")
+			}
+			select {
+			case err := <-failed:
+				if !IsFatal(err) {
+					t.Errorf("OnReconnectFailed() err = %v, want a fatal error", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("OnReconnectFailed() was not called")
+			}
+			select {
+			case <-sut.Done():
+			case <-time.After(time.Second):
+				t.Error("Done() was not closed")
+			}
+			if got := server.authCount(); got != tt.wantAuthCalls {
+				t.Errorf("auth calls = %d, want %d", got, tt.wantAuthCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package intriniorealtime
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"github.com/gorilla/websocket"
+)
+
+// maxReplayLine is the longest recorded frame NewReplay reads.
+const maxReplayLine = 16 << 20
+
+// NewReplay returns a client that plays back a recorded session of provider
+// instead of connecting: r holds the text frames the server sent, one per
+// line, as OnRawMessage gets them. Connect starts the playback, and every
+// frame goes through the same decoding and dispatch as live data, so the
+// handlers see what they would have seen then. Join and Leave only choose
+// the channels whose messages are delivered; join them before Connect.
+// Messages that aren't about a channel, such as replies, are delivered
+// regardless. At the end of r the client disconnects with io.EOF, see
+// OnDisconnect.
+func NewReplay(r io.Reader, provider provider, opts ...Option) *Client {
+	cli := New("", "", provider, opts...)
+	cli.replay = r
+	return cli
+}
+
+// startReplay plays back the recording on a goroutine of its own.
+func (cli *Client) startReplay() {
+	cli.info("replay started")
+	cli.startDispatcher()
+	go cli.runReplay(cli.Done())
+}
+
+// runReplay passes the recorded frames on until the recording ends, a frame
+// can't be handled or the client is disconnected, which closes done.
+func (cli *Client) runReplay(done <-chan struct{}) {
+	scanner := bufio.NewScanner(cli.replay)
+	scanner.Buffer(nil, maxReplayLine)
+	for scanner.Scan() {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		// The scanner reuses its buffer, but OnRawMessage may keep data.
+		data := append([]byte(nil), line...)
+		receivedAt := cli.now()
+		cli.touch()
+		if err := cli.handleFrame(websocket.TextMessage, data, receivedAt); err != nil {
+			cli.onError(err)
+			cli.stop(err)
+			return
+		}
+	}
+	err := scanner.Err()
+	if err == nil {
+		err = io.EOF
+	}
+	cli.info("replay ended", "err", err)
+	cli.stop(err)
+}
+
+// replayJoined reports whether a replayed message is about a joined
+// channel, or about none at all.
+func (cli *Client) replayJoined(msg map[string]interface{}) bool {
+	var channels []string
+	if symbol, ok := SymbolFromMessage(cli.provider, msg); ok {
+		channels = append(channels, symbol)
+		if Classify(cli.provider, msg) == MessageDepth {
+			channels = append(channels, DepthChannel(symbol))
+		}
+	}
+	if topic, _ := msg["topic"].(string); topicChannel(topic) != "" {
+		channels = append(channels, topicChannel(topic))
+	}
+	if len(channels) == 0 {
+		return true
+	}
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	for _, channel := range channels {
+		if 0 < cli.channels[normalizeChannel(channel)] {
+			return true
+		}
+	}
+	return false
+}
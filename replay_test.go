@@ -0,0 +1,98 @@
+package intriniorealtime
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplay(t *testing.T) {
+	tests := []struct {
+		name       string
+		channels   []string
+		wantQuotes []string
+		wantTrades []string
+	}{
+		{
+			name:       "参加したシンボルだけを配信すること",
+			channels:   []string{"aapl"},
+			wantQuotes: []string{"bid", "last", "ask"},
+			wantTrades: []string{"AAPL"},
+		},
+		{
+			name:       "複数のシンボルを配信すること",
+			channels:   []string{"AAPL", "MSFT"},
+			wantQuotes: []string{"bid", "last", "last", "ask"},
+			wantTrades: []string{"MSFT", "AAPL"},
+		},
+		{
+			name: "何も参加しなければ配信しないこと",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := NewReplay(bytes.NewReader(loadBinary(t, "iex_session.jsonl")), IEX)
+			var quotes, trades []string
+			disconnected := make(chan error, 1)
+			sut.OnQuote(func(q map[string]interface{}) {
+				payload, _ := q["payload"].(map[string]interface{})
+				typ, _ := payload["type"].(string)
+				quotes = append(quotes, typ)
+			})
+			sut.OnTrade(func(trade Trade) { trades = append(trades, trade.Symbol) })
+			sut.OnDisconnect(func(cause error) { disconnected <- cause })
+			sut.Join(tt.channels...)
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("Connect() error = %v", err)
+			}
+
+			if cause := receive(t, "OnDisconnect()", disconnected); cause != io.EOF {
+				t.Errorf("OnDisconnect() cause = %v, want io.EOF", cause)
+			}
+			if strings.Join(quotes, ",") != strings.Join(tt.wantQuotes, ",") {
+				t.Errorf("OnQuote() got %v, want %v", quotes, tt.wantQuotes)
+			}
+			if strings.Join(trades, ",") != strings.Join(tt.wantTrades, ",") {
+				t.Errorf("OnTrade() got %v, want %v", trades, tt.wantTrades)
+			}
+			select {
+			case <-sut.Done():
+			case <-time.After(time.Second):
+				t.Error("Done() not closed after the end of the replay")
+			}
+			if sut.Connected() {
+				t.Error("Connected() = true, want a replay never to connect")
+			}
+		})
+	}
+}
+
+func TestReplayDisconnect(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	sut := NewReplay(r, IEX)
+	quotes := make(chan map[string]interface{}, 10)
+	disconnected := make(chan error, 10)
+	sut.OnQuote(func(q map[string]interface{}) { quotes <- q })
+	sut.OnDisconnect(func(cause error) { disconnected <- cause })
+	sut.Join("AAPL")
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	go w.Write([]byte(`{"topic":"iex:securities:AAPL","event":"quote","ref":null,"payload":{"type":"bid","ticker":"AAPL","size":1,"price":1}}` + "\n"))
+	receive(t, "OnQuote()", quotes)
+
+	sut.Disconnect()
+	if cause := receive(t, "OnDisconnect()", disconnected); cause != nil {
+		t.Errorf("OnDisconnect() cause = %v, want nil after Disconnect", cause)
+	}
+	w.Close()
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case cause := <-disconnected:
+		t.Errorf("OnDisconnect() called again with %v, want once", cause)
+	default:
+	}
+}
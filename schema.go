@@ -0,0 +1,297 @@
+package intriniorealtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// fieldKind is the JSON type a field of a message has to have.
+type fieldKind int
+
+const (
+	kindString fieldKind = iota
+	kindNumber
+	kindBool
+	kindObject
+	kindCount
+)
+
+func (k fieldKind) String() string {
+	switch k {
+	case kindString:
+		return "a string"
+	case kindNumber:
+		return "a number"
+	case kindBool:
+		return "a boolean"
+	case kindCount:
+		return "a whole number of at least zero"
+	default:
+		return "an object"
+	}
+}
+
+// payloadField is a field of a payload. Only those marked required have to
+// be there.
+type payloadField struct {
+	name     string
+	kind     fieldKind
+	required bool
+}
+
+// iexQuoteFields are the fields of an IEX quote payload.
+var iexQuoteFields = []payloadField{
+	{"ticker", kindString, true},
+	{"type", kindString, true},
+	{"price", kindNumber, true},
+	{"size", kindCount, true},
+	{"timestamp", kindNumber, false},
+	{"conditions", kindNumber, false},
+}
+
+// realtimeTradeFields and realtimeQuoteFields are the fields of the payload
+// of REALTIME and DELAYED_SIP trades and quotes.
+var realtimeTradeFields = []payloadField{
+	{"symbol", kindString, false},
+	{"price", kindNumber, true},
+	{"size", kindCount, true},
+	{"total_volume", kindCount, false},
+	{"timestamp", kindNumber, false},
+	{"market_center", kindString, false},
+	{"conditions", kindString, false},
+}
+
+// nasdaqBasicQuoteFields are the fields of the payload of NASDAQ_BASIC
+// quotes, which carry both sides; its trades are REALTIME's.
+var nasdaqBasicQuoteFields = []payloadField{
+	{"symbol", kindString, false},
+	{"bid_price", kindNumber, false},
+	{"bid_size", kindCount, false},
+	{"ask_price", kindNumber, false},
+	{"ask_size", kindCount, false},
+	{"timestamp", kindNumber, false},
+}
+
+// optionTradeFields and optionQuoteFields are the fields of the payload of
+// OPRA trades and quotes.
+var optionTradeFields = []payloadField{
+	{"contract", kindString, true},
+	{"price", kindNumber, true},
+	{"size", kindCount, true},
+	{"total_volume", kindCount, false},
+	{"open_interest", kindCount, false},
+	{"timestamp", kindNumber, false},
+}
+
+var optionQuoteFields = []payloadField{
+	{"contract", kindString, true},
+	{"bid_price", kindNumber, false},
+	{"bid_size", kindCount, false},
+	{"ask_price", kindNumber, false},
+	{"ask_size", kindCount, false},
+	{"open_interest", kindCount, false},
+	{"timestamp", kindNumber, false},
+}
+
+// cryptoTradeFields and cryptoBookFields are the fields of the payload of
+// CRYPTOQUOTE trades and order book updates. Sizes are fractions of the base
+// currency.
+var cryptoTradeFields = []payloadField{
+	{"pair", kindString, true},
+	{"exchange", kindString, false},
+	{"price", kindNumber, true},
+	{"size", kindNumber, true},
+	{"side", kindString, false},
+	{"timestamp", kindNumber, false},
+}
+
+var cryptoBookFields = []payloadField{
+	{"pair", kindString, true},
+	{"exchange", kindString, false},
+	{"side", kindString, true},
+	{"price", kindNumber, true},
+	{"size", kindNumber, true},
+	{"timestamp", kindNumber, false},
+}
+
+// fxQuoteFields are the fields of the payload of FXCM price updates.
+var fxQuoteFields = []payloadField{
+	{"code", kindString, true},
+	{"bid_price", kindNumber, true},
+	{"ask_price", kindNumber, true},
+	{"timestamp", kindNumber, false},
+}
+
+var realtimeQuoteFields = []payloadField{
+	{"symbol", kindString, false},
+	{"type", kindString, true},
+	{"price", kindNumber, true},
+	{"size", kindCount, true},
+	{"timestamp", kindNumber, false},
+	{"market_center", kindString, false},
+}
+
+// payloadFields returns the fields of the payload of the event of a
+// provider that speaks phoenix, nil for the events without a known payload.
+func payloadFields(provider provider, event string) []payloadField {
+	switch {
+	case provider == IEX && event == "quote":
+		return iexQuoteFields
+	case (realtimeFeed(provider) || provider == NASDAQ_BASIC) && event == "trade":
+		return realtimeTradeFields
+	case provider == NASDAQ_BASIC && event == "quote":
+		return nasdaqBasicQuoteFields
+	case provider == OPRA && event == "trade":
+		return optionTradeFields
+	case provider == OPRA && event == "quote":
+		return optionQuoteFields
+	case provider == CRYPTOQUOTE && event == "trade":
+		return cryptoTradeFields
+	case provider == CRYPTOQUOTE && event == "book_update":
+		return cryptoBookFields
+	case provider == FXCM && event == "price_update":
+		return fxQuoteFields
+	case realtimeFeed(provider) && event == "quote":
+		return realtimeQuoteFields
+	}
+	return nil
+}
+
+// quoddFieldKind returns the type of a QUODD data field by its name. QUODD
+// only sends the fields that changed, so none but the ticker is required,
+// and fields it doesn't know are let through.
+func quoddFieldKind(name string) (fieldKind, bool) {
+	switch {
+	case strings.HasPrefix(name, "is_"):
+		return kindBool, true
+	case countField(name):
+		return kindCount, true
+	case strings.HasSuffix(name, "_4d"), strings.HasSuffix(name, "_time"), name == "protocol_id", name == "rtl", name == "level":
+		return kindNumber, true
+	case strings.HasSuffix(name, "_exchange"), strings.HasSuffix(name, "up_down"), name == "root_ticker",
+		name == "market_maker", name == "side", name == "status", name == "reason":
+		return kindString, true
+	}
+	return 0, false
+}
+
+// checkSchema returns why msg doesn't look like a message of provider, or
+// nil when it does. It is what WithStrictDecoding checks.
+func checkSchema(provider provider, msg map[string]interface{}) error {
+	if msg == nil {
+		return fmt.Errorf("not a JSON object")
+	}
+	event, err := requiredString(msg, "event")
+	if err != nil {
+		return err
+	}
+	switch provider {
+	case IEX, REALTIME, DELAYED_SIP, NASDAQ_BASIC, OPRA, CRYPTOQUOTE, FXCM:
+		if _, err := requiredString(msg, "topic"); err != nil {
+			return err
+		}
+		fields := payloadFields(provider, event)
+		switch {
+		case fields != nil:
+			payload, err := requiredObject(msg, "payload")
+			if err != nil {
+				return err
+			}
+			for _, f := range fields {
+				if err := checkField(payload, "payload."+f.name, f.name, f.kind, f.required); err != nil {
+					return err
+				}
+			}
+		case event == "phx_reply":
+			payload, err := requiredObject(msg, "payload")
+			if err != nil {
+				return err
+			}
+			return checkField(payload, "payload.status", "status", kindString, true)
+		default:
+			return checkField(msg, "payload", "payload", kindObject, false)
+		}
+	case QUODD:
+		switch event {
+		case "quote", "quote_data", "trade", "trade_data", "depth", "status", "luld":
+			data, err := requiredObject(msg, "data")
+			if err != nil {
+				return err
+			}
+			if err := checkField(data, "data.ticker", "ticker", kindString, true); err != nil {
+				return err
+			}
+			for name := range data {
+				if kind, ok := quoddFieldKind(name); ok {
+					if err := checkField(data, "data."+name, name, kind, false); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// isCount reports whether v is a JSON number that can be a number of shares.
+// Sizes decoded as json.Number are checked from their text, so that a
+// fraction too small for a float64 is still caught.
+func isCount(v interface{}) bool {
+	n, ok := v.(json.Number)
+	if !ok {
+		f, ok := v.(float64)
+		return ok && 0 <= f && f == math.Trunc(f)
+	}
+	r, ok := new(big.Rat).SetString(n.String())
+	return ok && r.IsInt() && 0 <= r.Sign()
+}
+
+func requiredString(msg map[string]interface{}, key string) (string, error) {
+	if err := checkField(msg, key, key, kindString, true); err != nil {
+		return "", err
+	}
+	s := msg[key].(string)
+	if s == "" {
+		return "", fmt.Errorf("%q is empty", key)
+	}
+	return s, nil
+}
+
+func requiredObject(msg map[string]interface{}, key string) (map[string]interface{}, error) {
+	if err := checkField(msg, key, key, kindObject, true); err != nil {
+		return nil, err
+	}
+	return msg[key].(map[string]interface{}), nil
+}
+
+// checkField checks the type of obj[key]; path names it in the error. A
+// null counts as missing.
+func checkField(obj map[string]interface{}, path, key string, kind fieldKind, required bool) error {
+	v, ok := obj[key]
+	if !ok || v == nil {
+		if required {
+			return fmt.Errorf("missing %q", path)
+		}
+		return nil
+	}
+	var valid bool
+	switch kind {
+	case kindString:
+		_, valid = v.(string)
+	case kindNumber:
+		_, valid = number(v)
+	case kindBool:
+		_, valid = v.(bool)
+	case kindObject:
+		_, valid = v.(map[string]interface{})
+	case kindCount:
+		valid = isCount(v)
+	}
+	if !valid {
+		return fmt.Errorf("%q is not %s: %v", path, kind, v)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package intriniorealtime
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestCheckSchema(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider provider
+		frame    string
+		wantErr  bool
+	}{
+		{name: "IEXのクォートは通すこと", provider: IEX, frame: `{"topic":"iex:securities:GE","event":"quote","payload":{"type":"ask","timestamp":1493409509.3932788,"ticker":"GE","size":13750,"price":28.97},"ref":null}`},
+		{name: "IEXの応答は通すこと", provider: IEX, frame: `{"topic":"phoenix","event":"phx_reply","payload":{"status":"ok","response":{}},"ref":null}`},
+		{name: "IEXのその他のイベントは通すこと", provider: IEX, frame: `{"topic":"iex:lobby","event":"presence_state","payload":{}}`},
+		{name: "IEXのタイムスタンプはなくてもよいこと", provider: IEX, frame: `{"topic":"iex:securities:GE","event":"quote","payload":{"type":"last","ticker":"GE","size":1,"price":1}}`},
+		{name: "JSONのnullはエラーにすること", provider: IEX, frame: `null`, wantErr: true},
+		{name: "eventがなければエラーにすること", provider: IEX, frame: `{"topic":"iex:securities:GE","payload":{}}`, wantErr: true},
+		{name: "eventが文字列でなければエラーにすること", provider: IEX, frame: `{"topic":"iex:securities:GE","event":1}`, wantErr: true},
+		{name: "eventが空ならエラーにすること", provider: IEX, frame: `{"topic":"iex:securities:GE","event":""}`, wantErr: true},
+		{name: "IEXでtopicがなければエラーにすること", provider: IEX, frame: `{"event":"quote","payload":{"type":"ask","ticker":"GE","size":1,"price":1}}`, wantErr: true},
+		{name: "IEXのクォートにpayloadがなければエラーにすること", provider: IEX, frame: `{"topic":"iex:securities:GE","event":"quote"}`, wantErr: true},
+		{name: "IEXのpayloadがオブジェクトでなければエラーにすること", provider: IEX, frame: `{"topic":"iex:securities:GE","event":"quote","payload":"GE 28.97"}`, wantErr: true},
+		{name: "IEXのその他のイベントでもpayloadの型は確かめること", provider: IEX, frame: `{"topic":"iex:lobby","event":"presence_state","payload":[1]}`, wantErr: true},
+		{name: "IEXのクォートにtickerがなければエラーにすること", provider: IEX, frame: `{"topic":"iex:securities:GE","event":"quote","payload":{"type":"ask","size":1,"price":1}}`, wantErr: true},
+		{name: "IEXの価格が文字列ならエラーにすること", provider: IEX, frame: `{"topic":"iex:securities:GE","event":"quote","payload":{"type":"ask","ticker":"GE","size":1,"price":"28.97"}}`, wantErr: true},
+		{name: "IEXの数量がnullならエラーにすること", provider: IEX, frame: `{"topic":"iex:securities:GE","event":"quote","payload":{"type":"ask","ticker":"GE","size":null,"price":1}}`, wantErr: true},
+		{name: "IEXのタイムスタンプが数値でなければエラーにすること", provider: IEX, frame: `{"topic":"iex:securities:GE","event":"quote","payload":{"type":"ask","ticker":"GE","size":1,"price":1,"timestamp":true}}`, wantErr: true},
+		{name: "IEXの数量が負ならエラーにすること", provider: IEX, frame: `{"topic":"iex:securities:GE","event":"quote","payload":{"type":"ask","ticker":"GE","size":-100,"price":1}}`, wantErr: true},
+		{name: "IEXの数量が小数ならエラーにすること", provider: IEX, frame: `{"topic":"iex:securities:GE","event":"quote","payload":{"type":"ask","ticker":"GE","size":100.5,"price":1}}`, wantErr: true},
+		{name: "IEXの応答にstatusがなければエラーにすること", provider: IEX, frame: `{"topic":"phoenix","event":"phx_reply","payload":{}}`, wantErr: true},
+		{name: "QUODDのクォートは通すこと", provider: QUODD, frame: `{"event":"quote","data":{"ticker":"AAPL.NB","bid_size":500,"bid_price_4d":1594800,"bid_exchange":"t","quote_time":1508165070850,"rtl":1}}`},
+		{name: "QUODDのトレードは通すこと", provider: QUODD, frame: `{"event":"trade_data","data":{"ticker":"AAPL.NB","last_price_4d":1594900,"up_down":"^","is_halted":false}}`},
+		{name: "QUODDの板情報は通すこと", provider: QUODD, frame: `{"event":"depth","data":{"ticker":"AAPL.NB","side":"b","level":1,"price_4d":1594800,"market_maker":"NSDQ"}}`},
+		{name: "QUODDのハートビートは通すこと", provider: QUODD, frame: `{"event":"heartbeat","data":{"action":"heartbeat"}}`},
+		{name: "QUODDの知らない項目は通すこと", provider: QUODD, frame: `{"event":"quote","data":{"ticker":"AAPL.NB","new_field":[1,2]}}`},
+		{name: "QUODDでeventがなければエラーにすること", provider: QUODD, frame: `{"data":{"ticker":"AAPL.NB"}}`, wantErr: true},
+		{name: "QUODDのクォートにdataがなければエラーにすること", provider: QUODD, frame: `{"event":"quote"}`, wantErr: true},
+		{name: "QUODDのdataがオブジェクトでなければエラーにすること", provider: QUODD, frame: `{"event":"trade","data":[1594900]}`, wantErr: true},
+		{name: "QUODDのクォートにtickerがなければエラーにすること", provider: QUODD, frame: `{"event":"quote","data":{"bid_size":500}}`, wantErr: true},
+		{name: "QUODDの価格が文字列ならエラーにすること", provider: QUODD, frame: `{"event":"quote","data":{"ticker":"AAPL.NB","bid_price_4d":"1594800"}}`, wantErr: true},
+		{name: "QUODDの取引所が数値ならエラーにすること", provider: QUODD, frame: `{"event":"trade","data":{"ticker":"AAPL.NB","trade_exchange":7}}`, wantErr: true},
+		{name: "QUODDの真偽値が文字列ならエラーにすること", provider: QUODD, frame: `{"event":"trade","data":{"ticker":"AAPL.NB","is_halted":"false"}}`, wantErr: true},
+		{name: "QUODDの出来高が負ならエラーにすること", provider: QUODD, frame: `{"event":"trade","data":{"ticker":"AAPL.NB","total_volume":-1}}`, wantErr: true},
+		{name: "QUODDの板の数量が小数ならエラーにすること", provider: QUODD, frame: `{"event":"depth","data":{"ticker":"AAPL.NB","size":0.5}}`, wantErr: true},
+		{name: "QUODDの時刻が文字列ならエラーにすること", provider: QUODD, frame: `{"event":"trade","data":{"ticker":"AAPL.NB","trade_time":"1508165070052"}}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var msg map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.frame), &msg); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+			err := checkSchema(tt.provider, msg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkSchema() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckSchemaFixtures(t *testing.T) {
+	for _, f := range []struct {
+		provider provider
+		name     string
+	}{
+		{IEX, "iex_quote.json"},
+		{IEX, "iex_join_ok.json"},
+		{IEX, "iex_join_error.json"},
+		{QUODD, "quodd_quote.json"},
+		{QUODD, "quodd_quote_partial.json"},
+		{QUODD, "quodd_trade.json"},
+		{QUODD, "quodd_trade_partial.json"},
+	} {
+		if err := checkSchema(f.provider, loadFixture(t, f.name)); err != nil {
+			t.Errorf("checkSchema(%s) error = %v", f.name, err)
+		}
+	}
+}
+
+func TestClientStrictDecoding(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	errs := make(chan error, 10)
+	quotes := make(chan map[string]interface{}, 10)
+	sut := server.newClient(QUODD, WithStrictDecoding())
+	sut.OnError(func(err error) { errs <- err })
+	sut.OnQuote(func(quote map[string]interface{}) { quotes <- quote })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	broken := []string{
+		`{"event":"quote","data":{"ticker":"AAPL.NB","bid_price_4d":"oops"}}`,
+		`{"event":"quote","data":{"ticker":"AAPL.NB","bid_size":-500}}`,
+		`{"event":"trade","data":{"ticker":"AAPL.NB","total_volume":9007199254740993.5}}`,
+		`{"event":"quote","data":`,
+	}
+	for _, frame := range broken {
+		server.broadcastRaw(websocket.TextMessage, []byte(frame))
+	}
+	server.broadcast(loadFixture(t, "quodd_quote.json"))
+	for _, frame := range broken {
+		select {
+		case err := <-errs:
+			var me *MalformedMessageError
+			if !errors.As(err, &me) || string(me.Data) != frame {
+				t.Errorf("OnError() error = %v, want a *MalformedMessageError with %s", err, frame)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("OnError() was not called for %s", frame)
+		}
+	}
+	select {
+	case quote := <-quotes:
+		if ticker := quote["data"].(map[string]interface{})["ticker"]; ticker != "AAPL.NB" {
+			t.Errorf("OnQuote() = %v, want the well-formed quote", quote)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnQuote() was not called after the malformed frames")
+	}
+	connects := 0
+	for _, tr := range sut.StateHistory() {
+		if tr.Kind == TransitionConnected {
+			connects++
+		}
+	}
+	if connects != 1 {
+		t.Errorf("connected %d times, want the connection kept", connects)
+	}
+}
+
+func TestClientPermissiveDecoding(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	quotes := make(chan map[string]interface{}, 1)
+	sut := server.newClient(QUODD)
+	sut.OnQuote(func(quote map[string]interface{}) { quotes <- quote })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	server.broadcastRaw(websocket.TextMessage, []byte(`{"event":"quote","data":{"bid_price_4d":"oops"}}`))
+	select {
+	case quote := <-quotes:
+		if quote["data"].(map[string]interface{})["bid_price_4d"] != "oops" {
+			t.Errorf("OnQuote() = %v, want the frame as it was", quote)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnQuote() was not called")
+	}
+}
+
+func TestIsCount(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		want bool
+	}{
+		{name: "整数を通すこと", v: json.Number("100"), want: true},
+		{name: "0を通すこと", v: json.Number("0"), want: true},
+		{name: "2^53+1を通すこと", v: json.Number("9007199254740993"), want: true},
+		{name: "uint64より大きな整数も通すこと", v: json.Number("18446744073709551616"), want: true},
+		{name: "指数表記の整数を通すこと", v: json.Number("1e3"), want: true},
+		{name: "小数点以下が0なら通すこと", v: json.Number("100.0"), want: true},
+		{name: "負の数を弾くこと", v: json.Number("-1"), want: false},
+		{name: "小数を弾くこと", v: json.Number("1.5"), want: false},
+		{name: "float64にならないほど小さな端数も弾くこと", v: json.Number("1.0000000000000001"), want: false},
+		{name: "2^53を超える数の端数も弾くこと", v: json.Number("9007199254740993.5"), want: false},
+		{name: "float64の整数を通すこと", v: float64(100), want: true},
+		{name: "float64の小数を弾くこと", v: 0.5, want: false},
+		{name: "float64の負の数を弾くこと", v: float64(-1), want: false},
+		{name: "文字列を弾くこと", v: "100", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isCount(tt.v); got != tt.want {
+				t.Errorf("isCount(%v) = %v, want %v", tt.v, got, tt.want)
+			}
+		})
+	}
+}
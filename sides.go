@@ -0,0 +1,131 @@
+package intriniorealtime
+
+import (
+	"sync"
+	"time"
+)
+
+// QuoteSide is the top-of-book bid or ask of a security.
+type QuoteSide struct {
+	Symbol    string
+	Price     float64
+	Size      int64
+	Exchange  string // QUODD and the SIP feeds
+	Timestamp time.Time
+	Delayed   bool // DELAYED_SIP, 15 minutes behind the market
+
+	// RawTimestamp is Timestamp as the feed sent it, see Trade.
+	RawTimestamp RawTime
+
+	// Venue is Exchange looked up, always IEX for IEX.
+	Venue Exchange
+
+	// FixedPrice is Price, exactly, with WithFixedPointPrices.
+	FixedPrice Price
+}
+
+// OnBid registers a handler for changes of the top-of-book bid.
+//
+// IEX sends every side on its own. A QUODD quote message only carries the
+// fields that changed, so the client keeps the last bid and ask of every
+// symbol and fills in the rest from there; a message may change both sides,
+// and one that changes neither calls neither handler.
+func (cli *Client) OnBid(f func(bid QuoteSide)) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
+	cli.bidHandler = f
+}
+
+// OnAsk registers a handler for changes of the top-of-book ask, see OnBid.
+func (cli *Client) OnAsk(f func(ask QuoteSide)) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
+	cli.askHandler = f
+}
+
+// topOfBook is the last known bid and ask of every QUODD symbol.
+type topOfBook struct {
+	mu    sync.Mutex
+	books map[string]*[2]QuoteSide
+}
+
+// sides returns the bid and ask msg changed, nil for a side it left alone.
+func (b *topOfBook) sides(provider provider, msg map[string]interface{}) (bid, ask *QuoteSide) {
+	switch provider {
+	case IEX:
+		quote, ok := parseIEXQuote(provider, msg)
+		if !ok {
+			return nil, nil
+		}
+		side := &QuoteSide{Symbol: quote.Ticker, Price: quote.Price, Size: quote.Size, Timestamp: quote.Timestamp, RawTimestamp: quote.RawTimestamp, FixedPrice: quote.FixedPrice, Venue: quote.Venue}
+		switch quote.Type {
+		case "bid":
+			return side, nil
+		case "ask":
+			return nil, side
+		}
+	case REALTIME, DELAYED_SIP:
+		side, bid := parseRealtimeSide(provider, msg)
+		if side == nil {
+			return nil, nil
+		}
+		if bid {
+			return side, nil
+		}
+		return nil, side
+	case NASDAQ_BASIC:
+		return parseNasdaqBasicSides(provider, msg)
+	case QUODD:
+		quote, ok := parseQuoddQuote(provider, msg)
+		if !ok || quote.Ticker == "" {
+			return nil, nil
+		}
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.books == nil {
+			b.books = make(map[string]*[2]QuoteSide)
+		}
+		book, ok := b.books[quote.Ticker]
+		if !ok {
+			book = &[2]QuoteSide{{Symbol: quote.Ticker}, {Symbol: quote.Ticker}}
+			b.books[quote.Ticker] = book
+		}
+		if mergeSide(&book[0], quote.BidPrice, quote.BidPriceFixed, quote.BidSize, quote.BidExchange, quote.QuoteTime) {
+			bid = &QuoteSide{}
+			*bid = book[0]
+		}
+		if mergeSide(&book[1], quote.AskPrice, quote.AskPriceFixed, quote.AskSize, quote.AskExchange, quote.QuoteTime) {
+			ask = &QuoteSide{}
+			*ask = book[1]
+		}
+	}
+	return bid, ask
+}
+
+// mergeSide applies the fields that were sent to side and reports whether
+// any of them changed it.
+func mergeSide(side *QuoteSide, price *float64, fixed *Price, size *int64, exchange *string, at *time.Time) bool {
+	changed := false
+	if price != nil && *price != side.Price {
+		side.Price = *price
+		changed = true
+	}
+	if fixed != nil && *fixed != side.FixedPrice {
+		side.FixedPrice = *fixed
+		changed = true
+	}
+	if size != nil && *size != side.Size {
+		side.Size = *size
+		changed = true
+	}
+	if exchange != nil && *exchange != side.Exchange {
+		side.Exchange = *exchange
+		side.Venue = LookupExchange(*exchange)
+		changed = true
+	}
+	if changed && at != nil {
+		side.Timestamp = *at
+		side.RawTimestamp = rawTimeOf(*at, Milliseconds)
+	}
+	return changed
+}
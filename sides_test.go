@@ -0,0 +1,151 @@
+package intriniorealtime
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTopOfBookSides(t *testing.T) {
+	quote := func(data map[string]interface{}) map[string]interface{} {
+		data["ticker"] = "AAPL.NB"
+		return map[string]interface{}{"event": "quote", "data": data}
+	}
+	at := time.Unix(0, 1508165070850*int64(time.Millisecond)).UTC()
+	later := time.Unix(0, 1508165071130*int64(time.Millisecond)).UTC()
+	type sides struct{ bid, ask *QuoteSide }
+	tests := []struct {
+		name     string
+		provider provider
+		msgs     []map[string]interface{}
+		want     []sides
+	}{
+		{
+			name:     "全項目のクォートは両方の気配を通知すること",
+			provider: QUODD,
+			msgs: []map[string]interface{}{quote(map[string]interface{}{
+				"bid_price_4d": float64(1594800), "bid_size": float64(500), "bid_exchange": "t",
+				"ask_price_4d": float64(1594900), "ask_size": float64(600), "ask_exchange": "q", "quote_time": float64(1508165070850),
+			})},
+			want: []sides{{
+				bid: &QuoteSide{Symbol: "AAPL.NB", Price: 159.48, Size: 500, Exchange: "t", Venue: LookupExchange("t"), Timestamp: at, RawTimestamp: RawTime{Value: 1508165070850, Unit: Milliseconds}},
+				ask: &QuoteSide{Symbol: "AAPL.NB", Price: 159.49, Size: 600, Exchange: "q", Venue: LookupExchange("q"), Timestamp: at, RawTimestamp: RawTime{Value: 1508165070850, Unit: Milliseconds}},
+			}},
+		},
+		{
+			name:     "買い気配だけ変われば買い気配だけ通知し残りは前の値で埋めること",
+			provider: QUODD,
+			msgs: []map[string]interface{}{
+				quote(map[string]interface{}{
+					"bid_price_4d": float64(1594800), "bid_size": float64(500), "bid_exchange": "t",
+					"ask_price_4d": float64(1594900), "ask_size": float64(600), "quote_time": float64(1508165070850),
+				}),
+				quote(map[string]interface{}{"bid_size": float64(300), "quote_time": float64(1508165071130)}),
+			},
+			want: []sides{
+				{
+					bid: &QuoteSide{Symbol: "AAPL.NB", Price: 159.48, Size: 500, Exchange: "t", Venue: LookupExchange("t"), Timestamp: at, RawTimestamp: RawTime{Value: 1508165070850, Unit: Milliseconds}},
+					ask: &QuoteSide{Symbol: "AAPL.NB", Price: 159.49, Size: 600, Timestamp: at, RawTimestamp: RawTime{Value: 1508165070850, Unit: Milliseconds}},
+				},
+				{bid: &QuoteSide{Symbol: "AAPL.NB", Price: 159.48, Size: 300, Exchange: "t", Venue: LookupExchange("t"), Timestamp: later, RawTimestamp: RawTime{Value: 1508165071130, Unit: Milliseconds}}},
+			},
+		},
+		{
+			name:     "売り気配だけ変われば売り気配だけ通知すること",
+			provider: QUODD,
+			msgs: []map[string]interface{}{
+				quote(map[string]interface{}{"bid_price_4d": float64(1594800), "ask_price_4d": float64(1594900)}),
+				quote(map[string]interface{}{"ask_price_4d": float64(1595000)}),
+			},
+			want: []sides{
+				{bid: &QuoteSide{Symbol: "AAPL.NB", Price: 159.48}, ask: &QuoteSide{Symbol: "AAPL.NB", Price: 159.49}},
+				{ask: &QuoteSide{Symbol: "AAPL.NB", Price: 159.5}},
+			},
+		},
+		{
+			name:     "どちらも変わらなければ通知しないこと",
+			provider: QUODD,
+			msgs: []map[string]interface{}{
+				quote(map[string]interface{}{"bid_price_4d": float64(1594800), "ask_price_4d": float64(1594900)}),
+				quote(map[string]interface{}{"bid_price_4d": float64(1594800), "rtl": float64(129740)}),
+				quote(map[string]interface{}{"quote_time": float64(1508165071130)}),
+			},
+			want: []sides{
+				{bid: &QuoteSide{Symbol: "AAPL.NB", Price: 159.48}, ask: &QuoteSide{Symbol: "AAPL.NB", Price: 159.49}},
+				{},
+				{},
+			},
+		},
+		{
+			name:     "QUODDのトレードでは通知しないこと",
+			provider: QUODD,
+			msgs:     []map[string]interface{}{{"event": "trade", "data": map[string]interface{}{"ticker": "AAPL.NB", "last_price_4d": float64(1594850)}}},
+			want:     []sides{{}},
+		},
+		{
+			name:     "IEXのbidとaskをそれぞれ通知すること",
+			provider: IEX,
+			msgs: []map[string]interface{}{
+				{"event": "quote", "payload": map[string]interface{}{"type": "bid", "ticker": "GE", "price": 28.96, "size": float64(100), "timestamp": float64(1493409509)}},
+				{"event": "quote", "payload": map[string]interface{}{"type": "ask", "ticker": "GE", "price": 28.97, "size": float64(200), "timestamp": float64(1493409509)}},
+				{"event": "quote", "payload": map[string]interface{}{"type": "last", "ticker": "GE", "price": 28.97, "size": float64(10)}},
+			},
+			want: []sides{
+				{bid: &QuoteSide{Symbol: "GE", Price: 28.96, Size: 100, Timestamp: time.Unix(1493409509, 0).UTC(), RawTimestamp: RawTime{Value: 1493409509, Unit: Seconds}, Venue: iexVenue}},
+				{ask: &QuoteSide{Symbol: "GE", Price: 28.97, Size: 200, Timestamp: time.Unix(1493409509, 0).UTC(), RawTimestamp: RawTime{Value: 1493409509, Unit: Seconds}, Venue: iexVenue}},
+				{},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sut topOfBook
+			for i, msg := range tt.msgs {
+				bid, ask := sut.sides(tt.provider, msg)
+				if got := (sides{bid, ask}); !reflect.DeepEqual(got, tt.want[i]) {
+					t.Errorf("sides() #%d = %+v %+v, want %+v %+v", i, bid, ask, tt.want[i].bid, tt.want[i].ask)
+				}
+			}
+		})
+	}
+}
+
+func TestClientOnBidAsk(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	bids := make(chan QuoteSide, 10)
+	asks := make(chan QuoteSide, 10)
+	sut := server.newClient(QUODD)
+	sut.OnBid(func(bid QuoteSide) { bids <- bid })
+	sut.OnAsk(func(ask QuoteSide) { asks <- ask })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	server.broadcast(loadFixture(t, "quodd_quote.json"))
+	server.broadcast(loadFixture(t, "quodd_trade.json"))
+	server.broadcast(loadFixture(t, "quodd_quote_partial.json"))
+	for _, want := range []float64{159.48, 159.47} {
+		select {
+		case bid := <-bids:
+			if bid.Price != want {
+				t.Errorf("OnBid() price = %v, want %v", bid.Price, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("OnBid() was not called for %v", want)
+		}
+	}
+	select {
+	case ask := <-asks:
+		if ask.Price != 159.49 || ask.Size != 600 {
+			t.Errorf("OnAsk() ask = %+v", ask)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnAsk() was not called")
+	}
+	if len(asks) != 0 {
+		t.Errorf("OnAsk() was called %d more times", len(asks))
+	}
+}
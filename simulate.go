@@ -0,0 +1,187 @@
+package intriniorealtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// defaultSimulationInterval is how often a SIMULATED client emits a message
+// for every joined symbol, unless WithSimulationInterval says otherwise.
+const defaultSimulationInterval = 100 * time.Millisecond
+
+// simulation is the state of a SIMULATED client's generator.
+type simulation struct {
+	seed     int64
+	seeded   bool
+	interval time.Duration
+
+	mu    sync.Mutex             // held while generating
+	walks map[string]*randomWalk // by symbol
+}
+
+// randomWalk is the price of one simulated symbol. Each symbol has a source
+// of its own, so its messages don't depend on which others are joined.
+type randomWalk struct {
+	rng   *rand.Rand
+	cents int64
+}
+
+// WithSimulationSeed makes a SIMULATED client deterministic: with the same
+// seed every symbol gets the same sequence of messages. Without it the
+// seed is the time of New.
+func WithSimulationSeed(seed int64) Option {
+	return func(cli *Client) error {
+		sim, err := cli.simulationOption("WithSimulationSeed")
+		if err != nil {
+			return err
+		}
+		sim.seed, sim.seeded = seed, true
+		return nil
+	}
+}
+
+// WithSimulationInterval sets how often a SIMULATED client emits a message
+// for every joined symbol (100ms by default).
+func WithSimulationInterval(d time.Duration) Option {
+	return func(cli *Client) error {
+		if d <= 0 {
+			return fmt.Errorf("simulation interval must be positive: %v", d)
+		}
+		sim, err := cli.simulationOption("WithSimulationInterval")
+		if err != nil {
+			return err
+		}
+		sim.interval = d
+		return nil
+	}
+}
+
+// simulationOption returns the simulation a simulation option configures.
+func (cli *Client) simulationOption(name string) (*simulation, error) {
+	if cli.provider != SIMULATED {
+		return nil, fmt.Errorf("%s is for the SIMULATED provider, not %s", name, cli.provider)
+	}
+	if cli.sim == nil {
+		cli.sim = &simulation{}
+	}
+	return cli.sim, nil
+}
+
+// resolveSimulation makes a SIMULATED client an IEX client whose messages
+// are made up. It runs after all options have been applied.
+func (cli *Client) resolveSimulation() {
+	if cli.provider != SIMULATED {
+		return
+	}
+	if cli.sim == nil {
+		cli.sim = &simulation{}
+	}
+	if !cli.sim.seeded {
+		cli.sim.seed = time.Now().UnixNano()
+	}
+	if cli.sim.interval == 0 {
+		cli.sim.interval = defaultSimulationInterval
+	}
+	cli.sim.walks = make(map[string]*randomWalk)
+	cli.provider = IEX
+}
+
+// startSimulation runs the generator on a goroutine of its own until the
+// client is disconnected.
+func (cli *Client) startSimulation() {
+	cli.info("simulation started")
+	cli.startDispatcher()
+	tick, stop := cli.newTicker(cli.sim.interval)
+	go func(done <-chan struct{}) {
+		defer stop()
+		for {
+			select {
+			case <-done:
+				return
+			case at := <-tick:
+				if err := cli.simulate(at); err != nil {
+					cli.onError(err)
+					cli.stop(err)
+					return
+				}
+			}
+		}
+	}(cli.Done())
+}
+
+// simulate emits a message for each joined symbol, in the order of their
+// names.
+func (cli *Client) simulate(at time.Time) error {
+	cli.mu.Lock()
+	if cli.stopped {
+		cli.mu.Unlock()
+		return nil
+	}
+	var symbols []string
+	for channel := range cli.channels {
+		if isTicker(channel) {
+			symbols = append(symbols, channel)
+		}
+	}
+	cli.mu.Unlock()
+	sort.Strings(symbols)
+	// A generator of an earlier Connect may not have seen done yet.
+	cli.sim.mu.Lock()
+	defer cli.sim.mu.Unlock()
+	for _, symbol := range symbols {
+		data, err := json.Marshal(cli.sim.next(symbol, at))
+		if err != nil {
+			return err
+		}
+		cli.touch()
+		if err := cli.handleFrame(websocket.TextMessage, data, cli.now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// next returns the next IEX quote of symbol, at: a bid, an ask or a last
+// sale around its price, which takes a random step first.
+func (sim *simulation) next(symbol string, at time.Time) map[string]interface{} {
+	w := sim.walks[symbol]
+	if w == nil {
+		h := fnv.New64a()
+		h.Write([]byte(symbol))
+		rng := rand.New(rand.NewSource(sim.seed ^ int64(h.Sum64())))
+		w = &randomWalk{rng: rng, cents: 1000 + rng.Int63n(49000)}
+		sim.walks[symbol] = w
+	}
+	step := int64(math.Round(w.rng.NormFloat64() * float64(w.cents) * 0.0005))
+	if w.cents += step; w.cents < 100 {
+		w.cents = 100
+	}
+	typ := []string{"bid", "ask", "last"}[w.rng.Intn(3)]
+	cents := w.cents
+	switch typ {
+	case "bid":
+		cents -= 1 + w.rng.Int63n(3)
+	case "ask":
+		cents += 1 + w.rng.Int63n(3)
+	}
+	return map[string]interface{}{
+		"topic": iexSecuritiesPrefix + symbol,
+		"event": "quote",
+		"ref":   nil,
+		"payload": map[string]interface{}{
+			"type":      typ,
+			"timestamp": float64(at.UnixNano()) / 1e9,
+			"ticker":    symbol,
+			"size":      100 * (1 + w.rng.Int63n(10)),
+			"price":     float64(cents) / 100,
+		},
+	}
+}
@@ -0,0 +1,117 @@
+package intriniorealtime
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// simulatedClient returns a SIMULATED client that generates on every send
+// on the returned channel.
+func simulatedClient(t *testing.T, opts ...Option) (*Client, chan<- time.Time) {
+	t.Helper()
+	ticks := make(chan time.Time)
+	sut := New("", "", SIMULATED, opts...)
+	sut.newTicker = func(d time.Duration) (<-chan time.Time, func()) { return ticks, func() {} }
+	return sut, ticks
+}
+
+// simulatedQuotes returns the quotes of a SIMULATED client, without the
+// timestamps, as they arrive.
+func simulatedQuotes(sut *Client) chan string {
+	quotes := make(chan string, 100)
+	sut.OnIEXQuote(func(q IEXQuote) {
+		quotes <- fmt.Sprintf("%s %s %.2f %d", q.Ticker, q.Type, q.Price, q.Size)
+	})
+	return quotes
+}
+
+func TestSimulationIsReproducible(t *testing.T) {
+	run := func(seed int64) []string {
+		sut, ticks := simulatedClient(t, WithSimulationSeed(seed))
+		quotes := simulatedQuotes(sut)
+		sut.Join("MSFT", "AAPL")
+		if err := sut.Connect(); err != nil {
+			t.Fatalf("Connect() error = %v", err)
+		}
+		defer sut.Disconnect()
+		var got []string
+		for i := 0; i < 5; i++ {
+			ticks <- time.Unix(1760621400+int64(i), 0)
+			got = append(got, receive(t, "OnIEXQuote()", quotes), receive(t, "OnIEXQuote()", quotes))
+		}
+		return got
+	}
+
+	first, second := run(42), run(42)
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("seed 42 generated %v, then %v, want the same", first, second)
+	}
+	for i, quote := range first {
+		if want := []string{"AAPL", "MSFT"}[i%2]; quote[:4] != want {
+			t.Errorf("quote %d = %q, want one of %s", i, quote, want)
+		}
+	}
+	if other := run(43); reflect.DeepEqual(first, other) {
+		t.Errorf("seeds 42 and 43 both generated %v", first)
+	}
+}
+
+func TestSimulationFollowsJoins(t *testing.T) {
+	sut, ticks := simulatedClient(t, WithSimulationSeed(1))
+	quotes := simulatedQuotes(sut)
+	trades := make(chan Trade, 100)
+	sut.OnTrade(func(trade Trade) { trades <- trade })
+	sut.Join("AAPL", "MSFT")
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+
+	ticks <- time.Now()
+	receive(t, "OnIEXQuote()", quotes)
+	receive(t, "OnIEXQuote()", quotes)
+	sut.Leave("MSFT")
+	for i := 0; i < 10; i++ {
+		ticks <- time.Now()
+		if quote := receive(t, "OnIEXQuote()", quotes); quote[:4] != "AAPL" {
+			t.Fatalf("OnIEXQuote() = %q after MSFT was left, want AAPL only", quote)
+		}
+	}
+	select {
+	case trade := <-trades:
+		if trade.Symbol != "AAPL" && trade.Symbol != "MSFT" || trade.Price <= 0 {
+			t.Errorf("OnTrade() = %+v, want a trade of a joined symbol", trade)
+		}
+	default:
+		t.Error("OnTrade() not called, want last sales among the quotes")
+	}
+
+	sut.Disconnect()
+	select {
+	case ticks <- time.Now():
+		t.Error("generator still running after Disconnect")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSimulationOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider provider
+		opt      Option
+	}{
+		{name: "SIMULATED以外ではシードをエラーにすること", provider: IEX, opt: WithSimulationSeed(1)},
+		{name: "SIMULATED以外では間隔をエラーにすること", provider: QUODD, opt: WithSimulationInterval(time.Second)},
+		{name: "0の間隔をエラーにすること", provider: SIMULATED, opt: WithSimulationInterval(0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := New("", "", tt.provider, tt.opt)
+			if err := sut.Connect(); err == nil {
+				sut.Disconnect()
+				t.Error("Connect() error = nil, want the invalid option")
+			}
+		})
+	}
+}
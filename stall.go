@@ -0,0 +1,78 @@
+package intriniorealtime
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// defaultSendStallThreshold is half the default write deadline, early
+	// enough to act on before a write times out.
+	defaultSendStallThreshold = writeWait / 2
+
+	// sendStallReportInterval is the least time between two OnSendStall
+	// calls.
+	sendStallReportInterval = time.Second
+)
+
+// WithSendStallThreshold sets how long a write, or the wait of a join or
+// leave in the send queue, may take before OnSendStall is called. The
+// default is 5 seconds, half the default write deadline.
+func WithSendStallThreshold(d time.Duration) Option {
+	return func(cli *Client) error {
+		if d <= 0 {
+			return fmt.Errorf("send stall threshold must be positive: %v", d)
+		}
+		cli.sendStallThreshold = d
+		return nil
+	}
+}
+
+// OnSendStall registers a callback invoked when sending has slowed down: a
+// write took longer than the threshold set with WithSendStallThreshold, or a
+// join or leave waited that long in the send queue. d is the longer of the
+// two and queued the number of messages waiting to be sent. It is called at
+// most once a second, an early warning well before the write deadline
+// gives up on the connection. Only while it is registered are sends timed.
+func (cli *Client) OnSendStall(f func(d time.Duration, queued int)) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
+	cli.sendStallHandler = f
+}
+
+// timingSends reports whether sends are timed for OnSendStall.
+func (cli *Client) timingSends() bool {
+	cli.handlerMu.RLock()
+	defer cli.handlerMu.RUnlock()
+	return cli.sendStallHandler != nil
+}
+
+// sendTook checks a write that started at start and took took, of a message
+// queued at queuedAt when it is set, against the threshold.
+func (cli *Client) sendTook(start time.Time, took time.Duration, queuedAt time.Time) {
+	d := took
+	if !queuedAt.IsZero() {
+		if waited := start.Sub(queuedAt); d < waited {
+			d = waited
+		}
+	}
+	if d < cli.sendStallThreshold {
+		return
+	}
+	cli.mu.Lock()
+	now := cli.now()
+	if now.Sub(cli.sendStallReportedAt) < sendStallReportInterval {
+		cli.mu.Unlock()
+		return
+	}
+	cli.sendStallReportedAt = now
+	queued := len(cli.q) + len(cli.control)
+	cli.mu.Unlock()
+	cli.warn("send stalled", "stall", d, "queued", queued)
+	cli.handlerMu.RLock()
+	f := cli.sendStallHandler
+	cli.handlerMu.RUnlock()
+	if f != nil {
+		cli.callHandler("OnSendStall", func() { f(d, queued) })
+	}
+}
@@ -0,0 +1,116 @@
+package intriniorealtime
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestClientSendStall(t *testing.T) {
+	tests := []struct {
+		name      string
+		readDelay time.Duration
+		opts      []Option
+		wantStall bool
+	}{
+		{
+			name:      "サーバーが読むのが遅ければ知らせること",
+			readDelay: 100 * time.Millisecond,
+			opts:      []Option{WithSendStallThreshold(50 * time.Millisecond)},
+			wantStall: true,
+		},
+		{
+			name: "遅れがなければ知らせないこと",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+			server.mu.Lock()
+			server.readDelay = tt.readDelay
+			server.mu.Unlock()
+			// A pipe has no buffer, so every write waits for the server to read.
+			dial, _ := servePipes(t, server)
+			conn, err := dial(context.Background())
+			if err != nil {
+				t.Fatalf("dial() error = %v", err)
+			}
+
+			type stall struct {
+				d      time.Duration
+				queued int
+			}
+			stalls := make(chan stall, 10)
+			sut := New("", "", QUODD, append([]Option{WithConn(conn, nil), WithoutHeartbeat(), WithConcurrentCallbacks()}, tt.opts...)...)
+			sut.OnSendStall(func(d time.Duration, queued int) { stalls <- stall{d, queued} })
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			defer sut.Disconnect()
+			sut.Join("AAPL.NB", "MSFT.NB", "GE.NY", "F.NY", "T.NY")
+			if !waitUntil(5*time.Second, func() bool { return len(server.messagesWithEvent("subscribe")) == 5 }) {
+				t.Fatalf("subscribes = %v, want 5", server.messagesWithEvent("subscribe"))
+			}
+
+			if !tt.wantStall {
+				select {
+				case s := <-stalls:
+					t.Errorf("OnSendStall(%v, %d) without a stall", s.d, s.queued)
+				default:
+				}
+				return
+			}
+			s := receive(t, "OnSendStall()", stalls)
+			if s.d < 50*time.Millisecond || s.queued < 0 || 5 < s.queued {
+				t.Errorf("OnSendStall(%v, %d), want a stall of at least the threshold", s.d, s.queued)
+			}
+			// Every write stalled, but the calls are a second apart.
+			select {
+			case s := <-stalls:
+				t.Errorf("OnSendStall(%v, %d) again within a second", s.d, s.queued)
+			default:
+			}
+		})
+	}
+
+	if err := New("", "", IEX, WithSendStallThreshold(0)).Connect(); err == nil {
+		t.Error("Connect() with a zero send stall threshold error = nil")
+	}
+}
+
+// sharedJoinFeed is a tickFeed that joins every channel with the same map.
+type sharedJoinFeed struct {
+	tickFeed
+	join map[string]interface{}
+}
+
+func (f sharedJoinFeed) JoinMessage(channel string) interface{} {
+	return f.join
+}
+
+func TestClientSendStallLeavesMessagesAlone(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	join := map[string]interface{}{"event": "sub", "symbol": "ALL"}
+	sut := New("", "", sharedJoinFeed{tickFeed{authURL: server.authURL(), soketURL: server.soketURL()}, join}, WithoutHeartbeat())
+	sut.OnSendStall(func(time.Duration, int) {})
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+	sut.Join("AAPL", "MSFT", "GE")
+	if !waitUntil(5*time.Second, func() bool { return len(server.messagesWithEvent("sub")) == 3 }) {
+		t.Fatalf("subs = %v, want 3", server.messagesWithEvent("sub"))
+	}
+	if want := map[string]interface{}{"event": "sub", "symbol": "ALL"}; !reflect.DeepEqual(join, want) {
+		t.Errorf("join message = %v, changed by the client", join)
+	}
+	for _, msg := range server.messagesWithEvent("sub") {
+		if len(msg) != 2 {
+			t.Errorf("sub = %v, sent with more than the feed's fields", msg)
+		}
+	}
+}
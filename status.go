@@ -0,0 +1,175 @@
+package intriniorealtime
+
+import "time"
+
+// SecurityStatus is the trading state of a security.
+type SecurityStatus string
+
+// The trading states. A code a feed sends that isn't one of them is passed
+// on as it is.
+const (
+	// StatusHalted is a regulatory or operational halt: no trading and no
+	// quoting.
+	StatusHalted SecurityStatus = "halted"
+	// StatusPaused is a limit-up/limit-down trading pause, which the
+	// listing exchange reopens with an auction.
+	StatusPaused SecurityStatus = "paused"
+	// StatusQuoting is the quotation period before a halted or paused
+	// security resumes: orders are accepted, but nothing trades yet.
+	StatusQuoting SecurityStatus = "quoting"
+	// StatusTrading is regular trading, also after a halt was lifted.
+	StatusTrading SecurityStatus = "trading"
+)
+
+// iexStatuses are the codes of IEX trading status messages.
+var iexStatuses = map[string]SecurityStatus{
+	"H": StatusHalted,
+	"P": StatusPaused,
+	"O": StatusQuoting,
+	"T": StatusTrading,
+}
+
+// quoddStatuses are the states of QUODD status messages.
+var quoddStatuses = map[string]SecurityStatus{
+	"halted":  StatusHalted,
+	"paused":  StatusPaused,
+	"quoting": StatusQuoting,
+	"resumed": StatusTrading,
+	"trading": StatusTrading,
+}
+
+// PriceBands are the limit-up/limit-down bands a security may trade within.
+type PriceBands struct {
+	Lower float64
+	Upper float64
+
+	// LowerFixed and UpperFixed are the bands, exactly, with
+	// WithFixedPointPrices.
+	LowerFixed Price
+	UpperFixed Price
+}
+
+// SecurityStatusEvent is a change of a security's trading state, or of its
+// limit-up/limit-down bands.
+type SecurityStatusEvent struct {
+	Symbol string
+	Status SecurityStatus
+
+	// Reason is the code the feed gave for a halt or pause, such as T1 for
+	// news pending or LUDP for a limit-up/limit-down pause; empty when it
+	// gave none.
+	Reason string
+
+	// Bands are the limit-up/limit-down bands, nil when the message didn't
+	// carry them. IEX doesn't send them.
+	Bands *PriceBands
+
+	Timestamp time.Time // UTC, zero when it wasn't sent
+
+	// Raw is the payload or data as received; don't modify it.
+	Raw map[string]interface{}
+}
+
+// Halted reports whether the security can't be traded: it is halted, paused
+// or still in the quotation period before resuming.
+func (e SecurityStatusEvent) Halted() bool {
+	return e.Status == StatusHalted || e.Status == StatusPaused || e.Status == StatusQuoting
+}
+
+// OnSecurityStatus registers the handler for trading halts, pauses and
+// resumptions and limit-up/limit-down band updates. IEX sends trading status
+// messages on the channel of the security; QUODD sends status and luld
+// messages to the subscribers of the ticker. They are not passed to OnQuote.
+func (cli *Client) OnSecurityStatus(f func(status SecurityStatusEvent)) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
+	cli.statusHandler = f
+}
+
+func (cli *Client) onSecurityStatus(status SecurityStatusEvent) {
+	cli.debug("security status", "channel", status.Symbol, "status", status.Status)
+	cli.handlerMu.RLock()
+	f := cli.statusHandler
+	cli.handlerMu.RUnlock()
+	if f != nil {
+		cli.callHandler("OnSecurityStatus", func() { f(status) })
+	}
+}
+
+// SecurityStatus returns the status event msg carries, if it is one.
+func (msg Message) SecurityStatus() (SecurityStatusEvent, bool) {
+	if msg.Type != MessageStatus {
+		return SecurityStatusEvent{}, false
+	}
+	return parseSecurityStatus(msg.Provider, msg.exact)
+}
+
+// parseSecurityStatus returns the status event carried by msg, if it is one.
+//
+// An IEX trading_status payload has the ticker, the status as IEX codes it
+// (H halted, P paused, O quoting, T trading), the reason and the timestamp.
+// QUODD status data has the ticker, the status, the reason and status_time;
+// luld data has the ticker, luld_lower_4d, luld_upper_4d and luld_time and
+// leaves the security trading.
+func parseSecurityStatus(provider provider, msg map[string]interface{}) (SecurityStatusEvent, bool) {
+	switch provider {
+	case IEX:
+		if msg["event"] != "trading_status" {
+			return SecurityStatusEvent{}, false
+		}
+		payload, ok := msg["payload"].(map[string]interface{})
+		if !ok {
+			return SecurityStatusEvent{}, false
+		}
+		status := SecurityStatusEvent{Raw: payload}
+		status.Symbol, _ = SymbolFromMessage(provider, msg)
+		status.Status = lookupStatus(iexStatuses, payload["status"])
+		status.Reason, _ = payload["reason"].(string)
+		at := payload["timestamp"]
+		status.Timestamp, _ = unixTime(at, timeUnit(provider, "timestamp", at))
+		return status, true
+	case QUODD:
+		data, ok := quoddData(provider, msg, "status", "luld")
+		if !ok {
+			return SecurityStatusEvent{}, false
+		}
+		status := SecurityStatusEvent{Raw: data}
+		status.Symbol, _ = data["ticker"].(string)
+		status.Reason, _ = data["reason"].(string)
+		key := "status_time"
+		if msg["event"] == "luld" {
+			key = "luld_time"
+			status.Status = StatusTrading
+			status.Bands = parseBands(data)
+		} else {
+			status.Status = lookupStatus(quoddStatuses, data["status"])
+		}
+		if at := optMillis(data, key); at != nil {
+			status.Timestamp = *at
+		}
+		return status, true
+	}
+	return SecurityStatusEvent{}, false
+}
+
+// lookupStatus translates the status code v with table, passing on codes
+// that aren't in it.
+func lookupStatus(table map[string]SecurityStatus, v interface{}) SecurityStatus {
+	code, _ := v.(string)
+	if status, ok := table[code]; ok {
+		return status
+	}
+	return SecurityStatus(code)
+}
+
+// parseBands reads the bands of QUODD luld data, nil when either is missing.
+func parseBands(data map[string]interface{}) *PriceBands {
+	lower, upper := optPrice(data, "luld_lower_4d"), optPrice(data, "luld_upper_4d")
+	if lower == nil || upper == nil {
+		return nil
+	}
+	bands := &PriceBands{Lower: *lower, Upper: *upper}
+	bands.LowerFixed, _ = fixedPrice4d(data["luld_lower_4d"])
+	bands.UpperFixed, _ = fixedPrice4d(data["luld_upper_4d"])
+	return bands
+}
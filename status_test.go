@@ -0,0 +1,174 @@
+package intriniorealtime
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestParseSecurityStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider provider
+		opts     []Option
+		fixture  string
+		want     []SecurityStatusEvent
+	}{
+		{
+			name:     "IEXの売買停止から再開までを解析すること",
+			provider: IEX,
+			fixture:  "iex_halt_resume.jsonl",
+			want: []SecurityStatusEvent{
+				{Symbol: "AAPL", Status: StatusHalted, Reason: "T1", Timestamp: time.Unix(1760621400, 123456789).UTC()},
+				{Symbol: "AAPL", Status: StatusQuoting, Reason: "T1", Timestamp: time.Unix(1760622300, 500000000).UTC()},
+				{Symbol: "AAPL", Status: StatusTrading, Timestamp: time.Unix(1760622600, 1000).UTC()},
+			},
+		},
+		{
+			name:     "QUODDの値幅制限による中断から再開までを解析すること",
+			provider: QUODD,
+			fixture:  "quodd_halt_resume.jsonl",
+			want: []SecurityStatusEvent{
+				{Symbol: "AAPL.NB", Status: StatusTrading, Bands: &PriceBands{Lower: 179.45, Upper: 198.35},
+					Timestamp: time.Unix(1760621400, 123000000).UTC()},
+				{Symbol: "AAPL.NB", Status: StatusPaused, Reason: "LUDP", Timestamp: time.Unix(1760621460, 0).UTC()},
+				{Symbol: "AAPL.NB", Status: StatusQuoting, Reason: "LUDP", Timestamp: time.Unix(1760621700, 0).UTC()},
+				{Symbol: "AAPL.NB", Status: StatusTrading, Timestamp: time.Unix(1760621760, 250000000).UTC()},
+				{Symbol: "AAPL.NB", Status: StatusTrading, Bands: &PriceBands{Lower: 180, Upper: 199},
+					Timestamp: time.Unix(1760621760, 300000000).UTC()},
+			},
+		},
+		{
+			name:     "固定小数点で値幅を読むこと",
+			provider: QUODD,
+			opts:     []Option{WithFixedPointPrices()},
+			fixture:  "quodd_halt_resume.jsonl",
+			want: []SecurityStatusEvent{
+				{Symbol: "AAPL.NB", Status: StatusTrading, Bands: &PriceBands{Lower: 179.45, Upper: 198.35, LowerFixed: 1794500, UpperFixed: 1983500},
+					Timestamp: time.Unix(1760621400, 123000000).UTC()},
+				{Symbol: "AAPL.NB", Status: StatusPaused, Reason: "LUDP", Timestamp: time.Unix(1760621460, 0).UTC()},
+				{Symbol: "AAPL.NB", Status: StatusQuoting, Reason: "LUDP", Timestamp: time.Unix(1760621700, 0).UTC()},
+				{Symbol: "AAPL.NB", Status: StatusTrading, Timestamp: time.Unix(1760621760, 250000000).UTC()},
+				{Symbol: "AAPL.NB", Status: StatusTrading, Bands: &PriceBands{Lower: 180, Upper: 199, LowerFixed: 1800000, UpperFixed: 1990000},
+					Timestamp: time.Unix(1760621760, 300000000).UTC()},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := New(yourIntrinioAPIUserName, yourIntrinioAPIPassword, tt.provider, tt.opts...)
+			var got []SecurityStatusEvent
+			for _, frame := range loadFrames(t, tt.fixture) {
+				_, exact, err := cli.decodeFrame(frame)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if Classify(tt.provider, exact) != MessageStatus {
+					t.Errorf("Classify(%s) = %v, want %v", frame, Classify(tt.provider, exact), MessageStatus)
+				}
+				status, ok := parseSecurityStatus(tt.provider, exact)
+				if !ok {
+					t.Fatalf("parseSecurityStatus(%s) = false", frame)
+				}
+				if status.Raw == nil {
+					t.Errorf("parseSecurityStatus(%s) Raw = nil", frame)
+				}
+				status.Raw = nil
+				got = append(got, status)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSecurityStatus() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSecurityStatusOthers(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider provider
+		msg      map[string]interface{}
+		want     SecurityStatus
+		wantOK   bool
+	}{
+		{
+			name:     "知らないIEXの状態はそのまま渡すこと",
+			provider: IEX,
+			msg:      map[string]interface{}{"topic": "iex:securities:GE", "event": "trading_status", "payload": map[string]interface{}{"ticker": "GE", "status": "X"}},
+			want:     SecurityStatus("X"),
+			wantOK:   true,
+		},
+		{
+			name:     "QUODDの停止を読むこと",
+			provider: QUODD,
+			msg:      map[string]interface{}{"event": "status", "data": map[string]interface{}{"ticker": "GE", "status": "halted"}},
+			want:     StatusHalted,
+			wantOK:   true,
+		},
+		{
+			name:     "IEXのクォートは解析しないこと",
+			provider: IEX,
+			msg:      map[string]interface{}{"topic": "iex:securities:GE", "event": "quote", "payload": map[string]interface{}{"ticker": "GE"}},
+		},
+		{
+			name:     "IEXではQUODDの状態を解析しないこと",
+			provider: IEX,
+			msg:      map[string]interface{}{"event": "status", "data": map[string]interface{}{"ticker": "GE", "status": "halted"}},
+		},
+		{
+			name:     "dataのないQUODDの状態は解析しないこと",
+			provider: QUODD,
+			msg:      map[string]interface{}{"event": "luld"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseSecurityStatus(tt.provider, tt.msg)
+			if ok != tt.wantOK || got.Status != tt.want {
+				t.Errorf("parseSecurityStatus() = %+v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestClientOnSecurityStatus(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	statuses := make(chan SecurityStatusEvent, 10)
+	messages := make(chan Message, 10)
+	quotes := make(chan map[string]interface{}, 10)
+	sut := server.newClient(IEX)
+	sut.OnSecurityStatus(func(status SecurityStatusEvent) { statuses <- status })
+	sut.OnQuote(func(quote map[string]interface{}) { quotes <- quote })
+	sut.OnMessage(func(msg Message) {
+		if msg.Type == MessageStatus {
+			messages <- msg
+		}
+	})
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	for _, frame := range loadFrames(t, "iex_halt_resume.jsonl") {
+		server.broadcastRaw(websocket.TextMessage, frame)
+	}
+	server.broadcast(loadFixture(t, "iex_quote.json"))
+
+	for _, want := range []SecurityStatus{StatusHalted, StatusQuoting, StatusTrading} {
+		status := receive(t, "OnSecurityStatus()", statuses)
+		if status.Symbol != "AAPL" || status.Status != want || status.Halted() != (want != StatusTrading) {
+			t.Errorf("OnSecurityStatus() = %+v, want AAPL %v", status, want)
+		}
+		msg := receive(t, "OnMessage()", messages)
+		if got, ok := msg.SecurityStatus(); !ok || got.Status != want || msg.Channel != "AAPL" {
+			t.Errorf("Message.SecurityStatus() = %+v, %v on %q, want AAPL %v", got, ok, msg.Channel, want)
+		}
+	}
+	if quote := receive(t, "OnQuote()", quotes); quote["event"] != "quote" {
+		t.Errorf("OnQuote() got %v, want only the quote", quote)
+	}
+}
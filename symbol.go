@@ -0,0 +1,71 @@
+package intriniorealtime
+
+import "strings"
+
+// iexSecuritiesPrefix starts the topic of an IEX security channel.
+const iexSecuritiesPrefix = "iex:securities:"
+
+// SymbolFromMessage returns the symbol a received quote, trade, depth
+// update or status message is about: the ticker in the topic of an IEX
+// security channel, the ticker in the payload for the IEX lobbies, the same
+// for REALTIME, DELAYED_SIP and NASDAQ_BASIC with the symbol in the payload,
+// the contract in the payload of an OPRA message, also on the channel of a
+// chain, the pair in the payload of a CRYPTOQUOTE message, the pair's code
+// in the payload of an FXCM price update, and the ticker in the data of a
+// QUODD message. Anything else, such as replies, heartbeats or QUODD info
+// messages, returns false.
+func SymbolFromMessage(provider provider, msg map[string]interface{}) (string, bool) {
+	switch provider {
+	case IEX:
+		if msg["event"] != "quote" && msg["event"] != "trading_status" {
+			return "", false
+		}
+		topic, _ := msg["topic"].(string)
+		if strings.HasPrefix(topic, iexSecuritiesPrefix) {
+			symbol := topic[len(iexSecuritiesPrefix):]
+			return symbol, symbol != ""
+		}
+		payload, _ := msg["payload"].(map[string]interface{})
+		symbol, _ := payload["ticker"].(string)
+		return symbol, symbol != ""
+	case REALTIME, DELAYED_SIP, NASDAQ_BASIC:
+		payload, ok := realtimePayload(provider, msg, "trade", "quote")
+		if !ok {
+			return "", false
+		}
+		topic, _ := msg["topic"].(string)
+		if strings.HasPrefix(topic, iexSecuritiesPrefix) {
+			symbol := topic[len(iexSecuritiesPrefix):]
+			return symbol, symbol != ""
+		}
+		symbol, _ := payload["symbol"].(string)
+		return symbol, symbol != ""
+	case OPRA:
+		payload, ok := optionPayload(provider, msg, "trade", "quote")
+		if !ok {
+			return "", false
+		}
+		if contract, _ := payload["contract"].(string); contract != "" {
+			return contract, true
+		}
+		topic, _ := msg["topic"].(string)
+		symbol := strings.TrimPrefix(topic, optionsPrefix)
+		return symbol, symbol != "" && symbol != topic
+	case CRYPTOQUOTE:
+		payload, _ := cryptoPayload(provider, msg, "trade", "book_update")
+		symbol, _ := payload["pair"].(string)
+		return symbol, symbol != ""
+	case FXCM:
+		payload, _ := fxPayload(provider, msg, "price_update")
+		symbol, _ := payload["code"].(string)
+		return symbol, symbol != ""
+	case QUODD:
+		data, ok := quoddData(provider, msg, "quote", "quote_data", "trade", "trade_data", "depth", "status", "luld")
+		if !ok {
+			return "", false
+		}
+		symbol, _ := data["ticker"].(string)
+		return symbol, symbol != ""
+	}
+	return "", false
+}
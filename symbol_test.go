@@ -0,0 +1,80 @@
+package intriniorealtime
+
+import "testing"
+
+func TestSymbolFromMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider provider
+		msg      map[string]interface{}
+		want     string
+		wantOK   bool
+	}{
+		{name: "IEXの銘柄のトピックから取り出すこと", provider: IEX, msg: loadFixture(t, "iex_quote.json"), want: "GE", wantOK: true},
+		{
+			name:     "IEXの銘柄のトピックをペイロードより優先すること",
+			provider: IEX,
+			msg:      map[string]interface{}{"topic": "iex:securities:AAPL", "event": "quote", "payload": map[string]interface{}{"ticker": "MSFT"}},
+			want:     "AAPL",
+			wantOK:   true,
+		},
+		{
+			name:     "IEXのロビーはペイロードから取り出すこと",
+			provider: IEX,
+			msg:      map[string]interface{}{"topic": "iex:lobby", "event": "quote", "payload": map[string]interface{}{"type": "bid", "ticker": "MSFT"}},
+			want:     "MSFT",
+			wantOK:   true,
+		},
+		{
+			name:     "IEXの最終価格のロビーもペイロードから取り出すこと",
+			provider: IEX,
+			msg:      map[string]interface{}{"topic": "iex:lobby:last_price", "event": "quote", "payload": map[string]interface{}{"type": "last", "ticker": "IBM"}},
+			want:     "IBM",
+			wantOK:   true,
+		},
+		{
+			name:     "IEXのロビーでティッカーがなければfalseを返すこと",
+			provider: IEX,
+			msg:      map[string]interface{}{"topic": "iex:lobby", "event": "quote", "payload": map[string]interface{}{"type": "bid"}},
+		},
+		{
+			name:     "IEXの空の銘柄のトピックはfalseを返すこと",
+			provider: IEX,
+			msg:      map[string]interface{}{"topic": "iex:securities:", "event": "quote", "payload": map[string]interface{}{}},
+		},
+		{name: "IEXのJoinの応答はfalseを返すこと", provider: IEX, msg: loadFixture(t, "iex_join_ok.json")},
+		{name: "IEXのJoinのエラーはfalseを返すこと", provider: IEX, msg: loadFixture(t, "iex_join_error.json")},
+		{
+			name:     "IEXのハートビートの応答はfalseを返すこと",
+			provider: IEX,
+			msg:      map[string]interface{}{"topic": "phoenix", "event": "phx_reply", "payload": map[string]interface{}{"status": "ok"}},
+		},
+		{name: "QUODDのクォートから取り出すこと", provider: QUODD, msg: loadFixture(t, "quodd_quote.json"), want: "AAPL.NB", wantOK: true},
+		{name: "QUODDの差分のクォートから取り出すこと", provider: QUODD, msg: loadFixture(t, "quodd_quote_partial.json"), want: "AAPL.NB", wantOK: true},
+		{name: "QUODDのトレードから取り出すこと", provider: QUODD, msg: loadFixture(t, "quodd_trade.json"), want: "AAPL.NB", wantOK: true},
+		{name: "QUODDの差分のトレードから取り出すこと", provider: QUODD, msg: loadFixture(t, "quodd_trade_partial.json"), want: "AAPL.NB", wantOK: true},
+		{
+			name:     "QUODDの板情報から取り出すこと",
+			provider: QUODD,
+			msg:      map[string]interface{}{"event": "depth", "data": map[string]interface{}{"ticker": "AAPL.NB", "side": "b", "level": float64(1)}},
+			want:     "AAPL.NB",
+			wantOK:   true,
+		},
+		{name: "QUODDのinfoはfalseを返すこと", provider: QUODD, msg: loadFixture(t, "quodd_info_subscribed.json")},
+		{
+			name:     "QUODDのハートビートはfalseを返すこと",
+			provider: QUODD,
+			msg:      map[string]interface{}{"event": "heartbeat", "data": map[string]interface{}{"action": "heartbeat"}},
+		},
+		{name: "IEXのメッセージをQUODDとして読まないこと", provider: QUODD, msg: loadFixture(t, "iex_quote.json")},
+		{name: "QUODDのメッセージをIEXとして読まないこと", provider: IEX, msg: loadFixture(t, "quodd_quote.json")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := SymbolFromMessage(tt.provider, tt.msg)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("SymbolFromMessage() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package intriniorealtime
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// quoddSuffixes are the exchange suffixes of QUODD tickers this package
+// knows, by the market they name. None is a single letter, so the class,
+// unit and warrant letters of a symbol such as "BRK.B" or "PSTH.U" are never
+// taken for one.
+var quoddSuffixes = map[string]string{
+	"NB":  "Nasdaq Basic", // every US listing, as Nasdaq Basic carries it
+	"NQ":  "Nasdaq",
+	"NY":  "New York Stock Exchange",
+	"AM":  "NYSE American",
+	"AR":  "NYSE Arca",
+	"BZ":  "Cboe BZX",
+	"OTC": "OTC Markets",
+}
+
+// ToQuoddTicker returns the QUODD ticker of symbol on exchange, its suffix
+// such as "NB": "AAPL" on "NB" is "AAPL.NB". Share classes, preferreds and
+// units keep their punctuation, "BRK.B" being "BRK.B.NB" and "BAC-E"
+// "BAC-E.NB". An empty exchange is an *AmbiguousSymbolError, as QUODD has
+// no ticker without one; an unknown exchange or a symbol that isn't a ticker
+// is a *SymbolError.
+func ToQuoddTicker(symbol, exchange string) (string, error) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	exchange = strings.ToUpper(strings.TrimSpace(exchange))
+	if !isTicker(symbol) {
+		return "", &SymbolError{Symbol: symbol, Reason: "not a ticker"}
+	}
+	if exchange == "" {
+		return "", &AmbiguousSymbolError{Symbol: symbol}
+	}
+	if _, ok := quoddSuffixes[exchange]; !ok {
+		return "", &SymbolError{Symbol: symbol, Reason: fmt.Sprintf("unknown exchange %q", exchange)}
+	}
+	return symbol + "." + exchange, nil
+}
+
+// FromQuoddTicker splits a QUODD ticker into the symbol and the exchange
+// suffix, the reverse of ToQuoddTicker: "BRK.B.NB" is "BRK.B" on "NB". A
+// ticker without a known suffix, such as the bare unit "PSTH.U", is a
+// *SymbolError.
+func FromQuoddTicker(ticker string) (symbol, exchange string, err error) {
+	ticker = strings.ToUpper(strings.TrimSpace(ticker))
+	dot := strings.LastIndex(ticker, ".")
+	if dot < 0 {
+		return "", "", &SymbolError{Symbol: ticker, Reason: "no exchange suffix"}
+	}
+	symbol, exchange = ticker[:dot], ticker[dot+1:]
+	if _, ok := quoddSuffixes[exchange]; !ok {
+		return "", "", &SymbolError{Symbol: ticker, Reason: fmt.Sprintf("unknown exchange suffix %q", exchange)}
+	}
+	if !isTicker(symbol) {
+		return "", "", &SymbolError{Symbol: ticker, Reason: "not a ticker with an exchange suffix"}
+	}
+	return symbol, exchange, nil
+}
+
+// WithQuoddExchange sets the exchange JoinSymbols puts bare symbols on, a
+// suffix such as "NB": for the given symbols, or without any for all the
+// others. Naming a symbol on more than one exchange makes it ambiguous, and
+// JoinSymbols refuses it rather than picking one. The option is an error
+// for the other providers and for unknown exchanges.
+func WithQuoddExchange(exchange string, symbols ...string) Option {
+	return func(cli *Client) error {
+		if cli.provider != QUODD {
+			return fmt.Errorf("WithQuoddExchange is for the QUODD provider, not %s", cli.provider)
+		}
+		exchange = strings.ToUpper(strings.TrimSpace(exchange))
+		if _, ok := quoddSuffixes[exchange]; !ok {
+			return fmt.Errorf("unknown exchange %q", exchange)
+		}
+		if len(symbols) == 0 {
+			cli.quoddExchange = exchange
+			return nil
+		}
+		if cli.quoddExchanges == nil {
+			cli.quoddExchanges = make(map[string][]string)
+		}
+		for _, symbol := range symbols {
+			symbol = strings.ToUpper(strings.TrimSpace(symbol))
+			if !containsString(cli.quoddExchanges[symbol], exchange) {
+				cli.quoddExchanges[symbol] = append(cli.quoddExchanges[symbol], exchange)
+			}
+		}
+		return nil
+	}
+}
+
+// JoinSymbols is Join for symbols named the same for every provider. With
+// QUODD a bare symbol gets the suffix of its exchange, see
+// WithQuoddExchange, and a QUODD ticker is joined as it is; the other
+// providers take the symbol of a QUODD ticker. If any symbol fails, nothing
+// is joined and its *SymbolError or *AmbiguousSymbolError is returned.
+func (cli *Client) JoinSymbols(symbols ...string) error {
+	channels := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		channel, err := cli.providerSymbol(symbol)
+		if err != nil {
+			return err
+		}
+		channels[i] = channel
+	}
+	cli.Join(channels...)
+	return nil
+}
+
+// providerSymbol returns the channel of symbol on the client's provider.
+func (cli *Client) providerSymbol(symbol string) (string, error) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	root, _, err := FromQuoddTicker(symbol)
+	if cli.provider != QUODD {
+		if err == nil {
+			return root, nil
+		}
+		return symbol, nil
+	}
+	if err == nil {
+		return symbol, nil
+	}
+	exchanges := cli.quoddExchanges[symbol]
+	switch {
+	case 1 < len(exchanges):
+		sorted := append([]string(nil), exchanges...)
+		sort.Strings(sorted)
+		return "", &AmbiguousSymbolError{Symbol: symbol, Exchanges: sorted}
+	case len(exchanges) == 1:
+		return ToQuoddTicker(symbol, exchanges[0])
+	}
+	return ToQuoddTicker(symbol, cli.quoddExchange)
+}
+
+func containsString(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package intriniorealtime
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestToQuoddTicker(t *testing.T) {
+	tests := []struct {
+		name          string
+		symbol        string
+		exchange      string
+		want          string
+		wantAmbiguous bool
+		wantErr       bool
+	}{
+		{name: "銘柄に取引所の接尾辞を付けること", symbol: "AAPL", exchange: "NB", want: "AAPL.NB"},
+		{name: "小文字と空白を正規化すること", symbol: " msft ", exchange: "nq", want: "MSFT.NQ"},
+		{name: "クラス付きの銘柄はそのまま付けること", symbol: "BRK.B", exchange: "NY", want: "BRK.B.NY"},
+		{name: "優先株はそのまま付けること", symbol: "BAC-E", exchange: "NY", want: "BAC-E.NY"},
+		{name: "ユニットはそのまま付けること", symbol: "PSTH.U", exchange: "NY", want: "PSTH.U.NY"},
+		{name: "OTCの接尾辞を付けること", symbol: "TCEHY", exchange: "OTC", want: "TCEHY.OTC"},
+		{name: "取引所がなければ曖昧とすること", symbol: "AAPL", exchange: "", wantAmbiguous: true},
+		{name: "知らない取引所はエラーにすること", symbol: "AAPL", exchange: "XX", wantErr: true},
+		{name: "銘柄でなければエラーにすること", symbol: ".AAPL", exchange: "NB", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToQuoddTicker(tt.symbol, tt.exchange)
+			var ambiguous *AmbiguousSymbolError
+			if errors.As(err, &ambiguous) != tt.wantAmbiguous {
+				t.Errorf("ToQuoddTicker() error = %v, want ambiguous %v", err, tt.wantAmbiguous)
+			}
+			if (err != nil) != (tt.wantErr || tt.wantAmbiguous) {
+				t.Fatalf("ToQuoddTicker() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidSymbol) {
+				t.Errorf("ToQuoddTicker() error = %v, want ErrInvalidSymbol", err)
+			}
+			if got != tt.want {
+				t.Errorf("ToQuoddTicker() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromQuoddTicker(t *testing.T) {
+	tests := []struct {
+		name         string
+		ticker       string
+		wantSymbol   string
+		wantExchange string
+		wantErr      bool
+	}{
+		{name: "銘柄と取引所に分けること", ticker: "AAPL.NB", wantSymbol: "AAPL", wantExchange: "NB"},
+		{name: "最後の点で分けること", ticker: "BRK.B.NB", wantSymbol: "BRK.B", wantExchange: "NB"},
+		{name: "ユニットを分けること", ticker: "psth.u.ny", wantSymbol: "PSTH.U", wantExchange: "NY"},
+		{name: "優先株を分けること", ticker: "BAC-E.NY", wantSymbol: "BAC-E", wantExchange: "NY"},
+		{name: "接尾辞のない銘柄はエラーにすること", ticker: "AAPL", wantErr: true},
+		{name: "接尾辞のないユニットはエラーにすること", ticker: "PSTH.U", wantErr: true},
+		{name: "クラスを接尾辞と取り違えないこと", ticker: "BRK.B", wantErr: true},
+		{name: "銘柄のない接尾辞はエラーにすること", ticker: ".NB", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			symbol, exchange, err := FromQuoddTicker(tt.ticker)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FromQuoddTicker() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if symbol != tt.wantSymbol || exchange != tt.wantExchange {
+				t.Errorf("FromQuoddTicker() = %q, %q, want %q, %q", symbol, exchange, tt.wantSymbol, tt.wantExchange)
+			}
+			if err == nil {
+				// Every known suffix goes back the way it came.
+				if back, _ := ToQuoddTicker(symbol, exchange); back != symbol+"."+exchange {
+					t.Errorf("ToQuoddTicker(%q, %q) = %q", symbol, exchange, back)
+				}
+			}
+		})
+	}
+}
+
+func TestJoinSymbols(t *testing.T) {
+	tests := []struct {
+		name          string
+		provider      provider
+		opts          []Option
+		symbols       []string
+		want          []string
+		wantAmbiguous bool
+	}{
+		{
+			name:     "QUODDでは既定の取引所を付けること",
+			provider: QUODD,
+			opts:     []Option{WithQuoddExchange("NB")},
+			symbols:  []string{"AAPL", "brk.b"},
+			want:     []string{"AAPL.NB", "BRK.B.NB"},
+		},
+		{
+			name:     "QUODDでは銘柄ごとの取引所を優先すること",
+			provider: QUODD,
+			opts:     []Option{WithQuoddExchange("NB"), WithQuoddExchange("NY", "GE")},
+			symbols:  []string{"GE", "AAPL"},
+			want:     []string{"AAPL.NB", "GE.NY"},
+		},
+		{
+			name:     "QUODDの銘柄はそのまま参加すること",
+			provider: QUODD,
+			symbols:  []string{"MSFT.NQ"},
+			want:     []string{"MSFT.NQ"},
+		},
+		{
+			name:          "QUODDで取引所がなければ何も参加しないこと",
+			provider:      QUODD,
+			symbols:       []string{"MSFT.NQ", "AAPL"},
+			wantAmbiguous: true,
+		},
+		{
+			name:          "QUODDで複数の取引所にある銘柄は推測しないこと",
+			provider:      QUODD,
+			opts:          []Option{WithQuoddExchange("NB"), WithQuoddExchange("NY", "GE"), WithQuoddExchange("AR", "GE")},
+			symbols:       []string{"GE"},
+			wantAmbiguous: true,
+		},
+		{
+			name:     "IEXではQUODDの接尾辞を外すこと",
+			provider: IEX,
+			symbols:  []string{"AAPL.NB", "BRK.B", "PSTH.U.NY"},
+			want:     []string{"AAPL", "BRK.B", "PSTH.U"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := New("", "", tt.provider, tt.opts...)
+			err := sut.JoinSymbols(tt.symbols...)
+			var ambiguous *AmbiguousSymbolError
+			if errors.As(err, &ambiguous) != tt.wantAmbiguous || (err != nil) != tt.wantAmbiguous {
+				t.Fatalf("JoinSymbols() error = %v, want ambiguous %v", err, tt.wantAmbiguous)
+			}
+			if got := sut.Subscriptions(); len(got) != len(tt.want) || 0 < len(got) && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Subscriptions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	sut := New("", "", IEX, WithQuoddExchange("NB"))
+	if err := sut.Connect(); err == nil {
+		sut.Disconnect()
+		t.Error("Connect() with WithQuoddExchange on IEX error = nil")
+	}
+}
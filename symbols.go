@@ -0,0 +1,133 @@
+package intriniorealtime
+
+import "strings"
+
+// The IEX lobbies, which are the only IEX channels that aren't a ticker.
+var iexLobbies = map[string]bool{"$lobby": true, "$lobby_last_price": true}
+
+// normalizeChannel trims channel and upper-cases its ticker. The lobbies and
+// the depth and trades-only prefixes are kept in lower case.
+func normalizeChannel(channel string) string {
+	c := strings.TrimSpace(channel)
+	lower := strings.ToLower(c)
+	if iexLobbies[lower] {
+		return lower
+	}
+	for _, prefix := range []string{depthPrefix, tradesPrefix} {
+		if strings.HasPrefix(lower, prefix) {
+			return prefix + strings.ToUpper(strings.TrimSpace(c[len(prefix):]))
+		}
+	}
+	return strings.ToUpper(c)
+}
+
+// JoinChecked is Join for symbols that may be mistyped. Each channel is
+// normalized like Join does and then checked: IEX, REALTIME and DELAYED_SIP
+// take plain tickers and the two lobbies, NASDAQ_BASIC plain tickers, their
+// trades-only channels and $lobby, and QUODD tickers with an exchange suffix
+// such as "AAPL.NB" and their depth channels. OPRA takes contract symbols,
+// see BuildOptionSymbol, and the tickers of underlyings, which join their
+// whole chain. CRYPTOQUOTE takes pairs, optionally on one exchange, see
+// CryptoChannel, and FXCM currency pairs such as "EUR/USD", see FxChannel.
+// If any channel fails, nothing is joined and a *SymbolError for the first
+// one is returned, which matches ErrInvalidSymbol. WithoutSymbolValidation
+// turns the checks off.
+func (cli *Client) JoinChecked(channels ...string) error {
+	if !cli.noSymbolValidation {
+		for _, channel := range channels {
+			if err := validateChannel(cli.provider, normalizeChannel(channel)); err != nil {
+				return err
+			}
+		}
+	}
+	cli.Join(channels...)
+	return nil
+}
+
+// validateChannel checks a normalized channel for provider.
+func validateChannel(provider provider, channel string) error {
+	invalid := func(reason string) error {
+		return &SymbolError{Symbol: channel, Reason: reason}
+	}
+	switch provider {
+	case IEX, REALTIME, DELAYED_SIP, NASDAQ_BASIC:
+		if iexLobbies[channel] && (provider != NASDAQ_BASIC || channel == "$lobby") {
+			return nil
+		}
+		if strings.HasPrefix(channel, depthPrefix) {
+			return invalid("depth channels are QUODD only")
+		}
+		ticker := channel
+		if tradesOnly(channel) {
+			if provider != NASDAQ_BASIC {
+				return invalid("trades-only channels are NASDAQ_BASIC only")
+			}
+			ticker = strings.TrimPrefix(channel, tradesPrefix)
+		}
+		if !isTicker(ticker) {
+			return invalid("not a ticker")
+		}
+	case OPRA:
+		if iexLobbies[channel] {
+			return invalid("the lobbies are IEX only")
+		}
+		if _, err := ParseOptionSymbol(channel); err == nil {
+			return nil
+		}
+		if !optionUnderlying(channel) {
+			return invalid("neither an option contract nor an underlying")
+		}
+	case CRYPTOQUOTE:
+		return validateCryptoChannel(channel)
+	case FXCM:
+		return validateFxChannel(channel)
+	case QUODD:
+		if tradesOnly(channel) {
+			return invalid("trades-only channels are NASDAQ_BASIC only")
+		}
+		ticker := strings.TrimPrefix(channel, depthPrefix)
+		if iexLobbies[ticker] {
+			return invalid("the lobbies are IEX only")
+		}
+		dot := strings.LastIndex(ticker, ".")
+		if dot < 0 {
+			return invalid("QUODD tickers need an exchange suffix such as .NB")
+		}
+		root, suffix := ticker[:dot], ticker[dot+1:]
+		if !isTicker(root) || !isSuffix(suffix) {
+			return invalid("not a ticker with an exchange suffix")
+		}
+	}
+	return nil
+}
+
+// isTicker reports whether s looks like a ticker: up to ten letters, digits
+// and the punctuation share classes and preferreds use, starting with a
+// letter or digit.
+func isTicker(s string) bool {
+	if s == "" || 10 < len(s) {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		case 0 < i && strings.ContainsRune(".-+=^/", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isSuffix reports whether s looks like a QUODD exchange suffix.
+func isSuffix(s string) bool {
+	if s == "" || 4 < len(s) {
+		return false
+	}
+	for _, r := range s {
+		if r < 'A' || 'Z' < r {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,147 @@
+package intriniorealtime
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeChannel(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel string
+		want    string
+	}{
+		{name: "前後の空白を除くこと", channel: " AAPL\t", want: "AAPL"},
+		{name: "大文字にすること", channel: "aapl.nb", want: "AAPL.NB"},
+		{name: "ロビーは小文字にすること", channel: "$LOBBY", want: "$lobby"},
+		{name: "最終価格のロビーも小文字にすること", channel: " $Lobby_Last_Price ", want: "$lobby_last_price"},
+		{name: "板のチャンネルは銘柄だけ大文字にすること", channel: "$DEPTH: aapl.nb", want: "$depth:AAPL.NB"},
+		{name: "約定だけのチャンネルは銘柄だけ大文字にすること", channel: "$Trades:aapl ", want: "$trades:AAPL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeChannel(tt.channel); got != tt.want {
+				t.Errorf("normalizeChannel(%q) = %q, want %q", tt.channel, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateChannel(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider provider
+		channel  string
+		wantErr  bool
+	}{
+		{name: "IEXの銘柄を通すこと", provider: IEX, channel: "AAPL"},
+		{name: "IEXのクラス付きの銘柄を通すこと", provider: IEX, channel: "BRK.B"},
+		{name: "IEXのロビーを通すこと", provider: IEX, channel: "$lobby"},
+		{name: "IEXの最終価格のロビーを通すこと", provider: IEX, channel: "$lobby_last_price"},
+		{name: "IEXの空の銘柄を弾くこと", provider: IEX, channel: "", wantErr: true},
+		{name: "IEXの記号で始まる銘柄を弾くこと", provider: IEX, channel: ".AAPL", wantErr: true},
+		{name: "IEXの長すぎる銘柄を弾くこと", provider: IEX, channel: "ABCDEFGHIJK", wantErr: true},
+		{name: "IEXの空白を含む銘柄を弾くこと", provider: IEX, channel: "AA PL", wantErr: true},
+		{name: "IEXの知らないロビーを弾くこと", provider: IEX, channel: "$lobby_trades", wantErr: true},
+		{name: "IEXの板のチャンネルを弾くこと", provider: IEX, channel: "$depth:AAPL", wantErr: true},
+		{name: "IEXの約定だけのチャンネルを弾くこと", provider: IEX, channel: "$trades:AAPL", wantErr: true},
+		{name: "NASDAQ_BASICの銘柄を通すこと", provider: NASDAQ_BASIC, channel: "AAPL"},
+		{name: "NASDAQ_BASICの約定だけのチャンネルを通すこと", provider: NASDAQ_BASIC, channel: "$trades:BRK.B"},
+		{name: "NASDAQ_BASICのロビーを通すこと", provider: NASDAQ_BASIC, channel: "$lobby"},
+		{name: "NASDAQ_BASICの最終価格のロビーを弾くこと", provider: NASDAQ_BASIC, channel: "$lobby_last_price", wantErr: true},
+		{name: "NASDAQ_BASICの銘柄のない約定だけのチャンネルを弾くこと", provider: NASDAQ_BASIC, channel: "$trades:", wantErr: true},
+		{name: "CRYPTOQUOTEの通貨の組を通すこと", provider: CRYPTOQUOTE, channel: "BTC-USD"},
+		{name: "CRYPTOQUOTEの取引所付きの組を通すこと", provider: CRYPTOQUOTE, channel: "ETH-USDC@GEMINI_EU"},
+		{name: "CRYPTOQUOTEの区切りのない組を弾くこと", provider: CRYPTOQUOTE, channel: "BTCUSD", wantErr: true},
+		{name: "CRYPTOQUOTEの3つの通貨を弾くこと", provider: CRYPTOQUOTE, channel: "BTC-USD-EUR", wantErr: true},
+		{name: "CRYPTOQUOTEの短すぎる通貨を弾くこと", provider: CRYPTOQUOTE, channel: "B-USD", wantErr: true},
+		{name: "CRYPTOQUOTEの空の取引所を弾くこと", provider: CRYPTOQUOTE, channel: "BTC-USD@", wantErr: true},
+		{name: "CRYPTOQUOTEの空白を含む取引所を弾くこと", provider: CRYPTOQUOTE, channel: "BTC-USD@COIN BASE", wantErr: true},
+		{name: "CRYPTOQUOTEの株の銘柄を弾くこと", provider: CRYPTOQUOTE, channel: "AAPL", wantErr: true},
+		{name: "CRYPTOQUOTEのロビーを弾くこと", provider: CRYPTOQUOTE, channel: "$lobby", wantErr: true},
+		{name: "FXCMの通貨の組を通すこと", provider: FXCM, channel: "EUR/USD"},
+		{name: "FXCMの区切りのない組を弾くこと", provider: FXCM, channel: "EURUSD", wantErr: true},
+		{name: "FXCMの4文字の通貨を弾くこと", provider: FXCM, channel: "EURO/USD", wantErr: true},
+		{name: "FXCMの暗号資産の組を弾くこと", provider: FXCM, channel: "BTC-USD", wantErr: true},
+		{name: "FXCMのロビーを弾くこと", provider: FXCM, channel: "$lobby", wantErr: true},
+		{name: "QUODDの取引所付きの銘柄を通すこと", provider: QUODD, channel: "AAPL.NB"},
+		{name: "QUODDのクラス付きの銘柄を通すこと", provider: QUODD, channel: "BRK.B.NB"},
+		{name: "QUODDの板のチャンネルを通すこと", provider: QUODD, channel: "$depth:AAPL.NB"},
+		{name: "QUODDの取引所のない銘柄を弾くこと", provider: QUODD, channel: "AAPL", wantErr: true},
+		{name: "QUODDの空の取引所を弾くこと", provider: QUODD, channel: "AAPL.", wantErr: true},
+		{name: "QUODDの長すぎる取引所を弾くこと", provider: QUODD, channel: "AAPL.NASDAQ", wantErr: true},
+		{name: "QUODDの銘柄のない取引所を弾くこと", provider: QUODD, channel: ".NB", wantErr: true},
+		{name: "QUODDのロビーを弾くこと", provider: QUODD, channel: "$lobby", wantErr: true},
+		{name: "QUODDの取引所のない板のチャンネルを弾くこと", provider: QUODD, channel: "$depth:AAPL", wantErr: true},
+		{name: "QUODDの約定だけのチャンネルを弾くこと", provider: QUODD, channel: "$trades:AAPL.NB", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateChannel(tt.provider, tt.channel)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateChannel(%v, %q) error = %v, wantErr %v", tt.provider, tt.channel, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidSymbol) {
+				t.Errorf("validateChannel(%v, %q) error = %v, want ErrInvalidSymbol", tt.provider, tt.channel, err)
+			}
+		})
+	}
+}
+
+func TestClientJoinNormalizes(t *testing.T) {
+	sut := New(yourIntrinioAPIUserName, yourIntrinioAPIPassword, QUODD, WithRefCountedSubscriptions())
+	sut.Join("aapl.nb", " AAPL.NB ", "AAPL.NB")
+	if got := sut.SubscriptionRefs("Aapl.Nb"); got != 3 {
+		t.Errorf("SubscriptionRefs() = %d, want 3", got)
+	}
+	if n := len(sut.channels); n != 1 {
+		t.Errorf("channels = %v, want one channel", sut.channels)
+	}
+	sut.Leave("aapl.nb")
+	if got := sut.SubscriptionRefs("AAPL.NB"); got != 2 {
+		t.Errorf("SubscriptionRefs() after Leave = %d, want 2", got)
+	}
+}
+
+func TestClientJoinChecked(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider provider
+		opts     []Option
+		channels []string
+		want     []string
+		wantErr  string
+	}{
+		{name: "正規化して参加すること", provider: QUODD, channels: []string{"aapl.nb", "$depth:msft.nb"}, want: []string{"AAPL.NB", "$depth:MSFT.NB"}},
+		{name: "ひとつでも不正なら何も参加しないこと", provider: QUODD, channels: []string{"AAPL.NB", "msft"}, wantErr: "MSFT"},
+		{name: "IEXのロビーに参加すること", provider: IEX, channels: []string{"$LOBBY"}, want: []string{"$lobby"}},
+		{name: "検証を無効にできること", provider: QUODD, opts: []Option{WithoutSymbolValidation()}, channels: []string{"msft"}, want: []string{"MSFT"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := New(yourIntrinioAPIUserName, yourIntrinioAPIPassword, tt.provider, tt.opts...)
+			err := sut.JoinChecked(tt.channels...)
+			if tt.wantErr != "" {
+				var symbolErr *SymbolError
+				if !errors.As(err, &symbolErr) || symbolErr.Symbol != tt.wantErr {
+					t.Fatalf("JoinChecked() error = %v, want a SymbolError for %q", err, tt.wantErr)
+				}
+				if len(sut.channels) != 0 {
+					t.Errorf("channels = %v, want none", sut.channels)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("JoinChecked() error = %v", err)
+			}
+			if len(sut.channels) != len(tt.want) {
+				t.Errorf("channels = %v, want %v", sut.channels, tt.want)
+			}
+			for _, c := range tt.want {
+				if sut.channels[c] == 0 {
+					t.Errorf("channels = %v, want %q joined", sut.channels, c)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package intriniorealtime
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeUnit is the unit of a timestamp as a feed sends it, a count of which
+// since the Unix epoch makes the time.
+type TimeUnit int
+
+// The units feeds send timestamps in.
+const (
+	Seconds TimeUnit = iota
+	Milliseconds
+	Microseconds
+	Nanoseconds
+)
+
+func (u TimeUnit) String() string {
+	switch u {
+	case Seconds:
+		return "s"
+	case Milliseconds:
+		return "ms"
+	case Microseconds:
+		return "µs"
+	default:
+		return "ns"
+	}
+}
+
+// nanos returns the number of nanoseconds in one u.
+func (u TimeUnit) nanos() int64 {
+	switch u {
+	case Seconds:
+		return int64(time.Second)
+	case Milliseconds:
+		return int64(time.Millisecond)
+	case Microseconds:
+		return int64(time.Microsecond)
+	default:
+		return 1
+	}
+}
+
+// RawTime is a timestamp the way the feed sent it, for passing on untouched:
+// Value is the count of Unit since the Unix epoch. A value with a fraction,
+// like IEX's seconds, isn't a whole count; it is kept as nanoseconds
+// instead, which is every digit IEX sends.
+type RawTime struct {
+	Value int64
+	Unit  TimeUnit
+}
+
+// Time returns r as a time in UTC, the zero time for the zero RawTime.
+func (r RawTime) Time() time.Time {
+	if r.Value == 0 {
+		return time.Time{}
+	}
+	per := int64(time.Second) / r.Unit.nanos()
+	return time.Unix(r.Value/per, r.Value%per*r.Unit.nanos()).UTC()
+}
+
+// timeUnits are the units the providers document for their timestamp
+// fields. Fields that aren't listed, from feeds newer than this table, get
+// theirs from detectUnit.
+var timeUnits = map[provider]map[string]TimeUnit{
+	IEX: {
+		"timestamp": Seconds,
+	},
+	REALTIME: {
+		"timestamp": Nanoseconds,
+	},
+	DELAYED_SIP: {
+		"timestamp": Nanoseconds,
+	},
+	NASDAQ_BASIC: {
+		"timestamp": Nanoseconds,
+	},
+	OPRA: {
+		"timestamp": Seconds,
+	},
+	CRYPTOQUOTE: {
+		"timestamp": Milliseconds,
+	},
+	FXCM: {
+		"timestamp": Milliseconds,
+	},
+	QUODD: {
+		"quote_time":     Milliseconds,
+		"trade_time":     Milliseconds,
+		"ext_trade_time": Milliseconds,
+		"day_high_time":  Milliseconds,
+		"day_low_time":   Milliseconds,
+		"open_time":      Milliseconds,
+		"status_time":    Milliseconds,
+		"luld_time":      Milliseconds,
+	},
+}
+
+// timeUnit returns the unit of the timestamp field key of provider, with
+// the value v it was sent with.
+func timeUnit(provider provider, key string, v interface{}) TimeUnit {
+	if unit, ok := timeUnits[provider][key]; ok {
+		return unit
+	}
+	return detectUnit(v)
+}
+
+// detectUnit guesses the unit of a timestamp from its size, taking the
+// largest count of each unit that is before the year 5000: a count of
+// seconds that large would be milliseconds already, and so on.
+func detectUnit(v interface{}) TimeUnit {
+	f, _ := number(v)
+	if s, ok := v.(string); ok {
+		f, _ = strconv.ParseFloat(s, 64)
+	}
+	switch {
+	case f < 1e11:
+		return Seconds
+	case f < 1e14:
+		return Milliseconds
+	case f < 1e17:
+		return Microseconds
+	}
+	return Nanoseconds
+}
+
+// maxUnixSeconds keeps conversions clear of int64 overflow; it is far past
+// any time a feed will send.
+const maxUnixSeconds = 1 << 40
+
+// maxNanoSeconds is the last second whose nanoseconds still fit an int64.
+const maxNanoSeconds = math.MaxInt64/int64(time.Second) - 1
+
+// unixTime converts a timestamp in unit to UTC, along with the raw value.
+// A number decoded as float64 is rounded to microseconds, which is as far
+// as a float64 of today's seconds since the epoch carries; a json.Number or
+// a string is converted from its text to the nanosecond. Zero, absent or
+// unusable values give the zero time.
+func unixTime(v interface{}, unit TimeUnit) (time.Time, RawTime) {
+	switch v := v.(type) {
+	case float64:
+		return floatTime(v, unit)
+	case json.Number:
+		return textTime(string(v), unit)
+	case string:
+		return textTime(v, unit)
+	}
+	return time.Time{}, RawTime{}
+}
+
+func floatTime(f float64, unit TimeUnit) (time.Time, RawTime) {
+	per := float64(time.Second) / float64(unit.nanos())
+	sec := f / per
+	if sec <= 0 || maxUnixSeconds < sec || math.IsNaN(sec) {
+		return time.Time{}, RawTime{}
+	}
+	whole := math.Floor(sec)
+	micros := math.Round((sec - whole) * 1e6)
+	t := time.Unix(int64(whole), int64(micros)*int64(time.Microsecond)).UTC()
+	if f == math.Trunc(f) {
+		return t, RawTime{Value: int64(f), Unit: unit}
+	}
+	if float64(maxNanoSeconds) < sec {
+		return t, RawTime{Value: t.Unix(), Unit: Seconds}
+	}
+	return t, RawTime{Value: t.UnixNano(), Unit: Nanoseconds}
+}
+
+// textTime converts the decimal text of a timestamp exactly, rounding
+// digits beyond the nanosecond.
+func textTime(s string, unit TimeUnit) (time.Time, RawTime) {
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); 0 <= i {
+		whole, frac = s[:i], s[i+1:]
+	}
+	per := int64(time.Second) / unit.nanos()
+	w, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || w < 0 || maxUnixSeconds < w/per || !digits(frac) {
+		if f, err := strconv.ParseFloat(s, 64); err == nil && strings.ContainsAny(s, "eE") {
+			return floatTime(f, unit)
+		}
+		return time.Time{}, RawTime{}
+	}
+	frac = strings.TrimRight(frac, "0")
+	if frac == "" {
+		if w == 0 {
+			return time.Time{}, RawTime{}
+		}
+		r := RawTime{Value: w, Unit: unit}
+		return r.Time(), r
+	}
+	if maxNanoSeconds < w/per {
+		f, _ := strconv.ParseFloat(s, 64)
+		return floatTime(f, unit)
+	}
+	// The fraction in nanoseconds, rounded at the first digit past them.
+	places := len(strconv.FormatInt(unit.nanos(), 10)) - 1
+	padded := frac + strings.Repeat("0", places+1)
+	f, _ := strconv.ParseInt(padded[:places+1], 10, 64)
+	f = (f + 5) / 10
+	r := RawTime{Value: w*unit.nanos() + f, Unit: Nanoseconds}
+	return r.Time(), r
+}
+
+// rawTimeOf returns t, which was sent in unit, as a RawTime: a count of unit,
+// or of nanoseconds when t isn't a whole number of unit.
+func rawTimeOf(t time.Time, unit TimeUnit) RawTime {
+	if t.IsZero() {
+		return RawTime{}
+	}
+	if ns := int64(t.Nanosecond()); ns%unit.nanos() == 0 {
+		per := int64(time.Second) / unit.nanos()
+		return RawTime{Value: t.Unix()*per + ns/unit.nanos(), Unit: unit}
+	}
+	return RawTime{Value: t.UnixNano(), Unit: Nanoseconds}
+}
+
+func digits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || '9' < s[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// unixSeconds converts an IEX timestamp, seconds since the Unix epoch with
+// a fraction sent either as a number or a string, to UTC; see unixTime.
+func unixSeconds(v interface{}) time.Time {
+	t, _ := unixTime(v, Seconds)
+	return t
+}
+
+// unixMillis converts a QUODD time, milliseconds since the Unix epoch, to
+// UTC. Zero, absent or unusable values give the zero time.
+func unixMillis(v interface{}) time.Time {
+	if _, ok := v.(string); ok {
+		return time.Time{}
+	}
+	t, _ := unixTime(v, Milliseconds)
+	return t
+}
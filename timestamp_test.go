@@ -0,0 +1,163 @@
+package intriniorealtime
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestUnixSeconds(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  interface{}
+		want time.Time
+	}{
+		{name: "小数の秒をマイクロ秒まで変換すること", raw: 1493409509.3932788, want: time.Date(2017, 4, 28, 19, 58, 29, 393279000, time.UTC)},
+		{name: "文字列の秒を桁を落とさず変換すること", raw: "1493409509.3932788", want: time.Date(2017, 4, 28, 19, 58, 29, 393278800, time.UTC)},
+		{name: "整数の秒を変換すること", raw: float64(1493409509), want: time.Date(2017, 4, 28, 19, 58, 29, 0, time.UTC)},
+		{name: "繰り上がる端数を次の秒にすること", raw: 1493409509.9999997, want: time.Date(2017, 4, 28, 19, 58, 30, 0, time.UTC)},
+		{name: "ナノ秒でint64を超える値も変換すること", raw: float64(1e10), want: time.Date(2286, 11, 20, 17, 46, 40, 0, time.UTC)},
+		{name: "0はゼロ値にすること", raw: float64(0)},
+		{name: "ないときはゼロ値にすること", raw: nil},
+		{name: "数値でない文字列はゼロ値にすること", raw: "yesterday"},
+		{name: "負の値はゼロ値にすること", raw: float64(-1)},
+		{name: "表せない値はゼロ値にすること", raw: 1e300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unixSeconds(tt.raw)
+			if !got.Equal(tt.want) || got.Location() != time.UTC {
+				t.Errorf("unixSeconds(%v) = %v, want %v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnixMillis(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  interface{}
+		want time.Time
+	}{
+		{name: "ミリ秒を変換すること", raw: float64(1508165070852), want: time.Date(2017, 10, 16, 14, 44, 30, 852000000, time.UTC)},
+		{name: "ナノ秒でint64を超える値も変換すること", raw: float64(1e13), want: time.Date(2286, 11, 20, 17, 46, 40, 0, time.UTC)},
+		{name: "0はゼロ値にすること", raw: float64(0)},
+		{name: "ないときはゼロ値にすること", raw: nil},
+		{name: "文字列はゼロ値にすること", raw: "1508165070852"},
+		{name: "表せない値はゼロ値にすること", raw: 1e300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unixMillis(tt.raw)
+			if !got.Equal(tt.want) || got.Location() != time.UTC {
+				t.Errorf("unixMillis(%v) = %v, want %v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnixTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     interface{}
+		unit    TimeUnit
+		want    time.Time
+		wantRaw RawTime
+	}{
+		{name: "秒を変換すること", raw: json.Number("1493409509"), unit: Seconds,
+			want: time.Unix(1493409509, 0), wantRaw: RawTime{Value: 1493409509, Unit: Seconds}},
+		{name: "秒の端数をナノ秒まで残すこと", raw: json.Number("1493409509.123456789"), unit: Seconds,
+			want: time.Unix(1493409509, 123456789), wantRaw: RawTime{Value: 1493409509123456789, Unit: Nanoseconds}},
+		{name: "ナノ秒より細かい端数は丸めること", raw: json.Number("1493409509.1234567895"), unit: Seconds,
+			want: time.Unix(1493409509, 123456790), wantRaw: RawTime{Value: 1493409509123456790, Unit: Nanoseconds}},
+		{name: "ミリ秒を変換すること", raw: json.Number("1508165070852"), unit: Milliseconds,
+			want: time.Unix(1508165070, 852000000), wantRaw: RawTime{Value: 1508165070852, Unit: Milliseconds}},
+		{name: "ミリ秒の端数を残すこと", raw: json.Number("1508165070852.5"), unit: Milliseconds,
+			want: time.Unix(1508165070, 852500000), wantRaw: RawTime{Value: 1508165070852500000, Unit: Nanoseconds}},
+		{name: "マイクロ秒を変換すること", raw: json.Number("1508165070852123"), unit: Microseconds,
+			want: time.Unix(1508165070, 852123000), wantRaw: RawTime{Value: 1508165070852123, Unit: Microseconds}},
+		{name: "ナノ秒を変換すること", raw: json.Number("1760621400123456789"), unit: Nanoseconds,
+			want: time.Unix(1760621400, 123456789), wantRaw: RawTime{Value: 1760621400123456789, Unit: Nanoseconds}},
+		{name: "文字列のナノ秒を変換すること", raw: "1760621400123456789", unit: Nanoseconds,
+			want: time.Unix(1760621400, 123456789), wantRaw: RawTime{Value: 1760621400123456789, Unit: Nanoseconds}},
+		{name: "float64の秒はマイクロ秒まで変換すること", raw: 1493409509.3932788, unit: Seconds,
+			want: time.Unix(1493409509, 393279000), wantRaw: RawTime{Value: 1493409509393279000, Unit: Nanoseconds}},
+		{name: "float64のミリ秒を変換すること", raw: float64(1508165070852), unit: Milliseconds,
+			want: time.Unix(1508165070, 852000000), wantRaw: RawTime{Value: 1508165070852, Unit: Milliseconds}},
+		{name: "float64のマイクロ秒を変換すること", raw: float64(1508165070852123), unit: Microseconds,
+			want: time.Unix(1508165070, 852123000), wantRaw: RawTime{Value: 1508165070852123, Unit: Microseconds}},
+		{name: "指数表記の秒も変換すること", raw: json.Number("1.493409509e9"), unit: Seconds,
+			want: time.Unix(1493409509, 0), wantRaw: RawTime{Value: 1493409509, Unit: Seconds}},
+		{name: "0はゼロ値にすること", raw: json.Number("0"), unit: Nanoseconds},
+		{name: "負の値はゼロ値にすること", raw: json.Number("-1"), unit: Milliseconds},
+		{name: "数値でない文字列はゼロ値にすること", raw: "1.2.3", unit: Seconds},
+		{name: "表せない秒はゼロ値にすること", raw: json.Number("99999999999999999"), unit: Seconds},
+		{name: "ないときはゼロ値にすること", raw: nil, unit: Seconds},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, raw := unixTime(tt.raw, tt.unit)
+			if !got.Equal(tt.want) || got.Location() != time.UTC && !got.IsZero() {
+				t.Errorf("unixTime(%v, %v) = %v, want %v", tt.raw, tt.unit, got, tt.want)
+			}
+			if raw != tt.wantRaw {
+				t.Errorf("unixTime(%v, %v) raw = %+v, want %+v", tt.raw, tt.unit, raw, tt.wantRaw)
+			}
+			if !raw.Time().Equal(got) {
+				t.Errorf("RawTime.Time() = %v, want %v", raw.Time(), got)
+			}
+		})
+	}
+}
+
+func TestTimeUnit(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider provider
+		key      string
+		raw      interface{}
+		want     TimeUnit
+	}{
+		{name: "IEXのtimestampは秒であること", provider: IEX, key: "timestamp", raw: json.Number("1760621400123456789"), want: Seconds},
+		{name: "QUODDのquote_timeはミリ秒であること", provider: QUODD, key: "quote_time", raw: json.Number("1"), want: Milliseconds},
+		{name: "QUODDのext_trade_timeはミリ秒であること", provider: QUODD, key: "ext_trade_time", raw: nil, want: Milliseconds},
+		{name: "表にない秒は大きさで見分けること", provider: QUODD, key: "close_time", raw: json.Number("1760621400"), want: Seconds},
+		{name: "表にないミリ秒は大きさで見分けること", provider: QUODD, key: "close_time", raw: float64(1760621400123), want: Milliseconds},
+		{name: "表にないマイクロ秒は大きさで見分けること", provider: IEX, key: "sent", raw: "1760621400123456", want: Microseconds},
+		{name: "表にないナノ秒は大きさで見分けること", provider: IEX, key: "sent", raw: json.Number("1760621400123456789"), want: Nanoseconds},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := timeUnit(tt.provider, tt.key, tt.raw); got != tt.want {
+				t.Errorf("timeUnit(%v, %q, %v) = %v, want %v", tt.provider, tt.key, tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientTimestampPrecision(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	quotes := make(chan IEXQuote, 1)
+	sut := server.newClient(IEX)
+	sut.OnIEXQuote(func(quote IEXQuote) { quotes <- quote })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	server.broadcastRaw(websocket.TextMessage, []byte(`{"topic":"iex:securities:GE","event":"quote","payload":{"type":"bid","ticker":"GE","price":28.96,"size":100,"timestamp":1493409509.123456789}}`))
+	quote := receive(t, "OnIEXQuote()", quotes)
+	if want := time.Unix(1493409509, 123456789); !quote.Timestamp.Equal(want) {
+		t.Errorf("Timestamp = %v, want %v", quote.Timestamp, want)
+	}
+	if want := (RawTime{Value: 1493409509123456789, Unit: Nanoseconds}); quote.RawTimestamp != want {
+		t.Errorf("RawTimestamp = %+v, want %+v", quote.RawTimestamp, want)
+	}
+	if _, ok := quote.Raw["timestamp"].(json.Number); !ok {
+		t.Errorf("Raw timestamp = %T, want json.Number", quote.Raw["timestamp"])
+	}
+}
@@ -0,0 +1,22 @@
+package intriniorealtime
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// WithTLSConfig makes the client fetch tokens and dial the websocket with
+// the TLS settings of c, such as the RootCAs of a private CA or the
+// Certificates of mutual TLS. c is cloned, so changing it afterwards has no
+// effect, and the client never changes it either. It works together with
+// WithProxy; a client or dialer given with WithHTTPClient or WithDialer
+// keeps its own TLS settings.
+func WithTLSConfig(c *tls.Config) Option {
+	return func(cli *Client) error {
+		if c == nil {
+			return fmt.Errorf("TLS config must not be nil")
+		}
+		cli.tlsConfig = c.Clone()
+		return nil
+	}
+}
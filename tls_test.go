@@ -0,0 +1,116 @@
+package intriniorealtime
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newClientCert returns a self-signed client certificate and a pool that
+// trusts it.
+func newClientCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}, pool
+}
+
+func TestClientWithTLSConfig(t *testing.T) {
+	cert, clientCAs := newClientCert(t)
+	tests := []struct {
+		name       string
+		clientCAs  *x509.CertPool
+		clientCert bool
+		roots      bool
+		wantErr    bool
+	}{
+		{name: "私設CAを信頼しなければ接続できないこと", wantErr: true},
+		{name: "私設CAを信頼すれば接続できること", roots: true},
+		{name: "クライアント証明書がなければ相互TLSで接続できないこと", clientCAs: clientCAs, roots: true, wantErr: true},
+		{name: "クライアント証明書があれば相互TLSで接続できること", clientCAs: clientCAs, roots: true, clientCert: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeTLSServer(tt.clientCAs)
+			defer server.Close()
+			var opts []Option
+			if tt.roots || tt.clientCert {
+				config := &tls.Config{}
+				if tt.roots {
+					config.RootCAs = x509.NewCertPool()
+					config.RootCAs.AddCert(server.srv.Certificate())
+				}
+				if tt.clientCert {
+					config.Certificates = []tls.Certificate{cert}
+				}
+				opts = append(opts, WithTLSConfig(config))
+			}
+			sut := server.newClient(IEX, opts...)
+			sut.authRetry.MaxAttempts = 1
+			err := sut.Connect()
+			defer sut.Disconnect()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("connect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !waitUntil(5*time.Second, func() bool { return len(server.connections()) == 1 }) {
+				t.Error("the server never saw the connection")
+			}
+		})
+	}
+}
+
+func TestClientTLSConfigUnchanged(t *testing.T) {
+	server := newFakeTLSServer(nil)
+	defer server.Close()
+	proxy := newRecordingProxy(false)
+	defer proxy.srv.Close()
+
+	config := &tls.Config{RootCAs: x509.NewCertPool()}
+	config.RootCAs.AddCert(server.srv.Certificate())
+	sut := server.newClient(IEX, WithTLSConfig(config), WithProxy(http.ProxyURL(proxy.url(t))))
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+	if config.NextProtos != nil || config.ServerName != "" || config.Certificates != nil {
+		t.Errorf("config = %+v, changed by the client", config)
+	}
+	host := strings.TrimPrefix(server.srv.URL, "https://")
+	got := proxy.seen()
+	if len(got) != 2 || got[0] != "CONNECT "+host || got[1] != "CONNECT "+host {
+		t.Errorf("proxy saw %v, want tunnels for the token request and the websocket", got)
+	}
+
+	if err := New("", "", IEX, WithTLSConfig(nil)).Connect(); err == nil {
+		t.Error("Connect() with a nil TLS config error = nil")
+	}
+}
@@ -0,0 +1,220 @@
+package intriniorealtime
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	iexTokenTTL   = time.Hour
+	quoddTokenTTL = time.Hour
+	// realtimeTokenTTL is as long as the IEX token, which REALTIME's
+	// endpoint shares its token scheme with.
+	realtimeTokenTTL = time.Hour
+	// manualTokenTTL stands for the TTL of a MANUAL client's dialect until
+	// resolveManual knows it.
+	manualTokenTTL = -1
+
+	tokenRefreshMargin = 5 * time.Minute
+)
+
+// defaultAuthRetry is how transient auth failures are retried.
+var defaultAuthRetry = ReconnectPolicy{
+	InitialDelay: 200 * time.Millisecond,
+	Multiplier:   2,
+	MaxDelay:     2 * time.Second,
+	Jitter:       true,
+	MaxAttempts:  4,
+}
+
+func defaultTokenTTL(provider provider) time.Duration {
+	switch provider {
+	case IEX:
+		return iexTokenTTL
+	case QUODD:
+		return quoddTokenTTL
+	case REALTIME, DELAYED_SIP, NASDAQ_BASIC, OPRA, CRYPTOQUOTE, FXCM:
+		return realtimeTokenTTL
+	case MANUAL:
+		return manualTokenTTL
+	default:
+		return 0
+	}
+}
+
+// ForceTokenRefresh discards the cached token and fetches a new one. The new
+// token is used from the next (re)connect on.
+func (cli *Client) ForceTokenRefresh() error {
+	if cli.staticToken {
+		return ErrStaticToken
+	}
+	cli.invalidateToken()
+	return cli.refreshToken(context.Background())
+}
+
+// OnTokenRefresh registers a callback invoked after the token has been
+// refreshed in the background ahead of its expiry.
+func (cli *Client) OnTokenRefresh(f func()) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
+	cli.tokenRefreshHandler = f
+}
+
+func (cli *Client) onTokenRefresh() {
+	cli.info("token refreshed")
+	cli.handlerMu.RLock()
+	f := cli.tokenRefreshHandler
+	cli.handlerMu.RUnlock()
+	if f != nil {
+		cli.callHandler("OnTokenRefresh", f)
+	}
+}
+
+// startTokenRefresher starts refreshTokenBeforeExpiry unless it is running or
+// there is nothing to refresh.
+func (cli *Client) startTokenRefresher() {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	if cli.tokenRefresher == cli.done || cli.staticToken || cli.transport != nil || cli.tokenTTL <= 0 || cli.tokenMargin <= 0 {
+		return
+	}
+	cli.tokenRefresher = cli.done
+	go cli.refreshTokenBeforeExpiry(cli.done)
+}
+
+// refreshTokenBeforeExpiry fetches a new token tokenMargin before the cached
+// one is assumed to expire, so the next reconnect doesn't have to wait for
+// the auth endpoint. A failed refresh is retried with a backoff from a
+// quarter of the margin up to the margin, also once the token has expired.
+// Only an invalidated token is left to the next dial. It runs until done is
+// closed.
+func (cli *Client) refreshTokenBeforeExpiry(done <-chan struct{}) {
+	defer func() {
+		cli.mu.Lock()
+		// A Connect after Disconnect may have started the next one already.
+		if cli.tokenRefresher == done {
+			cli.tokenRefresher = nil
+		}
+		cli.mu.Unlock()
+	}()
+	var wait time.Duration
+	failures := 0
+	for {
+		cli.mu.Lock()
+		margin := cli.tokenMargin
+		if cli.tokenTTL <= margin {
+			// A margin as long as the TTL would refresh without pause.
+			margin = cli.tokenTTL / 2
+		}
+		switch {
+		case 0 < failures:
+			backoff := ReconnectPolicy{InitialDelay: margin / 4, Multiplier: 2, MaxDelay: margin}
+			wait = backoff.delay(failures - 1)
+		case cli.token == "":
+			// Invalidated; the next dial fetches one. Check back later.
+			wait = margin / 4
+		default:
+			// Right away when the token is stale already.
+			wait = cli.tokenAt.Add(cli.tokenTTL - margin).Sub(cli.now())
+		}
+		cli.mu.Unlock()
+		if wait < 0 {
+			wait = 0
+		}
+		select {
+		case <-cli.after(wait):
+		case <-done:
+			return
+		}
+		if cli.tokenInvalidated() {
+			failures = 0
+			continue
+		}
+		if err := cli.refreshToken(context.Background()); err != nil {
+			if err == ErrClientClosed {
+				return
+			}
+			cli.onError(err)
+			failures++
+			continue
+		}
+		failures = 0
+		cli.onTokenRefresh()
+	}
+}
+
+func (cli *Client) invalidateToken() {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	if cli.staticToken {
+		return
+	}
+	cli.token = ""
+	cli.tokenAt = time.Time{}
+}
+
+// tokenInvalidated reports whether the token was discarded, by
+// invalidateToken or because none was fetched yet.
+func (cli *Client) tokenInvalidated() bool {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	return cli.token == ""
+}
+
+func (cli *Client) tokenFresh() bool {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	return cli.staticToken || cli.token != "" && cli.now().Sub(cli.tokenAt) < cli.tokenTTL
+}
+
+// dial opens the websocket, reusing the cached token while it is fresh. If
+// the server rejects a cached token it is refreshed once and dialed again.
+// A token given with WithToken is never refreshed, and with WithConn there
+// is no token at all.
+func (cli *Client) dial(ctx context.Context) error {
+	if cli.transport != nil {
+		return cli.refreshWebsocket(ctx)
+	}
+	if cli.staticToken {
+		err := cli.refreshWebsocket(ctx)
+		if isBadHandshake(err) {
+			return ErrStaticTokenRejected
+		}
+		return err
+	}
+	cached := cli.tokenFresh()
+	if !cached {
+		if err := cli.refreshToken(ctx); err != nil {
+			return err
+		}
+	}
+	err := cli.refreshWebsocket(ctx)
+	if isBadHandshake(err) && cached {
+		cli.info("cached token rejected, refreshing")
+		if err := cli.refreshToken(ctx); err != nil {
+			return err
+		}
+		return cli.refreshWebsocket(ctx)
+	}
+	return err
+}
+
+// parseRetryAfter reads a Retry-After header in either its delay-seconds or
+// HTTP-date form. It returns zero when the header is missing or malformed.
+func parseRetryAfter(h string, now time.Time) time.Duration {
+	if h == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(h); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(h); err == nil && now.Before(at) {
+		return at.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,537 @@
+package intriniorealtime
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClientTokenCache(t *testing.T) {
+	tests := []struct {
+		name          string
+		ttl           time.Duration
+		wantAuthCalls func(reconnects int) bool
+	}{
+		{
+			name:          "TTL内の再接続では認証APIを1回しか呼ばないこと",
+			ttl:           time.Hour,
+			wantAuthCalls: func(reconnects int) bool { return reconnects == 1 },
+		},
+		{
+			name:          "TTLを0にすると再接続のたびに認証APIを呼ぶこと",
+			ttl:           0,
+			wantAuthCalls: func(calls int) bool { return 3 < calls },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+
+			reconnected := make(chan struct{}, 10)
+			sut := server.newClient(QUODD, WithTokenTTL(tt.ttl), WithStaleTimeout(100*time.Millisecond))
+			sut.OnReconnect(func(error) { reconnected <- struct{}{} })
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+			defer sut.Disconnect()
+			for i := 0; i < 3; i++ {
+				select {
+				case <-reconnected:
+				case <-time.After(5 * time.Second):
+					t.Fatal("client did not reconnect")
+				}
+			}
+			if got := server.authCount(); !tt.wantAuthCalls(got) {
+				t.Errorf("auth calls = %d", got)
+			}
+		})
+	}
+}
+
+func TestClientTokenRejectedOnDial(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	sut := server.newClient(IEX)
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	sut.Disconnect()
+
+	server.revoke("token-1")
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() with a revoked cached token error = %v", err)
+	}
+	defer sut.Disconnect()
+	if got := server.authCount(); got != 2 {
+		t.Errorf("auth calls = %d, want 2", got)
+	}
+}
+
+func TestClientForceTokenRefresh(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	sut := server.newClient(IEX)
+	if err := sut.ForceTokenRefresh(); err != nil {
+		t.Fatalf("ForceTokenRefresh() error = %v", err)
+	}
+	if err := sut.ForceTokenRefresh(); err != nil {
+		t.Fatalf("ForceTokenRefresh() error = %v", err)
+	}
+	if got := server.authCount(); got != 2 {
+		t.Errorf("auth calls = %d, want 2", got)
+	}
+	if !sut.tokenFresh() {
+		t.Error("token is not fresh after ForceTokenRefresh()")
+	}
+}
+
+// recordingTransport passes requests on to http.DefaultTransport and keeps
+// their URLs.
+type recordingTransport struct {
+	mu   sync.Mutex
+	urls []string
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.mu.Lock()
+	rt.urls = append(rt.urls, req.URL.Path)
+	rt.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestClientWithHTTPClient(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	rt := &recordingTransport{}
+	sut := server.newClient(IEX, WithHTTPClient(&http.Client{Transport: rt}))
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+	if err := sut.ForceTokenRefresh(); err != nil {
+		t.Fatalf("ForceTokenRefresh() error = %v", err)
+	}
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if !reflect.DeepEqual(rt.urls, []string{"/auth", "/auth"}) {
+		t.Errorf("requests through the transport = %v, want both token requests", rt.urls)
+	}
+	if got := server.authCount(); got != 2 {
+		t.Errorf("auth calls = %d, want 2", got)
+	}
+
+	if err := New("", "", IEX, WithHTTPClient(nil)).Connect(); err == nil {
+		t.Error("Connect() with a nil HTTP client error = nil")
+	}
+}
+
+func TestClientWithToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		revoke  bool
+		wantErr error
+	}{
+		{name: "トークンを指定すると認証APIを呼ばずに接続できること", token: "given", revoke: false, wantErr: nil},
+		{name: "指定したトークンが拒否されたらErrStaticTokenRejectedになること", token: "given", revoke: true, wantErr: ErrStaticTokenRejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+			if tt.revoke {
+				server.revoke(tt.token)
+			}
+
+			sut := server.newClient(IEX, WithToken(tt.token))
+			err := sut.Connect()
+			defer sut.Disconnect()
+			if err != tt.wantErr {
+				t.Errorf("connect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := server.authCount(); got != 0 {
+				t.Errorf("auth calls = %d, want 0", got)
+			}
+			if err := sut.ForceTokenRefresh(); err != ErrStaticToken {
+				t.Errorf("ForceTokenRefresh() error = %v, want %v", err, ErrStaticToken)
+			}
+		})
+	}
+}
+
+func TestClientAuthRetry(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		failures      int
+		closed        bool
+		wantErr       *AuthError
+		wantAuthCalls int
+	}{
+		{
+			name:          "503が2回続いた後に成功すればConnectできること",
+			status:        http.StatusServiceUnavailable,
+			failures:      2,
+			wantAuthCalls: 3,
+		},
+		{
+			name:          "5xxが続くときは最後のステータスと試行回数を返すこと",
+			status:        http.StatusBadGateway,
+			failures:      100,
+			wantErr:       &AuthError{StatusCode: http.StatusBadGateway, Attempts: fastAuthRetry.MaxAttempts},
+			wantAuthCalls: fastAuthRetry.MaxAttempts,
+		},
+		{
+			name:          "4xxのときはリトライせずにすぐ返すこと",
+			status:        http.StatusUnauthorized,
+			failures:      100,
+			wantErr:       &AuthError{StatusCode: http.StatusUnauthorized, Attempts: 1},
+			wantAuthCalls: 1,
+		},
+		{
+			name:    "ネットワークエラーのときもリトライすること",
+			closed:  true,
+			wantErr: &AuthError{StatusCode: 0, Attempts: fastAuthRetry.MaxAttempts},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+			server.failAuth(tt.status, tt.failures)
+
+			sut := server.newClient(QUODD)
+			if tt.closed {
+				sut.authURL = "http://127.0.0.1:1/auth"
+			}
+			err := sut.Connect()
+			defer sut.Disconnect()
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("connect() error = %v", err)
+				}
+			} else {
+				ae, ok := err.(*AuthError)
+				if !ok {
+					t.Fatalf("connect() error = %v, want *AuthError", err)
+				}
+				if ae.StatusCode != tt.wantErr.StatusCode || ae.Attempts != tt.wantErr.Attempts {
+					t.Errorf("AuthError = %+v, want %+v", ae, tt.wantErr)
+				}
+			}
+			if got := server.authCount(); got != tt.wantAuthCalls {
+				t.Errorf("auth calls = %d, want %d", got, tt.wantAuthCalls)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2018, 6, 1, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+	}{
+		{name: "秒数の形式を解釈できること", header: "120", want: 2 * time.Minute},
+		{name: "HTTP日付の形式を解釈できること", header: now.Add(90 * time.Second).Format(http.TimeFormat), want: 90 * time.Second},
+		{name: "過去の日付のときは0になること", header: now.Add(-time.Minute).Format(http.TimeFormat), want: 0},
+		{name: "負の秒数のときは0になること", header: "-5", want: 0},
+		{name: "ヘッダーがないときは0になること", header: "", want: 0},
+		{name: "不正な値のときは0になること", header: "soon", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.header, now); got != tt.want {
+				t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientRateLimited(t *testing.T) {
+	tests := []struct {
+		name           string
+		retryAfter     string
+		failures       int
+		opts           []Option
+		timeout        time.Duration
+		wantErr        error
+		wantRetryAfter time.Duration
+		wantMinElapsed time.Duration
+	}{
+		{
+			name:           "Retry-Afterの秒数だけ待ってからリトライすること",
+			retryAfter:     "1",
+			failures:       1,
+			wantMinElapsed: time.Second,
+		},
+		{
+			name:       "Retry-AfterがHTTP日付でもリトライすること",
+			retryAfter: time.Now().Add(time.Second).UTC().Format(http.TimeFormat),
+			failures:   1,
+		},
+		{
+			name:           "リトライ回数を超えたらRateLimitedErrorを返すこと",
+			retryAfter:     "0",
+			failures:       100,
+			opts:           []Option{WithRateLimitRetries(2)},
+			wantErr:        &RateLimitedError{Attempts: 3},
+			wantRetryAfter: 0,
+		},
+		{
+			name:       "待っている間にコンテキストがキャンセルされたら中断すること",
+			retryAfter: "60",
+			failures:   100,
+			timeout:    100 * time.Millisecond,
+			wantErr:    context.DeadlineExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+			server.failAuth(http.StatusTooManyRequests, tt.failures)
+			server.setRetryAfter(tt.retryAfter)
+
+			ctx := context.Background()
+			if 0 < tt.timeout {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			sut := server.newClient(QUODD, tt.opts...)
+			start := time.Now()
+			err := sut.ConnectContext(ctx)
+			defer sut.Disconnect()
+			elapsed := time.Since(start)
+
+			switch want := tt.wantErr.(type) {
+			case nil:
+				if err != nil {
+					t.Fatalf("ConnectContext() error = %v", err)
+				}
+			case *RateLimitedError:
+				rl, ok := err.(*RateLimitedError)
+				if !ok {
+					t.Fatalf("ConnectContext() error = %v, want *RateLimitedError", err)
+				}
+				if rl.Attempts != want.Attempts || rl.RetryAfter != tt.wantRetryAfter {
+					t.Errorf("RateLimitedError = %+v, want attempts %d, retry after %v", rl, want.Attempts, tt.wantRetryAfter)
+				}
+			default:
+				if err != want {
+					t.Fatalf("ConnectContext() error = %v, want %v", err, want)
+				}
+				if 5*time.Second < elapsed {
+					t.Errorf("ConnectContext() took %v after the context was done", elapsed)
+				}
+			}
+			if elapsed < tt.wantMinElapsed {
+				t.Errorf("ConnectContext() returned after %v, want at least %v", elapsed, tt.wantMinElapsed)
+			}
+		})
+	}
+}
+
+func TestClientRefreshTokenBeforeExpiry(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		wantWait time.Duration
+		disabled bool
+	}{
+		{name: "有効期限のマージン前にトークンを更新すること", opts: []Option{WithTokenTTL(time.Hour), WithTokenRefreshMargin(10 * time.Minute)}, wantWait: 50 * time.Minute},
+		{name: "マージンを指定しなければ5分前に更新すること", opts: []Option{WithTokenTTL(time.Hour)}, wantWait: 55 * time.Minute},
+		{name: "マージンがTTL以上のときはTTLの半分で更新すること", opts: []Option{WithTokenTTL(4 * time.Minute)}, wantWait: 2 * time.Minute},
+		{name: "マージンを0にすると更新しないこと", opts: []Option{WithTokenRefreshMargin(0)}, disabled: true},
+		{name: "WithTokenのときは更新しないこと", opts: []Option{WithToken("token-static")}, disabled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeServer()
+			defer server.Close()
+
+			waits := make(chan time.Duration, 1)
+			fire := make(chan time.Time)
+			refreshed := make(chan struct{}, 1)
+			sut := server.newClient(QUODD, tt.opts...)
+			sut.after = func(d time.Duration) <-chan time.Time {
+				waits <- d
+				return fire
+			}
+			sut.OnTokenRefresh(func() { refreshed <- struct{}{} })
+			if err := sut.Connect(); err != nil {
+				t.Fatalf("connect() error = %v", err)
+			}
+
+			if tt.disabled {
+				select {
+				case d := <-waits:
+					t.Fatalf("refresh scheduled in %v", d)
+				case <-time.After(100 * time.Millisecond):
+				}
+				sut.Disconnect()
+				return
+			}
+			for i := 0; i < 2; i++ {
+				select {
+				case d := <-waits:
+					if d < tt.wantWait-time.Second || tt.wantWait < d {
+						t.Errorf("refresh scheduled in %v, want %v", d, tt.wantWait)
+					}
+				case <-time.After(time.Second):
+					t.Fatal("refresh was not scheduled")
+				}
+				calls := server.authCount()
+				fire <- time.Now()
+				select {
+				case <-refreshed:
+				case <-time.After(time.Second):
+					t.Fatal("OnTokenRefresh() was not called")
+				}
+				if got := server.authCount(); got != calls+1 {
+					t.Errorf("auth calls = %d, want %d", got, calls+1)
+				}
+			}
+
+			<-waits
+			sut.Disconnect()
+			if !waitUntil(time.Second, func() bool {
+				sut.mu.Lock()
+				defer sut.mu.Unlock()
+				return sut.tokenRefresher == nil
+			}) {
+				t.Error("refresh goroutine is still running after Disconnect()")
+			}
+		})
+	}
+}
+
+// refreshClock drives refreshTokenBeforeExpiry of a client of server: every
+// wait is sent on waits and ends when fire is sent on.
+type refreshClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	waits chan time.Duration
+	fire  chan time.Time
+}
+
+func newRefreshClient(server *fakeServer) (*Client, *refreshClock) {
+	c := &refreshClock{now: time.Date(2024, 1, 2, 9, 30, 0, 0, time.UTC), waits: make(chan time.Duration, 1), fire: make(chan time.Time)}
+	sut := server.newClient(QUODD, WithTokenTTL(time.Hour), WithTokenRefreshMargin(10*time.Minute))
+	sut.authRetry.MaxAttempts = 1
+	sut.now = func() time.Time {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.now
+	}
+	sut.after = func(d time.Duration) <-chan time.Time {
+		c.waits <- d
+		return c.fire
+	}
+	return sut, c
+}
+
+// next ends the current wait after d has passed and returns the next one.
+func (c *refreshClock) next(t *testing.T, d time.Duration) time.Duration {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+	c.mu.Unlock()
+	c.fire <- now
+	return receive(t, "after()", c.waits)
+}
+
+func TestClientRefreshTokenInvalidated(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+	sut, clock := newRefreshClient(server)
+	sut.OnTokenRefresh(func() { t.Error("OnTokenRefresh() was called") })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	if d := receive(t, "after()", clock.waits); d != 50*time.Minute {
+		t.Errorf("refresh scheduled in %v, want %v", d, 50*time.Minute)
+	}
+	calls := server.authCount()
+	sut.invalidateToken()
+	if d := clock.next(t, 50*time.Minute); d != 10*time.Minute/4 {
+		t.Errorf("next check in %v, want %v", d, 10*time.Minute/4)
+	}
+	if got := server.authCount(); got != calls {
+		t.Errorf("auth calls = %d, want %d", got, calls)
+	}
+}
+
+func TestClientRefreshTokenRecovers(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+	sut, clock := newRefreshClient(server)
+	refreshed := make(chan struct{}, 1)
+	sut.OnTokenRefresh(func() { refreshed <- struct{}{} })
+	sut.OnError(func(error) {})
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+	receive(t, "after()", clock.waits)
+
+	steps := []struct {
+		name     string
+		pass     time.Duration
+		status   int
+		wantWait time.Duration
+	}{
+		{name: "更新に失敗したら間隔を空けてやり直すこと", pass: 50 * time.Minute, status: http.StatusServiceUnavailable, wantWait: 10 * time.Minute / 4},
+		{name: "期限が切れてもやり直し続けること", pass: 15 * time.Minute, status: http.StatusServiceUnavailable, wantWait: 10 * time.Minute / 2},
+		{name: "取得できたら次の更新を予定すること", pass: 5 * time.Minute, wantWait: 50 * time.Minute},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			server.setAuthStatus(step.status)
+			calls := server.authCount()
+			if d := clock.next(t, step.pass); d != step.wantWait {
+				t.Errorf("next refresh in %v, want %v", d, step.wantWait)
+			}
+			if got := server.authCount(); got != calls+1 {
+				t.Errorf("auth calls = %d, want %d", got, calls+1)
+			}
+			if step.status == 0 {
+				receive(t, "OnTokenRefresh()", refreshed)
+			}
+		})
+	}
+}
+
+func TestClientRefreshTokenAfterReconnect(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	waits := make(chan time.Duration, 4)
+	sut := server.newClient(QUODD)
+	sut.after = func(d time.Duration) <-chan time.Time {
+		waits <- d
+		return make(chan time.Time)
+	}
+	for i := 0; i < 2; i++ {
+		if err := sut.Connect(); err != nil {
+			t.Fatalf("connect() error = %v", err)
+		}
+		select {
+		case <-waits:
+		case <-time.After(time.Second):
+			t.Fatalf("connect %d: refresh was not scheduled", i+1)
+		}
+		sut.Disconnect()
+	}
+}
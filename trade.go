@@ -0,0 +1,96 @@
+package intriniorealtime
+
+import "time"
+
+// Trade is an execution from any provider.
+type Trade struct {
+	Symbol    string
+	Price     float64
+	Size      int64
+	Timestamp time.Time
+	Extended  bool // traded outside regular market hours
+
+	// Darkpool reports a trade printed off exchange, at a dark pool or
+	// another venue that reports to a FINRA facility: QUODD trades whose
+	// exchange is one (D), and for the SIP feeds and the binary feed also
+	// those printed by the SIP (E) or without a market center. The IEX JSON
+	// feed only has IEX's own trades, which never are.
+	Darkpool bool
+
+	// Delayed is set for DELAYED_SIP trades, which are 15 minutes old.
+	Delayed bool
+
+	// RawTimestamp is Timestamp as the feed sent it: IEX seconds as
+	// nanoseconds, QUODD milliseconds, and REALTIME and binary nanoseconds.
+	RawTimestamp RawTime
+
+	// Venue is where the trade happened, zero for a QUODD trade that
+	// didn't say.
+	Venue Exchange
+
+	// Conditions are the sale conditions, IEX only.
+	Conditions TradeConditions
+
+	// FixedPrice is Price, exactly, with WithFixedPointPrices.
+	FixedPrice Price
+
+	// Quodd is the whole trade message for QUODD trades, nil for IEX.
+	Quodd *QuoddTradeData
+}
+
+// OnTrade registers a handler for trades only. IEX last price quotes, also
+// those from the lobbies, and QUODD trade messages that carry a last price
+// are trades. They still go to OnQuote and the provider's typed handlers as
+// well.
+func (cli *Client) OnTrade(f func(trade Trade)) {
+	cli.handlerMu.Lock()
+	defer cli.handlerMu.Unlock()
+	cli.tradeHandler = f
+}
+
+// parseTrade returns the trade carried by msg, if it is one.
+func parseTrade(provider provider, msg map[string]interface{}) (Trade, bool) {
+	switch provider {
+	case IEX:
+		quote, ok := parseIEXQuote(provider, msg)
+		if !ok || quote.Type != "last" {
+			return Trade{}, false
+		}
+		return Trade{Symbol: quote.Ticker, Price: quote.Price, Size: quote.Size, Timestamp: quote.Timestamp, RawTimestamp: quote.RawTimestamp,
+			FixedPrice: quote.FixedPrice, Conditions: quote.Conditions, Extended: quote.Conditions.IsExtendedHours(), Venue: quote.Venue, Darkpool: quote.Venue.OffExchange()}, true
+	case REALTIME, DELAYED_SIP, NASDAQ_BASIC:
+		return parseRealtimeTrade(provider, msg)
+	case QUODD:
+		data, ok := parseQuoddTrade(provider, msg)
+		if !ok {
+			return Trade{}, false
+		}
+		// The other trade messages only update the day's statistics.
+		trade := Trade{Symbol: data.Ticker, Quodd: &data}
+		price, fixed, size, at, venue := data.LastPrice, data.LastPriceFixed, data.TradeVolume, data.TradeTime, data.TradeVenue
+		if price == nil {
+			price, fixed, size, at, venue = data.ExtLastPrice, data.ExtLastPriceFixed, data.ExtTradeVolume, data.ExtTradeTime, data.ExtTradeVenue
+			trade.Extended = true
+		}
+		if price == nil {
+			return Trade{}, false
+		}
+		trade.Price = *price
+		if fixed != nil {
+			trade.FixedPrice = *fixed
+		}
+		if size != nil {
+			trade.Size = *size
+		}
+		if at != nil {
+			trade.Timestamp = *at
+			trade.RawTimestamp = rawTimeOf(*at, Milliseconds)
+		}
+		if venue != nil {
+			trade.Venue = *venue
+			trade.Darkpool = venue.OffExchange()
+		}
+		return trade, true
+	}
+	return Trade{}, false
+}
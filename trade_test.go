@@ -0,0 +1,145 @@
+package intriniorealtime
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTrade(t *testing.T) {
+	tests := []struct {
+		name      string
+		provider  provider
+		msg       map[string]interface{}
+		want      Trade
+		wantOK    bool
+		wantQuodd bool
+	}{
+		{
+			name:     "IEXのlastをトレードにすること",
+			provider: IEX,
+			msg: map[string]interface{}{"topic": "iex:securities:GE", "event": "quote", "payload": map[string]interface{}{
+				"type": "last", "timestamp": float64(1493409509), "ticker": "GE", "size": float64(100), "price": 28.97,
+			}},
+			want:   Trade{Symbol: "GE", Price: 28.97, Size: 100, Timestamp: time.Unix(1493409509, 0)},
+			wantOK: true,
+		},
+		{
+			name:     "IEXの約定ロビーのメッセージをトレードにすること",
+			provider: IEX,
+			msg: map[string]interface{}{"topic": "iex:lobby_last_price", "event": "quote", "payload": map[string]interface{}{
+				"type": "last", "timestamp": float64(1493409509), "ticker": "MSFT", "size": float64(50), "price": 68.1,
+			}},
+			want:   Trade{Symbol: "MSFT", Price: 68.1, Size: 50, Timestamp: time.Unix(1493409509, 0)},
+			wantOK: true,
+		},
+		{
+			name:     "IEXの時間外の端株の約定を読むこと",
+			provider: IEX,
+			msg: map[string]interface{}{"topic": "iex:securities:GE", "event": "quote", "payload": map[string]interface{}{
+				"type": "last", "timestamp": float64(1493409509), "ticker": "GE", "size": float64(5), "price": 28.9, "conditions": float64(0x60),
+			}},
+			want:   Trade{Symbol: "GE", Price: 28.9, Size: 5, Timestamp: time.Unix(1493409509, 0), Extended: true, Conditions: ConditionExtendedHours | ConditionOddLot},
+			wantOK: true,
+		},
+		{
+			name:     "IEXのbidはトレードにしないこと",
+			provider: IEX,
+			msg:      map[string]interface{}{"topic": "iex:lobby", "event": "quote", "payload": map[string]interface{}{"type": "bid", "ticker": "GE", "price": 28.96}},
+		},
+		{
+			name:      "QUODDのトレードをトレードにすること",
+			provider:  QUODD,
+			msg:       map[string]interface{}{"event": "trade", "data": map[string]interface{}{"ticker": "AAPL.NB", "last_price_4d": float64(1594850), "trade_volume": float64(100), "trade_time": float64(1508165070052)}},
+			want:      Trade{Symbol: "AAPL.NB", Price: 159.485, Size: 100, Timestamp: time.Unix(0, 1508165070052*int64(time.Millisecond))},
+			wantOK:    true,
+			wantQuodd: true,
+		},
+		{
+			name:      "QUODDの時間外のトレードをトレードにすること",
+			provider:  QUODD,
+			msg:       map[string]interface{}{"event": "trade_data", "data": map[string]interface{}{"ticker": "AAPL.NB", "ext_last_price_4d": float64(1579000), "ext_trade_volume": float64(100), "ext_trade_time": float64(1508160600567)}},
+			want:      Trade{Symbol: "AAPL.NB", Price: 157.9, Size: 100, Timestamp: time.Unix(0, 1508160600567*int64(time.Millisecond)), Extended: true},
+			wantOK:    true,
+			wantQuodd: true,
+		},
+		{
+			name:      "FINRAに報告されたQUODDのトレードをダークプールにすること",
+			provider:  QUODD,
+			msg:       map[string]interface{}{"event": "trade", "data": map[string]interface{}{"ticker": "AAPL.NB", "last_price_4d": float64(1594850), "trade_volume": float64(2500), "trade_exchange": "d"}},
+			want:      Trade{Symbol: "AAPL.NB", Price: 159.485, Size: 2500, Darkpool: true},
+			wantOK:    true,
+			wantQuodd: true,
+		},
+		{
+			name:      "取引所のQUODDのトレードはダークプールにしないこと",
+			provider:  QUODD,
+			msg:       map[string]interface{}{"event": "trade", "data": map[string]interface{}{"ticker": "AAPL.NB", "last_price_4d": float64(1594850), "trade_volume": float64(100), "trade_exchange": "t"}},
+			want:      Trade{Symbol: "AAPL.NB", Price: 159.485, Size: 100},
+			wantOK:    true,
+			wantQuodd: true,
+		},
+		{
+			name:     "価格のないQUODDのトレードはトレードにしないこと",
+			provider: QUODD,
+			msg:      map[string]interface{}{"event": "trade", "data": map[string]interface{}{"ticker": "AAPL.NB", "total_volume": float64(10209883)}},
+		},
+		{
+			name:     "QUODDのクォートはトレードにしないこと",
+			provider: QUODD,
+			msg:      map[string]interface{}{"event": "quote", "data": map[string]interface{}{"ticker": "AAPL.NB", "bid_price_4d": float64(1594800)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseTrade(tt.provider, tt.msg)
+			if (got.Quodd != nil) != tt.wantQuodd {
+				t.Errorf("parseTrade() Quodd = %+v", got.Quodd)
+			}
+			got.Quodd = nil
+			if ok != tt.wantOK || got.Symbol != tt.want.Symbol || got.Price != tt.want.Price || got.Size != tt.want.Size ||
+				!got.Timestamp.Equal(tt.want.Timestamp) || got.Extended != tt.want.Extended || got.Conditions != tt.want.Conditions ||
+				got.Darkpool != tt.want.Darkpool {
+				t.Errorf("parseTrade() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestClientOnTrade(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	trades := make(chan Trade, 10)
+	quotes := make(chan map[string]interface{}, 10)
+	sut := server.newClient(QUODD)
+	sut.OnTrade(func(trade Trade) { trades <- trade })
+	sut.OnQuote(func(quote map[string]interface{}) { quotes <- quote })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+
+	server.broadcast(loadFixture(t, "quodd_quote.json"))
+	server.broadcast(loadFixture(t, "quodd_trade.json"))
+	select {
+	case trade := <-trades:
+		if trade.Symbol != "AAPL.NB" || trade.Price != 159.485 || trade.Size != 100 || trade.Quodd == nil {
+			t.Errorf("OnTrade() trade = %+v", trade)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnTrade() was not called")
+	}
+	for _, event := range []string{"quote", "trade"} {
+		select {
+		case quote := <-quotes:
+			if quote["event"] != event {
+				t.Errorf("OnQuote() event = %v, want %v", quote["event"], event)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("OnQuote() was not called for %s", event)
+		}
+	}
+	if len(trades) != 0 {
+		t.Errorf("OnTrade() was called %d more times", len(trades))
+	}
+}
@@ -0,0 +1,57 @@
+package intriniorealtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gorilla/websocket"
+)
+
+// DialFunc opens a websocket to the feed in place of the client, see
+// WithConn.
+type DialFunc func(ctx context.Context) (*websocket.Conn, error)
+
+// WithConn makes the client use conn, a websocket the caller has already
+// opened, for example over a tunnel of its own or with websocket.NewClient
+// on any net.Conn. Connect then neither fetches a token nor dials, and goes
+// straight to serving conn. Every later connection, on reconnect or on a
+// Connect after Disconnect, comes from redial; without one the client gives
+// up with ErrNoRedial once conn is lost. A nil conn makes even the first
+// connection come from redial.
+func WithConn(conn *websocket.Conn, redial DialFunc) Option {
+	return func(cli *Client) error {
+		if conn == nil && redial == nil {
+			return fmt.Errorf("WithConn needs a connection or a redial function")
+		}
+		cli.transport = &transport{conn: conn, redial: redial}
+		return nil
+	}
+}
+
+// transport is the connection of WithConn and the function replacing it.
+type transport struct {
+	conn   *websocket.Conn // until the first connect takes it
+	redial DialFunc
+}
+
+// transportConn returns the connection given with WithConn the first time
+// and a redialed one after that.
+func (cli *Client) transportConn(ctx context.Context) (*websocket.Conn, error) {
+	cli.mu.Lock()
+	t := cli.transport
+	c := t.conn
+	t.conn = nil
+	cli.mu.Unlock()
+	if c != nil {
+		return c, nil
+	}
+	if t.redial == nil {
+		return nil, ErrNoRedial
+	}
+	c, err := t.redial(ctx)
+	if err == nil && c == nil {
+		err = errors.New("redial returned no connection")
+	}
+	return c, err
+}
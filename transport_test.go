@@ -0,0 +1,163 @@
+package intriniorealtime
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// pipeListener hands the server ends of net.Pipe connections to http.Serve.
+type pipeListener struct {
+	conns chan net.Conn
+	once  sync.Once
+	done  chan struct{}
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, errors.New("listener closed")
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return pipeAddr{}
+}
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "pipe" }
+
+// servePipes serves s's websocket over net.Pipe instead of TCP. The returned
+// dial opens a websocket on a fresh pipe and counts the dials.
+func servePipes(t *testing.T, s *fakeServer) (dial DialFunc, dials func() int) {
+	l := &pipeListener{conns: make(chan net.Conn), done: make(chan struct{})}
+	go http.Serve(l, s.srv.Config.Handler)
+	t.Cleanup(func() { l.Close() })
+	var mu sync.Mutex
+	n := 0
+	dial = func(ctx context.Context) (*websocket.Conn, error) {
+		client, server := net.Pipe()
+		select {
+		case l.conns <- server:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		u, _ := url.Parse("ws://pipe/socket?vsn=1.0.0&token=pipe")
+		c, _, err := websocket.NewClient(client, u, nil, 1024, 1024)
+		if err != nil {
+			client.Close()
+			return nil, err
+		}
+		mu.Lock()
+		n++
+		mu.Unlock()
+		return c, nil
+	}
+	dials = func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return n
+	}
+	return dial, dials
+}
+
+func TestClientWithConn(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+	server.setReply(quoteOnSubscribe)
+	dial, dials := servePipes(t, server)
+	conn, err := dial(context.Background())
+	if err != nil {
+		t.Fatalf("dial() error = %v", err)
+	}
+
+	quotes := make(chan map[string]interface{}, 10)
+	reconnected := make(chan error, 1)
+	sut := New("", "", QUODD, WithConn(conn, dial), WithReconnectPolicy(fastReconnect))
+	sut.OnQuote(func(quote map[string]interface{}) { quotes <- quote })
+	sut.OnReconnect(func(cause error) { reconnected <- cause })
+	sut.Join("AAPL.NB")
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+	receive(t, "OnQuote()", quotes)
+	if n := dials(); n != 1 {
+		t.Errorf("dialed %d times, want only the connection given", n)
+	}
+
+	server.kick(websocket.CloseGoingAway, "restart")
+	receive(t, "OnReconnect()", reconnected)
+	receive(t, "OnQuote()", quotes)
+	if n := dials(); n != 2 {
+		t.Errorf("dialed %d times, want one redial", n)
+	}
+	if n := server.authCount(); n != 0 {
+		t.Errorf("auth endpoint called %d times, want none", n)
+	}
+	server.mu.Lock()
+	dialed := append([]string(nil), server.dialTokens...)
+	server.mu.Unlock()
+	if len(dialed) != 2 || dialed[0] != "pipe" || dialed[1] != "pipe" {
+		t.Errorf("dialed with tokens %v, want the pipe's only", dialed)
+	}
+}
+
+func TestClientWithConnNoRedial(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+	dial, _ := servePipes(t, server)
+	conn, err := dial(context.Background())
+	if err != nil {
+		t.Fatalf("dial() error = %v", err)
+	}
+
+	failed := make(chan error, 1)
+	sut := New("", "", QUODD, WithConn(conn, nil), WithReconnectPolicy(fastReconnect))
+	sut.OnReconnectFailed(func(err error) { failed <- err })
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	defer sut.Disconnect()
+	if !waitUntil(5*time.Second, func() bool { return len(server.connections()) == 1 }) {
+		t.Fatal("the server never saw the connection")
+	}
+	server.kick(websocket.CloseGoingAway, "restart")
+	if err := receive(t, "OnReconnectFailed()", failed); err != ErrNoRedial {
+		t.Errorf("OnReconnectFailed() = %v, want ErrNoRedial", err)
+	}
+
+	if err := New("", "", QUODD, WithConn(nil, nil)).Connect(); err == nil {
+		t.Error("Connect() with WithConn(nil, nil) error = nil")
+	}
+}
+
+func TestClientWithConnTimeout(t *testing.T) {
+	block := func(ctx context.Context) (*websocket.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	sut := New("", "", QUODD, WithConn(nil, block))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := sut.ConnectContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		sut.Disconnect()
+		t.Errorf("ConnectContext() error = %v, want the redial's", err)
+	}
+}
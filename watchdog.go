@@ -0,0 +1,52 @@
+package intriniorealtime
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// LastMessageAt returns when the last frame was read from the server. It is
+// the zero time if the client has never connected.
+func (cli *Client) LastMessageAt() time.Time {
+	n := atomic.LoadInt64(&cli.lastMessageAt)
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// touch notes that a frame arrived, on the clock the watchdog reads.
+func (cli *Client) touch() {
+	atomic.StoreInt64(&cli.lastMessageAt, cli.now().UnixNano())
+}
+
+// watchdog reconnects ws once no frame has arrived for staleTimeout. It
+// leaves the connection alone while the client is idle.
+func (cli *Client) watchdog(ws *websocket.Conn) {
+	if cli.staleTimeout <= 0 {
+		return
+	}
+	cli.mu.Lock()
+	breakSender := cli.breakSender
+	cli.mu.Unlock()
+
+	tick, stop := cli.newTicker(cli.staleTimeout / 4)
+	defer stop()
+	for {
+		select {
+		case <-tick:
+			if cli.Idle() {
+				continue
+			}
+			if cli.staleTimeout < cli.now().Sub(cli.LastMessageAt()) {
+				cli.warn("stale connection", "timeout", cli.staleTimeout)
+				cli.connectionLost(ws, ErrStaleConnection)
+				return
+			}
+		case <-breakSender:
+			return
+		}
+	}
+}
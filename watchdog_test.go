@@ -0,0 +1,65 @@
+package intriniorealtime
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClientWatchdog(t *testing.T) {
+	server := newFakeServer()
+	defer server.Close()
+
+	reconnected := make(chan error, 1)
+	sut := server.newClient(QUODD, WithStaleTimeout(200*time.Millisecond))
+	sut.OnReconnect(func(cause error) {
+		select {
+		case reconnected <- cause:
+		default:
+		}
+	})
+	if !sut.LastMessageAt().IsZero() {
+		t.Errorf("LastMessageAt() = %v before Connect", sut.LastMessageAt())
+	}
+	if err := sut.Connect(); err != nil {
+		t.Fatalf("connect() error = %v", err)
+	}
+	if sut.LastMessageAt().IsZero() {
+		t.Error("LastMessageAt() is zero after Connect")
+	}
+
+	select {
+	case cause := <-reconnected:
+		if cause != ErrStaleConnection {
+			t.Errorf("OnReconnect() cause = %v, want %v", cause, ErrStaleConnection)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watchdog did not reconnect a silent connection")
+	}
+
+	sut.Disconnect()
+	calls := server.authCount()
+	time.Sleep(500 * time.Millisecond)
+	if got := server.authCount(); got != calls {
+		t.Errorf("watchdog kept reconnecting after Disconnect: auth calls %d -> %d", calls, got)
+	}
+}
+
+func TestWithStaleTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		d       time.Duration
+		wantErr bool
+	}{
+		{name: "正の値を指定できること", d: time.Minute, wantErr: false},
+		{name: "0を指定するとウォッチドッグが無効になること", d: 0, wantErr: false},
+		{name: "負の値を指定するとエラーになること", d: -time.Second, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sut := New(yourIntrinioAPIUserName, yourIntrinioAPIPassword, IEX, WithStaleTimeout(tt.d))
+			if (sut.optionErr != nil) != tt.wantErr {
+				t.Errorf("WithStaleTimeout() error = %v, wantErr %v", sut.optionErr, tt.wantErr)
+			}
+		})
+	}
+}